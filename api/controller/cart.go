@@ -108,13 +108,33 @@ func CheckCartItemBill(c *gin.Context) {
 		return i
 	})
 
+	// 选择了收货地址时计算运费
+	userAddressId, err := strconv.ParseInt(c.DefaultQuery("user_address_id", "0"), 10, 64)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+
+	// 用户选择的优惠券, 不传时自动选择减免金额最多的优惠券, 传 -1 表示不使用优惠券
+	couponId, err := strconv.ParseInt(c.DefaultQuery("coupon_id", "0"), 10, 64)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+
 	cartAppSvc := appservice.NewCartAppSvc(c)
-	replyData, err := cartAppSvc.CheckCartItemBillV2(itemIds, c.GetInt64("user_id"))
+	replyData, err := cartAppSvc.CheckCartItemBillV2(itemIds, c.GetInt64("user_id"), userAddressId, couponId)
 	if err != nil {
 		if errors.Is(err, errcode.ErrCartItemParam) {
 			app.NewResponse(c).Error(errcode.ErrCartItemParam)
 		} else if errors.Is(err, errcode.ErrCartWrongUser) {
 			app.NewResponse(c).Error(errcode.ErrCartWrongUser)
+		} else if errors.Is(err, errcode.ErrParams) {
+			app.NewResponse(c).Error(errcode.ErrParams)
+		} else if errors.Is(err, errcode.ErrFreightUndeliverable) {
+			app.NewResponse(c).Error(errcode.ErrFreightUndeliverable)
+		} else if errors.Is(err, errcode.ErrCouponUnavailable) {
+			app.NewResponse(c).Error(errcode.ErrCouponUnavailable)
 		} else {
 			app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
 		}
@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/hd2yao/go-mall/api/request"
+	"github.com/hd2yao/go-mall/common/app"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/logic/appservice"
+)
+
+// ClaimableCoupons 当前可以领取的优惠券列表
+func ClaimableCoupons(c *gin.Context) {
+	pagination := app.NewPagination(c)
+	couponAppSvc := appservice.NewCouponAppSvc(c)
+	replyTemplates, err := couponAppSvc.GetClaimableCoupons(pagination)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+	app.NewResponse(c).SetPagination(pagination).Success(replyTemplates)
+}
+
+// ClaimCoupon 用户领取优惠券
+func ClaimCoupon(c *gin.Context) {
+	templateId, err := strconv.ParseInt(c.Param("coupon_id"), 10, 64)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	couponAppSvc := appservice.NewCouponAppSvc(c)
+	replyCoupon, err := couponAppSvc.ClaimCoupon(templateId, c.GetInt64("user_id"))
+	if err != nil {
+		replyCouponError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(replyCoupon)
+}
+
+// UserCoupons 用户的优惠券列表, 可以按状态筛选
+func UserCoupons(c *gin.Context) {
+	status, err := strconv.Atoi(c.DefaultQuery("status", "0"))
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	pagination := app.NewPagination(c)
+	couponAppSvc := appservice.NewCouponAppSvc(c)
+	replyCoupons, err := couponAppSvc.GetUserCoupons(c.GetInt64("user_id"), status, pagination)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+	app.NewResponse(c).SetPagination(pagination).Success(replyCoupons)
+}
+
+// AdminCouponTemplates 管理后台优惠券模板列表
+func AdminCouponTemplates(c *gin.Context) {
+	pagination := app.NewPagination(c)
+	couponAppSvc := appservice.NewCouponAppSvc(c)
+	replyTemplates, err := couponAppSvc.GetCouponTemplateList(pagination)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+	app.NewResponse(c).SetPagination(pagination).Success(replyTemplates)
+}
+
+// AdminCouponTemplateCreate 管理后台创建优惠券模板
+func AdminCouponTemplateCreate(c *gin.Context) {
+	requestData := new(request.CouponTemplateCreate)
+	if err := c.ShouldBindJSON(requestData); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	couponAppSvc := appservice.NewCouponAppSvc(c)
+	replyTemplate, err := couponAppSvc.CreateCouponTemplate(requestData)
+	if err != nil {
+		replyCouponError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(replyTemplate)
+}
+
+// AdminCouponTemplateStop 管理后台停止发放优惠券
+func AdminCouponTemplateStop(c *gin.Context) {
+	templateId, err := strconv.ParseInt(c.Param("template_id"), 10, 64)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	couponAppSvc := appservice.NewCouponAppSvc(c)
+	if err = couponAppSvc.StopCouponTemplate(templateId); err != nil {
+		replyCouponError(c, err)
+		return
+	}
+	app.NewResponse(c).SuccessOk()
+}
+
+func replyCouponError(c *gin.Context, err error) {
+	if errors.Is(err, errcode.ErrParams) {
+		app.NewResponse(c).Error(errcode.ErrParams)
+	} else if errors.Is(err, errcode.ErrCouponParams) {
+		app.NewResponse(c).Error(errcode.ErrCouponParams)
+	} else if errors.Is(err, errcode.ErrCouponNotExists) {
+		app.NewResponse(c).Error(errcode.ErrCouponNotExists)
+	} else if errors.Is(err, errcode.ErrCouponClaimFailed) {
+		app.NewResponse(c).Error(errcode.ErrCouponClaimFailed)
+	} else {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+	}
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/hd2yao/go-mall/api/request"
+	"github.com/hd2yao/go-mall/common/app"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/logic/appservice"
+)
+
+// OngoingDiscountCampaigns 正在进行的满减活动列表
+func OngoingDiscountCampaigns(c *gin.Context) {
+	discountAppSvc := appservice.NewDiscountAppSvc(c)
+	replyCampaigns, err := discountAppSvc.GetOngoingCampaigns()
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+	app.NewResponse(c).Success(replyCampaigns)
+}
+
+// AdminDiscountCampaigns 管理后台满减活动列表
+func AdminDiscountCampaigns(c *gin.Context) {
+	pagination := app.NewPagination(c)
+	discountAppSvc := appservice.NewDiscountAppSvc(c)
+	replyCampaigns, err := discountAppSvc.GetDiscountCampaignList(pagination)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+	app.NewResponse(c).SetPagination(pagination).Success(replyCampaigns)
+}
+
+// AdminDiscountCampaignCreate 管理后台创建满减活动
+func AdminDiscountCampaignCreate(c *gin.Context) {
+	requestData := new(request.DiscountCampaignCreate)
+	if err := c.ShouldBindJSON(requestData); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	discountAppSvc := appservice.NewDiscountAppSvc(c)
+	replyCampaign, err := discountAppSvc.CreateDiscountCampaign(requestData)
+	if err != nil {
+		replyDiscountCampaignError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(replyCampaign)
+}
+
+// AdminDiscountCampaignStop 管理后台停止满减活动
+func AdminDiscountCampaignStop(c *gin.Context) {
+	campaignId, err := strconv.ParseInt(c.Param("campaign_id"), 10, 64)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	discountAppSvc := appservice.NewDiscountAppSvc(c)
+	if err = discountAppSvc.StopDiscountCampaign(campaignId); err != nil {
+		replyDiscountCampaignError(c, err)
+		return
+	}
+	app.NewResponse(c).SuccessOk()
+}
+
+func replyDiscountCampaignError(c *gin.Context, err error) {
+	if errors.Is(err, errcode.ErrParams) {
+		app.NewResponse(c).Error(errcode.ErrParams)
+	} else if errors.Is(err, errcode.ErrDiscountParams) {
+		app.NewResponse(c).Error(errcode.ErrDiscountParams)
+	} else if errors.Is(err, errcode.ErrDiscountNotExists) {
+		app.NewResponse(c).Error(errcode.ErrDiscountNotExists)
+	} else {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+	}
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/hd2yao/go-mall/api/request"
+	"github.com/hd2yao/go-mall/common/app"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/logic/appservice"
+)
+
+// FlashSaleSessions 正在抢购和即将开始的秒杀场次
+func FlashSaleSessions(c *gin.Context) {
+	flashSaleAppSvc := appservice.NewFlashSaleAppSvc(c)
+	replySessions, err := flashSaleAppSvc.GetActiveFlashSaleSessions()
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+	app.NewResponse(c).Success(replySessions)
+}
+
+// FlashSalePurchase 抢购秒杀商品
+func FlashSalePurchase(c *gin.Context) {
+	sessionId, err := strconv.ParseInt(c.Param("session_id"), 10, 64)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	requestData := new(request.FlashSalePurchase)
+	if err = c.ShouldBindJSON(requestData); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	flashSaleAppSvc := appservice.NewFlashSaleAppSvc(c)
+	replyResult, err := flashSaleAppSvc.Purchase(c.GetInt64("user_id"), sessionId, requestData)
+	if err != nil {
+		replyFlashSaleError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(replyResult)
+}
+
+// FlashSaleResult 查询秒杀请求的处理结果
+func FlashSaleResult(c *gin.Context) {
+	flashSaleAppSvc := appservice.NewFlashSaleAppSvc(c)
+	replyResult, err := flashSaleAppSvc.GetPurchaseResult(c.GetInt64("user_id"), c.Param("request_no"))
+	if err != nil {
+		replyFlashSaleError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(replyResult)
+}
+
+// AdminFlashSaleSessions 管理后台秒杀场次列表
+func AdminFlashSaleSessions(c *gin.Context) {
+	pagination := app.NewPagination(c)
+	flashSaleAppSvc := appservice.NewFlashSaleAppSvc(c)
+	replySessions, err := flashSaleAppSvc.GetFlashSaleSessionList(pagination)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+	app.NewResponse(c).SetPagination(pagination).Success(replySessions)
+}
+
+// AdminFlashSaleSessionCreate 管理后台创建秒杀场次
+func AdminFlashSaleSessionCreate(c *gin.Context) {
+	requestData := new(request.FlashSaleSessionCreate)
+	if err := c.ShouldBindJSON(requestData); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	flashSaleAppSvc := appservice.NewFlashSaleAppSvc(c)
+	replySession, err := flashSaleAppSvc.CreateFlashSaleSession(requestData)
+	if err != nil {
+		replyFlashSaleError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(replySession)
+}
+
+// AdminFlashSaleSessionStop 管理后台提前结束秒杀场次
+func AdminFlashSaleSessionStop(c *gin.Context) {
+	sessionId, err := strconv.ParseInt(c.Param("session_id"), 10, 64)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	flashSaleAppSvc := appservice.NewFlashSaleAppSvc(c)
+	if err = flashSaleAppSvc.StopFlashSaleSession(sessionId); err != nil {
+		replyFlashSaleError(c, err)
+		return
+	}
+	app.NewResponse(c).SuccessOk()
+}
+
+func replyFlashSaleError(c *gin.Context, err error) {
+	if errors.Is(err, errcode.ErrParams) {
+		app.NewResponse(c).Error(errcode.ErrParams)
+	} else if errors.Is(err, errcode.ErrCommodityNotExists) {
+		app.NewResponse(c).Error(errcode.ErrCommodityNotExists)
+	} else if errors.Is(err, errcode.ErrCommodityStockOut) {
+		app.NewResponse(c).Error(errcode.ErrCommodityStockOut)
+	} else if errors.Is(err, errcode.ErrFlashSaleParams) {
+		app.NewResponse(c).Error(errcode.ErrFlashSaleParams)
+	} else if errors.Is(err, errcode.ErrFlashSaleNotExists) {
+		app.NewResponse(c).Error(errcode.ErrFlashSaleNotExists)
+	} else if errors.Is(err, errcode.ErrFlashSaleNotOngoing) {
+		app.NewResponse(c).Error(errcode.ErrFlashSaleNotOngoing)
+	} else if errors.Is(err, errcode.ErrFlashSaleStockOut) {
+		app.NewResponse(c).Error(errcode.ErrFlashSaleStockOut)
+	} else if errors.Is(err, errcode.ErrFlashSaleLimitExceeded) {
+		app.NewResponse(c).Error(errcode.ErrFlashSaleLimitExceeded)
+	} else if errors.Is(err, errcode.ErrFlashSaleRequestNotExists) {
+		app.NewResponse(c).Error(errcode.ErrFlashSaleRequestNotExists)
+	} else {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+	}
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/hd2yao/go-mall/api/request"
+	"github.com/hd2yao/go-mall/common/app"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/logic/appservice"
+)
+
+// AdminFreightTemplates 管理后台运费模板列表
+func AdminFreightTemplates(c *gin.Context) {
+	pagination := app.NewPagination(c)
+	freightAppSvc := appservice.NewFreightAppSvc(c)
+	replyTemplates, err := freightAppSvc.GetFreightTemplateList(pagination)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+	app.NewResponse(c).SetPagination(pagination).Success(replyTemplates)
+}
+
+// AdminFreightTemplateInfo 管理后台运费模板详情
+func AdminFreightTemplateInfo(c *gin.Context) {
+	templateId, err := strconv.ParseInt(c.Param("template_id"), 10, 64)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	freightAppSvc := appservice.NewFreightAppSvc(c)
+	replyTemplate, err := freightAppSvc.GetFreightTemplate(templateId)
+	if err != nil {
+		replyFreightTemplateError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(replyTemplate)
+}
+
+// AdminFreightTemplateCreate 管理后台创建运费模板
+func AdminFreightTemplateCreate(c *gin.Context) {
+	requestData := new(request.FreightTemplateSave)
+	if err := c.ShouldBindJSON(requestData); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	freightAppSvc := appservice.NewFreightAppSvc(c)
+	replyTemplate, err := freightAppSvc.CreateFreightTemplate(requestData)
+	if err != nil {
+		replyFreightTemplateError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(replyTemplate)
+}
+
+// AdminFreightTemplateUpdate 管理后台更新运费模板, 模板的计费规则整体替换
+func AdminFreightTemplateUpdate(c *gin.Context) {
+	templateId, err := strconv.ParseInt(c.Param("template_id"), 10, 64)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	requestData := new(request.FreightTemplateSave)
+	if err = c.ShouldBindJSON(requestData); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	freightAppSvc := appservice.NewFreightAppSvc(c)
+	replyTemplate, err := freightAppSvc.UpdateFreightTemplate(templateId, requestData)
+	if err != nil {
+		replyFreightTemplateError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(replyTemplate)
+}
+
+// AdminFreightTemplateDelete 管理后台删除运费模板
+func AdminFreightTemplateDelete(c *gin.Context) {
+	templateId, err := strconv.ParseInt(c.Param("template_id"), 10, 64)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	freightAppSvc := appservice.NewFreightAppSvc(c)
+	if err = freightAppSvc.DeleteFreightTemplate(templateId); err != nil {
+		replyFreightTemplateError(c, err)
+		return
+	}
+	app.NewResponse(c).SuccessOk()
+}
+
+func replyFreightTemplateError(c *gin.Context, err error) {
+	if errors.Is(err, errcode.ErrFreightTemplateParams) {
+		app.NewResponse(c).Error(errcode.ErrFreightTemplateParams)
+	} else if errors.Is(err, errcode.ErrFreightTemplateNotExists) {
+		app.NewResponse(c).Error(errcode.ErrFreightTemplateNotExists)
+	} else {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+	}
+}
@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/hd2yao/go-mall/api/request"
+	"github.com/hd2yao/go-mall/common/app"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/logic/appservice"
+)
+
+// GroupBuyActivities 正在进行的拼团活动
+func GroupBuyActivities(c *gin.Context) {
+	groupBuyAppSvc := appservice.NewGroupBuyAppSvc(c)
+	replyActivities, err := groupBuyAppSvc.GetOngoingGroupBuyActivities()
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+	app.NewResponse(c).Success(replyActivities)
+}
+
+// GroupBuyGroupInfo 用分享码查看拼团的进度
+func GroupBuyGroupInfo(c *gin.Context) {
+	groupBuyAppSvc := appservice.NewGroupBuyAppSvc(c)
+	replyGroup, err := groupBuyAppSvc.GetGroup(c.Param("share_code"))
+	if err != nil {
+		replyGroupBuyError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(replyGroup)
+}
+
+// GroupBuyOpen 开团
+func GroupBuyOpen(c *gin.Context) {
+	activityId, err := strconv.ParseInt(c.Param("activity_id"), 10, 64)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	requestData := new(request.GroupBuyOpen)
+	if err = c.ShouldBindJSON(requestData); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	groupBuyAppSvc := appservice.NewGroupBuyAppSvc(c)
+	replyOrder, err := groupBuyAppSvc.OpenGroup(c.GetInt64("user_id"), activityId, requestData)
+	if err != nil {
+		replyGroupBuyError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(replyOrder)
+}
+
+// GroupBuyJoin 用分享码参团
+func GroupBuyJoin(c *gin.Context) {
+	requestData := new(request.GroupBuyJoin)
+	if err := c.ShouldBindJSON(requestData); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	groupBuyAppSvc := appservice.NewGroupBuyAppSvc(c)
+	replyOrder, err := groupBuyAppSvc.JoinGroup(c.GetInt64("user_id"), requestData)
+	if err != nil {
+		replyGroupBuyError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(replyOrder)
+}
+
+// AdminGroupBuyActivities 管理后台拼团活动列表
+func AdminGroupBuyActivities(c *gin.Context) {
+	pagination := app.NewPagination(c)
+	groupBuyAppSvc := appservice.NewGroupBuyAppSvc(c)
+	replyActivities, err := groupBuyAppSvc.GetGroupBuyActivityList(pagination)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+	app.NewResponse(c).SetPagination(pagination).Success(replyActivities)
+}
+
+// AdminGroupBuyActivityCreate 管理后台创建拼团活动
+func AdminGroupBuyActivityCreate(c *gin.Context) {
+	requestData := new(request.GroupBuyActivityCreate)
+	if err := c.ShouldBindJSON(requestData); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	groupBuyAppSvc := appservice.NewGroupBuyAppSvc(c)
+	replyActivity, err := groupBuyAppSvc.CreateGroupBuyActivity(requestData)
+	if err != nil {
+		replyGroupBuyError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(replyActivity)
+}
+
+// AdminGroupBuyActivityStop 管理后台停止拼团活动
+func AdminGroupBuyActivityStop(c *gin.Context) {
+	activityId, err := strconv.ParseInt(c.Param("activity_id"), 10, 64)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	groupBuyAppSvc := appservice.NewGroupBuyAppSvc(c)
+	if err = groupBuyAppSvc.StopGroupBuyActivity(activityId); err != nil {
+		replyGroupBuyError(c, err)
+		return
+	}
+	app.NewResponse(c).SuccessOk()
+}
+
+func replyGroupBuyError(c *gin.Context, err error) {
+	if errors.Is(err, errcode.ErrParams) {
+		app.NewResponse(c).Error(errcode.ErrParams)
+	} else if errors.Is(err, errcode.ErrCartItemParam) {
+		app.NewResponse(c).Error(errcode.ErrCartItemParam)
+	} else if errors.Is(err, errcode.ErrCommodityNotExists) {
+		app.NewResponse(c).Error(errcode.ErrCommodityNotExists)
+	} else if errors.Is(err, errcode.ErrCommodityStockOut) {
+		app.NewResponse(c).Error(errcode.ErrCommodityStockOut)
+	} else if errors.Is(err, errcode.ErrFreightUndeliverable) {
+		app.NewResponse(c).Error(errcode.ErrFreightUndeliverable)
+	} else if errors.Is(err, errcode.ErrGroupBuyParams) {
+		app.NewResponse(c).Error(errcode.ErrGroupBuyParams)
+	} else if errors.Is(err, errcode.ErrGroupBuyActivityNotExists) {
+		app.NewResponse(c).Error(errcode.ErrGroupBuyActivityNotExists)
+	} else if errors.Is(err, errcode.ErrGroupBuyActivityNotOngoing) {
+		app.NewResponse(c).Error(errcode.ErrGroupBuyActivityNotOngoing)
+	} else if errors.Is(err, errcode.ErrGroupBuyNotExists) {
+		app.NewResponse(c).Error(errcode.ErrGroupBuyNotExists)
+	} else if errors.Is(err, errcode.ErrGroupBuyNotJoinable) {
+		app.NewResponse(c).Error(errcode.ErrGroupBuyNotJoinable)
+	} else if errors.Is(err, errcode.ErrGroupBuyAlreadyJoined) {
+		app.NewResponse(c).Error(errcode.ErrGroupBuyAlreadyJoined)
+	} else {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+	}
+}
@@ -1,13 +1,18 @@
 package controller
 
 import (
+	"encoding/json"
 	"errors"
+	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/hd2yao/go-mall/api/reply"
 	"github.com/hd2yao/go-mall/api/request"
 	"github.com/hd2yao/go-mall/common/app"
 	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/logger"
 	"github.com/hd2yao/go-mall/logic/appservice"
 )
 
@@ -26,6 +31,78 @@ func OrderCreate(c *gin.Context) {
 			app.NewResponse(c).Error(errcode.ErrCartItemParam)
 		} else if errors.Is(err, errcode.ErrCartWrongUser) {
 			app.NewResponse(c).Error(errcode.ErrCartWrongUser)
+		} else if errors.Is(err, errcode.ErrOrderCheckoutInvalid) {
+			app.NewResponse(c).Error(errcode.ErrOrderCheckoutInvalid)
+		} else if errors.Is(err, errcode.ErrOrderBillChanged) {
+			app.NewResponse(c).Error(errcode.ErrOrderBillChanged)
+		} else if errors.Is(err, errcode.ErrFreightUndeliverable) {
+			app.NewResponse(c).Error(errcode.ErrFreightUndeliverable)
+		} else if errors.Is(err, errcode.ErrCouponUnavailable) {
+			app.NewResponse(c).Error(errcode.ErrCouponUnavailable)
+		} else if errors.Is(err, errcode.ErrDiscountLimitExceeded) {
+			app.NewResponse(c).Error(errcode.ErrDiscountLimitExceeded)
+		} else if errors.Is(err, errcode.ErrCommodityStockOut) {
+			app.NewResponse(c).Error(errcode.ErrCommodityStockOut.WithCause(err))
+		} else {
+			app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		}
+		return
+	}
+
+	app.NewResponse(c).Success(reply)
+}
+
+// OrderBuyNowBill 立即购买前查看账单 -- 确认下单前用来显示商品和支付金额明细, 返回立即购买时需要的结算凭证
+func OrderBuyNowBill(c *gin.Context) {
+	requestData := new(request.OrderBuyNowBill)
+	if err := c.ShouldBindJSON(requestData); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+
+	cartAppSvc := appservice.NewCartAppSvc(c)
+	reply, err := cartAppSvc.CheckBuyNowBill(requestData, c.GetInt64("user_id"))
+	if err != nil {
+		if errors.Is(err, errcode.ErrCartItemParam) {
+			app.NewResponse(c).Error(errcode.ErrCartItemParam)
+		} else if errors.Is(err, errcode.ErrParams) {
+			app.NewResponse(c).Error(errcode.ErrParams)
+		} else if errors.Is(err, errcode.ErrFreightUndeliverable) {
+			app.NewResponse(c).Error(errcode.ErrFreightUndeliverable)
+		} else if errors.Is(err, errcode.ErrCouponUnavailable) {
+			app.NewResponse(c).Error(errcode.ErrCouponUnavailable)
+		} else {
+			app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		}
+		return
+	}
+
+	app.NewResponse(c).Success(reply)
+}
+
+// OrderBuyNow 立即购买
+func OrderBuyNow(c *gin.Context) {
+	requestData := new(request.OrderBuyNow)
+	if err := c.ShouldBindJSON(requestData); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+
+	orderAppSvc := appservice.NewOrderAppSvc(c)
+	reply, err := orderAppSvc.BuyNow(requestData, c.GetInt64("user_id"))
+	if err != nil {
+		if errors.Is(err, errcode.ErrCartItemParam) {
+			app.NewResponse(c).Error(errcode.ErrCartItemParam)
+		} else if errors.Is(err, errcode.ErrOrderCheckoutInvalid) {
+			app.NewResponse(c).Error(errcode.ErrOrderCheckoutInvalid)
+		} else if errors.Is(err, errcode.ErrOrderBillChanged) {
+			app.NewResponse(c).Error(errcode.ErrOrderBillChanged)
+		} else if errors.Is(err, errcode.ErrFreightUndeliverable) {
+			app.NewResponse(c).Error(errcode.ErrFreightUndeliverable)
+		} else if errors.Is(err, errcode.ErrCouponUnavailable) {
+			app.NewResponse(c).Error(errcode.ErrCouponUnavailable)
+		} else if errors.Is(err, errcode.ErrDiscountLimitExceeded) {
+			app.NewResponse(c).Error(errcode.ErrDiscountLimitExceeded)
 		} else if errors.Is(err, errcode.ErrCommodityStockOut) {
 			app.NewResponse(c).Error(errcode.ErrCommodityStockOut.WithCause(err))
 		} else {
@@ -85,6 +162,25 @@ func OrderCancel(c *gin.Context) {
 	app.NewResponse(c).SuccessOk()
 }
 
+// OrderConfirmReceipt 用户确认收货
+func OrderConfirmReceipt(c *gin.Context) {
+	orderNo := c.Param("order_no")
+	orderAppSvc := appservice.NewOrderAppSvc(c)
+	err := orderAppSvc.ConfirmReceipt(orderNo, c.GetInt64("user_id"))
+	if err != nil {
+		if errors.Is(err, errcode.ErrOrderParams) {
+			app.NewResponse(c).Error(errcode.ErrOrderParams)
+		} else if errors.Is(err, errcode.ErrOrderCanNotBeChanged) {
+			app.NewResponse(c).Error(errcode.ErrOrderCanNotBeChanged)
+		} else {
+			app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		}
+		return
+	}
+
+	app.NewResponse(c).SuccessOk()
+}
+
 // CreateOrderPay 订单发起支付
 func CreateOrderPay(c *gin.Context) {
 	requestData := new(request.OrderPayCreate)
@@ -98,6 +194,10 @@ func CreateOrderPay(c *gin.Context) {
 	if err != nil {
 		if errors.Is(err, errcode.ErrOrderParams) {
 			app.NewResponse(c).Error(errcode.ErrOrderParams)
+		} else if errors.Is(err, errcode.ErrOrderPayInProgress) {
+			app.NewResponse(c).Error(errcode.ErrOrderPayInProgress)
+		} else if errors.Is(err, errcode.ErrGroupBuyNotJoinable) {
+			app.NewResponse(c).Error(errcode.ErrGroupBuyNotJoinable)
 		} else {
 			app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
 		}
@@ -106,3 +206,296 @@ func CreateOrderPay(c *gin.Context) {
 
 	app.NewResponse(c).Success(reply)
 }
+
+// OrderRefundApply 用户申请退款
+func OrderRefundApply(c *gin.Context) {
+	requestData := new(request.OrderRefundApply)
+	if err := c.ShouldBindJSON(requestData); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+
+	orderAppSvc := appservice.NewOrderAppSvc(c)
+	reply, err := orderAppSvc.ApplyRefund(c.Param("order_no"), requestData, c.GetInt64("user_id"))
+	if err != nil {
+		if errors.Is(err, errcode.ErrOrderParams) {
+			app.NewResponse(c).Error(errcode.ErrOrderParams)
+		} else if errors.Is(err, errcode.ErrOrderCanNotRefund) {
+			app.NewResponse(c).Error(errcode.ErrOrderCanNotRefund)
+		} else if errors.Is(err, errcode.ErrGroupBuyNotSucceeded) {
+			app.NewResponse(c).Error(errcode.ErrGroupBuyNotSucceeded)
+		} else if errors.Is(err, errcode.ErrOrderCanNotBeChanged) {
+			app.NewResponse(c).Error(errcode.ErrOrderCanNotBeChanged)
+		} else {
+			app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		}
+		return
+	}
+
+	app.NewResponse(c).Success(reply)
+}
+
+// OrderRefunds 订单的退款单列表
+func OrderRefunds(c *gin.Context) {
+	orderAppSvc := appservice.NewOrderAppSvc(c)
+	replyRefunds, err := orderAppSvc.GetOrderRefunds(c.Param("order_no"), c.GetInt64("user_id"))
+	if err != nil {
+		if errors.Is(err, errcode.ErrOrderParams) {
+			app.NewResponse(c).Error(errcode.ErrOrderParams)
+		} else {
+			app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		}
+		return
+	}
+
+	app.NewResponse(c).Success(replyRefunds)
+}
+
+// AdminOrderRefunds 管理后台退款单列表, 不传 status 时查询全部状态的退款单
+func AdminOrderRefunds(c *gin.Context) {
+	status, err := strconv.Atoi(c.DefaultQuery("status", "-1"))
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	pagination := app.NewPagination(c)
+	orderAppSvc := appservice.NewOrderAppSvc(c)
+	replyRefunds, err := orderAppSvc.AdminGetRefunds(status, pagination)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+	app.NewResponse(c).SetPagination(pagination).Success(replyRefunds)
+}
+
+// AdminRefundApprove 商家同意退款
+func AdminRefundApprove(c *gin.Context) {
+	orderAppSvc := appservice.NewOrderAppSvc(c)
+	err := orderAppSvc.AdminApproveRefund(c.Param("refund_no"))
+	if err != nil {
+		replyRefundAdminError(c, err)
+		return
+	}
+
+	app.NewResponse(c).SuccessOk()
+}
+
+// AdminRefundReject 商家拒绝退款
+func AdminRefundReject(c *gin.Context) {
+	requestData := new(request.OrderRefundReject)
+	if err := c.ShouldBindJSON(requestData); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+
+	orderAppSvc := appservice.NewOrderAppSvc(c)
+	err := orderAppSvc.AdminRejectRefund(c.Param("refund_no"), requestData)
+	if err != nil {
+		replyRefundAdminError(c, err)
+		return
+	}
+
+	app.NewResponse(c).SuccessOk()
+}
+
+func replyRefundAdminError(c *gin.Context, err error) {
+	if errors.Is(err, errcode.ErrOrderRefundNotExist) {
+		app.NewResponse(c).Error(errcode.ErrOrderRefundNotExist)
+	} else if errors.Is(err, errcode.ErrOrderRefundCanNotChanged) {
+		app.NewResponse(c).Error(errcode.ErrOrderRefundCanNotChanged)
+	} else if errors.Is(err, errcode.ErrOrderCanNotRefund) {
+		app.NewResponse(c).Error(errcode.ErrOrderCanNotRefund)
+	} else {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+	}
+}
+
+// AdminOrderPick 商家检货完成
+func AdminOrderPick(c *gin.Context) {
+	orderAppSvc := appservice.NewOrderAppSvc(c)
+	err := orderAppSvc.AdminPickOrder(c.Param("order_no"))
+	if err != nil {
+		replyOrderAdminError(c, err)
+		return
+	}
+
+	app.NewResponse(c).SuccessOk()
+}
+
+// AdminOrderShip 商家发货
+func AdminOrderShip(c *gin.Context) {
+	requestData := new(request.OrderShip)
+	if err := c.ShouldBindJSON(requestData); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+
+	orderAppSvc := appservice.NewOrderAppSvc(c)
+	reply, err := orderAppSvc.AdminShipOrder(c.Param("order_no"), requestData)
+	if err != nil {
+		replyOrderAdminError(c, err)
+		return
+	}
+
+	app.NewResponse(c).Success(reply)
+}
+
+// AdminOrderDeliver 订单送达
+func AdminOrderDeliver(c *gin.Context) {
+	orderAppSvc := appservice.NewOrderAppSvc(c)
+	err := orderAppSvc.AdminDeliverOrder(c.Param("order_no"))
+	if err != nil {
+		replyOrderAdminError(c, err)
+		return
+	}
+
+	app.NewResponse(c).SuccessOk()
+}
+
+// AdminOrderClose 商家关闭订单
+func AdminOrderClose(c *gin.Context) {
+	requestData := new(request.OrderMerchantClose)
+	if err := c.ShouldBindJSON(requestData); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+
+	orderAppSvc := appservice.NewOrderAppSvc(c)
+	err := orderAppSvc.AdminCloseOrder(c.Param("order_no"), requestData)
+	if err != nil {
+		replyOrderAdminError(c, err)
+		return
+	}
+
+	app.NewResponse(c).SuccessOk()
+}
+
+func replyOrderAdminError(c *gin.Context, err error) {
+	if errors.Is(err, errcode.ErrOrderParams) {
+		app.NewResponse(c).Error(errcode.ErrOrderParams)
+	} else if errors.Is(err, errcode.ErrOrderCanNotBeChanged) {
+		app.NewResponse(c).Error(errcode.ErrOrderCanNotBeChanged)
+	} else if errors.Is(err, errcode.ErrOrderCarrierUnsupported) {
+		app.NewResponse(c).Error(errcode.ErrOrderCarrierUnsupported)
+	} else if errors.Is(err, errcode.ErrGroupBuyNotSucceeded) {
+		app.NewResponse(c).Error(errcode.ErrGroupBuyNotSucceeded)
+	} else {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+	}
+}
+
+// AdminPayBillMismatches 管理后台支付账单对账差异列表, 不传 bill_date、mismatch_type 时查询全部
+func AdminPayBillMismatches(c *gin.Context) {
+	mismatchType, err := strconv.Atoi(c.DefaultQuery("mismatch_type", "0"))
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	pagination := app.NewPagination(c)
+	orderAppSvc := appservice.NewOrderAppSvc(c)
+	replyMismatches, err := orderAppSvc.AdminGetPayBillMismatches(c.Query("bill_date"), mismatchType, pagination)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+	app.NewResponse(c).SetPagination(pagination).Success(replyMismatches)
+}
+
+// AdminPayBillCheck 管理后台手动对指定日期的微信支付交易账单对账, 重新对账会覆盖这一天之前的对账结果
+func AdminPayBillCheck(c *gin.Context) {
+	requestData := new(request.PayBillCheck)
+	if err := c.ShouldBindJSON(requestData); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+
+	orderAppSvc := appservice.NewOrderAppSvc(c)
+	replyMismatches, err := orderAppSvc.AdminCheckWxTradeBill(requestData)
+	if err != nil {
+		if errors.Is(err, errcode.ErrParams) {
+			app.NewResponse(c).Error(errcode.ErrParams)
+		} else {
+			app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		}
+		return
+	}
+	app.NewResponse(c).Success(replyMismatches)
+}
+
+// WxPayNotify 接收微信支付结果通知
+// 应答需要使用微信支付要求的格式, 不使用项目统一的响应结构
+func WxPayNotify(c *gin.Context) {
+	notifyRequest, rawBody, ok := bindWxPayNotifyRequest(c)
+	if !ok {
+		return
+	}
+
+	orderAppSvc := appservice.NewOrderAppSvc(c)
+	err := orderAppSvc.WxPayNotify(notifyRequest, rawBody)
+	replyWxPayNotify(c, "WxPayNotifyError", err)
+}
+
+// WxRefundNotify 接收微信支付退款结果通知
+func WxRefundNotify(c *gin.Context) {
+	notifyRequest, rawBody, ok := bindWxPayNotifyRequest(c)
+	if !ok {
+		return
+	}
+
+	orderAppSvc := appservice.NewOrderAppSvc(c)
+	err := orderAppSvc.WxRefundNotify(notifyRequest, rawBody)
+	replyWxPayNotify(c, "WxRefundNotifyError", err)
+}
+
+// bindWxPayNotifyRequest 读取微信支付通知的原始请求体和签名请求头, 失败时直接给微信支付应答
+func bindWxPayNotifyRequest(c *gin.Context) (*request.WxPayNotifyRequest, string, bool) {
+	rawBody, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, reply.WxPayNotifyReply{Code: "FAIL", Message: "读取请求失败"})
+		return nil, "", false
+	}
+	notifyRequest := new(request.WxPayNotifyRequest)
+	if err = c.ShouldBindHeader(&notifyRequest.Header); err != nil {
+		c.JSON(http.StatusBadRequest, reply.WxPayNotifyReply{Code: "FAIL", Message: "签名信息缺失"})
+		return nil, "", false
+	}
+	if err = json.Unmarshal(rawBody, &notifyRequest.Body); err != nil {
+		c.JSON(http.StatusBadRequest, reply.WxPayNotifyReply{Code: "FAIL", Message: "通知数据格式错误"})
+		return nil, "", false
+	}
+	return notifyRequest, string(rawBody), true
+}
+
+// replyWxPayNotify 按处理结果给微信支付应答, 应答失败时微信支付会重新发送通知
+func replyWxPayNotify(c *gin.Context, errLogMsg string, err error) {
+	if err != nil {
+		logger.New(c).Error(errLogMsg, "err", err)
+		if errors.Is(err, errcode.ErrOrderPayNotifyInvalid) {
+			c.JSON(http.StatusBadRequest, reply.WxPayNotifyReply{Code: "FAIL", Message: "通知验证失败"})
+		} else {
+			c.JSON(http.StatusInternalServerError, reply.WxPayNotifyReply{Code: "FAIL", Message: "处理失败"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, reply.WxPayNotifyReply{Code: "SUCCESS", Message: "成功"})
+}
+
+// AliPayNotify 接收支付宝异步通知
+// 处理成功后需要返回纯文本 success, 否则支付宝会重复发送通知
+func AliPayNotify(c *gin.Context) {
+	if err := c.Request.ParseForm(); err != nil {
+		c.String(http.StatusOK, "fail")
+		return
+	}
+
+	orderAppSvc := appservice.NewOrderAppSvc(c)
+	err := orderAppSvc.AliPayNotify(c.Request.PostForm)
+	if err != nil {
+		logger.New(c).Error("AliPayNotifyError", "err", err)
+		c.String(http.StatusOK, "fail")
+		return
+	}
+
+	c.String(http.StatusOK, "success")
+}
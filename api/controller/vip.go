@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/hd2yao/go-mall/api/request"
+	"github.com/hd2yao/go-mall/common/app"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/logic/appservice"
+)
+
+// UserVipInfo 用户的会员信息
+func UserVipInfo(c *gin.Context) {
+	vipAppSvc := appservice.NewVipAppSvc(c)
+	replyUserVip, err := vipAppSvc.GetUserVip(c.GetInt64("user_id"))
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+	app.NewResponse(c).Success(replyUserVip)
+}
+
+// VipTiers 会员等级列表
+func VipTiers(c *gin.Context) {
+	vipAppSvc := appservice.NewVipAppSvc(c)
+	replyTiers, err := vipAppSvc.GetVipTiers()
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+	app.NewResponse(c).Success(replyTiers)
+}
+
+// AdminVipTierCreate 管理后台创建会员等级
+func AdminVipTierCreate(c *gin.Context) {
+	requestData := new(request.VipTierSave)
+	if err := c.ShouldBindJSON(requestData); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	vipAppSvc := appservice.NewVipAppSvc(c)
+	replyTier, err := vipAppSvc.CreateVipTier(requestData)
+	if err != nil {
+		replyVipTierError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(replyTier)
+}
+
+// AdminVipTierUpdate 管理后台更新会员等级
+func AdminVipTierUpdate(c *gin.Context) {
+	tierId, err := strconv.ParseInt(c.Param("tier_id"), 10, 64)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	requestData := new(request.VipTierSave)
+	if err = c.ShouldBindJSON(requestData); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	vipAppSvc := appservice.NewVipAppSvc(c)
+	if err = vipAppSvc.UpdateVipTier(tierId, requestData); err != nil {
+		replyVipTierError(c, err)
+		return
+	}
+	app.NewResponse(c).SuccessOk()
+}
+
+// AdminVipTierDelete 管理后台删除会员等级
+func AdminVipTierDelete(c *gin.Context) {
+	tierId, err := strconv.ParseInt(c.Param("tier_id"), 10, 64)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	vipAppSvc := appservice.NewVipAppSvc(c)
+	if err = vipAppSvc.DeleteVipTier(tierId); err != nil {
+		replyVipTierError(c, err)
+		return
+	}
+	app.NewResponse(c).SuccessOk()
+}
+
+func replyVipTierError(c *gin.Context, err error) {
+	if errors.Is(err, errcode.ErrVipTierParams) {
+		app.NewResponse(c).Error(errcode.ErrVipTierParams)
+	} else if errors.Is(err, errcode.ErrVipTierNotExists) {
+		app.NewResponse(c).Error(errcode.ErrVipTierNotExists)
+	} else {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+	}
+}
@@ -29,8 +29,21 @@ type CheckedCartItemBillV2 struct {
 			DiscountName  string `json:"discount_name"`
 			DiscountMoney int    `json:"discount_money"`
 		} `json:"discount"`
-		VipDiscountMoney   int `json:"vip_discount_money"`   // VIP 减免的金额
-		OriginalTotalPrice int `json:"original_total_price"` // 减免、优惠前的总金额
-		TotalPrice         int `json:"total_price"`          // 实际要支付的总金额
+		VipDiscountMoney   int             `json:"vip_discount_money"`   // VIP 减免的金额
+		FreightMoney       int             `json:"freight_money"`        // 运费
+		OriginalTotalPrice int             `json:"original_total_price"` // 减免、优惠前的商品总金额
+		TotalPrice         int             `json:"total_price"`          // 实际要支付的总金额, 包含运费
+		Steps              []*CartBillStep `json:"steps"`                // 按优惠叠加顺序计算每项优惠减免的步骤
 	} `json:"bill_detail"`
+	CheckoutToken string `json:"checkout_token"` // 结算凭证, 创建订单时传入, 用来校验下单时的金额和确认的金额一致
+}
+
+// CartBillStep 账单中一项优惠的计算步骤
+type CartBillStep struct {
+	Promotion   string `json:"promotion"`    // 优惠名称 vip-会员折扣 coupon-优惠券 discount-满减活动
+	Description string `json:"description"`  // 这一步的说明
+	Base        string `json:"base"`         // 计算减免的基准金额 original-商品原价 reduced-减去前面各项优惠后的金额
+	BaseMoney   int    `json:"base_money"`   // 基准金额
+	DeductMoney int    `json:"deduct_money"` // 这项优惠减免的金额
+	TotalPrice  int    `json:"total_price"`  // 减去这项优惠后的商品金额
 }
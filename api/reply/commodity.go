@@ -45,6 +45,7 @@ type Commodity struct {
 	OriginalPrice int       `json:"original_price"`
 	SellingPrice  int       `json:"selling_price"`
 	StockNum      int       `json:"stock_num"`
+	Weight        int       `json:"weight"` // 商品重量（克）
 	Tag           string    `json:"tag"`
 	SellStatus    int       `json:"sell_status"`
 	CreatedAt     time.Time `json:"created_at"`
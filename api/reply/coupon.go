@@ -0,0 +1,34 @@
+package reply
+
+type CouponTemplate struct {
+	ID               int64   `json:"id"`
+	Name             string  `json:"name"`
+	CouponType       int     `json:"coupon_type"`
+	CouponTypeName   string  `json:"coupon_type_name"`
+	DiscountMoney    int     `json:"discount_money"`
+	DiscountRate     int     `json:"discount_rate"`
+	MaxDiscountMoney int     `json:"max_discount_money"`
+	Threshold        int     `json:"threshold"`
+	ScopeType        int     `json:"scope_type"`
+	ScopeIds         []int64 `json:"scope_ids" copier:"-"`
+	TotalNum         int     `json:"total_num"`
+	ClaimedNum       int     `json:"claimed_num"`
+	PerUserLimit     int     `json:"per_user_limit"`
+	ValidStart       string  `json:"valid_start"`
+	ValidEnd         string  `json:"valid_end"`
+	Status           int     `json:"status"`
+	CreatedAt        string  `json:"created_at"`
+}
+
+type UserCoupon struct {
+	ID         int64           `json:"id"` // 用户优惠券ID, 查看账单和下单时用它选择优惠券
+	CouponId   int64           `json:"coupon_id"`
+	Status     int             `json:"status"`
+	StatusName string          `json:"status_name"`
+	OrderNo    string          `json:"order_no"` // 锁定或使用这张优惠券的订单号
+	ValidStart string          `json:"valid_start"`
+	ValidEnd   string          `json:"valid_end"`
+	UsedAt     string          `json:"used_at"`
+	ClaimedAt  string          `json:"claimed_at" copier:"CreatedAt"`
+	Template   *CouponTemplate `json:"template" copier:"-"`
+}
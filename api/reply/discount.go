@@ -0,0 +1,19 @@
+package reply
+
+type DiscountCampaign struct {
+	ID           int64                   `json:"id"`
+	Name         string                  `json:"name"`
+	ScopeType    int                     `json:"scope_type"`
+	ScopeIds     []int64                 `json:"scope_ids" copier:"-"`
+	PerUserLimit int                     `json:"per_user_limit"`
+	StartTime    string                  `json:"start_time"`
+	EndTime      string                  `json:"end_time"`
+	Status       int                     `json:"status"`
+	Tiers        []*DiscountCampaignTier `json:"tiers"`
+	CreatedAt    string                  `json:"created_at"`
+}
+
+type DiscountCampaignTier struct {
+	Threshold     int `json:"threshold"`
+	DiscountMoney int `json:"discount_money"`
+}
@@ -0,0 +1,24 @@
+package reply
+
+type FlashSaleSession struct {
+	ID             int64  `json:"id"`
+	Name           string `json:"name"`
+	CommodityId    int64  `json:"commodity_id"`
+	FlashPrice     int    `json:"flash_price"`
+	TotalStock     int    `json:"total_stock"`
+	SoldNum        int    `json:"sold_num"`
+	RemainingStock int    `json:"remaining_stock"`
+	PerUserLimit   int    `json:"per_user_limit"`
+	StartTime      string `json:"start_time"`
+	EndTime        string `json:"end_time"`
+	Status         int    `json:"status"`
+	CreatedAt      string `json:"created_at"`
+}
+
+type FlashSaleResult struct {
+	RequestNo  string `json:"request_no"`
+	Status     int    `json:"status"`
+	StatusName string `json:"status_name"`
+	OrderNo    string `json:"order_no"`
+	FailReason string `json:"fail_reason"`
+}
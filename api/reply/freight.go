@@ -0,0 +1,22 @@
+package reply
+
+type FreightTemplate struct {
+	ID             int64                  `json:"id"`
+	Name           string                 `json:"name"`
+	ChargeType     int                    `json:"charge_type"`
+	ChargeTypeName string                 `json:"charge_type_name"`
+	IsDefault      int                    `json:"is_default"`
+	Rules          []*FreightTemplateRule `json:"rules" copier:"-"`
+	CreatedAt      string                 `json:"created_at"`
+	UpdatedAt      string                 `json:"updated_at"`
+}
+
+type FreightTemplateRule struct {
+	ID             int64    `json:"id"`
+	Provinces      []string `json:"provinces"` // 为空时适用于其他省份
+	FirstUnit      int      `json:"first_unit"`
+	FirstFee       int      `json:"first_fee"`
+	AdditionalUnit int      `json:"additional_unit"`
+	AdditionalFee  int      `json:"additional_fee"`
+	FreeThreshold  int      `json:"free_threshold"`
+}
@@ -0,0 +1,39 @@
+package reply
+
+type GroupBuyActivity struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	CommodityId int64  `json:"commodity_id"`
+	GroupPrice  int    `json:"group_price"`
+	GroupSize   int    `json:"group_size"`
+	TimeLimit   int    `json:"time_limit"`
+	StartTime   string `json:"start_time"`
+	EndTime     string `json:"end_time"`
+	Status      int    `json:"status"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type GroupBuyGroup struct {
+	ShareCode   string            `json:"share_code"`
+	ActivityId  int64             `json:"activity_id"`
+	CommodityId int64             `json:"commodity_id"`
+	GroupSize   int               `json:"group_size"`
+	MemberNum   int               `json:"member_num"`
+	PaidNum     int               `json:"paid_num"`
+	ExpireAt    string            `json:"expire_at"`
+	Status      int               `json:"status"`
+	StatusName  string            `json:"status_name"`
+	Members     []*GroupBuyMember `json:"members" copier:"-"`
+	CreatedAt   string            `json:"created_at"`
+}
+
+type GroupBuyMember struct {
+	IsLeader  int    `json:"is_leader"`
+	Status    int    `json:"status"`
+	CreatedAt string `json:"joined_at"`
+}
+
+type GroupBuyOrder struct {
+	OrderNo string         `json:"order_no"`
+	Group   *GroupBuyGroup `json:"group"`
+}
@@ -5,15 +5,16 @@ type OrderCreateReply struct {
 }
 
 type Order struct {
-	OrderNo     string `json:"order_no"`
-	PayTransId  string `json:"pay_trans_id"`
-	PayType     int    `json:"pay_type"`
-	BillMoney   int    `json:"bill_money"`
-	PayMoney    int    `json:"pay_money"`
-	PayState    int    `json:"pay_state"`
-	OrderStatus int    `json:"-"`
-	FrontStatus string `json:"status"`
-	Address     struct {
+	OrderNo      string `json:"order_no"`
+	PayTransId   string `json:"pay_trans_id"`
+	PayType      int    `json:"pay_type"`
+	BillMoney    int    `json:"bill_money"`
+	PayMoney     int    `json:"pay_money"`
+	FreightMoney int    `json:"freight_money"` // 运费, 已包含在 pay_money 中
+	PayState     int    `json:"pay_state"`
+	OrderStatus  int    `json:"-"`
+	FrontStatus  string `json:"status"`
+	Address      struct {
 		UserName      string `json:"user_name"`
 		UserPhone     string `json:"user_phone"`
 		ProvinceName  string `json:"province_name"`
@@ -27,6 +28,80 @@ type Order struct {
 		CommodityImg          string `json:"commodity_img"`
 		CommoditySellingPrice int    `json:"commodity_selling_price"`
 		CommodityNum          int    `json:"commodity_num"`
+		PayMoney              int    `json:"pay_money"`      // 分摊优惠后的实付金额
+		RefundedNum           int    `json:"refunded_num"`   // 已退款的数量
+		RefundedMoney         int    `json:"refunded_money"` // 已退款的金额
 	} `json:"items,omitempty"`
+	Shipment   *OrderShipment    `json:"shipment,omitempty"`    // 物流信息, 只在订单详情中返回
+	StatusLogs []*OrderStatusLog `json:"status_logs,omitempty"` // 订单状态变更记录, 只在订单详情中返回
+	CreatedAt  string            `json:"created_at"`
+}
+
+// OrderShipment 订单的物流信息
+type OrderShipment struct {
+	ShipmentNo  string `json:"shipment_no"`
+	CarrierCode string `json:"carrier_code"`
+	CarrierName string `json:"carrier_name"`
+	TrackingNo  string `json:"tracking_no"`
+	Status      int    `json:"status"` // 0-运输中 1-已送达
+	ShippedAt   string `json:"shipped_at"`
+	DeliveredAt string `json:"delivered_at"`
+}
+
+// OrderStatusLog 订单状态变更记录
+type OrderStatusLog struct {
+	FromStatus     int    `json:"-"`
+	ToStatus       int    `json:"-"`
+	Actor          int    `json:"-"`
+	FromStatusName string `json:"from_status"`
+	ToStatusName   string `json:"to_status"`
+	ActorName      string `json:"actor"`
+	Reason         string `json:"reason"`
+	CreatedAt      string `json:"created_at"`
+}
+
+type OrderRefundApplyReply struct {
+	RefundNo string `json:"refund_no"`
+}
+
+type OrderRefund struct {
+	RefundNo     string `json:"refund_no"`
+	OrderNo      string `json:"order_no"`
+	PayType      int    `json:"pay_type"`
+	RefundMoney  int    `json:"refund_money"`
+	Reason       string `json:"reason"`
+	RejectReason string `json:"reject_reason"`
+	Status       int    `json:"-"`
+	FrontStatus  string `json:"status"`
+	RefundedAt   string `json:"refunded_at"`
+	Items        []struct {
+		CommodityId int64 `json:"commodity_id"`
+		RefundNum   int   `json:"refund_num"`
+		RefundMoney int   `json:"refund_money"`
+	} `json:"items"`
 	CreatedAt string `json:"created_at"`
 }
+
+// WxPayNotifyReply 接收微信支付结果通知后给微信支付的应答
+// https://pay.weixin.qq.com/docs/merchant/apis/jsapi-payment/payment-notice.html
+type WxPayNotifyReply struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PayBillMismatch 支付账单对账差异
+type PayBillMismatch struct {
+	BillDate          string `json:"bill_date"`
+	PayType           int    `json:"pay_type"`
+	OrderNo           string `json:"order_no"`
+	MismatchType      int    `json:"mismatch_type"`
+	MismatchTypeName  string `json:"mismatch_type_name"`
+	LocalPayTransId   string `json:"local_pay_trans_id"`
+	ChannelTransId    string `json:"channel_trans_id"`
+	LocalPayMoney     int    `json:"local_pay_money"`
+	ChannelPayMoney   int    `json:"channel_pay_money"`
+	LocalPayState     int    `json:"local_pay_state"`
+	ChannelTradeState string `json:"channel_trade_state"`
+	Remark            string `json:"remark"`
+	CreatedAt         string `json:"created_at"`
+}
@@ -0,0 +1,20 @@
+package reply
+
+type VipTier struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	Level        int    `json:"level"`
+	DiscountRate int    `json:"discount_rate"`
+	UpgradeSpend int    `json:"upgrade_spend"`
+	ValidDays    int    `json:"valid_days"`
+	CreatedAt    string `json:"created_at"`
+}
+
+type UserVip struct {
+	IsVip             bool     `json:"is_vip"`               // 是否是有效期内的会员
+	TotalSpend        int      `json:"total_spend"`          // 已完成订单的累计消费金额（分）
+	Tier              *VipTier `json:"tier"`                 // 当前的会员等级, 不是会员或者会员已过期时为 null
+	ExpireAt          string   `json:"expire_at"`            // 会员过期时间, 不是会员时为空
+	NextTier          *VipTier `json:"next_tier"`            // 下一个可以升级到的等级, 已经是最高等级时为 null
+	NextTierNeedSpend int      `json:"next_tier_need_spend"` // 升级到下一个等级还需要的消费金额（分）
+}
@@ -0,0 +1,17 @@
+package request
+
+// CouponTemplateCreate 创建优惠券模板
+type CouponTemplateCreate struct {
+	Name             string  `json:"name" binding:"required,max=50"`
+	CouponType       int     `json:"coupon_type" binding:"required,oneof=1 2"`                    // 类型 1-固定金额 2-按比例折扣
+	DiscountMoney    int     `json:"discount_money" binding:"min=0"`                              // 固定金额券的减免金额（分）
+	DiscountRate     int     `json:"discount_rate" binding:"min=0,max=99"`                        // 折扣券减免的百分比, 20 表示 8 折
+	MaxDiscountMoney int     `json:"max_discount_money" binding:"min=0"`                          // 折扣券最多减免的金额（分）, 0 表示不限
+	Threshold        int     `json:"threshold" binding:"min=0"`                                   // 使用门槛（分）, 0 表示无门槛
+	ScopeType        int     `json:"scope_type" binding:"required,oneof=1 2 3"`                   // 适用范围 1-全部商品 2-指定分类 3-指定商品
+	ScopeIds         []int64 `json:"scope_ids" binding:"omitempty,dive,min=1"`                    // 适用的分类ID或商品ID
+	TotalNum         int     `json:"total_num" binding:"min=0"`                                   // 发放总量, 0 表示不限
+	PerUserLimit     int     `json:"per_user_limit" binding:"required,min=1"`                     // 每个用户最多领取的数量
+	ValidStart       string  `json:"valid_start" binding:"required,datetime=2006-01-02 15:04:05"` // 有效期开始时间
+	ValidEnd         string  `json:"valid_end" binding:"required,datetime=2006-01-02 15:04:05"`   // 有效期结束时间
+}
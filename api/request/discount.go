@@ -0,0 +1,18 @@
+package request
+
+// DiscountCampaignCreate 创建满减活动
+type DiscountCampaignCreate struct {
+	Name         string                      `json:"name" binding:"required,max=50"`
+	ScopeType    int                         `json:"scope_type" binding:"required,oneof=1 2 3"`                  // 适用范围 1-全部商品 2-指定分类 3-指定商品
+	ScopeIds     []int64                     `json:"scope_ids" binding:"omitempty,dive,min=1"`                   // 适用的分类ID或商品ID, 指定分类时包含分类下的所有子分类
+	PerUserLimit int                         `json:"per_user_limit" binding:"min=0"`                             // 每个用户最多参加的次数, 0 表示不限
+	StartTime    string                      `json:"start_time" binding:"required,datetime=2006-01-02 15:04:05"` // 活动开始时间
+	EndTime      string                      `json:"end_time" binding:"required,datetime=2006-01-02 15:04:05"`   // 活动结束时间
+	Tiers        []*DiscountCampaignTierSave `json:"tiers" binding:"required,min=1,dive"`
+}
+
+// DiscountCampaignTierSave 满减活动的阶梯
+type DiscountCampaignTierSave struct {
+	Threshold     int `json:"threshold" binding:"required,min=1"`      // 门槛（分）
+	DiscountMoney int `json:"discount_money" binding:"required,min=1"` // 减免金额（分）
+}
@@ -0,0 +1,18 @@
+package request
+
+// FlashSaleSessionCreate 创建秒杀场次
+type FlashSaleSessionCreate struct {
+	Name         string `json:"name" binding:"required,max=50"`
+	CommodityId  int64  `json:"commodity_id" binding:"required"`
+	FlashPrice   int    `json:"flash_price" binding:"required,min=1"`                       // 秒杀价（分）
+	TotalStock   int    `json:"total_stock" binding:"required,min=1"`                       // 秒杀库存, 从商品库存中划出
+	PerUserLimit int    `json:"per_user_limit" binding:"required,min=1"`                    // 每个用户最多抢购的数量
+	StartTime    string `json:"start_time" binding:"required,datetime=2006-01-02 15:04:05"` // 场次开始时间
+	EndTime      string `json:"end_time" binding:"required,datetime=2006-01-02 15:04:05"`   // 场次结束时间
+}
+
+// FlashSalePurchase 抢购秒杀商品
+type FlashSalePurchase struct {
+	CommodityNum  int   `json:"commodity_num" binding:"required,min=1"`
+	UserAddressId int64 `json:"user_address_id" binding:"required"`
+}
@@ -0,0 +1,19 @@
+package request
+
+// FreightTemplateSave 创建、更新运费模板
+type FreightTemplateSave struct {
+	Name       string                     `json:"name" binding:"required,max=50"`
+	ChargeType int                        `json:"charge_type" binding:"required,oneof=1 2"` // 计费方式 1-按件数 2-按重量
+	IsDefault  int                        `json:"is_default" binding:"oneof=0 1"`           // 是否设为默认模板, 下单时使用默认模板计算运费
+	Rules      []*FreightTemplateRuleSave `json:"rules" binding:"required,min=1,dive"`
+}
+
+// FreightTemplateRuleSave 运费模板的地区计费规则
+type FreightTemplateRuleSave struct {
+	Provinces      []string `json:"provinces" binding:"omitempty,dive,required"` // 适用的省份, 不传时适用于其他没有单独设置规则的省份
+	FirstUnit      int      `json:"first_unit" binding:"required,min=1"`         // 首件数或首重（克）
+	FirstFee       int      `json:"first_fee" binding:"min=0"`                   // 首件或首重的运费（分）
+	AdditionalUnit int      `json:"additional_unit" binding:"min=0"`             // 续件数或续重（克）, 0 表示超出首件(首重)的部分不再收费
+	AdditionalFee  int      `json:"additional_fee" binding:"min=0"`              // 每个续件或续重单位的运费（分）
+	FreeThreshold  int      `json:"free_threshold" binding:"min=0"`              // 包邮门槛（分）, 0 表示不包邮
+}
@@ -0,0 +1,25 @@
+package request
+
+// GroupBuyActivityCreate 创建拼团活动
+type GroupBuyActivityCreate struct {
+	Name        string `json:"name" binding:"required,max=50"`
+	CommodityId int64  `json:"commodity_id" binding:"required"`
+	GroupPrice  int    `json:"group_price" binding:"required,min=1"`                       // 拼团价（分）
+	GroupSize   int    `json:"group_size" binding:"required,min=2"`                        // 成团人数, 包含团长
+	TimeLimit   int    `json:"time_limit" binding:"required,min=1"`                        // 成团时限（分钟）
+	StartTime   string `json:"start_time" binding:"required,datetime=2006-01-02 15:04:05"` // 活动开始时间
+	EndTime     string `json:"end_time" binding:"required,datetime=2006-01-02 15:04:05"`   // 活动结束时间
+}
+
+// GroupBuyOpen 开团
+type GroupBuyOpen struct {
+	CommodityNum  int   `json:"commodity_num" binding:"required,min=1"`
+	UserAddressId int64 `json:"user_address_id" binding:"required"`
+}
+
+// GroupBuyJoin 参团
+type GroupBuyJoin struct {
+	ShareCode     string `json:"share_code" binding:"required,max=32"`
+	CommodityNum  int    `json:"commodity_num" binding:"required,min=1"`
+	UserAddressId int64  `json:"user_address_id" binding:"required"`
+}
@@ -6,21 +6,71 @@ import "time"
 type OrderCreate struct {
 	CartItemIdList []int64 `json:"cart_item_id_list" binding:"required"`
 	UserAddressId  int64   `json:"user_address_id" binding:"required"`
+	CheckoutToken  string  `json:"checkout_token" binding:"required"` // 查看购物项账单时返回的结算凭证
+	CouponId       int64   `json:"coupon_id"`                         // 使用的优惠券, 和查看账单时传的一致, 不传时自动选择, -1 表示不使用优惠券
+}
+
+// BuyNowItem 立即购买的商品和购买数量
+type BuyNowItem struct {
+	CommodityId  int64 `json:"commodity_id" binding:"required"`
+	CommodityNum int   `json:"commodity_num" binding:"required,min=1,max=5"` // 和加入购物车一样一个商品一次最多买5个
+}
+
+// OrderBuyNowBill 立即购买前查看账单
+type OrderBuyNowBill struct {
+	Items         []*BuyNowItem `json:"items" binding:"required,min=1,dive"`
+	UserAddressId int64         `json:"user_address_id"` // 选择了收货地址时计算运费
+	CouponId      int64         `json:"coupon_id"`       // 使用的优惠券, 不传时自动选择减免金额最多的优惠券, -1 表示不使用优惠券
+}
+
+// OrderBuyNow 立即购买, 不经过购物车直接下单
+type OrderBuyNow struct {
+	Items         []*BuyNowItem `json:"items" binding:"required,min=1,dive"`
+	UserAddressId int64         `json:"user_address_id" binding:"required"`
+	CheckoutToken string        `json:"checkout_token" binding:"required"` // 立即购买查看账单时返回的结算凭证
+	CouponId      int64         `json:"coupon_id"`                         // 使用的优惠券, 和查看账单时传的一致, 不传时自动选择, -1 表示不使用优惠券
 }
 
 // OrderPayCreate 订单发起支付请求
 type OrderPayCreate struct {
-	OrderNo string `json:"order_no" binding:"required"`
-	PayType int    `json:"pay_type" binding:"required,oneof= 1 2"`
+	OrderNo  string `json:"order_no" binding:"required"`
+	PayType  int    `json:"pay_type" binding:"required,oneof= 1 2"`
+	PayScene string `json:"pay_scene" binding:"required"` // 支付场景, 微信: jsapi-JSAPI app-APP native-扫码; 支付宝: page-电脑网站 wap-手机网站 app-APP
+}
+
+// OrderRefundApply 用户申请退款
+type OrderRefundApply struct {
+	Reason string `json:"reason" binding:"required,max=200"` // 退款原因
+	Items  []struct {
+		CommodityId int64 `json:"commodity_id" binding:"required"`
+		RefundNum   int   `json:"refund_num" binding:"required,min=1"`
+	} `json:"items" binding:"omitempty,dive"` // 要退款的商品和数量, 不传时退订单中所有还没退款的商品
+}
+
+// OrderRefundReject 商家拒绝退款
+type OrderRefundReject struct {
+	RejectReason string `json:"reject_reason" binding:"required,max=200"` // 拒绝原因
+}
+
+// OrderShip 商家发货
+type OrderShip struct {
+	CarrierCode string `json:"carrier_code" binding:"required"`       // 物流公司编码, 比如 SF-顺丰速运 JD-京东物流
+	TrackingNo  string `json:"tracking_no" binding:"required,max=64"` // 物流单号
+}
+
+// OrderMerchantClose 商家关闭订单
+type OrderMerchantClose struct {
+	Reason string `json:"reason" binding:"required,max=200"` // 关闭原因
 }
 
 // WxPayNotifyRequest 微信支付回调通知请求
 // https://pay.weixin.qq.com/docs/merchant/apis/jsapi-payment/payment-notice.html
 type WxPayNotifyRequest struct {
 	Header struct {
-		Timestamp string `json:"Wechatpay-Timestamp"`
-		Nonce     string `json:"Wechatpay-Nonce"`
-		Signature string `json:"Wechatpay-Signature""`
+		Timestamp string `json:"Wechatpay-Timestamp" header:"Wechatpay-Timestamp" binding:"required"`
+		Nonce     string `json:"Wechatpay-Nonce" header:"Wechatpay-Nonce" binding:"required"`
+		Signature string `json:"Wechatpay-Signature" header:"Wechatpay-Signature" binding:"required"`
+		Serial    string `json:"Wechatpay-Serial" header:"Wechatpay-Serial"`
 	}
 	Body struct {
 		ID           string    `json:"id"`
@@ -37,3 +87,8 @@ type WxPayNotifyRequest struct {
 		} `json:"resource"`
 	}
 }
+
+// PayBillCheck 管理后台手动执行账单对账
+type PayBillCheck struct {
+	BillDate string `json:"bill_date" binding:"required,datetime=2006-01-02"` // 账单日期, 只能是今天之前的日期
+}
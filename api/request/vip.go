@@ -0,0 +1,10 @@
+package request
+
+// VipTierSave 创建或更新会员等级
+type VipTierSave struct {
+	Name         string `json:"name" binding:"required,max=30"`
+	Level        int    `json:"level" binding:"required,min=1"`       // 等级, 数字越大等级越高, 不能和其他等级重复
+	DiscountRate int    `json:"discount_rate" binding:"min=0,max=99"` // 会员折扣减免的百分比, 比如 5 表示 95 折
+	UpgradeSpend int    `json:"upgrade_spend" binding:"min=0"`        // 升级门槛, 已完成订单的累计消费金额（分）
+	ValidDays    int    `json:"valid_days" binding:"min=0"`           // 成为会员或续期后的有效天数, 0 表示永久有效
+}
@@ -16,7 +16,7 @@ func registerCartRoutes(rg *gin.RouterGroup) {
 	// 用户购物车中的购物项列表
 	g.GET("item/", controller.UserCartItems)
 	// 添加到购物车
-	g.POST("add-item", controller.AddCartItem)
+	g.POST("add-item", middleware.Idempotent(), controller.AddCartItem)
 	// 修改购物车中的商品数量
 	g.PATCH("update-item", controller.UpdateCartItem)
 	// 删除购物项
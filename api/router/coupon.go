@@ -0,0 +1,33 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/hd2yao/go-mall/api/controller"
+	"github.com/hd2yao/go-mall/common/middleware"
+)
+
+// 存放优惠券模块的路由
+
+func registerCouponRoutes(rg *gin.RouterGroup) {
+	// 这个路由组中的路由都以 /coupon/ 开头, 并且都需要身份验证
+	g := rg.Group("/coupon/")
+	g.Use(middleware.AuthUser())
+	// 可以领取的优惠券列表
+	g.GET("claimable", controller.ClaimableCoupons)
+	// 领取优惠券
+	g.POST(":coupon_id/claim", controller.ClaimCoupon)
+	// 用户的优惠券列表
+	g.GET("user-coupon/", controller.UserCoupons)
+
+	// 以下涉及到管理员系统, 需要登录并且是管理员
+	admin := rg.Group("/coupon/admin/", middleware.AuthUser(), middleware.AuthAdmin())
+	{
+		// 优惠券模板列表
+		admin.GET("templates", controller.AdminCouponTemplates)
+		// 创建优惠券模板
+		admin.POST("template", controller.AdminCouponTemplateCreate)
+		// 停止发放优惠券
+		admin.POST("template/:template_id/stop", controller.AdminCouponTemplateStop)
+	}
+}
@@ -0,0 +1,27 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/hd2yao/go-mall/api/controller"
+	"github.com/hd2yao/go-mall/common/middleware"
+)
+
+// 存放满减活动相关的路由
+
+func registerDiscountRoutes(rg *gin.RouterGroup) {
+	g := rg.Group("/discount/")
+	// 正在进行的满减活动
+	g.GET("campaigns", controller.OngoingDiscountCampaigns)
+
+	// 以下涉及到管理员系统, 需要登录并且是管理员
+	admin := rg.Group("/discount/admin/", middleware.AuthUser(), middleware.AuthAdmin())
+	{
+		// 满减活动列表
+		admin.GET("campaigns", controller.AdminDiscountCampaigns)
+		// 创建满减活动
+		admin.POST("campaign", controller.AdminDiscountCampaignCreate)
+		// 停止满减活动
+		admin.POST("campaign/:campaign_id/stop", controller.AdminDiscountCampaignStop)
+	}
+}
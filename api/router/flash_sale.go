@@ -0,0 +1,31 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/hd2yao/go-mall/api/controller"
+	"github.com/hd2yao/go-mall/common/middleware"
+)
+
+// 存放秒杀相关的路由
+
+func registerFlashSaleRoutes(rg *gin.RouterGroup) {
+	g := rg.Group("/flash-sale/")
+	// 正在抢购和即将开始的秒杀场次
+	g.GET("sessions", controller.FlashSaleSessions)
+	// 抢购秒杀商品
+	g.POST("session/:session_id/purchase", middleware.AuthUser(), controller.FlashSalePurchase)
+	// 查询抢购的处理结果
+	g.GET("result/:request_no", middleware.AuthUser(), controller.FlashSaleResult)
+
+	// 以下涉及到管理员系统, 需要登录并且是管理员
+	admin := rg.Group("/flash-sale/admin/", middleware.AuthUser(), middleware.AuthAdmin())
+	{
+		// 秒杀场次列表
+		admin.GET("sessions", controller.AdminFlashSaleSessions)
+		// 创建秒杀场次
+		admin.POST("session", controller.AdminFlashSaleSessionCreate)
+		// 提前结束秒杀场次
+		admin.POST("session/:session_id/stop", controller.AdminFlashSaleSessionStop)
+	}
+}
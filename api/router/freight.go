@@ -0,0 +1,27 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/hd2yao/go-mall/api/controller"
+	"github.com/hd2yao/go-mall/common/middleware"
+)
+
+// 存放运费模板相关的路由
+
+func registerFreightRoutes(rg *gin.RouterGroup) {
+	// 以下涉及到管理员系统, 需要登录并且是管理员
+	admin := rg.Group("/freight/admin/", middleware.AuthUser(), middleware.AuthAdmin())
+	{
+		// 运费模板列表
+		admin.GET("templates", controller.AdminFreightTemplates)
+		// 运费模板详情
+		admin.GET("template/:template_id", controller.AdminFreightTemplateInfo)
+		// 创建运费模板
+		admin.POST("template", controller.AdminFreightTemplateCreate)
+		// 更新运费模板
+		admin.PUT("template/:template_id", controller.AdminFreightTemplateUpdate)
+		// 删除运费模板
+		admin.DELETE("template/:template_id", controller.AdminFreightTemplateDelete)
+	}
+}
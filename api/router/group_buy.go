@@ -0,0 +1,33 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/hd2yao/go-mall/api/controller"
+	"github.com/hd2yao/go-mall/common/middleware"
+)
+
+// 存放拼团相关的路由
+
+func registerGroupBuyRoutes(rg *gin.RouterGroup) {
+	g := rg.Group("/group-buy/")
+	// 正在进行的拼团活动
+	g.GET("activities", controller.GroupBuyActivities)
+	// 用分享码查看拼团的进度
+	g.GET("group/:share_code", controller.GroupBuyGroupInfo)
+	// 开团
+	g.POST("activity/:activity_id/open", middleware.AuthUser(), middleware.Idempotent(), controller.GroupBuyOpen)
+	// 用分享码参团
+	g.POST("join", middleware.AuthUser(), middleware.Idempotent(), controller.GroupBuyJoin)
+
+	// 以下涉及到管理员系统, 需要登录并且是管理员
+	admin := rg.Group("/group-buy/admin/", middleware.AuthUser(), middleware.AuthAdmin())
+	{
+		// 拼团活动列表
+		admin.GET("activities", controller.AdminGroupBuyActivities)
+		// 创建拼团活动
+		admin.POST("activity", controller.AdminGroupBuyActivityCreate)
+		// 停止拼团活动
+		admin.POST("activity/:activity_id/stop", controller.AdminGroupBuyActivityStop)
+	}
+}
@@ -8,17 +8,59 @@ import (
 )
 
 func registerOrderRoutes(rg *gin.RouterGroup) {
+	// 支付平台的支付结果通知, 由支付平台调用, 不需要身份验证, 在处理时验证通知的签名
+	notify := rg.Group("/order/notify/")
+	// 微信支付结果通知
+	notify.POST("wxpay", controller.WxPayNotify)
+	// 微信支付退款结果通知
+	notify.POST("wxpay-refund", controller.WxRefundNotify)
+	// 支付宝异步通知
+	notify.POST("alipay", controller.AliPayNotify)
+
 	// 这个路由组中的路由都以 /order/ 开头, 并且都需要身份验证
 	g := rg.Group("/order/")
 	g.Use(middleware.AuthUser())
 	// 创建订单
-	g.POST("create", controller.OrderCreate)
+	g.POST("create", middleware.Idempotent(), controller.OrderCreate)
+	// 立即购买前查看账单
+	g.POST("buy-now/check-bill", controller.OrderBuyNowBill)
+	// 立即购买
+	g.POST("buy-now", middleware.Idempotent(), controller.OrderBuyNow)
 	// 用户订单列表
 	g.GET("user-order/", controller.UserOrders)
 	// 订单详情
 	g.GET(":order_no/info", controller.OrderInfo)
 	// 取消订单
 	g.PATCH(":order_no/cancel", controller.OrderCancel)
+	// 确认收货
+	g.PATCH(":order_no/confirm-receipt", controller.OrderConfirmReceipt)
 	// 发起订单支付
-	g.POST("create-pay", controller.CreateOrderPay)
+	g.POST("create-pay", middleware.Idempotent(), controller.CreateOrderPay)
+	// 申请退款
+	g.POST(":order_no/refund", controller.OrderRefundApply)
+	// 订单的退款单列表
+	g.GET(":order_no/refunds", controller.OrderRefunds)
+
+	// 以下涉及到管理员系统, 需要登录并且是管理员
+	admin := rg.Group("/order/admin/", middleware.AuthUser(), middleware.AuthAdmin())
+	{
+		// 退款单列表
+		admin.GET("refunds", controller.AdminOrderRefunds)
+		// 同意退款
+		admin.POST("refund/:refund_no/approve", controller.AdminRefundApprove)
+		// 拒绝退款
+		admin.POST("refund/:refund_no/reject", controller.AdminRefundReject)
+		// 检货完成
+		admin.POST(":order_no/pick", controller.AdminOrderPick)
+		// 发货
+		admin.POST(":order_no/ship", controller.AdminOrderShip)
+		// 订单送达
+		admin.POST(":order_no/deliver", controller.AdminOrderDeliver)
+		// 商家关闭订单
+		admin.POST(":order_no/close", controller.AdminOrderClose)
+		// 支付账单对账差异列表
+		admin.GET("pay-bill/mismatches", controller.AdminPayBillMismatches)
+		// 手动执行支付账单对账
+		admin.POST("pay-bill/check", controller.AdminPayBillCheck)
+	}
 }
@@ -29,9 +29,8 @@ func registerReviewRoute(rg *gin.RouterGroup) {
 		g.GET("/commodity/:commodity_id/statistics", controller.GetReviewStatistics)
 	}
 
-	// 以下涉及到管理员系统
-	// 需要鉴权 middleware.AuthAdmin()
-	admin := g.Group("/admin")
+	// 以下涉及到管理员系统, 需要是管理员
+	admin := g.Group("/admin", middleware.AuthAdmin())
 	{
 		// 商家回复评价
 		admin.POST("/reply", controller.AdminReviewReply)
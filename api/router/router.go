@@ -17,4 +17,10 @@ func RegisterRoutes(engine *gin.Engine) {
 	registerCartRoutes(routeGroup)
 	registerOrderRoutes(routeGroup)
 	registerReviewRoute(routeGroup)
+	registerFreightRoutes(routeGroup)
+	registerCouponRoutes(routeGroup)
+	registerDiscountRoutes(routeGroup)
+	registerVipRoutes(routeGroup)
+	registerFlashSaleRoutes(routeGroup)
+	registerGroupBuyRoutes(routeGroup)
 }
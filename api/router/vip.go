@@ -0,0 +1,29 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/hd2yao/go-mall/api/controller"
+	"github.com/hd2yao/go-mall/common/middleware"
+)
+
+// 存放会员模块的路由
+
+func registerVipRoutes(rg *gin.RouterGroup) {
+	g := rg.Group("/vip/")
+	// 会员等级列表
+	g.GET("tiers", controller.VipTiers)
+	// 用户的会员信息
+	g.GET("my", middleware.AuthUser(), controller.UserVipInfo)
+
+	// 以下涉及到管理员系统, 需要登录并且是管理员
+	admin := rg.Group("/vip/admin/", middleware.AuthUser(), middleware.AuthAdmin())
+	{
+		// 创建会员等级
+		admin.POST("tier", controller.AdminVipTierCreate)
+		// 更新会员等级
+		admin.PATCH("tier/:tier_id", controller.AdminVipTierUpdate)
+		// 删除会员等级
+		admin.DELETE("tier/:tier_id", controller.AdminVipTierDelete)
+	}
+}
@@ -0,0 +1,61 @@
+// wxpayfake 启动一个模拟微信支付 v3 接口的本地服务
+//
+// 把应用配置 app.wechat_pay.api_base_url 指向该服务, platform_public_key_path 指向 -platform-public-key-out 输出的公钥文件,
+// 下单后调用 POST /sandbox/transactions/{out_trade_no}/pay 模拟用户完成支付, 服务会向下单时的 notify_url 发送支付结果通知
+//
+//	go run ./cmd/wxpayfake -appid wx123 -mchid 1900000109 -aes-key <32 字节的 APIv3 密钥>
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/hd2yao/go-mall/library/wxpayfake"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "服务监听的地址")
+	appId := flag.String("appid", "", "应用 appid")
+	mchId := flag.String("mchid", "", "商户号")
+	aesKey := flag.String("aes-key", "", "APIv3 密钥, 32 个字节")
+	mchPublicKeyPath := flag.String("mch-public-key", "", "商户公钥文件, 设置后校验请求签名")
+	platformPrivateKeyPath := flag.String("platform-private-key", "", "微信支付平台私钥文件, 为空时自动生成")
+	platformPublicKeyOut := flag.String("platform-public-key-out", "wxpayfake_pub.pem", "微信支付公钥的输出文件")
+	flag.Parse()
+
+	config := wxpayfake.Config{
+		AppId:  *appId,
+		MchId:  *mchId,
+		AesKey: *aesKey,
+	}
+	var err error
+	if *mchPublicKeyPath != "" {
+		if config.MchPublicKey, err = os.ReadFile(*mchPublicKeyPath); err != nil {
+			log.Fatalf("read mch public key error: %v", err)
+		}
+	}
+	if *platformPrivateKeyPath != "" {
+		if config.PlatformPrivateKey, err = os.ReadFile(*platformPrivateKeyPath); err != nil {
+			log.Fatalf("read platform private key error: %v", err)
+		}
+	}
+
+	server, err := wxpayfake.NewServer(config)
+	if err != nil {
+		log.Fatalf("create server error: %v", err)
+	}
+	publicKey, err := server.PlatformPublicKeyPEM()
+	if err != nil {
+		log.Fatalf("export platform public key error: %v", err)
+	}
+	if err = os.WriteFile(*platformPublicKeyOut, publicKey, 0644); err != nil {
+		log.Fatalf("write platform public key error: %v", err)
+	}
+
+	log.Printf("wxpayfake listening on %s, platform public key: %s", *addr, *platformPublicKeyOut)
+	if err = http.ListenAndServe(*addr, server.Handler()); err != nil {
+		log.Fatal(err)
+	}
+}
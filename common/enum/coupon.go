@@ -0,0 +1,42 @@
+package enum
+
+// 优惠券的类型
+const (
+	CouponTypeFixed   = iota + 1 // 固定金额, 减免 DiscountMoney
+	CouponTypePercent            // 按比例折扣, 减免 DiscountRate%, 可以设置减免金额的上限
+)
+
+// CouponTypeName 优惠券类型的名称
+var CouponTypeName = map[int]string{
+	CouponTypeFixed:   "满减券",
+	CouponTypePercent: "折扣券",
+}
+
+// 优惠券适用的商品范围
+const (
+	CouponScopeAll       = iota + 1 // 全部商品
+	CouponScopeCategory             // 指定商品分类
+	CouponScopeCommodity            // 指定商品
+)
+
+// 优惠券模板的发放状态
+const (
+	CouponTemplateIssuing = iota + 1 // 发放中, 用户可以领取
+	CouponTemplateStopped            // 已停止发放, 已领取的优惠券不受影响
+)
+
+// 用户优惠券的状态
+const (
+	UserCouponUnused = iota + 1 // 未使用
+	UserCouponLocked            // 下单时锁定, 订单支付后核销, 订单取消或关闭后释放
+	UserCouponUsed              // 已使用
+)
+
+var UserCouponStatusName = map[int]string{
+	UserCouponUnused: "未使用",
+	UserCouponLocked: "已锁定",
+	UserCouponUsed:   "已使用",
+}
+
+// 计算账单时不使用优惠券, 用户选择优惠券时传这个值表示不使用
+const BillNoCoupon = -1
@@ -0,0 +1,20 @@
+package enum
+
+// 满减活动适用的商品范围
+const (
+	DiscountScopeAll       = iota + 1 // 全部商品
+	DiscountScopeCategory             // 指定商品分类, 包含分类下的所有子分类
+	DiscountScopeCommodity            // 指定商品
+)
+
+// 满减活动的状态
+const (
+	DiscountCampaignActive  = iota + 1 // 进行中, 在活动时间内生效
+	DiscountCampaignStopped            // 已停止
+)
+
+// 订单参加满减活动的记录状态
+const (
+	DiscountRecordUsed     = iota + 1 // 已参加, 计入用户的参加次数
+	DiscountRecordReleased            // 订单取消或关闭后释放, 不计入参加次数
+)
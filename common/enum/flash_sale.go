@@ -0,0 +1,35 @@
+package enum
+
+// 秒杀场次的状态
+const (
+	FlashSaleSessionActive  = iota + 1 // 进行中, 在场次时间内可以抢购
+	FlashSaleSessionStopped            // 已结束, 管理员停止或者过了结束时间, 没有卖出的库存已经还给商品
+)
+
+// 秒杀下单记录的状态
+const (
+	FlashSaleOrderSuccess  = iota + 1 // 已创建订单
+	FlashSaleOrderFailed              // 创建订单失败
+	FlashSaleOrderReleased            // 订单取消或关闭后释放了秒杀库存
+)
+
+// 秒杀请求的处理结果, 客户端用抢购时返回的请求号轮询
+const (
+	FlashSaleRequestQueued  = iota + 1 // 排队中, 还没有创建订单
+	FlashSaleRequestSuccess            // 已创建订单
+	FlashSaleRequestFailed             // 创建订单失败
+)
+
+var FlashSaleRequestStatusName = map[int]string{
+	FlashSaleRequestQueued:  "排队中",
+	FlashSaleRequestSuccess: "抢购成功",
+	FlashSaleRequestFailed:  "抢购失败",
+}
+
+// Redis 预扣秒杀库存的结果
+const (
+	FlashSaleDeductOk           = 1  // 预扣成功, 已放入下单队列
+	FlashSaleDeductStockOut     = 0  // 库存不足
+	FlashSaleDeductLimitReached = -1 // 超过了每个用户的限购数量
+	FlashSaleDeductNotWarmed    = -2 // 场次的库存还没有加载到 Redis
+)
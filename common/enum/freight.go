@@ -0,0 +1,13 @@
+package enum
+
+// 运费模板的计费方式
+const (
+	FreightChargeByPiece  = iota + 1 // 按件数计费
+	FreightChargeByWeight            // 按重量计费, 重量单位: 克
+)
+
+// FreightChargeTypeName 运费计费方式的名称
+var FreightChargeTypeName = map[int]string{
+	FreightChargeByPiece:  "按件数",
+	FreightChargeByWeight: "按重量",
+}
@@ -0,0 +1,29 @@
+package enum
+
+// 拼团活动的状态
+const (
+	GroupBuyActivityActive  = iota + 1 // 进行中, 在活动时间内可以开团
+	GroupBuyActivityStopped            // 已停止, 不能再开团, 已经开的团可以继续参团直到成团时限
+)
+
+// 拼团的状态
+const (
+	GroupBuyForming = iota + 1 // 拼团中, 等待成员参团和支付
+	GroupBuySuccess            // 拼团成功, 成团人数的成员都已支付
+	GroupBuyFailed             // 拼团失败, 成团时限内支付的成员不够
+)
+
+var GroupBuyStatusName = map[int]string{
+	GroupBuyForming: "拼团中",
+	GroupBuySuccess: "拼团成功",
+	GroupBuyFailed:  "拼团失败",
+}
+
+// 拼团成员的状态
+const (
+	GroupBuyMemberJoined    = iota + 1 // 已参团, 订单还没有支付
+	GroupBuyMemberPaid                 // 已支付
+	GroupBuyMemberQuit                 // 订单取消或超时未支付关闭后退出拼团
+	GroupBuyMemberRefunding            // 拼团失败, 已经为成员的订单创建了退款单
+	GroupBuyMemberRefunded             // 拼团失败, 已经向支付平台发起了退款
+)
@@ -0,0 +1,16 @@
+package enum
+
+import "time"
+
+const IdempotencyKeyHeader = "Idempotency-Key"          // 客户端传递幂等键的请求头
+const IdempotencyReplayedHeader = "Idempotent-Replayed" // 重放第一次请求的响应时带上的响应头
+const IdempotencyKeyMaxLength = 64                      // 幂等键的最大长度
+
+const IdempotencyProcessingDuration = 30 * time.Second // 第一次请求处理中的标记的有效期, 服务异常退出时标记过期后客户端可以重试
+const IdempotencyResponseDuration = 24 * time.Hour     // 第一次请求的响应的保存时间, 超过后同样的幂等键会被当作新的请求
+
+// 幂等键记录的请求的处理状态
+const (
+	IdempotencyStatusProcessing = "processing" // 第一次请求还在处理中
+	IdempotencyStatusDone       = "done"       // 第一次请求已经处理完成, 响应已经保存
+)
@@ -0,0 +1,6 @@
+package enum
+
+import "time"
+
+const IdWorkerLeaseDuration = 30 * time.Second    // 单号生成器机器ID租约的有效期, 每隔三分之一有效期续约一次
+const IdWorkerLastMsDuration = 7 * 24 * time.Hour // 机器ID最后生成单号的时间的保存时间
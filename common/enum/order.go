@@ -1,18 +1,5 @@
 package enum
 
-const (
-	PayStateNotInitiated = iota
-	PayStateUnPaid
-	PayStatePaid
-	PayStatePayFailed
-)
-
-const (
-	PayTypeNotConfirmed = iota // 未确认 -- 创建订单时的初始状态
-	PayTypeWxPay               // 微信支付
-	PayTypeAliPay              // 支付宝
-)
-
 const (
 	OrderStatusCreated        = iota // 已创建
 	OrderStatusUnPaid                // 待支付
@@ -26,6 +13,8 @@ const (
 	OrderStatusUserQuit              // 用户取消
 	OrderStatusUnpaidClose           // 超时未支付
 	OrderStatusMerchantClose         // 商家关闭订单
+	OrderStatusRefunding             // 退款中 -- 用户申请退款后
+	OrderStatusRefunded              // 已退款
 )
 
 // OrderFrontStatus 用户在前台看到的订单状态
@@ -42,4 +31,40 @@ var OrderFrontStatus = map[int]string{
 	OrderStatusUserQuit:       "已取消",
 	OrderStatusUnpaidClose:    "已取消",
 	OrderStatusMerchantClose:  "已取消",
+	OrderStatusRefunding:      "退款中",
+	OrderStatusRefunded:       "已退款",
+}
+
+// OrderStatusName 订单状态的名称, 用于展示订单的状态变更记录
+var OrderStatusName = map[int]string{
+	OrderStatusCreated:        "已创建",
+	OrderStatusUnPaid:         "待支付",
+	OrderStatusPaid:           "已支付",
+	OrderStatusChecked:        "检货完成",
+	OrderStatusShipped:        "已发货",
+	OrderStatusOnDelivery:     "配送中",
+	OrderStatusDelivered:      "已送达",
+	OrderStatusConfirmReceipt: "已确认收货",
+	OrderStatusCompleted:      "订单完成",
+	OrderStatusUserQuit:       "用户取消",
+	OrderStatusUnpaidClose:    "超时未支付关闭",
+	OrderStatusMerchantClose:  "商家关闭",
+	OrderStatusRefunding:      "退款中",
+	OrderStatusRefunded:       "已退款",
+}
+
+// 触发订单状态变更的操作方
+const (
+	OrderActorUser     = iota + 1 // 用户
+	OrderActorMerchant            // 商家
+	OrderActorSystem              // 系统 -- 定时任务等自动执行的操作
+	OrderActorPayment             // 支付平台 -- 支付、退款结果
+)
+
+// OrderActorName 订单状态变更操作方的名称
+var OrderActorName = map[int]string{
+	OrderActorUser:     "用户",
+	OrderActorMerchant: "商家",
+	OrderActorSystem:   "系统",
+	OrderActorPayment:  "支付平台",
 }
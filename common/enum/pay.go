@@ -0,0 +1,28 @@
+package enum
+
+const (
+	PayStateNotInitiated = iota
+	PayStateUnPaid
+	PayStatePaid
+	PayStatePayFailed
+	PayStateRefunding // 退款中
+	PayStateRefunded  // 已退款
+)
+
+const (
+	PayTypeNotConfirmed = iota // 未确认 -- 创建订单时的初始状态
+	PayTypeWxPay               // 微信支付
+	PayTypeAliPay              // 支付宝
+)
+
+// 微信支付网关, 通过配置 app.wechat_pay.gateway 选择
+const (
+	WxPayGatewayWechat  = "wechat"  // 调用微信支付的接口
+	WxPayGatewaySandbox = "sandbox" // 本地沙箱网关, 不访问外部网络, 用于开发和测试环境
+)
+
+// 支付宝网关, 通过配置 app.ali_pay.gateway 选择
+const (
+	AliPayGatewayAlipay  = "alipay"  // 调用支付宝开放平台的接口
+	AliPayGatewaySandbox = "sandbox" // 本地沙箱网关, 不访问外部网络, 用于开发和测试环境
+)
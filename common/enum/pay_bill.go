@@ -0,0 +1,17 @@
+package enum
+
+// 支付账单对账发现的差异类型
+const (
+	PayBillMismatchLocalMissing   = iota + 1 // 支付平台的账单中有这笔交易, 本地没有对应的已支付订单
+	PayBillMismatchChannelMissing            // 本地订单已支付, 支付平台的账单中没有这笔交易
+	PayBillMismatchAmount                    // 支付金额不一致
+	PayBillMismatchStatus                    // 支付状态或支付平台交易号不一致
+)
+
+// PayBillMismatchName 对账差异类型的名称
+var PayBillMismatchName = map[int]string{
+	PayBillMismatchLocalMissing:   "本地缺失",
+	PayBillMismatchChannelMissing: "渠道缺失",
+	PayBillMismatchAmount:         "金额不一致",
+	PayBillMismatchStatus:         "状态不一致",
+}
@@ -0,0 +1,20 @@
+package enum
+
+// 参与叠加计算的优惠, 优惠叠加规则中用这些名称配置叠加顺序和互斥关系
+const (
+	PromotionVip      = "vip"      // 会员折扣
+	PromotionCoupon   = "coupon"   // 优惠券
+	PromotionDiscount = "discount" // 满减活动
+)
+
+var PromotionName = map[string]string{
+	PromotionVip:      "会员折扣",
+	PromotionCoupon:   "优惠券",
+	PromotionDiscount: "满减活动",
+}
+
+// 优惠计算减免时使用的基准金额
+const (
+	PromotionBaseOriginal = "original" // 商品原价
+	PromotionBaseReduced  = "reduced"  // 减去前面各项优惠后的金额
+)
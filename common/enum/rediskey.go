@@ -14,3 +14,32 @@ const (
 	REDISKEY_TOKEN_REFRESH_LOCK  = "GOMALL:USER:TOKEN_REFRESH_LOCk_%s"
 	REDISKEY_PASSWORDRESET_TOKEN = "GOMALL:USER:PASSWORD_RESET_TOKEN_%s"
 )
+
+const (
+	REDIS_KEY_ORDER_CLOSE_QUEUE        = "GOMALL:ORDER:UNPAID_CLOSE_QUEUE"
+	REDIS_KEY_ORDER_UNPAID_SCAN_LOCK   = "GOMALL:ORDER:UNPAID_SCAN_LOCK"
+	REDIS_KEY_ORDER_PAY_RECONCILE_LOCK = "GOMALL:ORDER:PAY_RECONCILE_LOCK"
+	REDIS_KEY_PAY_BILL_CHECK_LOCK      = "GOMALL:PAY_BILL:CHECK_LOCK:%d:%s" // 支付方式:账单日期
+	REDIS_KEY_ORDER_PAY_LOCK           = "GOMALL:ORDER:PAY_LOCK_%d_%s"      // 用户ID_订单号
+	REDIS_KEY_ORDER_PREPAY             = "GOMALL:ORDER:PREPAY_%d_%s"        // 用户ID_订单号
+)
+
+const (
+	REDIS_KEY_IDEMPOTENCY = "GOMALL:IDEMPOTENCY:%d_%s" // 用户ID_请求方法、路径和幂等键的摘要
+)
+
+const (
+	REDIS_KEY_ID_WORKER_LEASE   = "GOMALL:IDGEN:WORKER_LEASE_%d"   // 机器ID
+	REDIS_KEY_ID_WORKER_LAST_MS = "GOMALL:IDGEN:WORKER_LAST_MS_%d" // 机器ID
+)
+
+const (
+	REDIS_KEY_FLASH_SALE_STOCK       = "GOMALL:FLASH_SALE:STOCK_%d"       // 场次ID, 场次剩余的秒杀库存
+	REDIS_KEY_FLASH_SALE_USER_BOUGHT = "GOMALL:FLASH_SALE:USER_BOUGHT_%d" // 场次ID, 哈希表 用户ID => 已抢购的数量
+	REDIS_KEY_FLASH_SALE_QUEUE       = "GOMALL:FLASH_SALE:ORDER_QUEUE"    // 等待创建订单的秒杀请求
+	REDIS_KEY_FLASH_SALE_PENDING     = "GOMALL:FLASH_SALE:PENDING"        // 哈希表 请求号 => 还没处理完的秒杀请求, 补偿丢失的请求时使用
+	REDIS_KEY_FLASH_SALE_RESULT      = "GOMALL:FLASH_SALE:RESULT_%s"      // 请求号, 秒杀请求的处理结果
+	REDIS_KEY_FLASH_SALE_JOB_LOCK    = "GOMALL:FLASH_SALE:JOB_LOCK_%s"    // 任务名
+
+	REDIS_KEY_GROUP_BUY_EXPIRE_SCAN_LOCK = "GOMALL:GROUP_BUY:EXPIRE_SCAN_LOCK"
+)
@@ -0,0 +1,18 @@
+package enum
+
+const (
+	RefundStatusApplied    = iota // 用户已申请, 待商家审核
+	RefundStatusRejected          // 商家拒绝退款
+	RefundStatusProcessing        // 商家同意后已向支付平台发起退款, 等待退款结果
+	RefundStatusSuccess           // 退款成功
+	RefundStatusFailed            // 退款失败
+)
+
+// RefundFrontStatus 用户在前台看到的退款状态
+var RefundFrontStatus = map[int]string{
+	RefundStatusApplied:    "待审核",
+	RefundStatusRejected:   "已拒绝",
+	RefundStatusProcessing: "退款中",
+	RefundStatusSuccess:    "已退款",
+	RefundStatusFailed:     "退款失败",
+}
@@ -0,0 +1,18 @@
+package enum
+
+const (
+	ShipmentStatusShipped   = iota // 已发货, 运输中
+	ShipmentStatusDelivered        // 已送达
+)
+
+// ShipmentCarrierName 支持的物流公司, Key 为商家发货时填写的物流公司编码
+var ShipmentCarrierName = map[string]string{
+	"SF":   "顺丰速运",
+	"JD":   "京东物流",
+	"EMS":  "中国邮政EMS",
+	"ZTO":  "中通快递",
+	"YTO":  "圆通速递",
+	"STO":  "申通快递",
+	"YD":   "韵达速递",
+	"JTSD": "极兔速递",
+}
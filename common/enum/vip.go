@@ -0,0 +1,6 @@
+package enum
+
+import "time"
+
+// VipPermanentExpireAt 有效天数为 0 的会员等级的过期时间, 表示永久有效
+var VipPermanentExpireAt = time.Date(9999, 12, 31, 23, 59, 59, 0, time.Local)
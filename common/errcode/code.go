@@ -15,6 +15,8 @@ var (
 	ErrForbidden       = newError(10000005, "未授权") // 访问一些未授权的资源时的错误
 	ErrTooManyRequests = newError(10000006, "请求过多")
 	ErrCoverData       = newError(10000007, "ConvertDataError") // 数据转换错误
+	ErrRequestInFlight = newError(10000008, "请求正在处理中, 请勿重复提交")  // 相同 Idempotency-Key 的请求还没有处理完
+	ErrIdempotencyKey  = newError(10000009, "Idempotency-Key 无效或已被其他请求使用")
 )
 
 // 各个业务模块自定义的错误码, 从 10000100 开始, 可以按照不同的业务模块划分不同的号段
@@ -44,6 +46,18 @@ var (
 	ErrOrderParams              = newError(10000500, "订单参数异常")
 	ErrOrderCanNotBeChanged     = newError(10000501, "订单不可修改")
 	ErrOrderUnsupportedPayScene = newError(10000502, "支付场景暂不支持")
+	ErrOrderPayNotifyInvalid    = newError(10000503, "支付结果通知异常")
+	ErrOrderCanNotRefund        = newError(10000504, "订单不可退款")
+	ErrOrderRefundNotExist      = newError(10000505, "退款单不存在")
+	ErrOrderRefundCanNotChanged = newError(10000506, "退款单状态不可修改")
+	ErrOrderRefundItemInvalid   = newError(10000507, "退款商品或数量不正确")
+	ErrOrderCarrierUnsupported  = newError(10000508, "不支持的物流公司")
+	ErrOrderPayInProgress       = newError(10000509, "订单正在发起支付, 请稍后再试")
+	ErrOrderCheckoutInvalid     = newError(10000510, "结算凭证无效或已过期, 请重新确认订单")
+	ErrOrderBillChanged         = newError(10000511, "商品价格或优惠发生变化, 请重新确认订单金额")
+	ErrFreightTemplateParams    = newError(10000512, "运费模板参数异常")
+	ErrFreightTemplateNotExists = newError(10000513, "运费模板不存在")
+	ErrFreightUndeliverable     = newError(10000514, "收货地址不在配送范围内")
 )
 
 // 评价模块相关错误码 10000600 ~ 10000699
@@ -53,6 +67,48 @@ var (
 	ErrReviewUnsupportedScene    = newError(10000602, "评价场景暂不支持")
 )
 
+// 优惠券模块相关错误码 10000700 ~ 10000799
+var (
+	ErrCouponParams      = newError(10000700, "优惠券参数异常")
+	ErrCouponNotExists   = newError(10000701, "优惠券不存在")
+	ErrCouponClaimFailed = newError(10000702, "优惠券已领完或已达到领取上限")
+	ErrCouponUnavailable = newError(10000703, "优惠券不可用")
+)
+
+// 满减活动模块相关错误码 10000800 ~ 10000899
+var (
+	ErrDiscountParams        = newError(10000800, "满减活动参数异常")
+	ErrDiscountNotExists     = newError(10000801, "满减活动不存在")
+	ErrDiscountLimitExceeded = newError(10000802, "已达到满减活动的参加次数上限")
+)
+
+// 会员模块相关错误码 10000900 ~ 10000999
+var (
+	ErrVipTierParams    = newError(10000900, "会员等级参数异常")
+	ErrVipTierNotExists = newError(10000901, "会员等级不存在")
+)
+
+// 秒杀模块相关错误码 10001000 ~ 10001099
+var (
+	ErrFlashSaleParams           = newError(10001000, "秒杀场次参数异常")
+	ErrFlashSaleNotExists        = newError(10001001, "秒杀场次不存在")
+	ErrFlashSaleNotOngoing       = newError(10001002, "秒杀场次不在抢购时间内")
+	ErrFlashSaleStockOut         = newError(10001003, "秒杀商品已抢光")
+	ErrFlashSaleLimitExceeded    = newError(10001004, "超过了秒杀商品的限购数量")
+	ErrFlashSaleRequestNotExists = newError(10001005, "秒杀请求不存在或已过期")
+)
+
+// 拼团模块相关错误码 10001100 ~ 10001199
+var (
+	ErrGroupBuyParams             = newError(10001100, "拼团活动参数异常")
+	ErrGroupBuyActivityNotExists  = newError(10001101, "拼团活动不存在")
+	ErrGroupBuyActivityNotOngoing = newError(10001102, "拼团活动不在进行中")
+	ErrGroupBuyNotExists          = newError(10001103, "拼团不存在")
+	ErrGroupBuyNotJoinable        = newError(10001104, "拼团已结束或人数已满")
+	ErrGroupBuyAlreadyJoined      = newError(10001105, "已经参加了这个拼团")
+	ErrGroupBuyNotSucceeded       = newError(10001106, "拼团还没有成功")
+)
+
 // HttpStatusCode 返回 HTTP 状态码
 func (e *AppError) HttpStatusCode() int {
 	switch e.Code() {
@@ -60,17 +116,26 @@ func (e *AppError) HttpStatusCode() int {
 		return http.StatusOK
 	case ErrServer.Code(), ErrPanic.Code():
 		return http.StatusInternalServerError
-	case ErrParams.Code(), ErrUserInvalid.Code(), ErrUserNameOccupied.Code(), ErrUserNotRight.Code(), ErrPasswordComplexity.Code(),
-		ErrCommodityNotExists.Code(), ErrCommodityStockOut.Code(), ErrCartItemParam.Code(), ErrOrderParams.Code(),
+	case ErrParams.Code(), ErrIdempotencyKey.Code(), ErrUserInvalid.Code(), ErrUserNameOccupied.Code(), ErrUserNotRight.Code(), ErrPasswordComplexity.Code(),
+		ErrCommodityNotExists.Code(), ErrCommodityStockOut.Code(), ErrCartItemParam.Code(), ErrOrderParams.Code(), ErrOrderCheckoutInvalid.Code(),
+		ErrFreightTemplateParams.Code(), ErrFreightUndeliverable.Code(), ErrCouponParams.Code(), ErrCouponUnavailable.Code(),
+		ErrDiscountParams.Code(), ErrDiscountLimitExceeded.Code(), ErrVipTierParams.Code(),
+		ErrFlashSaleParams.Code(), ErrFlashSaleNotOngoing.Code(), ErrFlashSaleLimitExceeded.Code(),
+		ErrGroupBuyParams.Code(), ErrGroupBuyActivityNotOngoing.Code(), ErrGroupBuyNotJoinable.Code(), ErrGroupBuyAlreadyJoined.Code(),
+		ErrOrderPayNotifyInvalid.Code(), ErrOrderRefundItemInvalid.Code(), ErrOrderCarrierUnsupported.Code(),
 		ErrReviewParams.Code(), ErrReviewUnsupportedScene.Code():
 		return http.StatusBadRequest
-	case ErrNotFound.Code():
+	case ErrNotFound.Code(), ErrOrderRefundNotExist.Code(), ErrFreightTemplateNotExists.Code(), ErrCouponNotExists.Code(), ErrDiscountNotExists.Code(), ErrVipTierNotExists.Code(),
+		ErrFlashSaleNotExists.Code(), ErrFlashSaleRequestNotExists.Code(), ErrGroupBuyActivityNotExists.Code(), ErrGroupBuyNotExists.Code():
 		return http.StatusNotFound
+	case ErrRequestInFlight.Code(), ErrOrderPayInProgress.Code(), ErrOrderBillChanged.Code(), ErrCouponClaimFailed.Code(), ErrFlashSaleStockOut.Code():
+		return http.StatusConflict
 	case ErrTooManyRequests.Code():
 		return http.StatusTooManyRequests
 	case ErrToken.Code():
 		return http.StatusUnauthorized
-	case ErrForbidden.Code(), ErrCartWrongUser.Code(), ErrOrderCanNotBeChanged.Code(), ErrReviewStatusCanNotChanged.Code():
+	case ErrForbidden.Code(), ErrCartWrongUser.Code(), ErrOrderCanNotBeChanged.Code(), ErrOrderCanNotRefund.Code(),
+		ErrOrderRefundCanNotChanged.Code(), ErrReviewStatusCanNotChanged.Code(), ErrGroupBuyNotSucceeded.Code():
 		return http.StatusForbidden
 	default:
 		return http.StatusInternalServerError
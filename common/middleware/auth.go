@@ -2,9 +2,11 @@ package middleware
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
 
 	"github.com/hd2yao/go-mall/common/app"
 	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/config"
 	"github.com/hd2yao/go-mall/logic/domainservice"
 )
 
@@ -41,3 +43,16 @@ func AuthUser() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// AuthAdmin 验证登录的用户是管理员, 需要放在 AuthUser 之后使用
+// 管理员的用户 ID 在配置 app.admin.user_ids 中设置, 没有配置时所有用户都不能访问管理后台的接口
+func AuthAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !lo.Contains(config.App.Admin.UserIds, c.GetInt64("user_id")) {
+			app.NewResponse(c).Error(errcode.ErrForbidden)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
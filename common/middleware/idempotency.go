@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/hd2yao/go-mall/common/app"
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/logger"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/dal/cache"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+// 接口幂等相关的中间件
+
+// Idempotent 按请求头中的 Idempotency-Key 保证接口的幂等, 需要放在 AuthUser 之后使用
+// 同一个用户用同一个幂等键请求同一个接口时, 只有第一次请求会被处理, 它的响应保存在 Redis 中, 之后的请求直接重放这个响应
+// 第一次请求还没处理完时重复的请求返回 ErrRequestInFlight; 第一次请求出现服务端错误等临时错误时不保存响应, 客户端可以用同一个幂等键重试
+// 没有传 Idempotency-Key 的请求不做幂等处理
+func Idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idempotencyKey := c.Request.Header.Get(enum.IdempotencyKeyHeader)
+		if idempotencyKey == "" {
+			c.Next()
+			return
+		}
+		if len(idempotencyKey) > enum.IdempotencyKeyMaxLength {
+			app.NewResponse(c).Error(errcode.ErrIdempotencyKey)
+			c.Abort()
+			return
+		}
+
+		log := logger.New(c)
+		userId := c.GetInt64("user_id")
+		keyDigest := util.SHA256HashString(c.Request.Method + " " + c.Request.URL.Path + " " + idempotencyKey)
+		requestHash := util.SHA256HashString(string(requestBody(c)))
+		record := &do.IdempotencyRecord{
+			Status:      enum.IdempotencyStatusProcessing,
+			RequestHash: requestHash,
+		}
+		locked, err := cache.SetIdempotencyRecordNX(c, userId, keyDigest, record, enum.IdempotencyProcessingDuration)
+		if err != nil {
+			app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+			c.Abort()
+			return
+		}
+		if !locked {
+			replayIdempotentResponse(c, userId, keyDigest, requestHash)
+			c.Abort()
+			return
+		}
+
+		// 和 LogAccess 一样替换 ResponseWriter 拦截响应数据
+		blw := &bodyLogWriter{body: bytes.NewBufferString(""), ResponseWriter: c.Writer}
+		c.Writer = blw
+		c.Next()
+
+		if isTransientStatus(blw.Status()) {
+			if err = cache.DelIdempotencyRecord(c, userId, keyDigest); err != nil {
+				log.Error("DelIdempotencyRecordError", "idempotencyKey", idempotencyKey, "err", err)
+			}
+			return
+		}
+		record.Status = enum.IdempotencyStatusDone
+		record.HttpStatus = blw.Status()
+		record.ContentType = blw.Header().Get("Content-Type")
+		record.Body = blw.body.String()
+		if err = cache.SetIdempotencyRecord(c, userId, keyDigest, record, enum.IdempotencyResponseDuration); err != nil {
+			log.Error("SetIdempotencyRecordError", "idempotencyKey", idempotencyKey, "err", err)
+		}
+	}
+}
+
+// isTransientStatus 是否为重试后可能成功的临时错误, 这些响应不保存
+func isTransientStatus(status int) bool {
+	return status >= http.StatusInternalServerError || status == http.StatusConflict || status == http.StatusTooManyRequests
+}
+
+// replayIdempotentResponse 重放幂等键对应的第一次请求的响应
+func replayIdempotentResponse(c *gin.Context, userId int64, keyDigest, requestHash string) {
+	record, err := cache.GetIdempotencyRecord(c, userId, keyDigest)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+	if record == nil || record.Status == enum.IdempotencyStatusProcessing {
+		// 记录为空时第一次请求刚好处理失败删除了记录, 让客户端稍后重试
+		app.NewResponse(c).Error(errcode.ErrRequestInFlight)
+		return
+	}
+	if record.RequestHash != requestHash {
+		app.NewResponse(c).Error(errcode.ErrIdempotencyKey)
+		return
+	}
+	c.Header(enum.IdempotencyReplayedHeader, "true")
+	c.Data(record.HttpStatus, record.ContentType, []byte(record.Body))
+}
+
+// requestBody 获取请求体, LogAccess 中已经读取过请求体时直接从 context 中获取
+func requestBody(c *gin.Context) []byte {
+	if body, exists := c.Get("reqBody"); exists {
+		return body.([]byte)
+	}
+	reqBody, _ := io.ReadAll(io.LimitReader(c.Request.Body, 10<<20))
+	c.Set("reqBody", reqBody)
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(reqBody))
+	return reqBody
+}
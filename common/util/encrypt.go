@@ -5,6 +5,7 @@ import (
 	"crypto"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	cryptoRand "crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -99,6 +100,23 @@ func RsaSignPKCS1v15(msg, privateKey []byte, hashType crypto.Hash) ([]byte, erro
 	return sign, nil
 }
 
+// RsaVerifyPKCS1v15 使用公钥验证消息散列值的数字签名
+func RsaVerifyPKCS1v15(msg, sign, publicKey []byte, hashType crypto.Hash) error {
+	block, _ := pem.Decode(publicKey)
+	if block == nil {
+		return errors.New("public key decode error")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return errors.New("parse public key error")
+	}
+	key, ok := pub.(*rsa.PublicKey)
+	if ok == false {
+		return errors.New("public key format error")
+	}
+	return rsa.VerifyPKCS1v15(key, hashType, msg, sign)
+}
+
 // SHA256HashString 对字符串消息进行 sha256 哈希
 func SHA256HashString(stringMessage string) string {
 	message := []byte(stringMessage) //字符串转化字节数组
@@ -122,3 +140,10 @@ func SHA256HashBytes(stringMessage string) []byte {
 	bytes := hash.Sum(nil)
 	return bytes
 }
+
+// HmacSHA256HashString 用 key 对字符串消息计算 HMAC-SHA256, 返回十六进制编码的字符串
+func HmacSHA256HashString(stringMessage, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(stringMessage))
+	return hex.EncodeToString(mac.Sum(nil))
+}
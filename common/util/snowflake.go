@@ -0,0 +1,108 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hd2yao/go-mall/common/enum"
+)
+
+// 雪花算法生成的序列号的位分配: 当天的毫秒数(27 位) + 机器ID(8 位) + 毫秒内的序号(11 位)
+// 日期放在单号的前缀中, 序列号只需要表示当天内的时间, 46 位的序列号最多 14 位十进制数字,
+// 生成的单号和原来的单号一样是 日期(8 位) + 序列号(14 位) + 用户分片后缀(4 位) 共 26 位, 按字符串排序即按生成时间排序
+const (
+	snowflakeWorkerBits   = 8
+	snowflakeSequenceBits = 11
+	SnowflakeMaxWorkerId  = 1<<snowflakeWorkerBits - 1
+	snowflakeMaxSequence  = 1<<snowflakeSequenceBits - 1
+	// 时钟回拨不超过这个时间时等待时钟追上, 超过时返回 ErrClockBackwards
+	snowflakeMaxBackward = 10 * time.Millisecond
+	// 单号末尾的用户分片后缀的位数, 按用户ID分库分表时可以直接从单号中得到分片
+	userShardDigits = 4
+	userShardMod    = 10000
+)
+
+var ErrClockBackwards = errors.New("snowflake: clock moved backwards")
+
+// Snowflake 雪花算法序列号生成器, 同一个机器ID同一时间只能有一个生成器在使用
+type Snowflake struct {
+	mu       sync.Mutex
+	workerId int64
+	lastMs   int64 // 上一次生成序列号的毫秒时间戳
+	sequence int64
+}
+
+// NewSnowflake 创建机器ID为 workerId 的生成器, lastMs 为这个机器ID上一次生成序列号的毫秒时间戳
+// 当前时间早于 lastMs 时(服务重启前后发生了时钟回拨)不会生成序列号, 避免生成重复的单号
+func NewSnowflake(workerId int64, lastMs int64) (*Snowflake, error) {
+	if workerId < 0 || workerId > SnowflakeMaxWorkerId {
+		return nil, fmt.Errorf("snowflake: worker id %d out of range [0, %d]", workerId, SnowflakeMaxWorkerId)
+	}
+	return &Snowflake{workerId: workerId, lastMs: lastMs}, nil
+}
+
+// WorkerId 生成器的机器ID
+func (s *Snowflake) WorkerId() int64 {
+	return s.workerId
+}
+
+// LastMs 生成器上一次生成序列号的毫秒时间戳
+func (s *Snowflake) LastMs() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastMs
+}
+
+// NextNo 生成单号: 日期 + 序列号 + 用户分片后缀
+func (s *Snowflake) NextNo(userId int64) (string, error) {
+	ms, sequence, err := s.next()
+	if err != nil {
+		return "", err
+	}
+	t := time.UnixMilli(ms)
+	dayStart := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	id := (ms-dayStart.UnixMilli())<<(snowflakeWorkerBits+snowflakeSequenceBits) | s.workerId<<snowflakeSequenceBits | sequence
+	return fmt.Sprintf("%s%014d%0*d", t.Format(enum.TimeFormatYMD), id, userShardDigits, UserShard(userId)), nil
+}
+
+// UserShard 用户的分片号, 即单号末尾的用户分片后缀
+func UserShard(userId int64) int64 {
+	if userId < 0 {
+		userId = -userId
+	}
+	return userId % userShardMod
+}
+
+// next 返回生成序列号的毫秒时间戳和毫秒内的序号
+func (s *Snowflake) next() (ms int64, sequence int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	if now < s.lastMs {
+		backward := time.Duration(s.lastMs-now) * time.Millisecond
+		if backward > snowflakeMaxBackward {
+			return 0, 0, ErrClockBackwards
+		}
+		time.Sleep(backward)
+		if now = time.Now().UnixMilli(); now < s.lastMs {
+			return 0, 0, ErrClockBackwards
+		}
+	}
+	if now == s.lastMs {
+		s.sequence = (s.sequence + 1) & snowflakeMaxSequence
+		if s.sequence == 0 {
+			// 这一毫秒的序号用完了, 等到下一毫秒
+			for now <= s.lastMs {
+				time.Sleep(100 * time.Microsecond)
+				now = time.Now().UnixMilli()
+			}
+		}
+	} else {
+		s.sequence = 0
+	}
+	s.lastMs = now
+	return now, s.sequence, nil
+}
@@ -22,13 +22,61 @@ type appConfig struct {
 		DefaultSize int `mapstructure:"default_size"`
 		MaxSize     int `mapstructure:"max_size"`
 	}
-	WechatPay struct {
-		AppId           string `mapstructure:"appid"`
-		MchId           string `mapstructure:"mchid"`
-		PrivateSerialNo string `mapstructure:"private_serial_no"`
-		AesKey          string `mapstructur:"aes_key""`
-		NotifyUrl       string `mapstructur:"notify_url"`
+	Admin struct {
+		UserIds []int64 `mapstructure:"user_ids"` // 可以访问管理后台接口的用户 ID
 	}
+	WechatPay struct {
+		AppId                 string `mapstructure:"appid"`
+		MchId                 string `mapstructure:"mchid"`
+		PrivateSerialNo       string `mapstructure:"private_serial_no"`
+		AesKey                string `mapstructure:"aes_key"`
+		NotifyUrl             string `mapstructure:"notify_url"`
+		RefundNotifyUrl       string `mapstructure:"refund_notify_url"`
+		Gateway               string `mapstructure:"gateway"` // 支付网关 wechat-微信支付 sandbox-本地沙箱
+		ApiBaseUrl            string `mapstructure:"api_base_url"`
+		PrivateKeyPath        string `mapstructure:"private_key_path"`
+		PlatformPublicKeyPath string `mapstructure:"platform_public_key_path"`
+	} `mapstructure:"wechat_pay"`
+	Order struct {
+		UnpaidCloseTimeout     time.Duration `mapstructure:"unpaid_close_timeout"`      // 未支付订单自动关闭的超时时间
+		CloseQueueInterval     time.Duration `mapstructure:"close_queue_interval"`      // 轮询关单延迟队列的间隔
+		UnpaidScanInterval     time.Duration `mapstructure:"unpaid_scan_interval"`      // 从数据库扫描超时未支付订单的间隔
+		AutoConfirmReceiptDays int           `mapstructure:"auto_confirm_receipt_days"` // 已送达的订单超过这个天数没有确认收货时自动确认收货
+		ReviewWindowDays       int           `mapstructure:"review_window_days"`        // 评价期天数, 确认收货超过这个天数的订单自动完成
+		AutoFinishScanInterval time.Duration `mapstructure:"auto_finish_scan_interval"` // 扫描需要自动确认收货、自动完成的订单的间隔
+		UnpaidReconcileAfter   time.Duration `mapstructure:"unpaid_reconcile_after"`    // 发起支付超过这个时间还没收到支付结果的订单主动向支付平台查询
+		PayReconcileInterval   time.Duration `mapstructure:"pay_reconcile_interval"`    // 支付对账任务的执行间隔
+		PayBillCheckHour       int           `mapstructure:"pay_bill_check_hour"`       // 每天这个时间(点)之后下载前一天的交易账单对账
+		PayBillCheckInterval   time.Duration `mapstructure:"pay_bill_check_interval"`   // 检查是否需要执行账单对账的间隔
+		CheckoutTokenSecret    string        `mapstructure:"checkout_token_secret"`     // 结算凭证的签名密钥
+		CheckoutTokenTTL       time.Duration `mapstructure:"checkout_token_ttl"`        // 结算凭证的有效期
+	} `mapstructure:"order"`
+	Promotion struct {
+		Stacking []struct {
+			Name string `mapstructure:"name"` // 优惠名称 vip-会员折扣 coupon-优惠券 discount-满减活动
+			Base string `mapstructure:"base"` // 计算减免的基准金额 original-商品原价 reduced-减去前面各项优惠后的金额
+		} `mapstructure:"stacking"` // 优惠的叠加顺序, 结算时按顺序依次计算每项优惠的减免, 没有配置的优惠不参与结算
+		Exclusions [][]string `mapstructure:"exclusions"` // 互斥的优惠, 同一组中按叠加顺序只使用第一项有减免的优惠
+	} `mapstructure:"promotion"`
+	FlashSale struct {
+		QueueInterval      time.Duration `mapstructure:"queue_interval"`      // 从下单队列中取秒杀请求创建订单的间隔
+		CompensateInterval time.Duration `mapstructure:"compensate_interval"` // 补偿秒杀库存、结束过期场次的任务的执行间隔
+		RequestTimeout     time.Duration `mapstructure:"request_timeout"`     // 秒杀请求超过这个时间还没处理完时认为请求已丢失, 退还预扣的库存
+		ResultTTL          time.Duration `mapstructure:"result_ttl"`          // 秒杀请求的处理结果在 Redis 中的保存时间
+	} `mapstructure:"flash_sale"`
+	GroupBuy struct {
+		ExpireScanInterval time.Duration `mapstructure:"expire_scan_interval"` // 处理超过成团时限的拼团的任务的执行间隔
+	} `mapstructure:"group_buy"`
+	AliPay struct {
+		AppId      string `mapstructure:"appid"`
+		GatewayUrl string `mapstructure:"gateway_url"`
+		NotifyUrl  string `mapstructure:"notify_url"`
+		ReturnUrl  string `mapstructure:"return_url"`
+		Gateway    string `mapstructure:"gateway"` // 支付网关 alipay-支付宝 sandbox-本地沙箱
+
+		PrivateKeyPath      string `mapstructure:"private_key_path"`
+		AliPayPublicKeyPath string `mapstructure:"alipay_public_key_path"`
+	} `mapstructure:"ali_pay"`
 }
 
 // Database 配置
@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+// 秒杀场次的库存和用户已抢购的数量保存在 Redis 中, 抢购时用 Lua 脚本原子地预扣库存并把请求放入下单队列
+// 后台任务从下单队列中取出请求创建订单, 客户端用请求号轮询处理结果
+
+// 库存还没加载时才加载, 同时用数据库中的下单记录重建用户已抢购的数量
+var warmUpFlashSaleStockScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1])
+redis.call('EXPIREAT', KEYS[1], ARGV[2])
+redis.call('DEL', KEYS[2])
+for i = 3, #ARGV, 2 do
+	redis.call('HSET', KEYS[2], ARGV[i], ARGV[i + 1])
+end
+redis.call('EXPIREAT', KEYS[2], ARGV[2])
+return 1
+`)
+
+// WarmUpFlashSaleStock 把场次的剩余库存和用户已抢购的数量加载到 Redis, 已经加载过时不做任何修改
+// 缓存在 expireAt 时过期, 返回 false 时表示库存已经加载过
+func WarmUpFlashSaleStock(ctx context.Context, sessionId int64, stock int, userBought map[int64]int, expireAt time.Time) (bool, error) {
+	keys := []string{
+		fmt.Sprintf(enum.REDIS_KEY_FLASH_SALE_STOCK, sessionId),
+		fmt.Sprintf(enum.REDIS_KEY_FLASH_SALE_USER_BOUGHT, sessionId),
+	}
+	args := []interface{}{stock, expireAt.Unix()}
+	for userId, num := range userBought {
+		args = append(args, userId, num)
+	}
+	warmed, err := warmUpFlashSaleStockScript.Run(ctx, Redis(), keys, args...).Int()
+	return warmed == 1, err
+}
+
+// 检查库存和用户的限购数量, 都满足时预扣库存、累加用户已抢购的数量, 并把请求放入下单队列和待处理的请求中
+// 返回值的含义见 enum.FlashSaleDeduct*
+var deductFlashSaleStockScript = redis.NewScript(`
+local stock = redis.call('GET', KEYS[1])
+if not stock then
+	return -2
+end
+local num = tonumber(ARGV[2])
+if tonumber(stock) < num then
+	return 0
+end
+local bought = tonumber(redis.call('HGET', KEYS[2], ARGV[1]) or '0')
+if bought + num > tonumber(ARGV[3]) then
+	return -1
+end
+redis.call('DECRBY', KEYS[1], num)
+redis.call('HINCRBY', KEYS[2], ARGV[1], num)
+redis.call('EXPIREAT', KEYS[2], ARGV[8])
+redis.call('LPUSH', KEYS[3], ARGV[5])
+redis.call('HSET', KEYS[4], ARGV[4], ARGV[5])
+redis.call('SET', KEYS[5], ARGV[6], 'EX', ARGV[7])
+return 1
+`)
+
+// DeductFlashSaleStock 预扣秒杀库存并把请求放入下单队列, 返回预扣结果 enum.FlashSaleDeduct*
+// perUserLimit 为每个用户最多抢购的数量, 排队中的处理结果保存 resultTTL, 用户已抢购数量的缓存在 expireAt 时过期
+func DeductFlashSaleStock(ctx context.Context, request *do.FlashSaleRequest, perUserLimit int, resultTTL time.Duration, expireAt time.Time) (int, error) {
+	requestBytes, err := json.Marshal(request)
+	if err != nil {
+		return 0, err
+	}
+	resultBytes, err := json.Marshal(&do.FlashSaleResult{
+		RequestNo: request.RequestNo,
+		UserId:    request.UserId,
+		Status:    enum.FlashSaleRequestQueued,
+	})
+	if err != nil {
+		return 0, err
+	}
+	keys := []string{
+		fmt.Sprintf(enum.REDIS_KEY_FLASH_SALE_STOCK, request.SessionId),
+		fmt.Sprintf(enum.REDIS_KEY_FLASH_SALE_USER_BOUGHT, request.SessionId),
+		enum.REDIS_KEY_FLASH_SALE_QUEUE,
+		enum.REDIS_KEY_FLASH_SALE_PENDING,
+		fmt.Sprintf(enum.REDIS_KEY_FLASH_SALE_RESULT, request.RequestNo),
+	}
+	return deductFlashSaleStockScript.Run(ctx, Redis(), keys, request.UserId, request.CommodityNum, perUserLimit,
+		request.RequestNo, requestBytes, resultBytes, int64(resultTTL.Seconds()), expireAt.Unix()).Int()
+}
+
+// 库存还在 Redis 中时加回库存, 同时减少用户已抢购的数量; 库存已经不在 Redis 中时会在重新加载时按数据库计算
+var returnFlashSaleStockScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('INCRBY', KEYS[1], ARGV[2])
+end
+local bought = tonumber(redis.call('HGET', KEYS[2], ARGV[1]) or '0')
+if bought > 0 then
+	redis.call('HINCRBY', KEYS[2], ARGV[1], -math.min(bought, tonumber(ARGV[2])))
+end
+return 1
+`)
+
+// ReturnFlashSaleStock 退还预扣的秒杀库存和用户的限购数量
+func ReturnFlashSaleStock(ctx context.Context, sessionId, userId int64, num int) error {
+	keys := []string{
+		fmt.Sprintf(enum.REDIS_KEY_FLASH_SALE_STOCK, sessionId),
+		fmt.Sprintf(enum.REDIS_KEY_FLASH_SALE_USER_BOUGHT, sessionId),
+	}
+	return returnFlashSaleStockScript.Run(ctx, Redis(), keys, userId, num).Err()
+}
+
+// PopFlashSaleRequests 从下单队列中取出最多 limit 个秒杀请求, 按放入队列的顺序取出
+func PopFlashSaleRequests(ctx context.Context, limit int) ([]*do.FlashSaleRequest, error) {
+	requestStrs, err := Redis().RPopCount(ctx, enum.REDIS_KEY_FLASH_SALE_QUEUE, limit).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return unmarshalFlashSaleRequests(requestStrs)
+}
+
+// GetPendingFlashSaleRequests 获取所有还没处理完的秒杀请求
+func GetPendingFlashSaleRequests(ctx context.Context) ([]*do.FlashSaleRequest, error) {
+	requestStrs, err := Redis().HVals(ctx, enum.REDIS_KEY_FLASH_SALE_PENDING).Result()
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalFlashSaleRequests(requestStrs)
+}
+
+func unmarshalFlashSaleRequests(requestStrs []string) ([]*do.FlashSaleRequest, error) {
+	requests := make([]*do.FlashSaleRequest, 0, len(requestStrs))
+	for _, requestStr := range requestStrs {
+		request := new(do.FlashSaleRequest)
+		if err := json.Unmarshal([]byte(requestStr), request); err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, nil
+}
+
+// 保存处理结果和把请求从待处理的请求中删除在一个 Lua 脚本中执行
+var finishFlashSaleRequestScript = redis.NewScript(`
+redis.call('SET', KEYS[2], ARGV[2], 'EX', ARGV[3])
+return redis.call('HDEL', KEYS[1], ARGV[1])
+`)
+
+// FinishFlashSaleRequest 保存秒杀请求的处理结果, 并把请求从待处理的请求中删除
+func FinishFlashSaleRequest(ctx context.Context, result *do.FlashSaleResult, resultTTL time.Duration) error {
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	keys := []string{
+		enum.REDIS_KEY_FLASH_SALE_PENDING,
+		fmt.Sprintf(enum.REDIS_KEY_FLASH_SALE_RESULT, result.RequestNo),
+	}
+	return finishFlashSaleRequestScript.Run(ctx, Redis(), keys, result.RequestNo, resultBytes, int64(resultTTL.Seconds())).Err()
+}
+
+// GetFlashSaleResult 获取秒杀请求的处理结果, 结果已经过期或者请求不存在时返回 nil
+func GetFlashSaleResult(ctx context.Context, requestNo string) (*do.FlashSaleResult, error) {
+	resultBytes, err := Redis().Get(ctx, fmt.Sprintf(enum.REDIS_KEY_FLASH_SALE_RESULT, requestNo)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	result := new(do.FlashSaleResult)
+	if err = json.Unmarshal(resultBytes, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// LockFlashSaleJob 获取秒杀后台任务的锁, 锁在 ttl 后自动过期, 不需要释放
+// 多个服务实例在 ttl 时间内只有一个执行这个任务
+func LockFlashSaleJob(ctx context.Context, jobName string, ttl time.Duration) (bool, error) {
+	return Redis().SetNX(ctx, fmt.Sprintf(enum.REDIS_KEY_FLASH_SALE_JOB_LOCK, jobName), "locked", ttl).Result()
+}
+
+// GetFlashSaleStock 获取 Redis 中场次的剩余库存, 库存还没加载时返回 -1
+func GetFlashSaleStock(ctx context.Context, sessionId int64) (int, error) {
+	stockStr, err := Redis().Get(ctx, fmt.Sprintf(enum.REDIS_KEY_FLASH_SALE_STOCK, sessionId)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return -1, nil
+		}
+		return 0, err
+	}
+	return strconv.Atoi(stockStr)
+}
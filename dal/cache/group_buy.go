@@ -0,0 +1,14 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/hd2yao/go-mall/common/enum"
+)
+
+// LockGroupBuyExpireScan 获取处理过期拼团的锁, 锁在 ttl 后自动过期, 不需要释放
+// 多个服务实例在 ttl 时间内只有一个执行, 避免为同一个成员重复关闭订单或发起退款
+func LockGroupBuyExpireScan(ctx context.Context, ttl time.Duration) (bool, error) {
+	return Redis().SetNX(ctx, enum.REDIS_KEY_GROUP_BUY_EXPIRE_SCAN_LOCK, "locked", ttl).Result()
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/hd2yao/go-mall/common/enum"
+)
+
+// 单号生成器的机器ID通过 Redis 租约分配, 每个服务实例启动时获取一个没有被占用的机器ID, 运行期间定时续约
+// 续约时同时记录生成器最后生成单号的时间, 下一个拿到这个机器ID的实例不会生成早于这个时间的单号
+
+// AcquireIdWorker 获取一个空闲的机器ID的租约, 返回机器ID和这个机器ID上一次记录的生成时间(毫秒)
+// 所有机器ID都被占用时返回的机器ID为 -1
+func AcquireIdWorker(ctx context.Context, token string, maxWorkerId int64, ttl time.Duration) (workerId int64, lastMs int64, err error) {
+	for workerId = 0; workerId <= maxWorkerId; workerId++ {
+		leaseKey := fmt.Sprintf(enum.REDIS_KEY_ID_WORKER_LEASE, workerId)
+		locked, err := Redis().SetNX(ctx, leaseKey, token, ttl).Result()
+		if err != nil {
+			return 0, 0, err
+		}
+		if !locked {
+			continue
+		}
+		lastMsStr, err := Redis().Get(ctx, fmt.Sprintf(enum.REDIS_KEY_ID_WORKER_LAST_MS, workerId)).Result()
+		if err != nil && err != redis.Nil {
+			return 0, 0, err
+		}
+		lastMs, _ = strconv.ParseInt(lastMsStr, 10, 64)
+		return workerId, lastMs, nil
+	}
+	return -1, 0, nil
+}
+
+// 租约还属于 token 时才续约, 同时记录最后生成单号的时间
+var renewIdWorkerScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+redis.call('SET', KEYS[2], ARGV[3], 'PX', ARGV[4])
+return 1
+`)
+
+// RenewIdWorker 续约机器ID, 返回 false 时表示租约已经过期并被其他实例获取
+func RenewIdWorker(ctx context.Context, workerId int64, token string, lastMs int64, ttl time.Duration) (bool, error) {
+	keys := []string{
+		fmt.Sprintf(enum.REDIS_KEY_ID_WORKER_LEASE, workerId),
+		fmt.Sprintf(enum.REDIS_KEY_ID_WORKER_LAST_MS, workerId),
+	}
+	renewed, err := renewIdWorkerScript.Run(ctx, Redis(), keys, token, ttl.Milliseconds(), lastMs,
+		enum.IdWorkerLastMsDuration.Milliseconds()).Int()
+	return renewed == 1, err
+}
+
+// 租约还属于 token 时才释放, 释放前记录最后生成单号的时间
+var releaseIdWorkerScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[2], ARGV[2], 'PX', ARGV[3])
+return redis.call('DEL', KEYS[1])
+`)
+
+// ReleaseIdWorker 服务停止时释放机器ID的租约
+func ReleaseIdWorker(ctx context.Context, workerId int64, token string, lastMs int64) error {
+	keys := []string{
+		fmt.Sprintf(enum.REDIS_KEY_ID_WORKER_LEASE, workerId),
+		fmt.Sprintf(enum.REDIS_KEY_ID_WORKER_LAST_MS, workerId),
+	}
+	return releaseIdWorkerScript.Run(ctx, Redis(), keys, token, lastMs, enum.IdWorkerLastMsDuration.Milliseconds()).Err()
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+// SetIdempotencyRecordNX 幂等键还没有记录时保存记录, 返回 false 时表示已经有相同幂等键的请求
+func SetIdempotencyRecordNX(ctx context.Context, userId int64, keyDigest string, record *do.IdempotencyRecord, ttl time.Duration) (bool, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_IDEMPOTENCY, userId, keyDigest)
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return false, err
+	}
+	return Redis().SetNX(ctx, redisKey, recordBytes, ttl).Result()
+}
+
+// SetIdempotencyRecord 保存幂等键的记录, 覆盖已有的记录
+func SetIdempotencyRecord(ctx context.Context, userId int64, keyDigest string, record *do.IdempotencyRecord, ttl time.Duration) error {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_IDEMPOTENCY, userId, keyDigest)
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return Redis().Set(ctx, redisKey, recordBytes, ttl).Err()
+}
+
+// GetIdempotencyRecord 获取幂等键的记录, 没有记录时返回 nil
+func GetIdempotencyRecord(ctx context.Context, userId int64, keyDigest string) (*do.IdempotencyRecord, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_IDEMPOTENCY, userId, keyDigest)
+	recordBytes, err := Redis().Get(ctx, redisKey).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	record := new(do.IdempotencyRecord)
+	if err = json.Unmarshal(recordBytes, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// DelIdempotencyRecord 删除幂等键的记录, 之后相同幂等键的请求会被当作新的请求处理
+func DelIdempotencyRecord(ctx context.Context, userId int64, keyDigest string) error {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_IDEMPOTENCY, userId, keyDigest)
+	return Redis().Del(ctx, redisKey).Err()
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/hd2yao/go-mall/common/enum"
+)
+
+// 超时未支付订单的关单延迟队列, 使用有序集合实现, member 为订单号, score 为订单的关闭时间
+
+// AddOrderToCloseQueue 把订单放入关单延迟队列, 订单已经在队列中时更新它的关闭时间
+func AddOrderToCloseQueue(ctx context.Context, orderNo string, closeAt time.Time) error {
+	return Redis().ZAdd(ctx, enum.REDIS_KEY_ORDER_CLOSE_QUEUE, redis.Z{
+		Score:  float64(closeAt.Unix()),
+		Member: orderNo,
+	}).Err()
+}
+
+// AddOrderToCloseQueueNX 订单不在关单延迟队列中时才放入, 不会修改已经在队列中的订单的关闭时间
+func AddOrderToCloseQueueNX(ctx context.Context, orderNo string, closeAt time.Time) error {
+	return Redis().ZAddNX(ctx, enum.REDIS_KEY_ORDER_CLOSE_QUEUE, redis.Z{
+		Score:  float64(closeAt.Unix()),
+		Member: orderNo,
+	}).Err()
+}
+
+// 取出到期订单和把它们从队列中删除在一个 Lua 脚本中执行, 多个服务实例同时取时每个订单只会被一个实例取到
+var popDueCloseOrdersScript = redis.NewScript(`
+local orderNos = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, orderNo in ipairs(orderNos) do
+	redis.call('ZREM', KEYS[1], orderNo)
+end
+return orderNos
+`)
+
+// PopDueCloseOrders 从关单延迟队列中取出最多 limit 个到了关闭时间的订单号
+func PopDueCloseOrders(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	return popDueCloseOrdersScript.Run(ctx, Redis(), []string{enum.REDIS_KEY_ORDER_CLOSE_QUEUE},
+		strconv.FormatInt(now.Unix(), 10), limit).StringSlice()
+}
+
+// LockUnpaidOrderScan 获取从数据库扫描超时未支付订单的锁, 锁在 ttl 后自动过期, 不需要释放
+// 多个服务实例在 ttl 时间内只有一个能获取到锁
+func LockUnpaidOrderScan(ctx context.Context, ttl time.Duration) (bool, error) {
+	return Redis().SetNX(ctx, enum.REDIS_KEY_ORDER_UNPAID_SCAN_LOCK, "locked", ttl).Result()
+}
+
+// LockPayReconcile 获取支付对账的锁, 锁在 ttl 后自动过期, 不需要释放
+// 多个服务实例在 ttl 时间内只有一个执行对账, 避免重复调用支付平台的查询接口
+func LockPayReconcile(ctx context.Context, ttl time.Duration) (bool, error) {
+	return Redis().SetNX(ctx, enum.REDIS_KEY_ORDER_PAY_RECONCILE_LOCK, "locked", ttl).Result()
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+// LockOrderPay 获取用户发起订单支付的防重锁, 锁的值为本次请求的 token, 锁在 ttl 后自动过期
+// 同一个用户对同一个订单的支付请求同一时间只有一个能向支付平台下单
+func LockOrderPay(ctx context.Context, userId int64, orderNo, token string, ttl time.Duration) (bool, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_ORDER_PAY_LOCK, userId, orderNo)
+	return Redis().SetNX(ctx, redisKey, token, ttl).Result()
+}
+
+// 锁的值和 token 相同时才删除, 防止锁过期后删掉其他请求获取的锁
+var unlockOrderPayScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// UnlockOrderPay 释放用户发起订单支付的防重锁
+func UnlockOrderPay(ctx context.Context, userId int64, orderNo, token string) error {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_ORDER_PAY_LOCK, userId, orderNo)
+	return unlockOrderPayScript.Run(ctx, Redis(), []string{redisKey}, token).Err()
+}
+
+// SetOrderPrepay 缓存订单的预支付信息, ttl 为预支付信息的有效期
+func SetOrderPrepay(ctx context.Context, userId int64, orderNo string, prepay *do.OrderPrepay, ttl time.Duration) error {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_ORDER_PREPAY, userId, orderNo)
+	prepayBytes, err := json.Marshal(prepay)
+	if err != nil {
+		return err
+	}
+	return Redis().Set(ctx, redisKey, prepayBytes, ttl).Err()
+}
+
+// GetOrderPrepay 获取订单缓存的预支付信息, 没有缓存或者已经过期时返回 nil
+func GetOrderPrepay(ctx context.Context, userId int64, orderNo string) (*do.OrderPrepay, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_ORDER_PREPAY, userId, orderNo)
+	prepayBytes, err := Redis().Get(ctx, redisKey).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	prepay := new(do.OrderPrepay)
+	if err = json.Unmarshal(prepayBytes, prepay); err != nil {
+		return nil, err
+	}
+	return prepay, nil
+}
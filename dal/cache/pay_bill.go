@@ -0,0 +1,22 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hd2yao/go-mall/common/enum"
+)
+
+// LockPayBillCheck 获取支付方式 payType 在账单日期 billDate 的账单对账锁, 锁在 ttl 后自动过期
+// 对账成功后不释放锁, 锁同时作为这天的账单已经对过账的标记, 避免多个服务实例重复对账
+func LockPayBillCheck(ctx context.Context, payType int, billDate string, ttl time.Duration) (bool, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_PAY_BILL_CHECK_LOCK, payType, billDate)
+	return Redis().SetNX(ctx, redisKey, "locked", ttl).Result()
+}
+
+// UnlockPayBillCheck 对账失败时释放账单对账锁, 下次检查时重新对账
+func UnlockPayBillCheck(ctx context.Context, payType int, billDate string) error {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_PAY_BILL_CHECK_LOCK, payType, billDate)
+	return Redis().Del(ctx, redisKey).Err()
+}
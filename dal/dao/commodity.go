@@ -173,23 +173,33 @@ func (cd *CommodityDao) ReduceStuckInOrderCreate(tx *gorm.DB, orderItems []*do.O
 
 // RecoverOrderCommodityStuck 用户取消订单后商品减库存
 func (cd *CommodityDao) RecoverOrderCommodityStuck(orderItems []*do.OrderItem) error {
-	err := DBMaster().Transaction(func(tx *gorm.DB) error {
-		for _, orderItem := range orderItems {
-			commodity := new(model.Commodity)
-			tx.Clauses(clause.Locking{Strength: "UPDATE"}).WithContext(cd.ctx).
-				Find(commodity, orderItem.CommodityId)
-			if commodity.ID == 0 {
-				return errcode.ErrNotFound.WithCause(errors.New(fmt.Sprintf("商品未找到, ID: %d", orderItem.CommodityId)))
-			}
-
-			newStock := commodity.StockNum + orderItem.CommodityNum
-			err := tx.WithContext(cd.ctx).Model(commodity).Update("stock_num", newStock).Error
-			if err != nil {
-				return err
-			}
-		}
-		return nil
+	return DBMaster().Transaction(func(tx *gorm.DB) error {
+		return cd.RecoverOrderCommodityStuckInTx(tx, orderItems)
 	})
+}
+
+// RecoverOrderCommodityStuckInTx 在调用方的事务中恢复订单商品的库存, 让库存恢复和订单状态的修改一起提交或回滚
+func (cd *CommodityDao) RecoverOrderCommodityStuckInTx(tx *gorm.DB, orderItems []*do.OrderItem) error {
+	for _, orderItem := range orderItems {
+		commodity := new(model.Commodity)
+		tx.Clauses(clause.Locking{Strength: "UPDATE"}).WithContext(cd.ctx).
+			Find(commodity, orderItem.CommodityId)
+		if commodity.ID == 0 {
+			return errcode.ErrNotFound.WithCause(errors.New(fmt.Sprintf("商品未找到, ID: %d", orderItem.CommodityId)))
+		}
+
+		newStock := commodity.StockNum + orderItem.CommodityNum
+		err := tx.WithContext(cd.ctx).Model(commodity).Update("stock_num", newStock).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	return err
+// GetCategoriesByIds 获取多个分类的信息
+func (cd *CommodityDao) GetCategoriesByIds(categoryIds []int64) ([]*model.CommodityCategory, error) {
+	categories := make([]*model.CommodityCategory, 0)
+	err := DB().WithContext(cd.ctx).Where("id IN (?)", categoryIds).Find(&categories).Error
+	return categories, err
 }
@@ -0,0 +1,193 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/dal/model"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+type CouponDao struct {
+	ctx context.Context
+}
+
+func NewCouponDao(ctx context.Context) *CouponDao {
+	return &CouponDao{ctx: ctx}
+}
+
+// CreateTemplate 创建优惠券模板
+func (cd *CouponDao) CreateTemplate(template *do.CouponTemplate) error {
+	templateModel := new(model.CouponTemplate)
+	if err := util.CopyProperties(templateModel, template); err != nil {
+		return errcode.ErrCoverData.WithCause(err)
+	}
+	if err := DBMaster().WithContext(cd.ctx).Create(templateModel).Error; err != nil {
+		return err
+	}
+	template.ID = templateModel.ID
+	return nil
+}
+
+// UpdateTemplateStatus 更新优惠券模板的发放状态
+func (cd *CouponDao) UpdateTemplateStatus(templateId int64, status int) error {
+	return DBMaster().WithContext(cd.ctx).Model(&model.CouponTemplate{}).
+		Where("id = ?", templateId).
+		Update("status", status).Error
+}
+
+// GetTemplate 获取优惠券模板, 不存在时返回 nil
+func (cd *CouponDao) GetTemplate(templateId int64) (*model.CouponTemplate, error) {
+	template := new(model.CouponTemplate)
+	err := DB().WithContext(cd.ctx).Where("id = ?", templateId).First(template).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return template, err
+}
+
+// GetTemplatesByIds 获取多个优惠券模板
+func (cd *CouponDao) GetTemplatesByIds(templateIds []int64) ([]*model.CouponTemplate, error) {
+	templates := make([]*model.CouponTemplate, 0)
+	err := DB().WithContext(cd.ctx).Unscoped().Where("id IN (?)", templateIds).Find(&templates).Error
+	return templates, err
+}
+
+// GetTemplateList 管理后台分页获取优惠券模板列表
+func (cd *CouponDao) GetTemplateList(offset, returnSize int) (templates []*model.CouponTemplate, totalRows int64, err error) {
+	query := DB().WithContext(cd.ctx).Model(model.CouponTemplate{})
+	err = query.Count(&totalRows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id DESC").
+		Offset(offset).Limit(returnSize).
+		Find(&templates).Error
+	return
+}
+
+// GetClaimableTemplates 获取 now 时可以领取的优惠券模板: 发放中、在有效期内并且还没有领完
+func (cd *CouponDao) GetClaimableTemplates(now time.Time, offset, returnSize int) (templates []*model.CouponTemplate, totalRows int64, err error) {
+	query := DB().WithContext(cd.ctx).Model(model.CouponTemplate{}).
+		Where("status = ? AND valid_start <= ? AND valid_end > ?", enum.CouponTemplateIssuing, now, now).
+		Where("total_num = 0 OR claimed_num < total_num")
+	err = query.Count(&totalRows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("valid_end ASC").
+		Offset(offset).Limit(returnSize).
+		Find(&templates).Error
+	return
+}
+
+// ClaimCoupon 用户领取优惠券, 锁定模板的记录后检查发放状态、发放总量和用户的领取数量
+// 模板不能领取时返回 ErrCouponClaimFailed
+func (cd *CouponDao) ClaimCoupon(templateId, userId int64, now time.Time) (*model.UserCoupon, error) {
+	userCoupon := new(model.UserCoupon)
+	err := DBMaster().WithContext(cd.ctx).Transaction(func(tx *gorm.DB) error {
+		// 锁定模板记录, 同一个模板的领取串行执行, 保证不超发和不超过用户的领取上限
+		template := new(model.CouponTemplate)
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", templateId).First(template).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errcode.ErrCouponNotExists
+			}
+			return err
+		}
+		if template.Status != enum.CouponTemplateIssuing || now.Before(template.ValidStart) || !now.Before(template.ValidEnd) ||
+			(template.TotalNum > 0 && template.ClaimedNum >= template.TotalNum) {
+			return errcode.ErrCouponClaimFailed
+		}
+		var claimedNum int64
+		err = tx.Model(&model.UserCoupon{}).Where("coupon_id = ? AND user_id = ?", templateId, userId).Count(&claimedNum).Error
+		if err != nil {
+			return err
+		}
+		if template.PerUserLimit > 0 && claimedNum >= int64(template.PerUserLimit) {
+			return errcode.ErrCouponClaimFailed
+		}
+
+		err = tx.Model(template).Update("claimed_num", gorm.Expr("claimed_num + 1")).Error
+		if err != nil {
+			return err
+		}
+		userCoupon.CouponId = templateId
+		userCoupon.UserId = userId
+		userCoupon.Status = enum.UserCouponUnused
+		userCoupon.ValidStart = template.ValidStart
+		userCoupon.ValidEnd = template.ValidEnd
+		return tx.Create(userCoupon).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return userCoupon, nil
+}
+
+// GetUserCoupons 分页获取用户的优惠券, status 小于等于 0 时获取全部状态的优惠券
+func (cd *CouponDao) GetUserCoupons(userId int64, status int, offset, returnSize int) (coupons []*model.UserCoupon, totalRows int64, err error) {
+	query := DB().WithContext(cd.ctx).Model(model.UserCoupon{}).Where("user_id = ?", userId)
+	if status > 0 {
+		query = query.Where("status = ?", status)
+	}
+	err = query.Count(&totalRows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id DESC").
+		Offset(offset).Limit(returnSize).
+		Find(&coupons).Error
+	return
+}
+
+// GetUsableUserCoupons 获取用户在 now 时可以使用的优惠券
+func (cd *CouponDao) GetUsableUserCoupons(userId int64, now time.Time) ([]*model.UserCoupon, error) {
+	coupons := make([]*model.UserCoupon, 0)
+	err := DB().WithContext(cd.ctx).
+		Where("user_id = ? AND status = ? AND valid_start <= ? AND valid_end > ?", userId, enum.UserCouponUnused, now, now).
+		Order("valid_end ASC").
+		Find(&coupons).Error
+	return coupons, err
+}
+
+// LockUserCoupon 在创建订单的事务中锁定用户的优惠券, 优惠券已经被使用、锁定或者过期时返回 locked 为 false
+func (cd *CouponDao) LockUserCoupon(tx *gorm.DB, userCouponId, userId int64, orderNo string, now time.Time) (locked bool, err error) {
+	result := tx.WithContext(cd.ctx).Model(&model.UserCoupon{}).
+		Where("id = ? AND user_id = ? AND status = ? AND valid_start <= ? AND valid_end > ?", userCouponId, userId, enum.UserCouponUnused, now, now).
+		Updates(map[string]interface{}{
+			"status":   enum.UserCouponLocked,
+			"order_no": orderNo,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// RedeemOrderCoupon 在订单支付成功的事务中核销订单锁定的优惠券, 订单没有使用优惠券时什么也不做
+func (cd *CouponDao) RedeemOrderCoupon(tx *gorm.DB, orderNo string, usedAt time.Time) error {
+	return tx.WithContext(cd.ctx).Model(&model.UserCoupon{}).
+		Where("order_no = ? AND status = ?", orderNo, enum.UserCouponLocked).
+		Updates(map[string]interface{}{
+			"status":  enum.UserCouponUsed,
+			"used_at": usedAt,
+		}).Error
+}
+
+// ReleaseOrderCoupon 在订单取消或关闭的事务中释放订单锁定的优惠券, 让用户可以再次使用
+func (cd *CouponDao) ReleaseOrderCoupon(tx *gorm.DB, orderNo string) error {
+	return tx.WithContext(cd.ctx).Model(&model.UserCoupon{}).
+		Where("order_no = ? AND status = ?", orderNo, enum.UserCouponLocked).
+		Updates(map[string]interface{}{
+			"status":   enum.UserCouponUnused,
+			"order_no": "",
+		}).Error
+}
@@ -0,0 +1,160 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/dal/model"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+type DiscountDao struct {
+	ctx context.Context
+}
+
+func NewDiscountDao(ctx context.Context) *DiscountDao {
+	return &DiscountDao{ctx: ctx}
+}
+
+// CreateCampaign 创建满减活动和活动的阶梯
+func (dd *DiscountDao) CreateCampaign(campaign *do.DiscountCampaign) error {
+	campaignModel := new(model.DiscountCampaign)
+	if err := util.CopyProperties(campaignModel, campaign); err != nil {
+		return errcode.ErrCoverData.WithCause(err)
+	}
+	return DBMaster().WithContext(dd.ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(campaignModel).Error; err != nil {
+			return err
+		}
+		campaign.ID = campaignModel.ID
+
+		tierModels := make([]*model.DiscountCampaignTier, 0, len(campaign.Tiers))
+		if err := util.CopyProperties(&tierModels, &campaign.Tiers); err != nil {
+			return errcode.ErrCoverData.WithCause(err)
+		}
+		for _, tierModel := range tierModels {
+			tierModel.ID = 0
+			tierModel.CampaignId = campaign.ID
+		}
+		if err := tx.Create(&tierModels).Error; err != nil {
+			return err
+		}
+		for i, tierModel := range tierModels {
+			campaign.Tiers[i].ID = tierModel.ID
+			campaign.Tiers[i].CampaignId = tierModel.CampaignId
+		}
+		return nil
+	})
+}
+
+// UpdateCampaignStatus 更新满减活动的状态
+func (dd *DiscountDao) UpdateCampaignStatus(campaignId int64, status int) error {
+	return DBMaster().WithContext(dd.ctx).Model(&model.DiscountCampaign{}).
+		Where("id = ?", campaignId).
+		Update("status", status).Error
+}
+
+// GetCampaign 获取满减活动, 活动不存在时返回 nil
+func (dd *DiscountDao) GetCampaign(campaignId int64) (*model.DiscountCampaign, error) {
+	campaign := new(model.DiscountCampaign)
+	err := DB().WithContext(dd.ctx).Where("id = ?", campaignId).First(campaign).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return campaign, err
+}
+
+// GetCampaignList 管理后台分页获取满减活动列表
+func (dd *DiscountDao) GetCampaignList(offset, returnSize int) (campaigns []*model.DiscountCampaign, totalRows int64, err error) {
+	query := DB().WithContext(dd.ctx).Model(model.DiscountCampaign{})
+	err = query.Count(&totalRows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id DESC").
+		Offset(offset).Limit(returnSize).
+		Find(&campaigns).Error
+	return
+}
+
+// GetOngoingCampaigns 获取 now 时正在进行的满减活动
+func (dd *DiscountDao) GetOngoingCampaigns(now time.Time) ([]*model.DiscountCampaign, error) {
+	campaigns := make([]*model.DiscountCampaign, 0)
+	err := DB().WithContext(dd.ctx).
+		Where("status = ? AND start_time <= ? AND end_time > ?", enum.DiscountCampaignActive, now, now).
+		Order("id ASC").
+		Find(&campaigns).Error
+	return campaigns, err
+}
+
+// GetCampaignTiers 获取多个满减活动的阶梯, 按门槛从低到高排序
+func (dd *DiscountDao) GetCampaignTiers(campaignIds []int64) ([]*model.DiscountCampaignTier, error) {
+	tiers := make([]*model.DiscountCampaignTier, 0)
+	err := DB().WithContext(dd.ctx).Where("campaign_id IN (?)", campaignIds).
+		Order("threshold ASC").Find(&tiers).Error
+	return tiers, err
+}
+
+// GetUserParticipations 获取用户参加每个满减活动的次数, 已释放的记录不计入
+func (dd *DiscountDao) GetUserParticipations(userId int64, campaignIds []int64) (map[int64]int, error) {
+	var rows []struct {
+		CampaignId int64
+		Num        int
+	}
+	err := DB().WithContext(dd.ctx).Model(&model.OrderDiscountRecord{}).
+		Select("campaign_id, COUNT(*) AS num").
+		Where("user_id = ? AND campaign_id IN (?) AND status = ?", userId, campaignIds, enum.DiscountRecordUsed).
+		Group("campaign_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	participations := make(map[int64]int, len(rows))
+	for _, row := range rows {
+		participations[row.CampaignId] = row.Num
+	}
+	return participations, nil
+}
+
+// CreateOrderRecord 在创建订单的事务中记录订单参加的满减活动
+// 先用当前读锁定用户在这个活动的参加记录再检查次数, 同一个用户并发下单时不会超过 perUserLimit, perUserLimit 为 0 时不限次数
+func (dd *DiscountDao) CreateOrderRecord(tx *gorm.DB, record *do.OrderDiscountRecord, perUserLimit int) (created bool, err error) {
+	if perUserLimit > 0 {
+		var usedNum int64
+		err = tx.WithContext(dd.ctx).Model(&model.OrderDiscountRecord{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("campaign_id = ? AND user_id = ? AND status = ?", record.CampaignId, record.UserId, enum.DiscountRecordUsed).
+			Count(&usedNum).Error
+		if err != nil {
+			return false, err
+		}
+		if usedNum >= int64(perUserLimit) {
+			return false, nil
+		}
+	}
+
+	recordModel := new(model.OrderDiscountRecord)
+	if err = util.CopyProperties(recordModel, record); err != nil {
+		return false, errcode.ErrCoverData.WithCause(err)
+	}
+	recordModel.Status = enum.DiscountRecordUsed
+	if err = tx.WithContext(dd.ctx).Create(recordModel).Error; err != nil {
+		return false, err
+	}
+	record.ID = recordModel.ID
+	return true, nil
+}
+
+// ReleaseOrderRecord 在订单取消或关闭的事务中释放订单的满减活动记录, 不再计入用户的参加次数
+func (dd *DiscountDao) ReleaseOrderRecord(tx *gorm.DB, orderNo string) error {
+	return tx.WithContext(dd.ctx).Model(&model.OrderDiscountRecord{}).
+		Where("order_no = ? AND status = ?", orderNo, enum.DiscountRecordUsed).
+		Update("status", enum.DiscountRecordReleased).Error
+}
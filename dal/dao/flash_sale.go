@@ -0,0 +1,194 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/dal/model"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+type FlashSaleDao struct {
+	ctx context.Context
+}
+
+func NewFlashSaleDao(ctx context.Context) *FlashSaleDao {
+	return &FlashSaleDao{ctx: ctx}
+}
+
+// CreateSession 在事务中创建秒杀场次
+func (fd *FlashSaleDao) CreateSession(tx *gorm.DB, session *do.FlashSaleSession) error {
+	sessionModel := new(model.FlashSaleSession)
+	if err := util.CopyProperties(sessionModel, session); err != nil {
+		return errcode.ErrCoverData.WithCause(err)
+	}
+	if err := tx.WithContext(fd.ctx).Create(sessionModel).Error; err != nil {
+		return err
+	}
+	return util.CopyProperties(session, sessionModel)
+}
+
+// GetSession 获取秒杀场次, 场次不存在时返回 nil
+func (fd *FlashSaleDao) GetSession(sessionId int64) (*model.FlashSaleSession, error) {
+	session := new(model.FlashSaleSession)
+	err := DB().WithContext(fd.ctx).Where("id = ?", sessionId).First(session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return session, err
+}
+
+// LockSession 在事务中用当前读锁定秒杀场次, 场次不存在时返回 nil
+func (fd *FlashSaleDao) LockSession(tx *gorm.DB, sessionId int64) (*model.FlashSaleSession, error) {
+	session := new(model.FlashSaleSession)
+	err := tx.WithContext(fd.ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", sessionId).First(session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return session, err
+}
+
+// GetSessionList 管理后台分页获取秒杀场次列表
+func (fd *FlashSaleDao) GetSessionList(offset, returnSize int) (sessions []*model.FlashSaleSession, totalRows int64, err error) {
+	query := DB().WithContext(fd.ctx).Model(model.FlashSaleSession{})
+	err = query.Count(&totalRows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id DESC").
+		Offset(offset).Limit(returnSize).
+		Find(&sessions).Error
+	return
+}
+
+// GetActiveSessions 获取 now 时还没结束的秒杀场次, 包含正在抢购和还没开始的场次, 按开始时间排序
+func (fd *FlashSaleDao) GetActiveSessions(now time.Time) ([]*model.FlashSaleSession, error) {
+	sessions := make([]*model.FlashSaleSession, 0)
+	err := DB().WithContext(fd.ctx).
+		Where("status = ? AND end_time > ?", enum.FlashSaleSessionActive, now).
+		Order("start_time ASC, id ASC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// GetEndedActiveSessionIds 获取在 now 时已经过了结束时间但还没结束的秒杀场次
+func (fd *FlashSaleDao) GetEndedActiveSessionIds(now time.Time, limit int) ([]int64, error) {
+	sessionIds := make([]int64, 0)
+	err := DB().WithContext(fd.ctx).Model(&model.FlashSaleSession{}).
+		Where("status = ? AND end_time <= ?", enum.FlashSaleSessionActive, now).
+		Limit(limit).
+		Pluck("id", &sessionIds).Error
+	return sessionIds, err
+}
+
+// IncrSessionSold 在创建订单的事务中增加场次的已售数量, 剩余库存不足或者场次已经结束时不更新, 返回 false
+// 按条件更新, 不需要先用当前读锁定场次
+func (fd *FlashSaleDao) IncrSessionSold(tx *gorm.DB, sessionId int64, num int) (bool, error) {
+	result := tx.WithContext(fd.ctx).Model(&model.FlashSaleSession{}).
+		Where("id = ? AND status = ? AND sold_num + ? <= total_stock", sessionId, enum.FlashSaleSessionActive, num).
+		Update("sold_num", gorm.Expr("sold_num + ?", num))
+	return result.RowsAffected > 0, result.Error
+}
+
+// UpdateSession 更新秒杀场次的已售数量、库存和状态
+func (fd *FlashSaleDao) UpdateSession(tx *gorm.DB, session *model.FlashSaleSession) error {
+	return tx.WithContext(fd.ctx).Model(&model.FlashSaleSession{}).
+		Where("id = ?", session.ID).
+		Updates(map[string]interface{}{
+			"total_stock": session.TotalStock,
+			"sold_num":    session.SoldNum,
+			"status":      session.Status,
+		}).Error
+}
+
+// CreateOrderRecord 创建秒杀下单记录, 同一个请求号只会创建一条记录
+func (fd *FlashSaleDao) CreateOrderRecord(tx *gorm.DB, record *do.FlashSaleOrder) error {
+	recordModel := new(model.FlashSaleOrder)
+	if err := util.CopyProperties(recordModel, record); err != nil {
+		return errcode.ErrCoverData.WithCause(err)
+	}
+	if err := tx.WithContext(fd.ctx).Create(recordModel).Error; err != nil {
+		return err
+	}
+	record.ID = recordModel.ID
+	return nil
+}
+
+// GetOrderRecordByRequestNo 用请求号获取秒杀下单记录, 记录不存在时返回 nil
+func (fd *FlashSaleDao) GetOrderRecordByRequestNo(requestNo string) (*model.FlashSaleOrder, error) {
+	record := new(model.FlashSaleOrder)
+	err := DBMaster().WithContext(fd.ctx).Where("request_no = ?", requestNo).First(record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return record, err
+}
+
+// LockOrderRecordByOrderNo 在订单取消或关闭的事务中用当前读锁定订单的秒杀下单记录, 不是秒杀订单时返回 nil
+func (fd *FlashSaleDao) LockOrderRecordByOrderNo(tx *gorm.DB, orderNo string) (*model.FlashSaleOrder, error) {
+	record := new(model.FlashSaleOrder)
+	err := tx.WithContext(fd.ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_no = ?", orderNo).First(record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return record, err
+}
+
+// UpdateOrderRecordStatus 更新秒杀下单记录的状态和是否已经退还 Redis 中预扣的库存
+func (fd *FlashSaleDao) UpdateOrderRecordStatus(tx *gorm.DB, recordId int64, status, stockReturned int) error {
+	return tx.WithContext(fd.ctx).Model(&model.FlashSaleOrder{}).
+		Where("id = ?", recordId).
+		Updates(map[string]interface{}{
+			"status":         status,
+			"stock_returned": stockReturned,
+		}).Error
+}
+
+// SetOrderRecordStockReturned 把还没退还库存的秒杀下单记录标记为已退还, 返回 false 时表示记录已经被其他实例处理过
+func (fd *FlashSaleDao) SetOrderRecordStockReturned(recordId int64) (bool, error) {
+	result := DBMaster().WithContext(fd.ctx).Model(&model.FlashSaleOrder{}).
+		Where("id = ? AND stock_returned = ?", recordId, 0).
+		Update("stock_returned", 1)
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetStockUnreturnedRecords 获取创建订单失败或已释放, 但还没有退还 Redis 中预扣库存的秒杀下单记录
+func (fd *FlashSaleDao) GetStockUnreturnedRecords(limit int) ([]*model.FlashSaleOrder, error) {
+	records := make([]*model.FlashSaleOrder, 0)
+	err := DBMaster().WithContext(fd.ctx).
+		Where("stock_returned = ? AND status IN (?)", 0, []int{enum.FlashSaleOrderFailed, enum.FlashSaleOrderReleased}).
+		Order("id ASC").
+		Limit(limit).
+		Find(&records).Error
+	return records, err
+}
+
+// GetSessionUserBought 获取每个用户在秒杀场次中已创建订单的数量, 重新加载 Redis 中的库存时使用
+func (fd *FlashSaleDao) GetSessionUserBought(sessionId int64) (map[int64]int, error) {
+	var rows []struct {
+		UserId int64
+		Num    int
+	}
+	err := DBMaster().WithContext(fd.ctx).Model(&model.FlashSaleOrder{}).
+		Select("user_id, SUM(commodity_num) AS num").
+		Where("session_id = ? AND status = ?", sessionId, enum.FlashSaleOrderSuccess).
+		Group("user_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	userBought := make(map[int64]int, len(rows))
+	for _, row := range rows {
+		userBought[row.UserId] = row.Num
+	}
+	return userBought, nil
+}
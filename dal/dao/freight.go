@@ -0,0 +1,160 @@
+package dao
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/dal/model"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+type FreightDao struct {
+	ctx context.Context
+}
+
+func NewFreightDao(ctx context.Context) *FreightDao {
+	return &FreightDao{ctx: ctx}
+}
+
+// CreateTemplate 创建运费模板和模板的计费规则, 新模板为默认模板时取消其他模板的默认状态
+func (fd *FreightDao) CreateTemplate(template *do.FreightTemplate) error {
+	templateModel := new(model.FreightTemplate)
+	if err := util.CopyProperties(templateModel, template); err != nil {
+		return errcode.ErrCoverData.WithCause(err)
+	}
+	return DBMaster().WithContext(fd.ctx).Transaction(func(tx *gorm.DB) error {
+		if templateModel.IsDefault == 1 {
+			if err := fd.clearDefaultTemplate(tx); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(templateModel).Error; err != nil {
+			return err
+		}
+		template.ID = templateModel.ID
+		return fd.createTemplateRules(tx, template)
+	})
+}
+
+// UpdateTemplate 更新运费模板, 模板的计费规则整体替换成 template.Rules
+func (fd *FreightDao) UpdateTemplate(template *do.FreightTemplate) error {
+	return DBMaster().WithContext(fd.ctx).Transaction(func(tx *gorm.DB) error {
+		if template.IsDefault == 1 {
+			if err := fd.clearDefaultTemplate(tx); err != nil {
+				return err
+			}
+		}
+		err := tx.Model(&model.FreightTemplate{}).Where("id = ?", template.ID).
+			Updates(map[string]interface{}{
+				"name":        template.Name,
+				"charge_type": template.ChargeType,
+				"is_default":  template.IsDefault,
+			}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Where("template_id = ?", template.ID).Delete(&model.FreightTemplateRule{}).Error
+		if err != nil {
+			return err
+		}
+		return fd.createTemplateRules(tx, template)
+	})
+}
+
+// DeleteTemplate 删除运费模板, 计费规则跟随模板一起删除
+func (fd *FreightDao) DeleteTemplate(templateId int64) error {
+	return DBMaster().WithContext(fd.ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("template_id = ?", templateId).Delete(&model.FreightTemplateRule{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&model.FreightTemplate{}, templateId).Error
+	})
+}
+
+// GetTemplate 获取运费模板和模板的计费规则, 模板不存在时返回 nil
+func (fd *FreightDao) GetTemplate(templateId int64) (*model.FreightTemplate, []*model.FreightTemplateRule, error) {
+	template := new(model.FreightTemplate)
+	err := DB().WithContext(fd.ctx).Where("id = ?", templateId).First(template).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	rules, err := fd.GetTemplateRules([]int64{template.ID})
+	if err != nil {
+		return nil, nil, err
+	}
+	return template, rules, nil
+}
+
+// GetDefaultTemplate 获取默认的运费模板和模板的计费规则, 没有默认模板时返回 nil
+func (fd *FreightDao) GetDefaultTemplate() (*model.FreightTemplate, []*model.FreightTemplateRule, error) {
+	template := new(model.FreightTemplate)
+	err := DB().WithContext(fd.ctx).Where("is_default = ?", 1).Order("id DESC").First(template).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	rules, err := fd.GetTemplateRules([]int64{template.ID})
+	if err != nil {
+		return nil, nil, err
+	}
+	return template, rules, nil
+}
+
+// GetTemplateList 管理后台分页获取运费模板列表
+func (fd *FreightDao) GetTemplateList(offset, returnSize int) (templates []*model.FreightTemplate, totalRows int64, err error) {
+	query := DB().WithContext(fd.ctx).Model(model.FreightTemplate{})
+	err = query.Count(&totalRows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id DESC").
+		Offset(offset).Limit(returnSize).
+		Find(&templates).Error
+	return
+}
+
+// GetTemplateRules 获取多个运费模板的计费规则
+func (fd *FreightDao) GetTemplateRules(templateIds []int64) ([]*model.FreightTemplateRule, error) {
+	rules := make([]*model.FreightTemplateRule, 0)
+	err := DB().WithContext(fd.ctx).Where("template_id IN (?)", templateIds).
+		Order("id ASC").Find(&rules).Error
+	return rules, err
+}
+
+// clearDefaultTemplate 取消所有模板的默认状态
+func (fd *FreightDao) clearDefaultTemplate(tx *gorm.DB) error {
+	return tx.Model(&model.FreightTemplate{}).Where("is_default = ?", 1).
+		Update("is_default", 0).Error
+}
+
+func (fd *FreightDao) createTemplateRules(tx *gorm.DB, template *do.FreightTemplate) error {
+	if len(template.Rules) == 0 {
+		return nil
+	}
+	ruleModels := make([]*model.FreightTemplateRule, 0, len(template.Rules))
+	if err := util.CopyProperties(&ruleModels, &template.Rules); err != nil {
+		return errcode.ErrCoverData.WithCause(err)
+	}
+	for _, ruleModel := range ruleModels {
+		ruleModel.ID = 0
+		ruleModel.TemplateId = template.ID
+	}
+	if err := tx.Create(&ruleModels).Error; err != nil {
+		return err
+	}
+	for i, ruleModel := range ruleModels {
+		template.Rules[i].ID = ruleModel.ID
+		template.Rules[i].TemplateId = ruleModel.TemplateId
+	}
+	return nil
+}
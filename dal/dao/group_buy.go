@@ -0,0 +1,216 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/dal/model"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+type GroupBuyDao struct {
+	ctx context.Context
+}
+
+func NewGroupBuyDao(ctx context.Context) *GroupBuyDao {
+	return &GroupBuyDao{ctx: ctx}
+}
+
+// CreateActivity 创建拼团活动
+func (gd *GroupBuyDao) CreateActivity(activity *do.GroupBuyActivity) error {
+	activityModel := new(model.GroupBuyActivity)
+	if err := util.CopyProperties(activityModel, activity); err != nil {
+		return errcode.ErrCoverData.WithCause(err)
+	}
+	if err := DBMaster().WithContext(gd.ctx).Create(activityModel).Error; err != nil {
+		return err
+	}
+	return util.CopyProperties(activity, activityModel)
+}
+
+// UpdateActivityStatus 更新拼团活动的状态
+func (gd *GroupBuyDao) UpdateActivityStatus(activityId int64, status int) error {
+	return DBMaster().WithContext(gd.ctx).Model(&model.GroupBuyActivity{}).
+		Where("id = ?", activityId).
+		Update("status", status).Error
+}
+
+// GetActivity 获取拼团活动, 活动不存在时返回 nil
+func (gd *GroupBuyDao) GetActivity(activityId int64) (*model.GroupBuyActivity, error) {
+	activity := new(model.GroupBuyActivity)
+	err := DB().WithContext(gd.ctx).Where("id = ?", activityId).First(activity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return activity, err
+}
+
+// GetActivityList 管理后台分页获取拼团活动列表
+func (gd *GroupBuyDao) GetActivityList(offset, returnSize int) (activities []*model.GroupBuyActivity, totalRows int64, err error) {
+	query := DB().WithContext(gd.ctx).Model(model.GroupBuyActivity{})
+	err = query.Count(&totalRows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id DESC").
+		Offset(offset).Limit(returnSize).
+		Find(&activities).Error
+	return
+}
+
+// GetOngoingActivities 获取在 now 时可以开团的拼团活动
+func (gd *GroupBuyDao) GetOngoingActivities(now time.Time) ([]*model.GroupBuyActivity, error) {
+	activities := make([]*model.GroupBuyActivity, 0)
+	err := DB().WithContext(gd.ctx).
+		Where("status = ? AND start_time <= ? AND end_time > ?", enum.GroupBuyActivityActive, now, now).
+		Order("id DESC").
+		Find(&activities).Error
+	return activities, err
+}
+
+// CreateGroup 在开团的事务中创建拼团
+func (gd *GroupBuyDao) CreateGroup(tx *gorm.DB, group *do.GroupBuyGroup) error {
+	groupModel := new(model.GroupBuyGroup)
+	if err := util.CopyProperties(groupModel, group); err != nil {
+		return errcode.ErrCoverData.WithCause(err)
+	}
+	if err := tx.WithContext(gd.ctx).Create(groupModel).Error; err != nil {
+		return err
+	}
+	group.ID = groupModel.ID
+	return nil
+}
+
+// GetGroup 获取拼团, 拼团不存在时返回 nil
+func (gd *GroupBuyDao) GetGroup(groupId int64) (*model.GroupBuyGroup, error) {
+	group := new(model.GroupBuyGroup)
+	err := DBMaster().WithContext(gd.ctx).Where("id = ?", groupId).First(group).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return group, err
+}
+
+// GetGroupByShareCode 用分享码获取拼团, 拼团不存在时返回 nil
+func (gd *GroupBuyDao) GetGroupByShareCode(shareCode string) (*model.GroupBuyGroup, error) {
+	group := new(model.GroupBuyGroup)
+	err := DBMaster().WithContext(gd.ctx).Where("share_code = ?", shareCode).First(group).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return group, err
+}
+
+// LockGroup 在事务中用当前读锁定拼团, 拼团不存在时返回 nil
+func (gd *GroupBuyDao) LockGroup(tx *gorm.DB, groupId int64) (*model.GroupBuyGroup, error) {
+	group := new(model.GroupBuyGroup)
+	err := tx.WithContext(gd.ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", groupId).First(group).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return group, err
+}
+
+// UpdateGroup 更新拼团的成员数、已支付的成员数和状态
+func (gd *GroupBuyDao) UpdateGroup(tx *gorm.DB, group *model.GroupBuyGroup) error {
+	return tx.WithContext(gd.ctx).Model(&model.GroupBuyGroup{}).
+		Where("id = ?", group.ID).
+		Updates(map[string]interface{}{
+			"member_num": group.MemberNum,
+			"paid_num":   group.PaidNum,
+			"status":     group.Status,
+		}).Error
+}
+
+// GetExpiredFormingGroupIds 获取在 now 时已经过了成团时限但还在拼团中的拼团
+func (gd *GroupBuyDao) GetExpiredFormingGroupIds(now time.Time, limit int) ([]int64, error) {
+	groupIds := make([]int64, 0)
+	err := DBMaster().WithContext(gd.ctx).Model(&model.GroupBuyGroup{}).
+		Where("status = ? AND expire_at <= ?", enum.GroupBuyForming, now).
+		Order("expire_at ASC").
+		Limit(limit).
+		Pluck("id", &groupIds).Error
+	return groupIds, err
+}
+
+// CreateMember 在开团或参团的事务中创建拼团成员
+func (gd *GroupBuyDao) CreateMember(tx *gorm.DB, member *do.GroupBuyMember) error {
+	memberModel := new(model.GroupBuyMember)
+	if err := util.CopyProperties(memberModel, member); err != nil {
+		return errcode.ErrCoverData.WithCause(err)
+	}
+	if err := tx.WithContext(gd.ctx).Create(memberModel).Error; err != nil {
+		return err
+	}
+	member.ID = memberModel.ID
+	return nil
+}
+
+// GetGroupMembers 获取拼团的所有成员, 包含已经退出的成员, 按参团顺序排序
+func (gd *GroupBuyDao) GetGroupMembers(groupId int64) ([]*model.GroupBuyMember, error) {
+	members := make([]*model.GroupBuyMember, 0)
+	err := DBMaster().WithContext(gd.ctx).
+		Where("group_id = ?", groupId).
+		Order("id ASC").
+		Find(&members).Error
+	return members, err
+}
+
+// HasActiveMember 在参团的事务中判断用户是否已经是拼团中没有退出的成员
+func (gd *GroupBuyDao) HasActiveMember(tx *gorm.DB, groupId, userId int64) (bool, error) {
+	var count int64
+	err := tx.WithContext(gd.ctx).Model(&model.GroupBuyMember{}).
+		Where("group_id = ? AND user_id = ? AND status IN (?)", groupId, userId, []int{enum.GroupBuyMemberJoined, enum.GroupBuyMemberPaid}).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// GetMemberByOrderNo 获取订单对应的拼团成员, 不是拼团订单时返回 nil
+func (gd *GroupBuyDao) GetMemberByOrderNo(orderNo string) (*model.GroupBuyMember, error) {
+	member := new(model.GroupBuyMember)
+	err := DBMaster().WithContext(gd.ctx).Where("order_no = ?", orderNo).First(member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return member, err
+}
+
+// LockMemberByOrderNo 在订单支付、取消或关闭的事务中用当前读锁定订单对应的拼团成员, 不是拼团订单时返回 nil
+func (gd *GroupBuyDao) LockMemberByOrderNo(tx *gorm.DB, orderNo string) (*model.GroupBuyMember, error) {
+	member := new(model.GroupBuyMember)
+	err := tx.WithContext(gd.ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_no = ?", orderNo).First(member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return member, err
+}
+
+// UpdateMemberFromStatus 只有拼团成员当前的状态是 fromStatus 时才更新, 返回 false 时表示状态已经被并发的请求修改
+func (gd *GroupBuyDao) UpdateMemberFromStatus(tx *gorm.DB, memberId int64, fromStatus int, updates map[string]interface{}) (bool, error) {
+	result := tx.WithContext(gd.ctx).Model(&model.GroupBuyMember{}).
+		Where("id = ? AND status = ?", memberId, fromStatus).
+		Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetFailedGroupUnsettledMembers 获取拼团失败后还没有关闭订单或者还没有发起退款的成员
+func (gd *GroupBuyDao) GetFailedGroupUnsettledMembers(limit int) ([]*model.GroupBuyMember, error) {
+	members := make([]*model.GroupBuyMember, 0)
+	err := DBMaster().WithContext(gd.ctx).
+		Joins("JOIN group_buy_groups ON group_buy_groups.id = group_buy_members.group_id").
+		Where("group_buy_groups.status = ? AND group_buy_members.status IN (?)", enum.GroupBuyFailed,
+			[]int{enum.GroupBuyMemberJoined, enum.GroupBuyMemberPaid, enum.GroupBuyMemberRefunding}).
+		Order("group_buy_members.id ASC").
+		Limit(limit).
+		Find(&members).Error
+	return members, err
+}
@@ -2,10 +2,12 @@ package dao
 
 import (
     "context"
+    "time"
 
     "github.com/samber/lo"
     "gorm.io/gorm"
 
+    "github.com/hd2yao/go-mall/common/enum"
     "github.com/hd2yao/go-mall/common/errcode"
     "github.com/hd2yao/go-mall/common/util"
     "github.com/hd2yao/go-mall/dal/model"
@@ -157,3 +159,84 @@ func (od *OrderDao) UpdateOrderStatus(orderId int64, status int) error {
 func (od *OrderDao) UpdateOrder(orderModel *model.Order) error {
     return DBMaster().WithContext(od.ctx).Model(orderModel).Updates(orderModel).Error
 }
+
+// UpdateOrderFromStatus 订单状态为 fromStatus 时才更新订单, 防止并发的请求把订单修改成不符合预期的状态
+// 返回值 updated 为 false 时表示订单的状态已经被修改
+func (od *OrderDao) UpdateOrderFromStatus(tx *gorm.DB, orderId int64, fromStatus int, updates map[string]interface{}) (updated bool, err error) {
+    result := tx.WithContext(od.ctx).Model(model.Order{}).
+        Where("id = ? AND order_status = ?", orderId, fromStatus).
+        Updates(updates)
+    if result.Error != nil {
+        return false, result.Error
+    }
+    return result.RowsAffected > 0, nil
+}
+
+// AddOrderItemRefunded 退款成功后累加订单明细的已退款数量和金额, 已退款数量不会超过购买数量
+// 返回值 updated 为 false 时表示累加后会超过购买数量
+func (od *OrderDao) AddOrderItemRefunded(tx *gorm.DB, orderItemId int64, refundNum, refundMoney int) (updated bool, err error) {
+    result := tx.WithContext(od.ctx).Model(model.OrderItem{}).
+        Where("id = ? AND refunded_num + ? <= commodity_num", orderItemId, refundNum).
+        Updates(map[string]interface{}{
+            "refunded_num":   gorm.Expr("refunded_num + ?", refundNum),
+            "refunded_money": gorm.Expr("refunded_money + ?", refundMoney),
+        })
+    if result.Error != nil {
+        return false, result.Error
+    }
+    return result.RowsAffected > 0, nil
+}
+
+// GetTimeoutUnpaidOrderNos 获取创建时间早于 createdBefore 且还没有支付的订单号
+func (od *OrderDao) GetTimeoutUnpaidOrderNos(createdBefore time.Time, limit int) ([]string, error) {
+    orderNos := make([]string, 0)
+    err := DB().WithContext(od.ctx).Model(model.Order{}).
+        Where("order_status IN (?) AND created_at < ?", []int{enum.OrderStatusCreated, enum.OrderStatusUnPaid}, createdBefore).
+        Order("id").Limit(limit).
+        Pluck("order_no", &orderNos).Error
+    return orderNos, err
+}
+
+// GetOrdersByStatusUpdatedBefore 获取状态为 status 且最后更新时间早于 updatedBefore 的订单
+func (od *OrderDao) GetOrdersByStatusUpdatedBefore(status int, updatedBefore time.Time, limit int) ([]*model.Order, error) {
+    orders := make([]*model.Order, 0)
+    err := DB().WithContext(od.ctx).
+        Where("order_status = ? AND updated_at < ?", status, updatedBefore).
+        Order("id").Limit(limit).
+        Find(&orders).Error
+    return orders, err
+}
+
+// GetOrdersByStatusEnteredBefore 获取状态为 status 且进入该状态的时间早于 enteredBefore 的订单
+// 进入状态的时间取订单状态变更记录中最后一次变更为 status 的时间, 申请退款、修改支付方式等不变更订单状态的更新会改变 updated_at, 但不会改变这个时间
+func (od *OrderDao) GetOrdersByStatusEnteredBefore(status int, enteredBefore time.Time, limit int) ([]*model.Order, error) {
+    orders := make([]*model.Order, 0)
+    err := DB().WithContext(od.ctx).Select("orders.*").
+        Joins("JOIN order_status_logs ON order_status_logs.order_id = orders.id AND order_status_logs.to_status = orders.order_status").
+        Where("orders.order_status = ?", status).
+        Group("orders.id").
+        Having("MAX(order_status_logs.created_at) < ?", enteredBefore).
+        Order("orders.id").Limit(limit).
+        Find(&orders).Error
+    return orders, err
+}
+
+// GetOrdersByNos 根据订单号批量获取订单
+func (od *OrderDao) GetOrdersByNos(orderNos []string) ([]*model.Order, error) {
+    orders := make([]*model.Order, 0)
+    if len(orderNos) == 0 {
+        return orders, nil
+    }
+    err := DB().WithContext(od.ctx).Where("order_no IN (?)", orderNos).Find(&orders).Error
+    return orders, err
+}
+
+// GetPaidOrdersBetween 获取支付方式为 payType 且支付时间在 [paidStart, paidEnd) 之间的订单
+func (od *OrderDao) GetPaidOrdersBetween(payType int, paidStart, paidEnd time.Time) ([]*model.Order, error) {
+    orders := make([]*model.Order, 0)
+    err := DB().WithContext(od.ctx).
+        Where("pay_type = ? AND paid_at >= ? AND paid_at < ?", payType, paidStart, paidEnd).
+        Order("id").
+        Find(&orders).Error
+    return orders, err
+}
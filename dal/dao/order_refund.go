@@ -0,0 +1,107 @@
+package dao
+
+import (
+	"context"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/dal/model"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+type OrderRefundDao struct {
+	ctx context.Context
+}
+
+func NewOrderRefundDao(ctx context.Context) *OrderRefundDao {
+	return &OrderRefundDao{ctx: ctx}
+}
+
+// CreateRefund 创建退款单
+func (rd *OrderRefundDao) CreateRefund(tx *gorm.DB, refund *do.OrderRefund) error {
+	refundModel := new(model.OrderRefund)
+	if err := util.CopyProperties(refundModel, refund); err != nil {
+		return errcode.ErrCoverData.WithCause(err)
+	}
+	if err := tx.WithContext(rd.ctx).Create(refundModel).Error; err != nil {
+		return err
+	}
+	refund.ID = refundModel.ID
+	for _, item := range refund.Items {
+		item.RefundId = refundModel.ID
+	}
+
+	return rd.createRefundItems(tx, refund.Items)
+}
+
+func (rd *OrderRefundDao) createRefundItems(tx *gorm.DB, refundItems []*do.OrderRefundItem) error {
+	if len(refundItems) == 0 {
+		return nil
+	}
+	refundItemModels := make([]*model.OrderRefundItem, 0, len(refundItems))
+	if err := util.CopyProperties(&refundItemModels, &refundItems); err != nil {
+		return errcode.ErrCoverData.WithCause(err)
+	}
+	return tx.WithContext(rd.ctx).Create(refundItemModels).Error
+}
+
+// GetRefundByNo 根据退款单号获取退款单
+func (rd *OrderRefundDao) GetRefundByNo(refundNo string) (*model.OrderRefund, error) {
+	refund := new(model.OrderRefund)
+	err := DB().WithContext(rd.ctx).Where("refund_no = ?", refundNo).
+		Find(refund).Error
+	return refund, err
+}
+
+// GetMultiRefundsItems 获取多个退款单的退款明细, 返回以 refundId 为 Key, 对应的退款明细列表为值的 Map
+func (rd *OrderRefundDao) GetMultiRefundsItems(refundIds []int64) (map[int64][]*model.OrderRefundItem, error) {
+	refundItems := make([]*model.OrderRefundItem, 0)
+	err := DB().WithContext(rd.ctx).Where("refund_id IN (?)", refundIds).
+		Find(&refundItems).Error
+	if err != nil {
+		return nil, err
+	}
+	return lo.GroupBy(refundItems, func(item *model.OrderRefundItem) int64 {
+		return item.RefundId
+	}), nil
+}
+
+// GetOrderRefunds 获取订单的退款单列表
+func (rd *OrderRefundDao) GetOrderRefunds(orderId int64) ([]*model.OrderRefund, error) {
+	refunds := make([]*model.OrderRefund, 0)
+	err := DB().WithContext(rd.ctx).Where("order_id = ?", orderId).
+		Order("created_at DESC").
+		Find(&refunds).Error
+	return refunds, err
+}
+
+// GetRefundList 管理后台获取退款单列表, status 小于 0 时不按状态筛选
+func (rd *OrderRefundDao) GetRefundList(status int, offset, returnSize int) (refunds []*model.OrderRefund, totalRows int64, err error) {
+	query := DB().WithContext(rd.ctx).Model(model.OrderRefund{})
+	if status >= 0 {
+		query = query.Where("status = ?", status)
+	}
+	err = query.Count(&totalRows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("created_at DESC").
+		Offset(offset).Limit(returnSize).
+		Find(&refunds).Error
+	return
+}
+
+// UpdateRefundFromStatus 退款单状态为 fromStatus 时才更新, 防止并发的请求重复修改退款单
+// 返回值 updated 为 false 时表示退款单的状态已经被修改
+func (rd *OrderRefundDao) UpdateRefundFromStatus(tx *gorm.DB, refundId int64, fromStatus int, updates map[string]interface{}) (updated bool, err error) {
+	result := tx.WithContext(rd.ctx).Model(model.OrderRefund{}).
+		Where("id = ? AND status = ?", refundId, fromStatus).
+		Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
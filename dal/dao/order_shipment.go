@@ -0,0 +1,53 @@
+package dao
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/dal/model"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+type OrderShipmentDao struct {
+	ctx context.Context
+}
+
+func NewOrderShipmentDao(ctx context.Context) *OrderShipmentDao {
+	return &OrderShipmentDao{ctx: ctx}
+}
+
+// CreateShipment 创建发货记录
+func (sd *OrderShipmentDao) CreateShipment(tx *gorm.DB, shipment *do.OrderShipment) error {
+	shipmentModel := new(model.OrderShipment)
+	if err := util.CopyProperties(shipmentModel, shipment); err != nil {
+		return errcode.ErrCoverData.WithCause(err)
+	}
+	if err := tx.WithContext(sd.ctx).Create(shipmentModel).Error; err != nil {
+		return err
+	}
+	shipment.ID = shipmentModel.ID
+	return nil
+}
+
+// GetOrderShipment 获取订单最新的发货记录, 订单还没发货时返回的发货记录 ID 为 0
+func (sd *OrderShipmentDao) GetOrderShipment(orderId int64) (*model.OrderShipment, error) {
+	shipment := new(model.OrderShipment)
+	err := DB().WithContext(sd.ctx).Where("order_id = ?", orderId).
+		Order("id DESC").Limit(1).
+		Find(shipment).Error
+	return shipment, err
+}
+
+// UpdateShipmentFromStatus 发货记录的状态为 fromStatus 时才更新
+func (sd *OrderShipmentDao) UpdateShipmentFromStatus(tx *gorm.DB, shipmentId int64, fromStatus int, updates map[string]interface{}) (updated bool, err error) {
+	result := tx.WithContext(sd.ctx).Model(model.OrderShipment{}).
+		Where("id = ? AND status = ?", shipmentId, fromStatus).
+		Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
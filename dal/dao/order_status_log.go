@@ -0,0 +1,42 @@
+package dao
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/dal/model"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+type OrderStatusLogDao struct {
+	ctx context.Context
+}
+
+func NewOrderStatusLogDao(ctx context.Context) *OrderStatusLogDao {
+	return &OrderStatusLogDao{ctx: ctx}
+}
+
+// CreateStatusLog 记录订单状态变更, 和订单状态的更新在同一个事务中执行
+func (sld *OrderStatusLogDao) CreateStatusLog(tx *gorm.DB, statusLog *do.OrderStatusLog) error {
+	statusLogModel := new(model.OrderStatusLog)
+	if err := util.CopyProperties(statusLogModel, statusLog); err != nil {
+		return errcode.ErrCoverData.WithCause(err)
+	}
+	if err := tx.WithContext(sld.ctx).Create(statusLogModel).Error; err != nil {
+		return err
+	}
+	statusLog.ID = statusLogModel.ID
+	statusLog.CreatedAt = statusLogModel.CreatedAt
+	return nil
+}
+
+// GetOrderStatusLogs 按变更的先后顺序获取订单的状态变更记录
+func (sld *OrderStatusLogDao) GetOrderStatusLogs(orderId int64) ([]*model.OrderStatusLog, error) {
+	statusLogs := make([]*model.OrderStatusLog, 0)
+	err := DB().WithContext(sld.ctx).Where("order_id = ?", orderId).
+		Order("id").Find(&statusLogs).Error
+	return statusLogs, err
+}
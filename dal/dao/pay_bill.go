@@ -0,0 +1,59 @@
+package dao
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/dal/model"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+type PayBillDao struct {
+	ctx context.Context
+}
+
+func NewPayBillDao(ctx context.Context) *PayBillDao {
+	return &PayBillDao{ctx: ctx}
+}
+
+// ReplaceMismatches 用新的对账结果替换账单日期 billDate 的支付方式 payType 的差异记录
+// 同一天的账单可以重复对账, 每次对账的结果覆盖上一次的结果
+func (pbd *PayBillDao) ReplaceMismatches(billDate string, payType int, mismatches []*do.PayBillMismatch) error {
+	mismatchModels := make([]*model.PayBillMismatch, 0, len(mismatches))
+	if err := util.CopyProperties(&mismatchModels, &mismatches); err != nil {
+		return errcode.ErrCoverData.WithCause(err)
+	}
+	return DBMaster().WithContext(pbd.ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("bill_date = ? AND pay_type = ?", billDate, payType).
+			Delete(&model.PayBillMismatch{}).Error
+		if err != nil {
+			return err
+		}
+		if len(mismatchModels) == 0 {
+			return nil
+		}
+		return tx.Create(&mismatchModels).Error
+	})
+}
+
+// GetMismatchList 管理后台获取对账差异列表, billDate 为空时不按日期筛选, mismatchType 小于等于 0 时不按差异类型筛选
+func (pbd *PayBillDao) GetMismatchList(billDate string, mismatchType int, offset, returnSize int) (mismatches []*model.PayBillMismatch, totalRows int64, err error) {
+	query := DB().WithContext(pbd.ctx).Model(model.PayBillMismatch{})
+	if billDate != "" {
+		query = query.Where("bill_date = ?", billDate)
+	}
+	if mismatchType > 0 {
+		query = query.Where("mismatch_type = ?", mismatchType)
+	}
+	err = query.Count(&totalRows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id DESC").
+		Offset(offset).Limit(returnSize).
+		Find(&mismatches).Error
+	return
+}
@@ -0,0 +1,104 @@
+package dao
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/dal/model"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+type VipDao struct {
+	ctx context.Context
+}
+
+func NewVipDao(ctx context.Context) *VipDao {
+	return &VipDao{ctx: ctx}
+}
+
+// CreateTier 创建会员等级
+func (vd *VipDao) CreateTier(tier *do.VipTier) error {
+	tierModel := new(model.VipTier)
+	if err := util.CopyProperties(tierModel, tier); err != nil {
+		return errcode.ErrCoverData.WithCause(err)
+	}
+	if err := DBMaster().WithContext(vd.ctx).Create(tierModel).Error; err != nil {
+		return err
+	}
+	return util.CopyProperties(tier, tierModel)
+}
+
+// UpdateTier 更新会员等级
+func (vd *VipDao) UpdateTier(tier *do.VipTier) error {
+	return DBMaster().WithContext(vd.ctx).Model(&model.VipTier{}).
+		Where("id = ?", tier.ID).
+		Updates(map[string]interface{}{
+			"name":          tier.Name,
+			"level":         tier.Level,
+			"discount_rate": tier.DiscountRate,
+			"upgrade_spend": tier.UpgradeSpend,
+			"valid_days":    tier.ValidDays,
+		}).Error
+}
+
+// DeleteTier 删除会员等级
+func (vd *VipDao) DeleteTier(tierId int64) error {
+	return DBMaster().WithContext(vd.ctx).Delete(&model.VipTier{}, tierId).Error
+}
+
+// GetTier 获取会员等级, 等级不存在时返回 nil
+func (vd *VipDao) GetTier(tierId int64) (*model.VipTier, error) {
+	tier := new(model.VipTier)
+	err := DB().WithContext(vd.ctx).Where("id = ?", tierId).First(tier).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return tier, err
+}
+
+// GetAllTiers 获取所有会员等级, 按等级从低到高排序
+func (vd *VipDao) GetAllTiers() ([]*model.VipTier, error) {
+	tiers := make([]*model.VipTier, 0)
+	err := DB().WithContext(vd.ctx).Order("level ASC, id ASC").Find(&tiers).Error
+	return tiers, err
+}
+
+// GetUserVip 获取用户的会员信息, 用户还没有完成过订单时返回 nil
+func (vd *VipDao) GetUserVip(userId int64) (*model.UserVip, error) {
+	userVip := new(model.UserVip)
+	err := DB().WithContext(vd.ctx).Where("user_id = ?", userId).First(userVip).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return userVip, err
+}
+
+// LockUserVip 在事务中用当前读锁定用户的会员信息, 用户还没有会员信息时先创建再锁定
+// 同一个用户的多个订单同时完成时按顺序累计消费金额
+func (vd *VipDao) LockUserVip(tx *gorm.DB, userId int64) (*model.UserVip, error) {
+	err := tx.WithContext(vd.ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.UserVip{UserId: userId}).Error
+	if err != nil {
+		return nil, err
+	}
+	userVip := new(model.UserVip)
+	err = tx.WithContext(vd.ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ?", userId).First(userVip).Error
+	return userVip, err
+}
+
+// UpdateUserVip 更新用户累计的消费金额、会员等级和过期时间
+func (vd *VipDao) UpdateUserVip(tx *gorm.DB, userVip *model.UserVip) error {
+	return tx.WithContext(vd.ctx).Model(&model.UserVip{}).
+		Where("id = ?", userVip.ID).
+		Updates(map[string]interface{}{
+			"tier_id":     userVip.TierId,
+			"total_spend": userVip.TotalSpend,
+			"expire_at":   userVip.ExpireAt,
+		}).Error
+}
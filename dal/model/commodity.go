@@ -17,6 +17,7 @@ type Commodity struct {
 	OriginalPrice int                   `gorm:"column:original_price;default:1;NOT NULL"`             // 商品原价
 	SellingPrice  int                   `gorm:"column:selling_price;default:1;NOT NULL"`              // 商品售价
 	StockNum      int                   `gorm:"column:stock_num;default:0;NOT NULL"`                  // 商品库存数量
+	Weight        int                   `gorm:"column:weight;default:0;NOT NULL"`                     // 商品重量（克）, 按重量计算运费时使用
 	Tag           string                `gorm:"column:tag;NOT NULL"`                                  // 商品标签
 	SellStatus    int                   `gorm:"column:sell_status;default:1;NOT NULL"`                // 商品上架状态 1-上架  2-下架
 	IsDel         soft_delete.DeletedAt `gorm:"softDelete:flag"`                                      // 删除标识字段(0-未删除 1-已删除)
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/plugin/soft_delete"
+)
+
+// CouponTemplate 优惠券模板, 用户从发放中的模板领取优惠券
+type CouponTemplate struct {
+	ID               int64                 `gorm:"column:id;primary_key;AUTO_INCREMENT"`                 // 优惠券模板ID
+	Name             string                `gorm:"column:name;NOT NULL"`                                 // 优惠券名称
+	CouponType       int                   `gorm:"column:coupon_type;default:1;NOT NULL"`                // 类型 1-固定金额 2-按比例折扣
+	DiscountMoney    int                   `gorm:"column:discount_money;default:0;NOT NULL"`             // 固定金额券的减免金额（分）
+	DiscountRate     int                   `gorm:"column:discount_rate;default:0;NOT NULL"`              // 折扣券减免的百分比, 比如 20 表示减免 20% 即 8 折
+	MaxDiscountMoney int                   `gorm:"column:max_discount_money;default:0;NOT NULL"`         // 折扣券最多减免的金额（分）, 0 表示不限
+	Threshold        int                   `gorm:"column:threshold;default:0;NOT NULL"`                  // 使用门槛（分）, 适用商品的金额满这个金额可用, 0 表示无门槛
+	ScopeType        int                   `gorm:"column:scope_type;default:1;NOT NULL"`                 // 适用范围 1-全部商品 2-指定分类 3-指定商品
+	ScopeIds         string                `gorm:"column:scope_ids;NOT NULL"`                            // 适用的分类ID或商品ID, 多个用逗号分隔
+	TotalNum         int                   `gorm:"column:total_num;default:0;NOT NULL"`                  // 发放总量, 0 表示不限
+	ClaimedNum       int                   `gorm:"column:claimed_num;default:0;NOT NULL"`                // 已领取数量
+	PerUserLimit     int                   `gorm:"column:per_user_limit;default:1;NOT NULL"`             // 每个用户最多领取的数量
+	ValidStart       time.Time             `gorm:"column:valid_start;NOT NULL"`                          // 有效期开始时间, 有效期内才能领取和使用
+	ValidEnd         time.Time             `gorm:"column:valid_end;NOT NULL"`                            // 有效期结束时间
+	Status           int                   `gorm:"column:status;default:1;NOT NULL"`                     // 发放状态 1-发放中 2-已停止发放
+	IsDel            soft_delete.DeletedAt `gorm:"softDelete:flag"`                                      // 0-未删除 1-已删除
+	CreatedAt        time.Time             `gorm:"column:created_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 创建时间
+	UpdatedAt        time.Time             `gorm:"column:updated_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 更新时间
+}
+
+func (CouponTemplate) TableName() string {
+	return "coupon_templates"
+}
+
+// UserCoupon 用户领取的优惠券
+type UserCoupon struct {
+	ID         int64     `gorm:"column:id;primary_key;AUTO_INCREMENT"`                 // 用户优惠券ID
+	CouponId   int64     `gorm:"column:coupon_id;NOT NULL;index:idx_coupon_user"`      // 优惠券模板ID
+	UserId     int64     `gorm:"column:user_id;NOT NULL;index:idx_coupon_user"`        // 用户ID
+	Status     int       `gorm:"column:status;default:1;NOT NULL"`                     // 状态 1-未使用 2-已锁定 3-已使用
+	OrderNo    string    `gorm:"column:order_no;NOT NULL;index:idx_order_no"`          // 锁定或使用这张优惠券的订单号
+	ValidStart time.Time `gorm:"column:valid_start;NOT NULL"`                          // 有效期开始时间, 领取时从模板复制
+	ValidEnd   time.Time `gorm:"column:valid_end;NOT NULL"`                            // 有效期结束时间
+	UsedAt     time.Time `gorm:"column:used_at;default:1970-01-01 00:00:00;NOT NULL"`  // 核销时间, 未使用时为 1970-01-01
+	CreatedAt  time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 领取时间
+	UpdatedAt  time.Time `gorm:"column:updated_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 更新时间
+}
+
+func (UserCoupon) TableName() string {
+	return "user_coupons"
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/plugin/soft_delete"
+)
+
+// DiscountCampaign 满减活动, 订单中适用商品的金额达到阶梯门槛时减免对应的金额
+type DiscountCampaign struct {
+	ID           int64                 `gorm:"column:id;primary_key;AUTO_INCREMENT"`                 // 满减活动ID
+	Name         string                `gorm:"column:name;NOT NULL"`                                 // 活动名称
+	ScopeType    int                   `gorm:"column:scope_type;default:1;NOT NULL"`                 // 适用范围 1-全部商品 2-指定分类 3-指定商品
+	ScopeIds     string                `gorm:"column:scope_ids;NOT NULL"`                            // 适用的分类ID或商品ID, 多个用逗号分隔
+	PerUserLimit int                   `gorm:"column:per_user_limit;default:0;NOT NULL"`             // 每个用户最多参加的次数, 0 表示不限
+	StartTime    time.Time             `gorm:"column:start_time;NOT NULL"`                           // 活动开始时间
+	EndTime      time.Time             `gorm:"column:end_time;NOT NULL"`                             // 活动结束时间
+	Status       int                   `gorm:"column:status;default:1;NOT NULL"`                     // 状态 1-进行中 2-已停止
+	IsDel        soft_delete.DeletedAt `gorm:"softDelete:flag"`                                      // 0-未删除 1-已删除
+	CreatedAt    time.Time             `gorm:"column:created_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 创建时间
+	UpdatedAt    time.Time             `gorm:"column:updated_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 更新时间
+}
+
+func (DiscountCampaign) TableName() string {
+	return "discount_campaigns"
+}
+
+// DiscountCampaignTier 满减活动的阶梯, 满 Threshold 减 DiscountMoney
+type DiscountCampaignTier struct {
+	ID            int64     `gorm:"column:id;primary_key;AUTO_INCREMENT"`                 // 阶梯ID
+	CampaignId    int64     `gorm:"column:campaign_id;NOT NULL;index:idx_campaign_id"`    // 满减活动ID
+	Threshold     int       `gorm:"column:threshold;default:0;NOT NULL"`                  // 门槛（分）
+	DiscountMoney int       `gorm:"column:discount_money;default:0;NOT NULL"`             // 减免金额（分）
+	CreatedAt     time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 创建时间
+	UpdatedAt     time.Time `gorm:"column:updated_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 更新时间
+}
+
+func (DiscountCampaignTier) TableName() string {
+	return "discount_campaign_tiers"
+}
+
+// OrderDiscountRecord 订单参加满减活动的记录
+type OrderDiscountRecord struct {
+	ID            int64     `gorm:"column:id;primary_key;AUTO_INCREMENT"`                 // 记录ID
+	CampaignId    int64     `gorm:"column:campaign_id;NOT NULL;index:idx_campaign_user"`  // 满减活动ID
+	UserId        int64     `gorm:"column:user_id;NOT NULL;index:idx_campaign_user"`      // 用户ID
+	OrderNo       string    `gorm:"column:order_no;NOT NULL;uniqueIndex:uniq_order_no"`   // 订单号
+	DiscountMoney int       `gorm:"column:discount_money;default:0;NOT NULL"`             // 减免的金额（分）
+	Status        int       `gorm:"column:status;default:1;NOT NULL"`                     // 状态 1-已参加 2-已释放
+	CreatedAt     time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 创建时间
+	UpdatedAt     time.Time `gorm:"column:updated_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 更新时间
+}
+
+func (OrderDiscountRecord) TableName() string {
+	return "order_discount_records"
+}
@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// FlashSaleSession 秒杀场次, 创建场次时从商品库存中划出秒杀库存, 场次结束后没有卖出的库存还给商品
+type FlashSaleSession struct {
+	ID           int64     `gorm:"column:id;primary_key;AUTO_INCREMENT"`                 // 场次ID
+	Name         string    `gorm:"column:name;NOT NULL"`                                 // 场次名称
+	CommodityId  int64     `gorm:"column:commodity_id;NOT NULL;index:idx_commodity_id"`  // 秒杀的商品ID
+	FlashPrice   int       `gorm:"column:flash_price;default:0;NOT NULL"`                // 秒杀价（分）
+	TotalStock   int       `gorm:"column:total_stock;default:0;NOT NULL"`                // 秒杀库存
+	SoldNum      int       `gorm:"column:sold_num;default:0;NOT NULL"`                   // 已创建订单的数量, 订单取消或关闭后减回去
+	PerUserLimit int       `gorm:"column:per_user_limit;default:1;NOT NULL"`             // 每个用户最多抢购的数量
+	StartTime    time.Time `gorm:"column:start_time;NOT NULL"`                           // 开始时间
+	EndTime      time.Time `gorm:"column:end_time;NOT NULL;index:idx_end_time"`          // 结束时间
+	Status       int       `gorm:"column:status;default:1;NOT NULL"`                     // 状态 1-进行中 2-已结束
+	CreatedAt    time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 创建时间
+	UpdatedAt    time.Time `gorm:"column:updated_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 更新时间
+}
+
+func (FlashSaleSession) TableName() string {
+	return "flash_sale_sessions"
+}
+
+// FlashSaleOrder 秒杀下单记录, 记录每个秒杀请求的处理结果和对应的订单
+type FlashSaleOrder struct {
+	ID            int64     `gorm:"column:id;primary_key;AUTO_INCREMENT"`                        // 主键ID
+	RequestNo     string    `gorm:"column:request_no;NOT NULL;uniqueIndex:uniq_request_no"`      // 秒杀请求号
+	SessionId     int64     `gorm:"column:session_id;NOT NULL;index:idx_session_user"`           // 场次ID
+	UserId        int64     `gorm:"column:user_id;NOT NULL;index:idx_session_user"`              // 用户ID
+	CommodityId   int64     `gorm:"column:commodity_id;NOT NULL"`                                // 商品ID
+	CommodityNum  int       `gorm:"column:commodity_num;default:0;NOT NULL"`                     // 抢购数量
+	OrderNo       string    `gorm:"column:order_no;NOT NULL;index:idx_order_no"`                 // 订单号, 创建订单失败时为空
+	Status        int       `gorm:"column:status;default:1;NOT NULL"`                            // 状态 1-已创建订单 2-创建订单失败 3-已释放库存
+	FailReason    string    `gorm:"column:fail_reason;NOT NULL"`                                 // 创建订单失败的原因
+	StockReturned int       `gorm:"column:stock_returned;default:0;NOT NULL;index:idx_returned"` // 创建订单失败或释放后是否已经退还了 Redis 中预扣的库存 0-否 1-是
+	CreatedAt     time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP;NOT NULL"`        // 创建时间
+	UpdatedAt     time.Time `gorm:"column:updated_at;default:CURRENT_TIMESTAMP;NOT NULL"`        // 更新时间
+}
+
+func (FlashSaleOrder) TableName() string {
+	return "flash_sale_orders"
+}
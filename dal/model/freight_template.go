@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/plugin/soft_delete"
+)
+
+// FreightTemplate 运费模板, 下单时使用默认模板计算运费
+type FreightTemplate struct {
+	ID         int64                 `gorm:"column:id;primary_key;AUTO_INCREMENT"`                 // 运费模板ID
+	Name       string                `gorm:"column:name;NOT NULL"`                                 // 模板名称
+	ChargeType int                   `gorm:"column:charge_type;default:1;NOT NULL"`                // 计费方式 1-按件数 2-按重量
+	IsDefault  int                   `gorm:"column:is_default;default:0;NOT NULL"`                 // 是否为默认模板 0-否 1-是, 同一时间只有一个默认模板
+	IsDel      soft_delete.DeletedAt `gorm:"softDelete:flag"`                                      // 0-未删除 1-已删除
+	CreatedAt  time.Time             `gorm:"column:created_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 创建时间
+	UpdatedAt  time.Time             `gorm:"column:updated_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 更新时间
+}
+
+func (FreightTemplate) TableName() string {
+	return "freight_templates"
+}
+
+// FreightTemplateRule 运费模板的地区计费规则
+type FreightTemplateRule struct {
+	ID             int64     `gorm:"column:id;primary_key;AUTO_INCREMENT"`                 // 规则ID
+	TemplateId     int64     `gorm:"column:template_id;NOT NULL;index:idx_template_id"`    // 运费模板ID
+	Provinces      string    `gorm:"column:provinces;NOT NULL"`                            // 适用的省份, 多个用逗号分隔, 为空时适用于其他没有单独设置规则的省份
+	FirstUnit      int       `gorm:"column:first_unit;default:0;NOT NULL"`                 // 首件数或首重（克）
+	FirstFee       int       `gorm:"column:first_fee;default:0;NOT NULL"`                  // 首件或首重的运费（分）
+	AdditionalUnit int       `gorm:"column:additional_unit;default:0;NOT NULL"`            // 续件数或续重（克）
+	AdditionalFee  int       `gorm:"column:additional_fee;default:0;NOT NULL"`             // 每个续件或续重单位的运费（分）
+	FreeThreshold  int       `gorm:"column:free_threshold;default:0;NOT NULL"`             // 包邮门槛（分）, 商品优惠后的金额达到门槛时免运费, 0 表示不包邮
+	CreatedAt      time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 创建时间
+	UpdatedAt      time.Time `gorm:"column:updated_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 更新时间
+}
+
+func (FreightTemplateRule) TableName() string {
+	return "freight_template_rules"
+}
@@ -0,0 +1,59 @@
+package model
+
+import "time"
+
+// GroupBuyActivity 拼团活动, 用户在活动时间内按拼团价开团, 其他用户通过分享码参团
+type GroupBuyActivity struct {
+	ID          int64     `gorm:"column:id;primary_key;AUTO_INCREMENT"`                 // 活动ID
+	Name        string    `gorm:"column:name;NOT NULL"`                                 // 活动名称
+	CommodityId int64     `gorm:"column:commodity_id;NOT NULL;index:idx_commodity_id"`  // 拼团的商品ID
+	GroupPrice  int       `gorm:"column:group_price;default:0;NOT NULL"`                // 拼团价（分）
+	GroupSize   int       `gorm:"column:group_size;default:2;NOT NULL"`                 // 成团人数, 包含团长
+	TimeLimit   int       `gorm:"column:time_limit;default:0;NOT NULL"`                 // 成团时限, 单位: 分钟, 从开团时开始计算
+	StartTime   time.Time `gorm:"column:start_time;NOT NULL"`                           // 开始时间
+	EndTime     time.Time `gorm:"column:end_time;NOT NULL"`                             // 结束时间
+	Status      int       `gorm:"column:status;default:1;NOT NULL"`                     // 状态 1-进行中 2-已停止
+	CreatedAt   time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 创建时间
+	UpdatedAt   time.Time `gorm:"column:updated_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 更新时间
+}
+
+func (GroupBuyActivity) TableName() string {
+	return "group_buy_activities"
+}
+
+// GroupBuyGroup 用户开的团, 成团时限内支付的成员达到成团人数时拼团成功
+type GroupBuyGroup struct {
+	ID           int64     `gorm:"column:id;primary_key;AUTO_INCREMENT"`                     // 拼团ID
+	ActivityId   int64     `gorm:"column:activity_id;NOT NULL;index:idx_activity_id"`        // 拼团活动ID
+	CommodityId  int64     `gorm:"column:commodity_id;NOT NULL"`                             // 商品ID
+	ShareCode    string    `gorm:"column:share_code;NOT NULL;uniqueIndex:uniq_share_code"`   // 分享码, 其他用户通过它参团
+	LeaderUserId int64     `gorm:"column:leader_user_id;NOT NULL"`                           // 团长的用户ID
+	GroupSize    int       `gorm:"column:group_size;default:2;NOT NULL"`                     // 成团人数, 开团时从活动中复制
+	MemberNum    int       `gorm:"column:member_num;default:0;NOT NULL"`                     // 已参团并且没有退出的成员数
+	PaidNum      int       `gorm:"column:paid_num;default:0;NOT NULL"`                       // 已支付的成员数
+	ExpireAt     time.Time `gorm:"column:expire_at;NOT NULL;index:idx_status_expire"`        // 成团的截止时间
+	Status       int       `gorm:"column:status;default:1;NOT NULL;index:idx_status_expire"` // 状态 1-拼团中 2-拼团成功 3-拼团失败
+	CreatedAt    time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP;NOT NULL"`     // 创建时间
+	UpdatedAt    time.Time `gorm:"column:updated_at;default:CURRENT_TIMESTAMP;NOT NULL"`     // 更新时间
+}
+
+func (GroupBuyGroup) TableName() string {
+	return "group_buy_groups"
+}
+
+// GroupBuyMember 拼团成员, 每个成员参团时创建一个订单
+type GroupBuyMember struct {
+	ID        int64     `gorm:"column:id;primary_key;AUTO_INCREMENT"`                 // 主键ID
+	GroupId   int64     `gorm:"column:group_id;NOT NULL;index:idx_group_id"`          // 拼团ID
+	UserId    int64     `gorm:"column:user_id;NOT NULL"`                              // 用户ID
+	OrderNo   string    `gorm:"column:order_no;NOT NULL;uniqueIndex:uniq_order_no"`   // 参团的订单号
+	IsLeader  int       `gorm:"column:is_leader;default:0;NOT NULL"`                  // 是否是团长 0-否 1-是
+	Status    int       `gorm:"column:status;default:1;NOT NULL"`                     // 状态 1-已参团 2-已支付 3-已退出 4-退款中 5-已退款
+	RefundNo  string    `gorm:"column:refund_no;NOT NULL"`                            // 拼团失败后为订单创建的退款单号
+	CreatedAt time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 创建时间
+	UpdatedAt time.Time `gorm:"column:updated_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 更新时间
+}
+
+func (GroupBuyMember) TableName() string {
+	return "group_buy_members"
+}
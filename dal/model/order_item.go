@@ -12,6 +12,13 @@ type OrderItem struct {
 	CommodityImg          string    `gorm:"column:commodity_img;NOT NULL"`                        // 下单时商品的主图(订单快照)
 	CommoditySellingPrice int       `gorm:"column:commodity_selling_price;default:0;NOT NULL"`    // 下单时商品的价格(订单快照)
 	CommodityNum          int       `gorm:"column:commodity_num;default:1;NOT NULL"`              // 数量(订单快照)
+	BillMoney             int       `gorm:"column:bill_money;default:0;NOT NULL"`                 // 减免、优惠前的金额（分）
+	VipDiscountMoney      int       `gorm:"column:vip_discount_money;default:0;NOT NULL"`         // 分摊到的 VIP 减免金额（分）
+	CouponDiscountMoney   int       `gorm:"column:coupon_discount_money;default:0;NOT NULL"`      // 分摊到的优惠券减免金额（分）
+	DiscountMoney         int       `gorm:"column:discount_money;default:0;NOT NULL"`             // 分摊到的满减活动减免金额（分）
+	PayMoney              int       `gorm:"column:pay_money;default:0;NOT NULL"`                  // 分摊后的实付金额（分）
+	RefundedNum           int       `gorm:"column:refunded_num;default:0;NOT NULL"`               // 已退款的数量
+	RefundedMoney         int       `gorm:"column:refunded_money;default:0;NOT NULL"`             // 已退款的金额（分）
 	CreatedAt             time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 创建时间
 	UpdatedAt             time.Time `gorm:"column:updated_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 更新时间
 }
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+)
+
+// OrderRefund 订单退款表
+type OrderRefund struct {
+	ID                int64     `gorm:"column:id;primary_key;AUTO_INCREMENT"`                    // 退款ID
+	RefundNo          string    `gorm:"column:refund_no;NOT NULL;uniqueIndex:uk_refund_no"`      // 业务退款单号
+	OrderId           int64     `gorm:"column:order_id;NOT NULL;index:idx_order_id"`             // 订单ID
+	OrderNo           string    `gorm:"column:order_no;NOT NULL"`                                // 订单号
+	UserId            int64     `gorm:"column:user_id;NOT NULL"`                                 // 用户ID
+	PayType           int       `gorm:"column:pay_type;default:0;NOT NULL"`                      // 订单的支付类型 1-微信支付 2-支付宝
+	RefundMoney       int       `gorm:"column:refund_money;default:0;NOT NULL"`                  // 退款金额（分）
+	Reason            string    `gorm:"column:reason;NOT NULL"`                                  // 用户申请退款的原因
+	RejectReason      string    `gorm:"column:reject_reason;NOT NULL"`                           // 商家拒绝退款的原因
+	Status            int       `gorm:"column:status;default:0;NOT NULL"`                        // 0-待审核 1-已拒绝 2-退款中 3-退款成功 4-退款失败
+	OrderStatusBefore int       `gorm:"column:order_status_before;default:0;NOT NULL"`           // 申请退款前的订单状态, 拒绝退款或退款失败时恢复
+	RefundTransId     string    `gorm:"column:refund_trans_id;NOT NULL"`                         // 支付平台的退款单号
+	RefundedAt        time.Time `gorm:"column:refunded_at;default:1970-01-01 00:00:00;NOT NULL"` // 退款成功的时间
+	CreatedAt         time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP;NOT NULL"`    // 创建时间
+	UpdatedAt         time.Time `gorm:"column:updated_at;default:CURRENT_TIMESTAMP;NOT NULL"`    // 更新时间
+}
+
+func (OrderRefund) TableName() string {
+	return "order_refunds"
+}
+
+// OrderRefundItem 退款单明细, 记录退款单退的是哪些订单明细以及退款的数量和金额
+type OrderRefundItem struct {
+	ID          int64     `gorm:"column:id;primary_key;AUTO_INCREMENT"`                 // 退款明细ID
+	RefundId    int64     `gorm:"column:refund_id;NOT NULL;index:idx_refund_id"`        // 退款单ID
+	OrderItemId int64     `gorm:"column:order_item_id;NOT NULL"`                        // 订单明细ID
+	CommodityId int64     `gorm:"column:commodity_id;NOT NULL"`                         // 商品ID
+	RefundNum   int       `gorm:"column:refund_num;default:0;NOT NULL"`                 // 退款的商品数量
+	RefundMoney int       `gorm:"column:refund_money;default:0;NOT NULL"`               // 退款金额（分）
+	CreatedAt   time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 创建时间
+	UpdatedAt   time.Time `gorm:"column:updated_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 更新时间
+}
+
+func (OrderRefundItem) TableName() string {
+	return "order_refund_items"
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+)
+
+// OrderShipment 订单发货记录表
+type OrderShipment struct {
+	ID          int64     `gorm:"column:id;primary_key;AUTO_INCREMENT"`                     // 发货记录ID
+	ShipmentNo  string    `gorm:"column:shipment_no;NOT NULL;uniqueIndex:uk_shipment_no"`   // 发货单号
+	OrderId     int64     `gorm:"column:order_id;NOT NULL;index:idx_order_id"`              // 订单ID
+	OrderNo     string    `gorm:"column:order_no;NOT NULL"`                                 // 订单号
+	CarrierCode string    `gorm:"column:carrier_code;NOT NULL"`                             // 物流公司编码
+	CarrierName string    `gorm:"column:carrier_name;NOT NULL"`                             // 物流公司名称
+	TrackingNo  string    `gorm:"column:tracking_no;NOT NULL"`                              // 物流单号
+	Status      int       `gorm:"column:status;default:0;NOT NULL"`                         // 0-已发货 1-已送达
+	ShippedAt   time.Time `gorm:"column:shipped_at;default:CURRENT_TIMESTAMP;NOT NULL"`     // 发货时间
+	DeliveredAt time.Time `gorm:"column:delivered_at;default:1970-01-01 00:00:00;NOT NULL"` // 送达时间
+	CreatedAt   time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP;NOT NULL"`     // 创建时间
+	UpdatedAt   time.Time `gorm:"column:updated_at;default:CURRENT_TIMESTAMP;NOT NULL"`     // 更新时间
+}
+
+func (OrderShipment) TableName() string {
+	return "order_shipments"
+}
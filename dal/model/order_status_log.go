@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+)
+
+// OrderStatusLog 订单状态变更记录表
+type OrderStatusLog struct {
+	ID         int64     `gorm:"column:id;primary_key;AUTO_INCREMENT"`                 // 记录ID
+	OrderId    int64     `gorm:"column:order_id;NOT NULL;index:idx_order_id"`          // 订单ID
+	OrderNo    string    `gorm:"column:order_no;NOT NULL"`                             // 订单号
+	FromStatus int       `gorm:"column:from_status;default:0;NOT NULL"`                // 变更前的订单状态
+	ToStatus   int       `gorm:"column:to_status;default:0;NOT NULL"`                  // 变更后的订单状态
+	Actor      int       `gorm:"column:actor;default:0;NOT NULL"`                      // 操作方 1-用户 2-商家 3-系统 4-支付平台
+	ActorId    int64     `gorm:"column:actor_id;default:0;NOT NULL"`                   // 操作人ID, 操作方是用户时为用户ID
+	Reason     string    `gorm:"column:reason;NOT NULL"`                               // 变更原因
+	CreatedAt  time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 创建时间
+}
+
+func (OrderStatusLog) TableName() string {
+	return "order_status_logs"
+}
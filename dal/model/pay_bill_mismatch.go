@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+)
+
+// PayBillMismatch 支付账单对账差异表, 记录本地订单和支付平台交易账单对不上的交易
+type PayBillMismatch struct {
+	ID                int64     `gorm:"column:id;primary_key;AUTO_INCREMENT"`                            // 差异记录ID
+	BillDate          string    `gorm:"column:bill_date;NOT NULL;index:idx_bill_date_pay_type"`          // 账单日期 格式 2006-01-02
+	PayType           int       `gorm:"column:pay_type;default:0;NOT NULL;index:idx_bill_date_pay_type"` // 支付方式 1-微信支付 2-支付宝
+	OrderNo           string    `gorm:"column:order_no;NOT NULL"`                                        // 订单号
+	MismatchType      int       `gorm:"column:mismatch_type;default:0;NOT NULL"`                         // 1-本地缺失 2-渠道缺失 3-金额不一致 4-状态不一致
+	LocalPayTransId   string    `gorm:"column:local_pay_trans_id;NOT NULL"`                              // 本地订单记录的支付平台交易号
+	ChannelTransId    string    `gorm:"column:channel_trans_id;NOT NULL"`                                // 账单中的支付平台交易号
+	LocalPayMoney     int       `gorm:"column:local_pay_money;default:0;NOT NULL"`                       // 本地订单的支付金额（分）
+	ChannelPayMoney   int       `gorm:"column:channel_pay_money;default:0;NOT NULL"`                     // 账单中的订单金额（分）
+	LocalPayState     int       `gorm:"column:local_pay_state;default:0;NOT NULL"`                       // 本地订单的支付状态
+	ChannelTradeState string    `gorm:"column:channel_trade_state;NOT NULL"`                             // 账单中的交易状态
+	Remark            string    `gorm:"column:remark;NOT NULL"`                                          // 差异说明
+	CreatedAt         time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP;NOT NULL"`            // 创建时间
+	UpdatedAt         time.Time `gorm:"column:updated_at;default:CURRENT_TIMESTAMP;NOT NULL"`            // 更新时间
+}
+
+func (PayBillMismatch) TableName() string {
+	return "pay_bill_mismatches"
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/plugin/soft_delete"
+)
+
+// VipTier 会员等级, 用户已完成订单的累计消费达到升级门槛后成为这个等级的会员
+type VipTier struct {
+	ID           int64                 `gorm:"column:id;primary_key;AUTO_INCREMENT"`                 // 会员等级ID
+	Name         string                `gorm:"column:name;NOT NULL"`                                 // 等级名称
+	Level        int                   `gorm:"column:level;default:1;NOT NULL"`                      // 等级, 数字越大等级越高
+	DiscountRate int                   `gorm:"column:discount_rate;default:0;NOT NULL"`              // 会员折扣减免的百分比, 比如 5 表示 95 折
+	UpgradeSpend int                   `gorm:"column:upgrade_spend;default:0;NOT NULL"`              // 升级门槛, 已完成订单的累计消费金额（分）
+	ValidDays    int                   `gorm:"column:valid_days;default:0;NOT NULL"`                 // 成为会员或续期后的有效天数, 0 表示永久有效
+	IsDel        soft_delete.DeletedAt `gorm:"softDelete:flag"`                                      // 0-未删除 1-已删除
+	CreatedAt    time.Time             `gorm:"column:created_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 创建时间
+	UpdatedAt    time.Time             `gorm:"column:updated_at;default:CURRENT_TIMESTAMP;NOT NULL"` // 更新时间
+}
+
+func (VipTier) TableName() string {
+	return "vip_tiers"
+}
+
+// UserVip 用户的会员信息, 订单完成时累计消费金额并按累计消费升级或续期
+type UserVip struct {
+	ID         int64     `gorm:"column:id;primary_key;AUTO_INCREMENT"`                  // 主键ID
+	UserId     int64     `gorm:"column:user_id;NOT NULL;uniqueIndex:uniq_user_id"`      // 用户ID
+	TierId     int64     `gorm:"column:tier_id;default:0;NOT NULL"`                     // 会员等级ID, 0 表示还不是会员
+	TotalSpend int       `gorm:"column:total_spend;default:0;NOT NULL"`                 // 已完成订单的累计消费金额（分）
+	ExpireAt   time.Time `gorm:"column:expire_at;default:1970-01-01 00:00:00;NOT NULL"` // 会员过期时间
+	CreatedAt  time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP;NOT NULL"`  // 创建时间
+	UpdatedAt  time.Time `gorm:"column:updated_at;default:CURRENT_TIMESTAMP;NOT NULL"`  // 更新时间
+}
+
+func (UserVip) TableName() string {
+	return "user_vips"
+}
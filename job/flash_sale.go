@@ -0,0 +1,34 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/hd2yao/go-mall/config"
+	"github.com/hd2yao/go-mall/logic/appservice"
+)
+
+const (
+	defaultFlashSaleQueueInterval      = 200 * time.Millisecond
+	defaultFlashSaleCompensateInterval = 30 * time.Second
+)
+
+func startFlashSaleJobs(ctx context.Context) {
+	queueInterval := config.App.FlashSale.QueueInterval
+	if queueInterval <= 0 {
+		queueInterval = defaultFlashSaleQueueInterval
+	}
+	compensateInterval := config.App.FlashSale.CompensateInterval
+	if compensateInterval <= 0 {
+		compensateInterval = defaultFlashSaleCompensateInterval
+	}
+
+	// 从下单队列中取出秒杀请求创建订单
+	go runPeriodically(ctx, "ConsumeFlashSaleRequests", queueInterval, func(ctx context.Context) error {
+		return appservice.NewFlashSaleAppSvc(ctx).ConsumeFlashSaleRequests()
+	})
+	// 结束过期的秒杀场次, 退还没有创建订单或订单已取消、关闭的 Redis 库存
+	go runPeriodically(ctx, "CompensateFlashSale", compensateInterval, func(ctx context.Context) error {
+		return appservice.NewFlashSaleAppSvc(ctx).CompensateFlashSale(compensateInterval)
+	})
+}
@@ -0,0 +1,23 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/hd2yao/go-mall/config"
+	"github.com/hd2yao/go-mall/logic/appservice"
+)
+
+const defaultGroupBuyExpireScanInterval = 30 * time.Second
+
+func startGroupBuyJobs(ctx context.Context) {
+	scanInterval := config.App.GroupBuy.ExpireScanInterval
+	if scanInterval <= 0 {
+		scanInterval = defaultGroupBuyExpireScanInterval
+	}
+
+	// 把超过成团时限的拼团标记为失败, 关闭没有支付的订单, 已经支付的订单自动全额退款
+	go runPeriodically(ctx, "ExpireGroupBuys", scanInterval, func(ctx context.Context) error {
+		return appservice.NewGroupBuyAppSvc(ctx).ExpireGroupBuys(scanInterval)
+	})
+}
@@ -0,0 +1,56 @@
+package job
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+
+	"github.com/hd2yao/go-mall/common/logger"
+	"github.com/hd2yao/go-mall/common/util"
+)
+
+// 后台定时任务, 随 HTTP 服务一起启动
+// 多个服务实例会同时运行这些任务, 每个任务需要自己保证同一份数据不会被重复处理
+
+// Start 启动所有后台任务, ctx 取消后任务退出
+func Start(ctx context.Context) {
+	startOrderJobs(ctx)
+	startFlashSaleJobs(ctx)
+	startGroupBuyJobs(ctx)
+}
+
+// runPeriodically 每隔 interval 执行一次 task, 直到 ctx 被取消
+func runPeriodically(ctx context.Context, name string, interval time.Duration, task func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			runTask(ctx, name, task)
+		}
+	}
+}
+
+// runTask 执行一次任务, 任务出错或者 panic 时记录日志, 不影响任务的下一次执行
+func runTask(ctx context.Context, name string, task func(ctx context.Context) error) {
+	taskCtx := newTaskContext(ctx)
+	log := logger.New(taskCtx)
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("JobPanic", "job", name, "panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+	if err := task(taskCtx); err != nil {
+		log.Error("JobError", "job", name, "err", err)
+	}
+}
+
+// newTaskContext 每次执行任务时生成新的 traceId, 方便在日志中区分每次执行
+func newTaskContext(ctx context.Context) context.Context {
+	spanId := util.GenerateSpanID("127.0.0.1")
+	ctx = context.WithValue(ctx, "traceId", spanId)
+	ctx = context.WithValue(ctx, "spanId", spanId)
+	return ctx
+}
@@ -0,0 +1,65 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/hd2yao/go-mall/config"
+	"github.com/hd2yao/go-mall/logic/appservice"
+)
+
+const (
+	defaultCloseQueueInterval = 5 * time.Second
+	defaultUnpaidScanInterval = 10 * time.Minute
+	defaultAutoFinishInterval = time.Hour
+	defaultReconcileInterval  = time.Minute
+	defaultPayBillInterval    = 10 * time.Minute
+)
+
+func startOrderJobs(ctx context.Context) {
+	closeQueueInterval := config.App.Order.CloseQueueInterval
+	if closeQueueInterval <= 0 {
+		closeQueueInterval = defaultCloseQueueInterval
+	}
+	unpaidScanInterval := config.App.Order.UnpaidScanInterval
+	if unpaidScanInterval <= 0 {
+		unpaidScanInterval = defaultUnpaidScanInterval
+	}
+	autoFinishInterval := config.App.Order.AutoFinishScanInterval
+	if autoFinishInterval <= 0 {
+		autoFinishInterval = defaultAutoFinishInterval
+	}
+	reconcileInterval := config.App.Order.PayReconcileInterval
+	if reconcileInterval <= 0 {
+		reconcileInterval = defaultReconcileInterval
+	}
+	payBillInterval := config.App.Order.PayBillCheckInterval
+	if payBillInterval <= 0 {
+		payBillInterval = defaultPayBillInterval
+	}
+
+	// 关闭超时未支付的订单
+	go runPeriodically(ctx, "CloseDueUnpaidOrders", closeQueueInterval, func(ctx context.Context) error {
+		return appservice.NewOrderAppSvc(ctx).CloseDueUnpaidOrders()
+	})
+	// 从数据库扫描超时未支付的订单放入关单延迟队列
+	go runPeriodically(ctx, "EnqueueTimeoutUnpaidOrders", unpaidScanInterval, func(ctx context.Context) error {
+		return appservice.NewOrderAppSvc(ctx).EnqueueTimeoutUnpaidOrders(unpaidScanInterval)
+	})
+	// 支付对账, 主动查询没有收到支付结果通知的订单
+	go runPeriodically(ctx, "ReconcileUnpaidOrders", reconcileInterval, func(ctx context.Context) error {
+		return appservice.NewOrderAppSvc(ctx).ReconcileUnpaidOrders(reconcileInterval)
+	})
+	// 下载前一天的微信支付交易账单和本地订单对账
+	go runPeriodically(ctx, "CheckDailyWxTradeBill", payBillInterval, func(ctx context.Context) error {
+		return appservice.NewOrderAppSvc(ctx).CheckDailyWxTradeBill()
+	})
+	// 已送达超过期限的订单自动确认收货
+	go runPeriodically(ctx, "AutoConfirmDeliveredOrders", autoFinishInterval, func(ctx context.Context) error {
+		return appservice.NewOrderAppSvc(ctx).AutoConfirmDeliveredOrders()
+	})
+	// 过了评价期的订单自动完成
+	go runPeriodically(ctx, "AutoCompleteOrders", autoFinishInterval, func(ctx context.Context) error {
+		return appservice.NewOrderAppSvc(ctx).AutoCompleteOrders()
+	})
+}
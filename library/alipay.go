@@ -0,0 +1,421 @@
+package library
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/common/util/httptool"
+	"github.com/hd2yao/go-mall/logic/do"
+	"github.com/hd2yao/go-mall/resources"
+)
+
+// 对接支付宝开放平台的 Lib
+// 文档: https://opendocs.alipay.com/open/270/105898
+
+type AliPayLib struct {
+	ctx       context.Context
+	payConfig AliPayConfig
+}
+
+type AliPayConfig struct {
+	AppId      string
+	GatewayUrl string // 支付宝网关地址, 沙箱环境为 https://openapi-sandbox.dl.alipaydev.com/gateway.do
+	NotifyUrl  string // 支付成功后, 异步通知的地址
+	ReturnUrl  string // 网页支付完成后, 同步跳转回商户的页面地址
+
+	PrivateKeyPath      string // 应用私钥文件路径, 为空时使用 resources 目录下的私钥
+	AliPayPublicKeyPath string // 支付宝公钥文件路径, 为空时使用 resources 目录下的公钥
+}
+
+func NewAliPayLib(ctx context.Context, payConfig AliPayConfig) *AliPayLib {
+	if payConfig.GatewayUrl == "" {
+		payConfig.GatewayUrl = aliPayGatewayUrl
+	}
+	return &AliPayLib{
+		ctx:       ctx,
+		payConfig: payConfig,
+	}
+}
+
+const aliPayGatewayUrl = "https://openapi.alipay.com/gateway.do"
+
+// 支付宝的不同支付场景对应的接口和产品码
+const (
+	aliPayMethodPagePay = "alipay.trade.page.pay" // 电脑网站支付
+	aliPayMethodWapPay  = "alipay.trade.wap.pay"  // 手机网站支付
+	aliPayMethodAppPay  = "alipay.trade.app.pay"  // APP 支付
+
+	aliPayMethodRefund = "alipay.trade.refund" // 交易退款
+	aliPayMethodQuery  = "alipay.trade.query"  // 交易查询
+	aliPayMethodClose  = "alipay.trade.close"  // 交易关闭
+
+	aliPayProductPagePay = "FAST_INSTANT_TRADE_PAY"
+	aliPayProductWapPay  = "QUICK_WAP_WAY"
+	aliPayProductAppPay  = "QUICK_MSECURITY_PAY"
+)
+
+// 支付宝接口的应答码
+const (
+	aliPayCodeSuccess          = "10000"               // 接口调用成功
+	aliPaySubCodeTradeNotExist = "ACQ.TRADE_NOT_EXIST" // 交易不存在
+)
+
+// 支付宝的交易状态
+const (
+	AliPayTradeStatusWaitBuyerPay = "WAIT_BUYER_PAY" // 交易创建, 等待买家付款
+	AliPayTradeStatusClosed       = "TRADE_CLOSED"   // 未付款交易超时关闭, 或支付完成后全额退款
+	AliPayTradeStatusSuccess      = "TRADE_SUCCESS"  // 交易支付成功
+	AliPayTradeStatusFinished     = "TRADE_FINISHED" // 交易结束, 不可退款
+	// 支付宝没有这个订单号的交易(用户还没有扫码或登录支付), 不是支付宝返回的交易状态
+	AliPayTradeStatusNotExist = "TRADE_NOT_EXIST"
+)
+
+// AliPayBizContent 支付宝下单接口的业务参数
+type AliPayBizContent struct {
+	OutTradeNo  string `json:"out_trade_no"` // 业务的订单号
+	TotalAmount string `json:"total_amount"` // 订单总金额, 单位为元, 精确到小数点后两位
+	Subject     string `json:"subject"`      // 订单标题
+	ProductCode string `json:"product_code"` // 销售产品码
+}
+
+// AliPayInvokeInfo 前端调起支付宝支付的参数信息
+type AliPayInvokeInfo struct {
+	PayUrl      string `json:"pay_url,omitempty"`      // 电脑网站、手机网站支付时前端跳转的支付地址
+	OrderString string `json:"order_string,omitempty"` // APP 支付时客户端调用 SDK 的订单信息
+}
+
+// CreatePagePay 创建电脑网站支付, 返回前端需要跳转的支付地址
+// 支付宝文档: https://opendocs.alipay.com/open/59da99d0_alipay.trade.page.pay
+func (apl *AliPayLib) CreatePagePay(order *do.Order) (*AliPayInvokeInfo, error) {
+	params, err := apl.genTradePayParams(order, aliPayMethodPagePay, aliPayProductPagePay)
+	if err != nil {
+		return nil, errcode.Wrap("AliPayLibCreatePagePayError", err)
+	}
+	return &AliPayInvokeInfo{PayUrl: apl.payConfig.GatewayUrl + "?" + params.Encode()}, nil
+}
+
+// CreateWapPay 创建手机网站支付, 返回前端需要跳转的支付地址
+// 支付宝文档: https://opendocs.alipay.com/open/29ae8cb6_alipay.trade.wap.pay
+func (apl *AliPayLib) CreateWapPay(order *do.Order) (*AliPayInvokeInfo, error) {
+	params, err := apl.genTradePayParams(order, aliPayMethodWapPay, aliPayProductWapPay)
+	if err != nil {
+		return nil, errcode.Wrap("AliPayLibCreateWapPayError", err)
+	}
+	return &AliPayInvokeInfo{PayUrl: apl.payConfig.GatewayUrl + "?" + params.Encode()}, nil
+}
+
+// CreateAppPay 创建 APP 支付, 返回客户端调用支付宝 SDK 需要的订单信息
+// 支付宝文档: https://opendocs.alipay.com/open/cd12c885_alipay.trade.app.pay
+func (apl *AliPayLib) CreateAppPay(order *do.Order) (*AliPayInvokeInfo, error) {
+	params, err := apl.genTradePayParams(order, aliPayMethodAppPay, aliPayProductAppPay)
+	if err != nil {
+		return nil, errcode.Wrap("AliPayLibCreateAppPayError", err)
+	}
+	// APP 支付不需要同步跳转地址
+	return &AliPayInvokeInfo{OrderString: params.Encode()}, nil
+}
+
+// AliPayRefundReply 支付宝退款接口的应答
+type AliPayRefundReply struct {
+	Code         string `json:"code"` // 10000 表示接口调用成功
+	Msg          string `json:"msg"`
+	SubCode      string `json:"sub_code"`
+	SubMsg       string `json:"sub_msg"`
+	TradeNo      string `json:"trade_no"` // 支付宝交易号
+	OutTradeNo   string `json:"out_trade_no"`
+	FundChange   string `json:"fund_change"` // 本次退款是否发生了资金变化 Y-是 N-否
+	RefundFee    string `json:"refund_fee"`  // 退款总金额
+	GmtRefundPay string `json:"gmt_refund_pay"`
+}
+
+// CreateRefund 申请退款, 支付宝的退款接口同步返回退款结果
+// 支付宝文档: https://opendocs.alipay.com/open/f60979b3_alipay.trade.refund
+func (apl *AliPayLib) CreateRefund(order *do.Order, refund *do.OrderRefund) (*AliPayRefundReply, error) {
+	bizContent := map[string]string{
+		"out_trade_no":   order.OrderNo,
+		"refund_amount":  AliPayCentToAmount(refund.RefundMoney),
+		"out_request_no": refund.RefundNo, // 同一笔交易多次退款时用来区分退款请求
+		"refund_reason":  refund.Reason,
+	}
+	response, err := apl.callApi(aliPayMethodRefund, bizContent)
+	if err != nil {
+		return nil, errcode.Wrap("AliPayLibCreateRefundError", err)
+	}
+	refundReply := new(AliPayRefundReply)
+	if err = json.Unmarshal(response, refundReply); err != nil {
+		return nil, errcode.Wrap("AliPayLibCreateRefundError", err)
+	}
+	if refundReply.Code != aliPayCodeSuccess {
+		return nil, errcode.Wrap("AliPayLibCreateRefundError", fmt.Errorf("refund failed, sub_code: %s, sub_msg: %s", refundReply.SubCode, refundReply.SubMsg))
+	}
+	return refundReply, nil
+}
+
+// AliPayTradeQueryReply 支付宝交易查询接口的应答
+type AliPayTradeQueryReply struct {
+	Code        string `json:"code"`
+	Msg         string `json:"msg"`
+	SubCode     string `json:"sub_code"`
+	SubMsg      string `json:"sub_msg"`
+	TradeNo     string `json:"trade_no"` // 支付宝交易号
+	OutTradeNo  string `json:"out_trade_no"`
+	TradeStatus string `json:"trade_status"`
+	TotalAmount string `json:"total_amount"`
+	SendPayDate string `json:"send_pay_date"` // 交易的打款时间
+}
+
+// QueryTrade 按业务订单号查询支付宝的交易
+// 用户还没有扫码或登录支付时支付宝没有这笔交易, 此时返回的交易状态为 AliPayTradeStatusNotExist
+// 支付宝文档: https://opendocs.alipay.com/open/82ea786a_alipay.trade.query
+func (apl *AliPayLib) QueryTrade(orderNo string) (*AliPayTradeQueryReply, error) {
+	response, err := apl.callApi(aliPayMethodQuery, map[string]string{"out_trade_no": orderNo})
+	if err != nil {
+		return nil, errcode.Wrap("AliPayLibQueryTradeError", err)
+	}
+	queryReply := new(AliPayTradeQueryReply)
+	if err = json.Unmarshal(response, queryReply); err != nil {
+		return nil, errcode.Wrap("AliPayLibQueryTradeError", err)
+	}
+	if queryReply.SubCode == aliPaySubCodeTradeNotExist {
+		queryReply.OutTradeNo = orderNo
+		queryReply.TradeStatus = AliPayTradeStatusNotExist
+		return queryReply, nil
+	}
+	if queryReply.Code != aliPayCodeSuccess {
+		return nil, errcode.Wrap("AliPayLibQueryTradeError", fmt.Errorf("query failed, sub_code: %s, sub_msg: %s", queryReply.SubCode, queryReply.SubMsg))
+	}
+	return queryReply, nil
+}
+
+// CloseTrade 关闭等待买家付款的交易, 支付宝没有这笔交易时不需要关闭
+// 支付宝文档: https://opendocs.alipay.com/open/8dc9ebb3_alipay.trade.close
+func (apl *AliPayLib) CloseTrade(orderNo string) error {
+	response, err := apl.callApi(aliPayMethodClose, map[string]string{"out_trade_no": orderNo})
+	if err != nil {
+		return errcode.Wrap("AliPayLibCloseTradeError", err)
+	}
+	closeReply := struct {
+		Code    string `json:"code"`
+		SubCode string `json:"sub_code"`
+		SubMsg  string `json:"sub_msg"`
+	}{}
+	if err = json.Unmarshal(response, &closeReply); err != nil {
+		return errcode.Wrap("AliPayLibCloseTradeError", err)
+	}
+	if closeReply.Code != aliPayCodeSuccess && closeReply.SubCode != aliPaySubCodeTradeNotExist {
+		return errcode.Wrap("AliPayLibCloseTradeError", fmt.Errorf("close failed, sub_code: %s, sub_msg: %s", closeReply.SubCode, closeReply.SubMsg))
+	}
+	return nil
+}
+
+// callApi 调用支付宝开放平台的接口, 验证应答的签名后返回接口的应答内容
+// 应答的签名是对 xxx_response 的原始 JSON 字符串计算的, 需要用 json.RawMessage 保留原始内容
+func (apl *AliPayLib) callApi(method string, bizContent interface{}) (json.RawMessage, error) {
+	bizContentBytes, _ := json.Marshal(bizContent)
+	params := apl.genCommonParams(method)
+	params.Set("biz_content", string(bizContentBytes))
+	sign, err := apl.sign(params)
+	if err != nil {
+		return nil, err
+	}
+	params.Set("sign", sign)
+
+	_, replyBody, err := httptool.Post(apl.ctx, apl.payConfig.GatewayUrl, []byte(params.Encode()), httptool.WithHeaders(map[string]string{
+		"Content-Type": "application/x-www-form-urlencoded;charset=utf-8",
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	reply := make(map[string]json.RawMessage)
+	if err = json.Unmarshal(replyBody, &reply); err != nil {
+		return nil, err
+	}
+	// 应用ID、签名等网关层面的错误在 error_response 中返回, 没有接口的应答内容
+	if errorResponse, ok := reply["error_response"]; ok {
+		errorReply := struct {
+			Code    string `json:"code"`
+			Msg     string `json:"msg"`
+			SubCode string `json:"sub_code"`
+			SubMsg  string `json:"sub_msg"`
+		}{}
+		if err = json.Unmarshal(errorResponse, &errorReply); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("gateway error, code: %s, msg: %s, sub_code: %s, sub_msg: %s", errorReply.Code, errorReply.Msg, errorReply.SubCode, errorReply.SubMsg)
+	}
+	// 应答内容的 key 是接口名把 . 换成 _ 后加上 _response, 比如 alipay_trade_refund_response
+	responseKey := strings.ReplaceAll(method, ".", "_") + "_response"
+	response, ok := reply[responseKey]
+	if !ok {
+		return nil, fmt.Errorf("%s not found in reply", responseKey)
+	}
+	signRaw, ok := reply["sign"]
+	if !ok {
+		return nil, errors.New("reply sign is empty")
+	}
+	var replySign string
+	if err = json.Unmarshal(signRaw, &replySign); err != nil {
+		return nil, err
+	}
+	if err = apl.verifySign(string(response), replySign); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// genCommonParams 生成公共请求参数 https://opendocs.alipay.com/common/02kf5q
+func (apl *AliPayLib) genCommonParams(method string) url.Values {
+	params := url.Values{}
+	params.Set("app_id", apl.payConfig.AppId)
+	params.Set("method", method)
+	params.Set("format", "JSON")
+	params.Set("charset", "utf-8")
+	params.Set("sign_type", "RSA2")
+	params.Set("timestamp", time.Now().Format(enum.TimeFormatHyphenedYMDHIS))
+	params.Set("version", "1.0")
+	return params
+}
+
+// genTradePayParams 生成带签名的下单请求参数
+func (apl *AliPayLib) genTradePayParams(order *do.Order, method, productCode string) (url.Values, error) {
+	bizContent := &AliPayBizContent{
+		OutTradeNo:  order.OrderNo,
+		TotalAmount: AliPayCentToAmount(order.PayMoney),
+		Subject:     fmt.Sprintf("GOMALL 商场购买 %s 等商品", order.Items[0].CommodityName),
+		ProductCode: productCode,
+	}
+	bizContentBytes, _ := json.Marshal(bizContent)
+
+	params := apl.genCommonParams(method)
+	params.Set("notify_url", apl.payConfig.NotifyUrl)
+	if method != aliPayMethodAppPay && apl.payConfig.ReturnUrl != "" {
+		params.Set("return_url", apl.payConfig.ReturnUrl)
+	}
+	params.Set("biz_content", string(bizContentBytes))
+
+	sign, err := apl.sign(params)
+	if err != nil {
+		return nil, err
+	}
+	params.Set("sign", sign)
+	return params, nil
+}
+
+// sign 使用应用私钥对请求参数进行 RSA2(SHA256WithRSA) 签名
+// 签名规则: https://opendocs.alipay.com/common/02khjm
+func (apl *AliPayLib) sign(params url.Values) (string, error) {
+	privateKey, err := apl.loadPrivateKey()
+	if err != nil {
+		return "", err
+	}
+
+	message := genAliPaySignContent(params, "sign")
+	signBytes, err := util.RsaSignPKCS1v15(util.SHA256HashBytes(message), privateKey, crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(signBytes), nil
+}
+
+// VerifyNotifySignature 验证支付宝异步通知的签名
+// 支付宝文档: https://opendocs.alipay.com/common/02mse7
+// @param notifyForm 异步通知 POST 过来的表单参数
+func (apl *AliPayLib) VerifyNotifySignature(notifyForm url.Values) (verifyRes bool, err error) {
+	signature := notifyForm.Get("sign")
+	if signature == "" {
+		return false, errcode.Wrap("AliPayLibVerifyNotifySignatureError", errors.New("sign is empty"))
+	}
+	// 异步通知验签时 sign 和 sign_type 两个参数都不参与签名
+	message := genAliPaySignContent(notifyForm, "sign", "sign_type")
+	if err = apl.verifySign(message, signature); err != nil {
+		return false, errcode.Wrap("AliPayLibVerifyNotifySignatureError", err)
+	}
+	return true, nil
+}
+
+// verifySign 使用支付宝公钥验证 RSA2 签名
+func (apl *AliPayLib) verifySign(message, signature string) error {
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return err
+	}
+	publicKey, err := apl.loadAliPayPublicKey()
+	if err != nil {
+		return err
+	}
+	return util.RsaVerifyPKCS1v15(util.SHA256HashBytes(message), signatureBytes, publicKey, crypto.SHA256)
+}
+
+// loadPrivateKey 读取应用私钥, 配置了私钥文件路径时从文件读取, 否则使用 resources 目录下的私钥
+func (apl *AliPayLib) loadPrivateKey() ([]byte, error) {
+	if apl.payConfig.PrivateKeyPath != "" {
+		return os.ReadFile(apl.payConfig.PrivateKeyPath)
+	}
+	pemFileReader, err := resources.LoadResourceFile("alipay.private.pem")
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(pemFileReader)
+}
+
+// loadAliPayPublicKey 读取支付宝公钥, 配置了公钥文件路径时从文件读取, 否则使用 resources 目录下的公钥
+func (apl *AliPayLib) loadAliPayPublicKey() ([]byte, error) {
+	if apl.payConfig.AliPayPublicKeyPath != "" {
+		return os.ReadFile(apl.payConfig.AliPayPublicKeyPath)
+	}
+	pemFileReader, err := resources.LoadResourceFile("alipay_pub.pem")
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(pemFileReader)
+}
+
+// genAliPaySignContent 把参数按参数名 ASCII 码升序排列后用 & 拼接成待签名的字符串, 值为空的参数不参与签名
+func genAliPaySignContent(params url.Values, excludeKeys ...string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		if params.Get(key) == "" || lo.Contains(excludeKeys, key) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+params.Get(key))
+	}
+	return strings.Join(pairs, "&")
+}
+
+// AliPayCentToAmount 把以分为单位的金额转换成支付宝使用的以元为单位的金额字符串
+func AliPayCentToAmount(cent int) string {
+	return fmt.Sprintf("%d.%02d", cent/100, cent%100)
+}
+
+// AliPayAmountToCent 把支付宝以元为单位的金额字符串转换成以分为单位的金额
+func AliPayAmountToCent(amount string) (int, error) {
+	yuan, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int(math.Round(yuan * 100)), nil
+}
@@ -0,0 +1,138 @@
+package library
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/logger"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+// AliPaySandboxLib 本地的支付宝沙箱网关
+// 与 AliPayLib 提供相同的方法, 但不请求支付宝开放平台的接口, 也不需要应用私钥和支付宝公钥,
+// 让开发和测试环境在没有外部网络和密钥的情况下也能走完整的支付流程
+// 沙箱网关使用以 AppId 为密钥的 HMAC-SHA256 代替 RSA2 签名, 模拟异步通知时按同样的规则签名
+type AliPaySandboxLib struct {
+	ctx       context.Context
+	payConfig AliPayConfig
+}
+
+func NewAliPaySandboxLib(ctx context.Context, payConfig AliPayConfig) *AliPaySandboxLib {
+	if payConfig.GatewayUrl == "" {
+		payConfig.GatewayUrl = aliPayGatewayUrl
+	}
+	return &AliPaySandboxLib{
+		ctx:       ctx,
+		payConfig: payConfig,
+	}
+}
+
+// CreatePagePay 创建电脑网站支付, 返回前端需要跳转的支付地址
+func (asl *AliPaySandboxLib) CreatePagePay(order *do.Order) (*AliPayInvokeInfo, error) {
+	params := asl.genTradePayParams(order, aliPayMethodPagePay, aliPayProductPagePay)
+	logger.New(asl.ctx).Info("AliPaySandboxCreatePagePay", "orderNo", order.OrderNo)
+	return &AliPayInvokeInfo{PayUrl: asl.payConfig.GatewayUrl + "?" + params.Encode()}, nil
+}
+
+// CreateWapPay 创建手机网站支付, 返回前端需要跳转的支付地址
+func (asl *AliPaySandboxLib) CreateWapPay(order *do.Order) (*AliPayInvokeInfo, error) {
+	params := asl.genTradePayParams(order, aliPayMethodWapPay, aliPayProductWapPay)
+	logger.New(asl.ctx).Info("AliPaySandboxCreateWapPay", "orderNo", order.OrderNo)
+	return &AliPayInvokeInfo{PayUrl: asl.payConfig.GatewayUrl + "?" + params.Encode()}, nil
+}
+
+// CreateAppPay 创建 APP 支付, 返回客户端调用支付宝 SDK 需要的订单信息
+func (asl *AliPaySandboxLib) CreateAppPay(order *do.Order) (*AliPayInvokeInfo, error) {
+	params := asl.genTradePayParams(order, aliPayMethodAppPay, aliPayProductAppPay)
+	logger.New(asl.ctx).Info("AliPaySandboxCreateAppPay", "orderNo", order.OrderNo)
+	return &AliPayInvokeInfo{OrderString: params.Encode()}, nil
+}
+
+// CreateRefund 申请退款, 沙箱网关直接返回退款成功
+func (asl *AliPaySandboxLib) CreateRefund(order *do.Order, refund *do.OrderRefund) (*AliPayRefundReply, error) {
+	refundReply := &AliPayRefundReply{
+		Code:         aliPayCodeSuccess,
+		Msg:          "Success",
+		TradeNo:      asl.genTradeNo(order.OrderNo),
+		OutTradeNo:   order.OrderNo,
+		FundChange:   "Y",
+		RefundFee:    AliPayCentToAmount(refund.RefundMoney),
+		GmtRefundPay: time.Now().Format(enum.TimeFormatHyphenedYMDHIS),
+	}
+	logger.New(asl.ctx).Info("AliPaySandboxCreateRefund", "orderNo", order.OrderNo, "refundNo", refund.RefundNo)
+	return refundReply, nil
+}
+
+// QueryTrade 查询交易, 沙箱网关不会收到用户的支付, 始终返回交易不存在
+func (asl *AliPaySandboxLib) QueryTrade(orderNo string) (*AliPayTradeQueryReply, error) {
+	return &AliPayTradeQueryReply{
+		Code:        aliPayCodeSuccess,
+		OutTradeNo:  orderNo,
+		TradeStatus: AliPayTradeStatusNotExist,
+	}, nil
+}
+
+// CloseTrade 关闭交易, 沙箱网关直接返回成功
+func (asl *AliPaySandboxLib) CloseTrade(orderNo string) error {
+	logger.New(asl.ctx).Info("AliPaySandboxCloseTrade", "orderNo", orderNo)
+	return nil
+}
+
+// VerifyNotifySignature 验证模拟的异步通知的签名
+func (asl *AliPaySandboxLib) VerifyNotifySignature(notifyForm url.Values) (verifyRes bool, err error) {
+	signature := notifyForm.Get("sign")
+	if signature == "" {
+		return false, errcode.Wrap("AliPaySandboxVerifyNotifySignatureError", errors.New("sign is empty"))
+	}
+	message := genAliPaySignContent(notifyForm, "sign", "sign_type")
+	if !hmac.Equal([]byte(asl.sign(message)), []byte(signature)) {
+		return false, errcode.Wrap("AliPaySandboxVerifyNotifySignatureError", errors.New("sign not match"))
+	}
+	return true, nil
+}
+
+// genTradePayParams 生成下单请求参数, 与 AliPayLib 的参数相同, 签名使用 HMAC-SHA256
+func (asl *AliPaySandboxLib) genTradePayParams(order *do.Order, method, productCode string) url.Values {
+	bizContent := &AliPayBizContent{
+		OutTradeNo:  order.OrderNo,
+		TotalAmount: AliPayCentToAmount(order.PayMoney),
+		Subject:     fmt.Sprintf("GOMALL 商场购买 %s 等商品", order.Items[0].CommodityName),
+		ProductCode: productCode,
+	}
+	bizContentBytes, _ := json.Marshal(bizContent)
+
+	params := url.Values{}
+	params.Set("app_id", asl.payConfig.AppId)
+	params.Set("method", method)
+	params.Set("charset", "utf-8")
+	params.Set("sign_type", "HMAC-SHA256")
+	params.Set("timestamp", time.Now().Format(enum.TimeFormatHyphenedYMDHIS))
+	params.Set("version", "1.0")
+	params.Set("notify_url", asl.payConfig.NotifyUrl)
+	params.Set("biz_content", string(bizContentBytes))
+	params.Set("sign", asl.sign(genAliPaySignContent(params, "sign")))
+	return params
+}
+
+// genTradeNo 根据 AppId 和订单号生成固定的支付宝交易号
+func (asl *AliPaySandboxLib) genTradeNo(orderNo string) string {
+	hash := sha256.Sum256([]byte("trade:" + asl.payConfig.AppId + ":" + orderNo))
+	return "2024" + hex.EncodeToString(hash[:])[:24]
+}
+
+// sign 沙箱网关没有应用私钥, 使用以 AppId 为密钥的 HMAC-SHA256 代替 RSA2 签名
+func (asl *AliPaySandboxLib) sign(message string) string {
+	mac := hmac.New(sha256.New, []byte(asl.payConfig.AppId))
+	mac.Write([]byte(message))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -10,10 +10,12 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
 	"time"
 
 	"github.com/hd2yao/go-mall/common/errcode"
@@ -34,20 +36,49 @@ type WxPayConfig struct {
 	PrivateSerialNo string
 	AesKey          string
 	NotifyUrl       string
+	RefundNotifyUrl string // 退款结果通知的地址
+	ApiBaseUrl      string // 微信支付 API 的地址, 为空时使用 https://api.mch.weixin.qq.com, 测试时可以指向本地的模拟服务
+	// 商户私钥和微信支付公钥的 PEM 文件路径, 为空时使用 resources 目录下的 wxpay.private.pem 和 wxp_pub.pem
+	PrivateKeyPath        string
+	PlatformPublicKeyPath string
 }
 
 func NewWxPayLib(ctx context.Context, payConfig WxPayConfig) *WxPayLib {
+	if payConfig.ApiBaseUrl == "" {
+		payConfig.ApiBaseUrl = wxPayApiBaseUrl
+	}
 	return &WxPayLib{
 		ctx:       ctx,
 		payConfig: payConfig,
 	}
 }
 
-const prePayApiUrl = "https://api.mch.weixin.qq.com/v3/pay/transactions/jsapi"
+const wxPayApiBaseUrl = "https://api.mch.weixin.qq.com"
+
+// 微信支付不同支付场景的下单接口
+const (
+	prePayApiPath       = "/v3/pay/transactions/jsapi"
+	appPrePayApiPath    = "/v3/pay/transactions/app"
+	nativePrePayApiPath = "/v3/pay/transactions/native"
+	refundApiPath       = "/v3/refund/domestic/refunds"
+	queryOrderApiPath   = "/v3/pay/transactions/out-trade-no/%s?mchid=%s"
+	closeOrderApiPath   = "/v3/pay/transactions/out-trade-no/%s/close"
+)
+
+// 微信支付的交易状态
+const (
+	WxTradeStateSuccess    = "SUCCESS"    // 支付成功
+	WxTradeStateNotPay     = "NOTPAY"     // 未支付
+	WxTradeStateClosed     = "CLOSED"     // 已关闭
+	WxTradeStateUserPaying = "USERPAYING" // 用户支付中
+	// 微信支付没有这个订单号的交易(没有调用过下单接口), 不是微信支付返回的交易状态
+	WxTradeStateNotExist = "ORDER_NOT_EXIST"
+)
 
+// PrePayParam JSAPI 下单参数
 type PrePayParam struct {
-	AppId       string `json:"app_id"`
-	MchId       string `json:"mch_id"`       // 商户号 ID
+	AppId       string `json:"appid"`
+	MchId       string `json:"mchid"`        // 商户号 ID
 	Description string `json:"description"`  // 商品描述
 	OutTradeNo  string `json:"out_trade_no"` // 业务的订单号
 	NotifyUrl   string `json:"notify_url"`   // 支付成功后，结果回调通知 url
@@ -56,10 +87,23 @@ type PrePayParam struct {
 		Currency string `json:"currency"`
 	} `json:"amount"`
 	Payer struct {
-		OpenId string `json:"open_id"`
+		OpenId string `json:"openid"`
 	} `json:"payer"`
 }
 
+// AppPrePayParam APP 和 Native 下单参数, 与 JSAPI 相比不需要传递支付者信息
+type AppPrePayParam struct {
+	AppId       string `json:"appid"`
+	MchId       string `json:"mchid"`
+	Description string `json:"description"`
+	OutTradeNo  string `json:"out_trade_no"`
+	NotifyUrl   string `json:"notify_url"`
+	Amount      struct {
+		Total    int    `json:"total"`
+		Currency string `json:"currency"`
+	} `json:"amount"`
+}
+
 // WxPayInvokeInfo 前端用 JSAPI 调起支付的参数信息
 // 微信支付文档: https://pay.weixin.qq.com/wiki/doc/apiv3/apis/chapter3_1_4.shtml
 type WxPayInvokeInfo struct {
@@ -71,6 +115,23 @@ type WxPayInvokeInfo struct {
 	PaySign   string `json:"paySign"`
 }
 
+// WxAppPayInvokeInfo APP 调起支付的参数信息
+// 微信支付文档: https://pay.weixin.qq.com/docs/merchant/apis/in-app-payment/app-transfer-payment.html
+type WxAppPayInvokeInfo struct {
+	AppId     string `json:"appid"`
+	PartnerId string `json:"partnerid"` // 商户号
+	PrepayId  string `json:"prepayid"`
+	Package   string `json:"package"` // 固定值 Sign=WXPay
+	NonceStr  string `json:"noncestr"`
+	TimeStamp string `json:"timestamp"`
+	Sign      string `json:"sign"`
+}
+
+// WxNativePayInfo Native 支付的信息, PC 网页把 CodeUrl 生成二维码给用户扫码支付
+type WxNativePayInfo struct {
+	CodeUrl string `json:"code_url"`
+}
+
 type WxPayNotifyResponse struct {
 	CreateTime string              `json:"create_time"`
 	Resource   WxPayNotifyResource `json:"resource"`
@@ -105,6 +166,52 @@ type WxPayNotifyResourceData struct {
 	Attach         string `json:"attach"`
 }
 
+// WxRefundParam 申请退款的参数
+type WxRefundParam struct {
+	TransactionId string `json:"transaction_id"` // 支付成功时微信支付返回的交易号
+	OutRefundNo   string `json:"out_refund_no"`  // 业务的退款单号
+	Reason        string `json:"reason,omitempty"`
+	NotifyUrl     string `json:"notify_url,omitempty"` // 退款结果通知的地址
+	Amount        struct {
+		Refund   int    `json:"refund"` // 退款金额
+		Total    int    `json:"total"`  // 原订单金额
+		Currency string `json:"currency"`
+	} `json:"amount"`
+}
+
+// 微信支付的退款状态
+const (
+	WxRefundStatusSuccess    = "SUCCESS"    // 退款成功
+	WxRefundStatusClosed     = "CLOSED"     // 退款关闭
+	WxRefundStatusProcessing = "PROCESSING" // 退款处理中
+	WxRefundStatusAbnormal   = "ABNORMAL"   // 退款异常
+)
+
+// WxRefundReply 申请退款接口的应答
+type WxRefundReply struct {
+	RefundId    string    `json:"refund_id"` // 微信支付退款单号
+	OutRefundNo string    `json:"out_refund_no"`
+	Status      string    `json:"status"`
+	SuccessTime time.Time `json:"success_time"`
+}
+
+// WxRefundNotifyResourceData 微信支付退款结果通知中解密后的 resource 数据
+type WxRefundNotifyResourceData struct {
+	Mchid         string    `json:"mchid"`
+	OutTradeNo    string    `json:"out_trade_no"`
+	TransactionId string    `json:"transaction_id"`
+	OutRefundNo   string    `json:"out_refund_no"`
+	RefundId      string    `json:"refund_id"`
+	RefundStatus  string    `json:"refund_status"`
+	SuccessTime   time.Time `json:"success_time"`
+	Amount        struct {
+		Total       int `json:"total"`
+		Refund      int `json:"refund"`
+		PayerTotal  int `json:"payer_total"`
+		PayerRefund int `json:"payer_refund"`
+	} `json:"amount"`
+}
+
 // CreateOrderPay 创建支付信息
 // @param order *do.Order 业务的订单信息
 // @param userOpenId string 用户的Openid
@@ -130,20 +237,7 @@ func (wpl *WxPayLib) CreateOrderPay(order *do.Order, userOpenId string) (payInvo
 	prePayPram.Amount.Total = order.PayMoney
 	prePayPram.Amount.Currency = "CNY"
 	prePayPram.Payer.OpenId = userOpenId
-	// 将预支付参数转换为 JSON 格式
-	reqBody, _ := json.Marshal(prePayPram)
-
-	// 获取微信支付 API 调用凭证（签名 token）
-	token, err := wpl.getToken(http.MethodPost, string(reqBody), prePayApiUrl)
-	if err != nil {
-		err = errcode.Wrap("WxPayLibCreatePrePayError", err)
-		return
-	}
-
-	// 发送 HTTP POST 请求到微信支付 API，创建预支付订单
-	_, replyBody, err := httptool.Post(wpl.ctx, prePayApiUrl, reqBody, httptool.WithHeaders(map[string]string{
-		"Authorization": "WECHATPAY2-SHA256-RSA2048 " + token,
-	}))
+	replyBody, err := wpl.postApi(wpl.payConfig.ApiBaseUrl+prePayApiPath, prePayPram)
 	if err != nil {
 		err = errcode.Wrap("WxPayLibCreatePrePayError", err)
 		return
@@ -167,6 +261,169 @@ func (wpl *WxPayLib) CreateOrderPay(order *do.Order, userOpenId string) (payInvo
 	return payInvokeInfo, nil
 }
 
+// CreateAppOrderPay 创建 APP 支付信息
+// 微信支付文档: https://pay.weixin.qq.com/docs/merchant/apis/in-app-payment/direct-jsons/app-prepay.html
+// @return payInvokeInfo *WxAppPayInvokeInfo APP 调起微信支付的参数
+func (wpl *WxPayLib) CreateAppOrderPay(order *do.Order) (payInvokeInfo *WxAppPayInvokeInfo, err error) {
+	prePayParam := wpl.genAppPrePayParam(order)
+	replyBody, err := wpl.postApi(wpl.payConfig.ApiBaseUrl+appPrePayApiPath, prePayParam)
+	if err != nil {
+		err = errcode.Wrap("WxPayLibCreateAppPrePayError", err)
+		return
+	}
+
+	prePayReply := struct {
+		PrePayId string `json:"prepay_id"`
+	}{}
+	if err = json.Unmarshal(replyBody, &prePayReply); err != nil {
+		err = errcode.Wrap("WxPayLibCreateAppPrePayError", err)
+		return
+	}
+
+	payInvokeInfo = &WxAppPayInvokeInfo{
+		AppId:     wpl.payConfig.AppId,
+		PartnerId: wpl.payConfig.MchId,
+		PrepayId:  prePayReply.PrePayId,
+		Package:   "Sign=WXPay",
+		NonceStr:  util.RandomString(32),
+		TimeStamp: fmt.Sprintf("%v", time.Now().Unix()),
+	}
+	// APP 调起支付的签名 https://pay.weixin.qq.com/docs/merchant/apis/in-app-payment/app-transfer-payment.html
+	message := fmt.Sprintf("%s\n%s\n%s\n%s\n", payInvokeInfo.AppId, payInvokeInfo.TimeStamp, payInvokeInfo.NonceStr, payInvokeInfo.PrepayId)
+	payInvokeInfo.Sign, err = wpl.signWithPrivateKey(message)
+	if err != nil {
+		err = errcode.Wrap("WxPayLibCreateAppPrePayError", err)
+		return
+	}
+	return payInvokeInfo, nil
+}
+
+// CreateNativeOrderPay 创建 Native 支付信息, 返回用于生成支付二维码的链接
+// 微信支付文档: https://pay.weixin.qq.com/docs/merchant/apis/native-payment/direct-jsons/native-prepay.html
+func (wpl *WxPayLib) CreateNativeOrderPay(order *do.Order) (payInfo *WxNativePayInfo, err error) {
+	prePayParam := wpl.genAppPrePayParam(order)
+	replyBody, err := wpl.postApi(wpl.payConfig.ApiBaseUrl+nativePrePayApiPath, prePayParam)
+	if err != nil {
+		err = errcode.Wrap("WxPayLibCreateNativePrePayError", err)
+		return
+	}
+
+	payInfo = new(WxNativePayInfo)
+	if err = json.Unmarshal(replyBody, payInfo); err != nil {
+		err = errcode.Wrap("WxPayLibCreateNativePrePayError", err)
+		return
+	}
+	return payInfo, nil
+}
+
+// CreateRefund 申请退款
+// 微信支付文档: https://pay.weixin.qq.com/docs/merchant/apis/jsapi-payment/create.html
+// @param order *do.Order 要退款的订单
+// @param refund *do.OrderRefund 业务的退款单
+func (wpl *WxPayLib) CreateRefund(order *do.Order, refund *do.OrderRefund) (refundReply *WxRefundReply, err error) {
+	refundParam := &WxRefundParam{
+		TransactionId: order.PayTransId,
+		OutRefundNo:   refund.RefundNo,
+		Reason:        refund.Reason,
+		NotifyUrl:     wpl.payConfig.RefundNotifyUrl,
+	}
+	refundParam.Amount.Refund = refund.RefundMoney
+	refundParam.Amount.Total = order.PayMoney
+	refundParam.Amount.Currency = "CNY"
+	replyBody, err := wpl.postApi(wpl.payConfig.ApiBaseUrl+refundApiPath, refundParam)
+	if err != nil {
+		err = errcode.Wrap("WxPayLibCreateRefundError", err)
+		return
+	}
+
+	refundReply = new(WxRefundReply)
+	if err = json.Unmarshal(replyBody, refundReply); err != nil {
+		err = errcode.Wrap("WxPayLibCreateRefundError", err)
+		return
+	}
+	return refundReply, nil
+}
+
+// QueryOrder 按业务订单号查询微信支付的交易, 查询接口的应答与支付结果通知的 resource 数据结构相同
+// 微信支付没有这笔交易时返回的交易状态为 WxTradeStateNotExist
+// 微信支付文档: https://pay.weixin.qq.com/docs/merchant/apis/jsapi-payment/query-by-out-trade-no.html
+func (wpl *WxPayLib) QueryOrder(orderNo string) (*WxPayNotifyResourceData, error) {
+	apiUrl := wpl.payConfig.ApiBaseUrl + fmt.Sprintf(queryOrderApiPath, url.PathEscape(orderNo), wpl.payConfig.MchId)
+	token, err := wpl.getToken(http.MethodGet, "", apiUrl)
+	if err != nil {
+		return nil, errcode.Wrap("WxPayLibQueryOrderError", err)
+	}
+	httpStatusCode, replyBody, err := httptool.Get(wpl.ctx, apiUrl, httptool.WithHeaders(map[string]string{
+		"Authorization": "WECHATPAY2-SHA256-RSA2048 " + token,
+	}))
+	if httpStatusCode == http.StatusNotFound {
+		return &WxPayNotifyResourceData{OutTradeNo: orderNo, TradeState: WxTradeStateNotExist}, nil
+	}
+	if err != nil {
+		return nil, errcode.Wrap("WxPayLibQueryOrderError", err)
+	}
+
+	transaction := new(WxPayNotifyResourceData)
+	if err = json.Unmarshal(replyBody, transaction); err != nil {
+		return nil, errcode.Wrap("WxPayLibQueryOrderError", err)
+	}
+	return transaction, nil
+}
+
+// CloseOrder 关闭微信支付的交易, 关闭后用户不能再支付这个订单
+// 微信支付文档: https://pay.weixin.qq.com/docs/merchant/apis/jsapi-payment/close-order.html
+func (wpl *WxPayLib) CloseOrder(orderNo string) error {
+	apiUrl := wpl.payConfig.ApiBaseUrl + fmt.Sprintf(closeOrderApiPath, url.PathEscape(orderNo))
+	reqBody, _ := json.Marshal(map[string]string{"mchid": wpl.payConfig.MchId})
+	token, err := wpl.getToken(http.MethodPost, string(reqBody), apiUrl)
+	if err != nil {
+		return errcode.Wrap("WxPayLibCloseOrderError", err)
+	}
+	httpStatusCode, _, err := httptool.Post(wpl.ctx, apiUrl, reqBody, httptool.WithHeaders(map[string]string{
+		"Authorization": "WECHATPAY2-SHA256-RSA2048 " + token,
+	}))
+	// 关单成功时返回 204 No Content, httptool 会把非 200 的响应也处理成 error
+	if httpStatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err != nil {
+		return errcode.Wrap("WxPayLibCloseOrderError", err)
+	}
+	return nil
+}
+
+// genAppPrePayParam 生成 APP 和 Native 支付的下单参数
+func (wpl *WxPayLib) genAppPrePayParam(order *do.Order) *AppPrePayParam {
+	prePayParam := &AppPrePayParam{
+		AppId:       wpl.payConfig.AppId,
+		MchId:       wpl.payConfig.MchId,
+		Description: fmt.Sprintf("GOMALL 商场购买 %s 等商品", order.Items[0].CommodityName),
+		OutTradeNo:  order.OrderNo,
+		NotifyUrl:   wpl.payConfig.NotifyUrl,
+	}
+	prePayParam.Amount.Total = order.PayMoney
+	prePayParam.Amount.Currency = "CNY"
+	return prePayParam
+}
+
+// postApi 调用微信支付的 POST 接口, 返回接口的响应数据
+func (wpl *WxPayLib) postApi(apiUrl string, param interface{}) (replyBody []byte, err error) {
+	// 将请求参数转换为 JSON 格式
+	reqBody, _ := json.Marshal(param)
+
+	// 获取微信支付 API 调用凭证（签名 token）
+	token, err := wpl.getToken(http.MethodPost, string(reqBody), apiUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	// 发送 HTTP POST 请求到微信支付 API
+	_, replyBody, err = httptool.Post(wpl.ctx, apiUrl, reqBody, httptool.WithHeaders(map[string]string{
+		"Authorization": "WECHATPAY2-SHA256-RSA2048 " + token,
+	}))
+	return replyBody, err
+}
+
 // genToken 生成微信支付的请求签名
 // 文档：https://pay.weixin.qq.com/docs/merchant/development/interface-rules/signature-generation.html
 func (wpl *WxPayLib) getToken(httpMethod, requestBody, wxApiUrl string) (token string, err error) {
@@ -188,14 +445,9 @@ func (wpl *WxPayLib) getToken(httpMethod, requestBody, wxApiUrl string) (token s
 	// 构造签名原始字符串，符合微信支付 API 规范
 	message := fmt.Sprintf("%s\n%s\n%d\n%s\n%s\n", httpMethod, canonicalUrl, timestamp, nonce, requestBody)
 
-	// 商户私有证书放在了 resources 目录下
-	// 读取私钥文件
-	pemFileReader, err := resources.LoadResourceFile("wxpay.private.pem")
-	if err != nil {
-		return token, err
-	}
+	// 商户私有证书默认放在了 resources 目录下
 	// 读取私钥内容
-	privateKey, err := ioutil.ReadAll(pemFileReader)
+	privateKey, err := wpl.loadPrivateKey()
 	if err != nil {
 		return token, err
 	}
@@ -230,23 +482,50 @@ func (wpl *WxPayLib) genPayInvokeInfo(prepayId string) (payInvokeInfo *WxPayInvo
 	// 签名
 	message := fmt.Sprintf("%s\n%s\n%s\n%s\n", payInvokeInfo.AppId, payInvokeInfo.TimeStamp, payInvokeInfo.NonceStr, payInvokeInfo.Package)
 
-	pemFileReader, err := resources.LoadResourceFile("wxpay.private.pem")
+	payInvokeInfo.PaySign, err = wpl.signWithPrivateKey(message)
 	if err != nil {
 		return
 	}
-	privateKey, err := ioutil.ReadAll(pemFileReader)
+
+	return payInvokeInfo, nil
+}
+
+// signWithPrivateKey 使用商户私钥对消息进行 SHA256 with RSA 签名, 返回 Base64 编码后的签名
+func (wpl *WxPayLib) signWithPrivateKey(message string) (string, error) {
+	privateKey, err := wpl.loadPrivateKey()
 	if err != nil {
-		return
+		return "", err
 	}
 
-	sha245MsgBytes := util.SHA256HashBytes(message)
-	signBytes, err := util.RsaSignPKCS1v15(sha245MsgBytes, privateKey, crypto.SHA256)
+	signBytes, err := util.RsaSignPKCS1v15(util.SHA256HashBytes(message), privateKey, crypto.SHA256)
 	if err != nil {
-		return
+		return "", err
 	}
-	payInvokeInfo.PaySign = base64.StdEncoding.EncodeToString(signBytes)
+	return base64.StdEncoding.EncodeToString(signBytes), nil
+}
 
-	return payInvokeInfo, nil
+// loadPrivateKey 读取商户私钥, 配置了私钥文件路径时从文件读取, 否则使用 resources 目录下的私钥
+func (wpl *WxPayLib) loadPrivateKey() ([]byte, error) {
+	if wpl.payConfig.PrivateKeyPath != "" {
+		return os.ReadFile(wpl.payConfig.PrivateKeyPath)
+	}
+	pemFileReader, err := resources.LoadResourceFile("wxpay.private.pem")
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(pemFileReader)
+}
+
+// loadPlatformPublicKey 读取微信支付公钥, 配置了公钥文件路径时从文件读取, 否则使用 resources 目录下的公钥
+func (wpl *WxPayLib) loadPlatformPublicKey() ([]byte, error) {
+	if wpl.payConfig.PlatformPublicKeyPath != "" {
+		return os.ReadFile(wpl.payConfig.PlatformPublicKeyPath)
+	}
+	pemFileReader, err := resources.LoadResourceFile("wxp_pub.pem")
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(pemFileReader)
 }
 
 // ValidateNotifySignature 验证微信支付结果通知的签名
@@ -264,12 +543,7 @@ func (wpl *WxPayLib) ValidateNotifySignature(timeStamp, nonce, signature, rawPos
 
 	message := fmt.Sprintf("%s\n%s\n%s\n", timeStamp, nonce, rawPost)
 
-	pemFileReader, err := resources.LoadResourceFile("wxp_pub.pem")
-	if err != nil {
-		err = errcode.Wrap("WxPayLibValidateCallBackSignatureError", err)
-		return
-	}
-	publicKeyStr, err := ioutil.ReadAll(pemFileReader)
+	publicKeyStr, err := wpl.loadPlatformPublicKey()
 	if err != nil {
 		err = errcode.Wrap("WxPayLibValidateCallBackSignatureError", err)
 		return
@@ -277,12 +551,21 @@ func (wpl *WxPayLib) ValidateNotifySignature(timeStamp, nonce, signature, rawPos
 
 	//pem解码
 	block, _ := pem.Decode(publicKeyStr)
-	//x509解码
-	publicKeyInterface, err := x509.ParseCertificate(block.Bytes)
+	if block == nil {
+		err = errcode.Wrap("WxPayLibValidateCallBackSignatureError", errors.New("wechat pay public key decode error"))
+		return
+	}
+	//x509解码, 使用的是微信支付公钥 (非平台证书)
+	publicKeyInterface, err := x509.ParsePKIXPublicKey(block.Bytes)
 	if err != nil {
-		panic(err)
+		err = errcode.Wrap("WxPayLibValidateCallBackSignatureError", err)
+		return
+	}
+	publicKey, ok := publicKeyInterface.(*rsa.PublicKey)
+	if !ok {
+		err = errcode.Wrap("WxPayLibValidateCallBackSignatureError", errors.New("wechat pay public key format error"))
+		return
 	}
-	publicKey := publicKeyInterface.PublicKey.(*rsa.PublicKey)
 	//验证数字签名
 	err = rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, util.SHA256HashBytes(message), signatureBytes) //crypto.SHA1
 	verifyRes = nil == err
@@ -291,33 +574,51 @@ func (wpl *WxPayLib) ValidateNotifySignature(timeStamp, nonce, signature, rawPos
 }
 
 // DecryptNotifyResourceData 解密微信支付通知中的 resource 数据
-func (wpl *WxPayLib) DecryptNotifyResourceData(rawPost string) (notifyResourceData *WxPayNotifyResource, err error) {
-	var notifyResponse WxPayNotifyResponse
-	if err = json.Unmarshal([]byte(rawPost), &notifyResponse); err != nil {
+// 文档: https://pay.weixin.qq.com/docs/merchant/development/interface-rules/certificate-callback-decryption.html
+func (wpl *WxPayLib) DecryptNotifyResourceData(rawPost string) (notifyResourceData *WxPayNotifyResourceData, err error) {
+	plaintext, err := wpl.decryptNotifyResource(rawPost)
+	if err != nil {
 		return notifyResourceData, errcode.Wrap("WxPayLibDecryptNotifyResourceDataError", err)
 	}
 
+	err = json.Unmarshal(plaintext, &notifyResourceData)
+	return
+}
+
+// DecryptRefundNotifyResourceData 解密微信支付退款结果通知中的 resource 数据
+// 文档: https://pay.weixin.qq.com/docs/merchant/apis/jsapi-payment/refund-result-notice.html
+func (wpl *WxPayLib) DecryptRefundNotifyResourceData(rawPost string) (notifyResourceData *WxRefundNotifyResourceData, err error) {
+	plaintext, err := wpl.decryptNotifyResource(rawPost)
+	if err != nil {
+		return notifyResourceData, errcode.Wrap("WxPayLibDecryptRefundNotifyResourceDataError", err)
+	}
+
+	err = json.Unmarshal(plaintext, &notifyResourceData)
+	return
+}
+
+// decryptNotifyResource 使用 APIv3 密钥解密通知中 AEAD_AES_256_GCM 加密的 resource 数据
+func (wpl *WxPayLib) decryptNotifyResource(rawPost string) ([]byte, error) {
+	var notifyResponse WxPayNotifyResponse
+	if err := json.Unmarshal([]byte(rawPost), &notifyResponse); err != nil {
+		return nil, err
+	}
+
 	aesKey := []byte(wpl.payConfig.AesKey)
 	nonce := []byte(notifyResponse.Resource.Nonce)
 	associatedData := []byte(notifyResponse.Resource.AssociatedData)
 	ciphertext, err := base64.StdEncoding.DecodeString(notifyResponse.Resource.Ciphertext)
 	if err != nil {
-		return notifyResourceData, errcode.Wrap("WxPayLibDecryptNotifyResourceDataError", err)
+		return nil, err
 	}
 
 	block, err := aes.NewCipher(aesKey)
 	if err != nil {
-		return notifyResourceData, errcode.Wrap("WxPayLibDecryptNotifyResourceDataError", err)
+		return nil, err
 	}
 	aesGCM, err := cipher.NewGCM(block)
 	if err != nil {
-		return notifyResourceData, errcode.Wrap("WxPayLibDecryptNotifyResourceDataError", err)
-	}
-	plaintext, err := aesGCM.Open(nil, nonce, ciphertext, associatedData)
-	if err != nil {
-		return notifyResourceData, errcode.Wrap("WxPayLibDecryptNotifyResourceDataError", err)
+		return nil, err
 	}
-
-	err = json.Unmarshal(plaintext, &notifyResourceData)
-	return
+	return aesGCM.Open(nil, nonce, ciphertext, associatedData)
 }
@@ -0,0 +1,179 @@
+package library
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util/httptool"
+)
+
+const (
+	tradeBillApiPath = "/v3/bill/tradebill?bill_date=%s&bill_type=ALL"
+)
+
+// 交易账单中的交易状态
+const (
+	WxBillTradeStateSuccess = "SUCCESS" // 支付成功
+	WxBillTradeStateRefund  = "REFUND"  // 退款
+)
+
+// WxTradeBillApplyReply 申请交易账单接口的应答
+type WxTradeBillApplyReply struct {
+	HashType    string `json:"hash_type"`
+	HashValue   string `json:"hash_value"`
+	DownloadUrl string `json:"download_url"`
+}
+
+// WxTradeBillRecord 交易账单中的一条交易记录, 金额单位为分
+type WxTradeBillRecord struct {
+	TradeTime       time.Time // 交易时间
+	TransactionId   string    // 微信支付订单号
+	OutTradeNo      string    // 商户订单号
+	TradeType       string    // 交易类型
+	TradeState      string    // 交易状态 SUCCESS-支付成功 REFUND-退款
+	SettlementTotal int       // 应结订单金额
+	OrderTotal      int       // 订单金额
+	RefundId        string    // 微信退款单号
+	OutRefundNo     string    // 商户退款单号
+	RefundAmount    int       // 退款金额
+}
+
+// 交易账单的表头, 账单中的字段按表头的名称读取, 不依赖字段的顺序
+const (
+	tradeBillColumnTradeTime       = "交易时间"
+	tradeBillColumnTransactionId   = "微信订单号"
+	tradeBillColumnOutTradeNo      = "商户订单号"
+	tradeBillColumnTradeType       = "交易类型"
+	tradeBillColumnTradeState      = "交易状态"
+	tradeBillColumnSettlementTotal = "应结订单金额"
+	tradeBillColumnOrderTotal      = "订单金额"
+	tradeBillColumnRefundId        = "微信退款单号"
+	tradeBillColumnOutRefundNo     = "商户退款单号"
+	tradeBillColumnRefundAmount    = "退款金额"
+	// 交易记录之后是汇总数据, 汇总数据的表头以这一列开始
+	tradeBillSummaryFirstColumn = "总交易单数"
+)
+
+// DownloadTradeBill 下载并解析 billDate 当天的交易账单
+// 先申请交易账单拿到下载地址和文件的摘要, 下载后校验摘要再解析
+// 微信支付文档: https://pay.weixin.qq.com/docs/merchant/apis/bill-download/trade-bill.html
+func (wpl *WxPayLib) DownloadTradeBill(billDate time.Time) ([]*WxTradeBillRecord, error) {
+	apiUrl := wpl.payConfig.ApiBaseUrl + fmt.Sprintf(tradeBillApiPath, billDate.Format(enum.TimeFormatHyphenedYMD))
+	applyReply, err := wpl.getApi(apiUrl)
+	if err != nil {
+		return nil, errcode.Wrap("WxPayLibDownloadTradeBillError", err)
+	}
+	billApply := new(WxTradeBillApplyReply)
+	if err = json.Unmarshal(applyReply, billApply); err != nil {
+		return nil, errcode.Wrap("WxPayLibDownloadTradeBillError", err)
+	}
+
+	billData, err := wpl.getApi(billApply.DownloadUrl)
+	if err != nil {
+		return nil, errcode.Wrap("WxPayLibDownloadTradeBillError", err)
+	}
+	if strings.EqualFold(billApply.HashType, "SHA1") {
+		hash := sha1.Sum(billData)
+		if !strings.EqualFold(hex.EncodeToString(hash[:]), billApply.HashValue) {
+			return nil, errcode.Wrap("WxPayLibDownloadTradeBillError", errors.New("trade bill hash not match"))
+		}
+	}
+	return ParseWxTradeBill(billData)
+}
+
+// ParseWxTradeBill 解析 CSV 格式的交易账单
+// 账单第一行是表头, 之后每行是一条交易记录, 最后是以 "总交易单数" 开头的汇总数据; 每个字段的值前面都有一个 ` 符号
+func ParseWxTradeBill(billData []byte) ([]*WxTradeBillRecord, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(billData, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errcode.Wrap("ParseWxTradeBillError", err)
+	}
+	columnIndex := make(map[string]int, len(header))
+	for i, column := range header {
+		columnIndex[strings.TrimSpace(column)] = i
+	}
+	for _, column := range []string{tradeBillColumnTradeTime, tradeBillColumnTransactionId, tradeBillColumnOutTradeNo,
+		tradeBillColumnTradeState, tradeBillColumnOrderTotal} {
+		if _, ok := columnIndex[column]; !ok {
+			return nil, errcode.Wrap("ParseWxTradeBillError", fmt.Errorf("trade bill column %s not found", column))
+		}
+	}
+
+	records := make([]*WxTradeBillRecord, 0)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errcode.Wrap("ParseWxTradeBillError", err)
+		}
+		if len(row) > 0 && strings.TrimSpace(row[0]) == tradeBillSummaryFirstColumn {
+			break
+		}
+		field := func(column string) string {
+			index, ok := columnIndex[column]
+			if !ok || index >= len(row) {
+				return ""
+			}
+			return strings.TrimPrefix(strings.TrimSpace(row[index]), "`")
+		}
+		amount := func(column string) (int, error) {
+			value := field(column)
+			if value == "" {
+				return 0, nil
+			}
+			return AliPayAmountToCent(value)
+		}
+
+		record := &WxTradeBillRecord{
+			TransactionId: field(tradeBillColumnTransactionId),
+			OutTradeNo:    field(tradeBillColumnOutTradeNo),
+			TradeType:     field(tradeBillColumnTradeType),
+			TradeState:    field(tradeBillColumnTradeState),
+			RefundId:      field(tradeBillColumnRefundId),
+			OutRefundNo:   field(tradeBillColumnOutRefundNo),
+		}
+		if record.TradeTime, err = time.ParseInLocation(enum.TimeFormatHyphenedYMDHIS, field(tradeBillColumnTradeTime), time.Local); err != nil {
+			return nil, errcode.Wrap("ParseWxTradeBillError", err)
+		}
+		if record.SettlementTotal, err = amount(tradeBillColumnSettlementTotal); err != nil {
+			return nil, errcode.Wrap("ParseWxTradeBillError", err)
+		}
+		if record.OrderTotal, err = amount(tradeBillColumnOrderTotal); err != nil {
+			return nil, errcode.Wrap("ParseWxTradeBillError", err)
+		}
+		if record.RefundAmount, err = amount(tradeBillColumnRefundAmount); err != nil {
+			return nil, errcode.Wrap("ParseWxTradeBillError", err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// getApi 调用微信支付的 GET 接口, 返回接口的响应数据
+func (wpl *WxPayLib) getApi(apiUrl string) ([]byte, error) {
+	token, err := wpl.getToken(http.MethodGet, "", apiUrl)
+	if err != nil {
+		return nil, err
+	}
+	_, replyBody, err := httptool.Get(wpl.ctx, apiUrl, httptool.WithHeaders(map[string]string{
+		"Authorization": "WECHATPAY2-SHA256-RSA2048 " + token,
+	}))
+	return replyBody, err
+}
@@ -0,0 +1,123 @@
+package library
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/hd2yao/go-mall/common/logger"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+// WxPaySandboxLib 本地的微信支付沙箱网关
+// 与 WxPayLib 提供相同的下单方法, 但不请求微信支付的接口, 根据订单号生成固定的预支付信息,
+// 让开发和测试环境在没有外部网络和商户证书的情况下也能走完整的支付流程
+type WxPaySandboxLib struct {
+	ctx       context.Context
+	payConfig WxPayConfig
+}
+
+func NewWxPaySandboxLib(ctx context.Context, payConfig WxPayConfig) *WxPaySandboxLib {
+	return &WxPaySandboxLib{
+		ctx:       ctx,
+		payConfig: payConfig,
+	}
+}
+
+// CreateOrderPay 创建 JSAPI 支付信息
+func (wsl *WxPaySandboxLib) CreateOrderPay(order *do.Order, userOpenId string) (*WxPayInvokeInfo, error) {
+	prepayId := wsl.genPrepayId(order.OrderNo)
+	payInvokeInfo := &WxPayInvokeInfo{
+		AppId:     wsl.payConfig.AppId,
+		TimeStamp: fmt.Sprintf("%v", order.CreatedAt.Unix()),
+		NonceStr:  wsl.genNonceStr(order.OrderNo),
+		Package:   "prepay_id=" + prepayId,
+		SignType:  "RSA",
+	}
+	message := fmt.Sprintf("%s\n%s\n%s\n%s\n", payInvokeInfo.AppId, payInvokeInfo.TimeStamp, payInvokeInfo.NonceStr, payInvokeInfo.Package)
+	payInvokeInfo.PaySign = wsl.sign(message)
+	logger.New(wsl.ctx).Info("WxPaySandboxCreateOrderPay", "orderNo", order.OrderNo, "openId", userOpenId, "prepayId", prepayId)
+	return payInvokeInfo, nil
+}
+
+// CreateAppOrderPay 创建 APP 支付信息
+func (wsl *WxPaySandboxLib) CreateAppOrderPay(order *do.Order) (*WxAppPayInvokeInfo, error) {
+	payInvokeInfo := &WxAppPayInvokeInfo{
+		AppId:     wsl.payConfig.AppId,
+		PartnerId: wsl.payConfig.MchId,
+		PrepayId:  wsl.genPrepayId(order.OrderNo),
+		Package:   "Sign=WXPay",
+		NonceStr:  wsl.genNonceStr(order.OrderNo),
+		TimeStamp: fmt.Sprintf("%v", order.CreatedAt.Unix()),
+	}
+	message := fmt.Sprintf("%s\n%s\n%s\n%s\n", payInvokeInfo.AppId, payInvokeInfo.TimeStamp, payInvokeInfo.NonceStr, payInvokeInfo.PrepayId)
+	payInvokeInfo.Sign = wsl.sign(message)
+	logger.New(wsl.ctx).Info("WxPaySandboxCreateAppOrderPay", "orderNo", order.OrderNo, "prepayId", payInvokeInfo.PrepayId)
+	return payInvokeInfo, nil
+}
+
+// CreateNativeOrderPay 创建 Native 支付信息
+func (wsl *WxPaySandboxLib) CreateNativeOrderPay(order *do.Order) (*WxNativePayInfo, error) {
+	payInfo := &WxNativePayInfo{
+		CodeUrl: "weixin://wxpay/bizpayurl?pr=" + wsl.genPrepayId(order.OrderNo)[2:12],
+	}
+	logger.New(wsl.ctx).Info("WxPaySandboxCreateNativeOrderPay", "orderNo", order.OrderNo, "codeUrl", payInfo.CodeUrl)
+	return payInfo, nil
+}
+
+// CreateRefund 申请退款, 沙箱网关直接返回退款成功
+func (wsl *WxPaySandboxLib) CreateRefund(order *do.Order, refund *do.OrderRefund) (*WxRefundReply, error) {
+	hash := sha256.Sum256([]byte("refund:" + wsl.payConfig.MchId + ":" + refund.RefundNo))
+	refundReply := &WxRefundReply{
+		RefundId:    "50" + hex.EncodeToString(hash[:])[:30],
+		OutRefundNo: refund.RefundNo,
+		Status:      WxRefundStatusSuccess,
+		SuccessTime: time.Now(),
+	}
+	logger.New(wsl.ctx).Info("WxPaySandboxCreateRefund", "orderNo", order.OrderNo, "refundNo", refund.RefundNo, "refundId", refundReply.RefundId)
+	return refundReply, nil
+}
+
+// QueryOrder 查询交易, 沙箱网关不会收到用户的支付, 交易状态始终是未支付
+func (wsl *WxPaySandboxLib) QueryOrder(orderNo string) (*WxPayNotifyResourceData, error) {
+	return &WxPayNotifyResourceData{
+		Mchid:      wsl.payConfig.MchId,
+		OutTradeNo: orderNo,
+		TradeState: WxTradeStateNotPay,
+	}, nil
+}
+
+// CloseOrder 关闭交易, 沙箱网关直接返回成功
+func (wsl *WxPaySandboxLib) CloseOrder(orderNo string) error {
+	logger.New(wsl.ctx).Info("WxPaySandboxCloseOrder", "orderNo", orderNo)
+	return nil
+}
+
+// DownloadTradeBill 沙箱网关没有真实的交易, 返回空的交易账单
+func (wsl *WxPaySandboxLib) DownloadTradeBill(billDate time.Time) ([]*WxTradeBillRecord, error) {
+	logger.New(wsl.ctx).Info("WxPaySandboxDownloadTradeBill", "billDate", billDate.Format("2006-01-02"))
+	return []*WxTradeBillRecord{}, nil
+}
+
+// genPrepayId 根据商户号和订单号生成固定的预支付 ID, 同一订单多次下单得到的结果相同
+func (wsl *WxPaySandboxLib) genPrepayId(orderNo string) string {
+	hash := sha256.Sum256([]byte("prepay:" + wsl.payConfig.MchId + ":" + orderNo))
+	return "wx" + hex.EncodeToString(hash[:])[:32]
+}
+
+// genNonceStr 根据订单号生成固定的随机串
+func (wsl *WxPaySandboxLib) genNonceStr(orderNo string) string {
+	hash := sha256.Sum256([]byte("nonce:" + orderNo))
+	return hex.EncodeToString(hash[:16])
+}
+
+// sign 沙箱网关没有商户私钥, 使用以商户号为密钥的 HMAC-SHA256 代替 RSA 签名
+func (wsl *WxPaySandboxLib) sign(message string) string {
+	mac := hmac.New(sha256.New, []byte(wsl.payConfig.MchId))
+	mac.Write([]byte(message))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
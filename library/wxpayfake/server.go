@@ -0,0 +1,570 @@
+package wxpayfake
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/library"
+)
+
+// 模拟微信支付 v3 接口的本地服务, 用于在没有外部网络的情况下跑通 下单 -> 支付 -> 支付结果通知 的完整流程
+// 可以在测试中用 httptest.NewServer(server.Handler()) 启动, 也可以通过 cmd/wxpayfake 命令启动
+
+// 交易状态, 与微信支付的 trade_state 一致
+const (
+	TradeStateNotPay  = "NOTPAY"
+	TradeStateSuccess = "SUCCESS"
+	TradeStateClosed  = "CLOSED"
+)
+
+type Config struct {
+	AppId  string
+	MchId  string
+	AesKey string // APIv3 密钥, 用于加密支付结果通知, 长度必须是 32 个字节
+	// 商户公钥 PEM, 设置后会校验请求头 Authorization 中的签名
+	MchPublicKey []byte
+	// 微信支付平台私钥 PEM, 用于对应答和通知签名, 为空时自动生成
+	PlatformPrivateKey []byte
+}
+
+// Transaction 模拟服务中记录的交易
+type Transaction struct {
+	AppId         string
+	MchId         string
+	OutTradeNo    string
+	TransactionId string
+	PrepayId      string
+	TradeType     string // JSAPI、APP、NATIVE
+	TradeState    string
+	Description   string
+	NotifyUrl     string
+	OpenId        string
+	Total         int
+	SuccessTime   time.Time
+	CreatedAt     time.Time
+}
+
+type Server struct {
+	config       Config
+	platformKey  *rsa.PrivateKey
+	mchPublicKey *rsa.PublicKey
+	serialNo     string
+	httpClient   *http.Client
+
+	mu           sync.Mutex
+	transactions map[string]*Transaction // out_trade_no => 交易
+	transSeq     int
+}
+
+func NewServer(config Config) (*Server, error) {
+	if len(config.AesKey) != 32 {
+		return nil, errors.New("aes key must be 32 bytes")
+	}
+	server := &Server{
+		config:       config,
+		serialNo:     "FAKE" + strings.ToUpper(util.RandomString(16)),
+		httpClient:   &http.Client{Timeout: 5 * time.Second},
+		transactions: make(map[string]*Transaction),
+	}
+
+	var err error
+	if len(config.PlatformPrivateKey) > 0 {
+		server.platformKey, err = parsePrivateKey(config.PlatformPrivateKey)
+	} else {
+		server.platformKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(config.MchPublicKey) > 0 {
+		if server.mchPublicKey, err = parsePublicKey(config.MchPublicKey); err != nil {
+			return nil, err
+		}
+	}
+	return server, nil
+}
+
+// PlatformPublicKeyPEM 返回微信支付公钥, 配置给 WxPayLib 用于验证支付结果通知的签名
+func (s *Server) PlatformPublicKeyPEM() ([]byte, error) {
+	keyBytes, err := x509.MarshalPKIXPublicKey(&s.platformKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: keyBytes}), nil
+}
+
+// Handler 返回模拟服务的 HTTP 处理器
+func (s *Server) Handler() http.Handler {
+	g := gin.New()
+	g.Use(gin.Recovery())
+	g.POST("/v3/pay/transactions/:tradeType", s.prepay)
+	g.GET("/v3/pay/transactions/out-trade-no/:outTradeNo", s.queryTransaction)
+	g.POST("/v3/pay/transactions/out-trade-no/:outTradeNo/close", s.closeTransaction)
+	g.GET("/v3/bill/tradebill", s.applyTradeBill)
+	g.GET("/v3/billdownload/file", s.downloadTradeBill)
+	// 模拟用户完成支付, 供手动调试使用, 测试中可以直接调用 Server.Pay
+	g.POST("/sandbox/transactions/:outTradeNo/pay", s.sandboxPay)
+	return g
+}
+
+// Transaction 获取交易的当前状态
+func (s *Server) Transaction(outTradeNo string) (Transaction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	trans, ok := s.transactions[outTradeNo]
+	if !ok {
+		return Transaction{}, false
+	}
+	return *trans, true
+}
+
+// Pay 模拟用户完成支付, 把交易设置为支付成功后向下单时的 notify_url 发送支付结果通知
+func (s *Server) Pay(outTradeNo string) error {
+	s.mu.Lock()
+	trans, ok := s.transactions[outTradeNo]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("transaction %s not exist", outTradeNo)
+	}
+	if trans.TradeState == TradeStateClosed {
+		s.mu.Unlock()
+		return fmt.Errorf("transaction %s closed", outTradeNo)
+	}
+	if trans.TradeState != TradeStateSuccess {
+		s.transSeq++
+		trans.TradeState = TradeStateSuccess
+		trans.TransactionId = fmt.Sprintf("4200%s%010d", time.Now().Format("20060102"), s.transSeq)
+		trans.SuccessTime = time.Now().Truncate(time.Second)
+	}
+	paidTrans := *trans
+	s.mu.Unlock()
+
+	return s.SendNotify(&paidTrans)
+}
+
+// SendNotify 向交易的 notify_url 发送签名并加密后的支付结果通知
+// 通知格式: https://pay.weixin.qq.com/docs/merchant/apis/jsapi-payment/payment-notice.html
+func (s *Server) SendNotify(trans *Transaction) error {
+	if trans.NotifyUrl == "" {
+		return fmt.Errorf("transaction %s has no notify_url", trans.OutTradeNo)
+	}
+	resourceData := library.WxPayNotifyResourceData{
+		TransactionID:  trans.TransactionId,
+		Mchid:          trans.MchId,
+		TradeState:     trans.TradeState,
+		BankType:       "OTHERS",
+		SuccessTime:    trans.SuccessTime,
+		OutTradeNo:     trans.OutTradeNo,
+		AppID:          trans.AppId,
+		TradeStateDesc: "支付成功",
+		TradeType:      trans.TradeType,
+	}
+	resourceData.Amount.Total = trans.Total
+	resourceData.Amount.PayerTotal = trans.Total
+	resourceData.Amount.Currency = "CNY"
+	resourceData.Amount.PayerCurrency = "CNY"
+	resourceData.Payer.Openid = trans.OpenId
+	plaintext, _ := json.Marshal(resourceData)
+
+	nonce := util.RandomString(12)
+	associatedData := "transaction"
+	ciphertext, err := s.encrypt(plaintext, nonce, associatedData)
+	if err != nil {
+		return err
+	}
+
+	notify := map[string]interface{}{
+		"id":            util.RandomString(32),
+		"create_time":   time.Now().Format(time.RFC3339),
+		"resource_type": "encrypt-resource",
+		"event_type":    "TRANSACTION.SUCCESS",
+		"summary":       "支付成功",
+		"resource": map[string]string{
+			"original_type":   "transaction",
+			"algorithm":       "AEAD_AES_256_GCM",
+			"ciphertext":      ciphertext,
+			"associated_data": associatedData,
+			"nonce":           nonce,
+		},
+	}
+	body, _ := json.Marshal(notify)
+
+	req, err := http.NewRequest(http.MethodPost, trans.NotifyUrl, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err = s.signHeader(req.Header, body); err != nil {
+		return err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		replyBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("notify %s failed, status: %d, reply: %s", trans.NotifyUrl, resp.StatusCode, replyBody)
+	}
+	return nil
+}
+
+// prepay 处理 JSAPI、APP、Native 下单请求
+func (s *Server) prepay(c *gin.Context) {
+	tradeType := strings.ToUpper(c.Param("tradeType"))
+	if tradeType != "JSAPI" && tradeType != "APP" && tradeType != "NATIVE" {
+		s.replyError(c, http.StatusNotFound, "NOT_FOUND", "unsupported trade type")
+		return
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		s.replyError(c, http.StatusBadRequest, "PARAM_ERROR", err.Error())
+		return
+	}
+	if err = s.verifyAuthorization(c.Request, body); err != nil {
+		s.replyError(c, http.StatusUnauthorized, "SIGN_ERROR", err.Error())
+		return
+	}
+
+	param := struct {
+		AppId       string `json:"appid"`
+		MchId       string `json:"mchid"`
+		Description string `json:"description"`
+		OutTradeNo  string `json:"out_trade_no"`
+		NotifyUrl   string `json:"notify_url"`
+		Amount      struct {
+			Total    int    `json:"total"`
+			Currency string `json:"currency"`
+		} `json:"amount"`
+		Payer struct {
+			OpenId string `json:"openid"`
+		} `json:"payer"`
+	}{}
+	if err = json.Unmarshal(body, &param); err != nil {
+		s.replyError(c, http.StatusBadRequest, "PARAM_ERROR", err.Error())
+		return
+	}
+	switch {
+	case param.AppId != s.config.AppId || param.MchId != s.config.MchId:
+		s.replyError(c, http.StatusBadRequest, "APPID_MCHID_NOT_MATCH", "appid 和 mchid 不匹配")
+		return
+	case param.OutTradeNo == "" || param.Description == "":
+		s.replyError(c, http.StatusBadRequest, "PARAM_ERROR", "缺少 out_trade_no 或 description")
+		return
+	case param.Amount.Total <= 0:
+		s.replyError(c, http.StatusBadRequest, "PARAM_ERROR", "amount.total 必须大于 0")
+		return
+	case tradeType == "JSAPI" && param.Payer.OpenId == "":
+		s.replyError(c, http.StatusBadRequest, "PARAM_ERROR", "缺少 payer.openid")
+		return
+	}
+
+	s.mu.Lock()
+	trans, exist := s.transactions[param.OutTradeNo]
+	if exist {
+		s.mu.Unlock()
+		if trans.TradeState == TradeStateSuccess {
+			s.replyError(c, http.StatusBadRequest, "ORDERPAID", "该订单已支付")
+			return
+		}
+		if trans.Total != param.Amount.Total || trans.TradeType != tradeType {
+			s.replyError(c, http.StatusBadRequest, "OUT_TRADE_NO_USED", "商户订单号重复")
+			return
+		}
+		// 同一订单重复下单时返回原来的预支付信息
+		s.replyPrepay(c, trans)
+		return
+	}
+	trans = &Transaction{
+		AppId:       param.AppId,
+		MchId:       param.MchId,
+		OutTradeNo:  param.OutTradeNo,
+		PrepayId:    "wx" + util.RandomString(30),
+		TradeType:   tradeType,
+		TradeState:  TradeStateNotPay,
+		Description: param.Description,
+		NotifyUrl:   param.NotifyUrl,
+		OpenId:      param.Payer.OpenId,
+		Total:       param.Amount.Total,
+		CreatedAt:   time.Now(),
+	}
+	s.transactions[trans.OutTradeNo] = trans
+	s.mu.Unlock()
+
+	s.replyPrepay(c, trans)
+}
+
+func (s *Server) replyPrepay(c *gin.Context, trans *Transaction) {
+	if trans.TradeType == "NATIVE" {
+		s.replyJSON(c, http.StatusOK, gin.H{"code_url": "weixin://wxpay/bizpayurl?pr=" + trans.PrepayId[2:12]})
+		return
+	}
+	s.replyJSON(c, http.StatusOK, gin.H{"prepay_id": trans.PrepayId})
+}
+
+// queryTransaction 按商户订单号查询交易
+// 微信支付文档: https://pay.weixin.qq.com/docs/merchant/apis/jsapi-payment/query-by-out-trade-no.html
+func (s *Server) queryTransaction(c *gin.Context) {
+	if err := s.verifyAuthorization(c.Request, nil); err != nil {
+		s.replyError(c, http.StatusUnauthorized, "SIGN_ERROR", err.Error())
+		return
+	}
+	trans, ok := s.Transaction(c.Param("outTradeNo"))
+	if !ok || c.Query("mchid") != trans.MchId {
+		s.replyError(c, http.StatusNotFound, "ORDER_NOT_EXIST", "订单不存在")
+		return
+	}
+
+	reply := gin.H{
+		"appid":            trans.AppId,
+		"mchid":            trans.MchId,
+		"out_trade_no":     trans.OutTradeNo,
+		"trade_type":       trans.TradeType,
+		"trade_state":      trans.TradeState,
+		"trade_state_desc": trans.TradeState,
+		"amount":           gin.H{"total": trans.Total, "currency": "CNY"},
+	}
+	if trans.TradeState == TradeStateSuccess {
+		reply["transaction_id"] = trans.TransactionId
+		reply["success_time"] = trans.SuccessTime.Format(time.RFC3339)
+		reply["amount"] = gin.H{"total": trans.Total, "payer_total": trans.Total, "currency": "CNY", "payer_currency": "CNY"}
+	}
+	s.replyJSON(c, http.StatusOK, reply)
+}
+
+// closeTransaction 关闭交易, 已支付的交易不能关闭
+// 微信支付文档: https://pay.weixin.qq.com/docs/merchant/apis/jsapi-payment/close-order.html
+func (s *Server) closeTransaction(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		s.replyError(c, http.StatusBadRequest, "PARAM_ERROR", err.Error())
+		return
+	}
+	if err = s.verifyAuthorization(c.Request, body); err != nil {
+		s.replyError(c, http.StatusUnauthorized, "SIGN_ERROR", err.Error())
+		return
+	}
+	param := struct {
+		MchId string `json:"mchid"`
+	}{}
+	if err = json.Unmarshal(body, &param); err != nil {
+		s.replyError(c, http.StatusBadRequest, "PARAM_ERROR", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	trans, ok := s.transactions[c.Param("outTradeNo")]
+	if !ok || param.MchId != trans.MchId {
+		s.replyError(c, http.StatusNotFound, "ORDER_NOT_EXIST", "订单不存在")
+		return
+	}
+	if trans.TradeState == TradeStateSuccess {
+		s.replyError(c, http.StatusBadRequest, "ORDERPAID", "该订单已支付")
+		return
+	}
+	trans.TradeState = TradeStateClosed
+	c.Status(http.StatusNoContent)
+}
+
+// applyTradeBill 申请交易账单, 返回账单的下载地址和摘要
+// 微信支付文档: https://pay.weixin.qq.com/docs/merchant/apis/bill-download/trade-bill.html
+func (s *Server) applyTradeBill(c *gin.Context) {
+	if err := s.verifyAuthorization(c.Request, nil); err != nil {
+		s.replyError(c, http.StatusUnauthorized, "SIGN_ERROR", err.Error())
+		return
+	}
+	billDate, err := time.ParseInLocation("2006-01-02", c.Query("bill_date"), time.Local)
+	if err != nil {
+		s.replyError(c, http.StatusBadRequest, "PARAM_ERROR", "bill_date 格式错误")
+		return
+	}
+	hash := sha1.Sum(s.TradeBill(billDate))
+	s.replyJSON(c, http.StatusOK, gin.H{
+		"hash_type":    "SHA1",
+		"hash_value":   hex.EncodeToString(hash[:]),
+		"download_url": "http://" + c.Request.Host + "/v3/billdownload/file?bill_date=" + billDate.Format("2006-01-02"),
+	})
+}
+
+// downloadTradeBill 下载交易账单文件
+func (s *Server) downloadTradeBill(c *gin.Context) {
+	if err := s.verifyAuthorization(c.Request, nil); err != nil {
+		s.replyError(c, http.StatusUnauthorized, "SIGN_ERROR", err.Error())
+		return
+	}
+	billDate, err := time.ParseInLocation("2006-01-02", c.Query("bill_date"), time.Local)
+	if err != nil {
+		s.replyError(c, http.StatusBadRequest, "PARAM_ERROR", "bill_date 格式错误")
+		return
+	}
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", s.TradeBill(billDate))
+}
+
+// TradeBill 生成 billDate 当天支付成功的交易的账单, 格式与微信支付的交易账单相同
+func (s *Server) TradeBill(billDate time.Time) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("交易时间,公众账号ID,商户号,特约商户号,设备号,微信订单号,商户订单号,用户标识,交易类型,交易状态,付款银行,货币种类," +
+		"应结订单金额,代金券金额,微信退款单号,商户退款单号,退款金额,充值券退款金额,退款类型,退款状态,商品名称,商户数据包,手续费,费率," +
+		"订单金额,申请退款金额,费率备注\n")
+	count, total := 0, 0
+	for _, trans := range s.transactions {
+		if trans.TradeState != TradeStateSuccess || trans.SuccessTime.Format("2006-01-02") != billDate.Format("2006-01-02") {
+			continue
+		}
+		amount := fmt.Sprintf("%.2f", float64(trans.Total)/100)
+		fields := []string{trans.SuccessTime.Format("2006-01-02 15:04:05"), trans.AppId, trans.MchId, "0", "", trans.TransactionId,
+			trans.OutTradeNo, trans.OpenId, trans.TradeType, TradeStateSuccess, "OTHERS", "CNY", amount, "0.00", "0", "0", "0.00",
+			"0.00", "", "", trans.Description, "", "0.00000", "0.60%", amount, "0.00", ""}
+		buf.WriteString("`" + strings.Join(fields, ",`") + "\n")
+		count++
+		total += trans.Total
+	}
+	totalAmount := fmt.Sprintf("%.2f", float64(total)/100)
+	buf.WriteString("总交易单数,应结订单总金额,退款总金额,充值券退款总金额,手续费总金额,订单总金额,申请退款总金额\n")
+	buf.WriteString(fmt.Sprintf("`%d,`%s,`0.00,`0.00,`0.00000,`%s,`0.00\n", count, totalAmount, totalAmount))
+	return buf.Bytes()
+}
+
+func (s *Server) sandboxPay(c *gin.Context) {
+	if err := s.Pay(c.Param("outTradeNo")); err != nil {
+		s.replyError(c, http.StatusBadRequest, "PAY_ERROR", err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// verifyAuthorization 校验请求头 Authorization 中的商户签名, 没有配置商户公钥时只校验商户号
+// 签名规则: https://pay.weixin.qq.com/docs/merchant/development/interface-rules/signature-generation.html
+func (s *Server) verifyAuthorization(req *http.Request, body []byte) error {
+	authorization := req.Header.Get("Authorization")
+	const schema = "WECHATPAY2-SHA256-RSA2048 "
+	if !strings.HasPrefix(authorization, schema) {
+		return errors.New("authorization schema error")
+	}
+	fields := make(map[string]string)
+	for _, pair := range strings.Split(strings.TrimPrefix(authorization, schema), ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if found {
+			fields[key] = strings.Trim(value, "\"")
+		}
+	}
+	if fields["mchid"] != s.config.MchId {
+		return errors.New("mchid not match")
+	}
+	if s.mchPublicKey == nil {
+		return nil
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(fields["signature"])
+	if err != nil {
+		return err
+	}
+	message := fmt.Sprintf("%s\n%s\n%s\n%s\n%s\n", req.Method, req.URL.RequestURI(), fields["timestamp"], fields["nonce_str"], body)
+	return rsa.VerifyPKCS1v15(s.mchPublicKey, crypto.SHA256, util.SHA256HashBytes(message), signature)
+}
+
+// replyJSON 返回带微信支付签名的应答
+func (s *Server) replyJSON(c *gin.Context, status int, data interface{}) {
+	body, _ := json.Marshal(data)
+	if err := s.signHeader(c.Writer.Header(), body); err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Data(status, "application/json", body)
+}
+
+func (s *Server) replyError(c *gin.Context, status int, code, message string) {
+	s.replyJSON(c, status, gin.H{"code": code, "message": message})
+}
+
+// signHeader 使用平台私钥对应答或通知签名, 并设置微信支付的签名请求头
+// 验签规则: https://pay.weixin.qq.com/docs/merchant/development/interface-rules/signature-verification.html
+func (s *Server) signHeader(header http.Header, body []byte) error {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := util.RandomString(32)
+	message := fmt.Sprintf("%s\n%s\n%s\n", timestamp, nonce, body)
+	signBytes, err := rsa.SignPKCS1v15(rand.Reader, s.platformKey, crypto.SHA256, util.SHA256HashBytes(message))
+	if err != nil {
+		return err
+	}
+	header.Set("Wechatpay-Timestamp", timestamp)
+	header.Set("Wechatpay-Nonce", nonce)
+	header.Set("Wechatpay-Serial", s.serialNo)
+	header.Set("Wechatpay-Signature-Type", "WECHATPAY2-SHA256-RSA2048")
+	header.Set("Wechatpay-Signature", base64.StdEncoding.EncodeToString(signBytes))
+	return nil
+}
+
+// encrypt 使用 APIv3 密钥对通知数据进行 AEAD_AES_256_GCM 加密, 返回 Base64 编码后的密文
+func (s *Server) encrypt(plaintext []byte, nonce, associatedData string) (string, error) {
+	block, err := aes.NewCipher([]byte(s.config.AesKey))
+	if err != nil {
+		return "", err
+	}
+	aesGCM, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	ciphertext := aesGCM.Seal(nil, []byte(nonce), plaintext, []byte(associatedData))
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+func parsePrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("private key decode error")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not rsa key")
+	}
+	return rsaKey, nil
+}
+
+func parsePublicKey(pemBytes []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("public key decode error")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not rsa key")
+	}
+	return rsaKey, nil
+}
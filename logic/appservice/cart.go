@@ -94,26 +94,58 @@ func (cas *CartAppSvc) CheckCartItemBill(cartItemIds []int64, userId int64) (*re
 	return replyBill, nil
 }
 
-// CheckCartItemBillV2 V2 版购物项账单, 支持满减、优惠卷、会员价
-func (cas *CartAppSvc) CheckCartItemBillV2(cartItemIds []int64, userId int64) (*reply.CheckedCartItemBillV2, error) {
+// CheckCartItemBillV2 V2 版购物项账单, 支持满减、优惠卷、会员价和运费
+// userAddressId 为 0 时(还没有选择收货地址)不计算运费, couponId 为 0 时自动选择减免金额最多的优惠券
+func (cas *CartAppSvc) CheckCartItemBillV2(cartItemIds []int64, userId int64, userAddressId int64, couponId int64) (*reply.CheckedCartItemBillV2, error) {
 	checkedCartItems, err := cas.cartDomainSvc.GetCheckedCartItems(cartItemIds, userId)
 	if err != nil {
 		return nil, err
 	}
+	return cas.checkItemsBill(checkedCartItems, userId, userAddressId, couponId)
+}
 
-	billChecker := domainservice.NewCartBillChecker(checkedCartItems, userId)
+// CheckBuyNowBill 立即购买前查看账单, 和购物项账单一样返回结算凭证, 立即购买下单时校验
+func (cas *CartAppSvc) CheckBuyNowBill(billRequest *request.OrderBuyNowBill, userId int64) (*reply.CheckedCartItemBillV2, error) {
+	buyItems := make([]*do.ShoppingCartItem, 0, len(billRequest.Items))
+	if err := util.CopyProperties(&buyItems, &billRequest.Items); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	items, err := cas.cartDomainSvc.GetBuyNowItems(buyItems, userId)
+	if err != nil {
+		return nil, err
+	}
+	return cas.checkItemsBill(items, userId, billRequest.UserAddressId, billRequest.CouponId)
+}
+
+// checkItemsBill 计算购物项的账单并生成结算凭证
+func (cas *CartAppSvc) checkItemsBill(items []*do.ShoppingCartItem, userId int64, userAddressId int64, couponId int64) (*reply.CheckedCartItemBillV2, error) {
+	var err error
+	var userAddress *do.UserAddressInfo
+	if userAddressId > 0 {
+		userAddress, err = domainservice.NewUserDomainSvc(cas.ctx).GetUserSingleAddress(userId, userAddressId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	billChecker := domainservice.NewCartBillChecker(cas.ctx, items, userId, userAddress)
+	billChecker.SelectedCouponId = couponId
 	billInfo, err := billChecker.GetBill()
 	if err != nil {
 		return nil, err
 	}
 
 	replyBillInfo := new(reply.CheckedCartItemBillV2)
-	if err = util.CopyProperties(&replyBillInfo.Items, checkedCartItems); err != nil {
+	if err = util.CopyProperties(&replyBillInfo.Items, items); err != nil {
 		return nil, errcode.ErrCoverData.WithCause(err)
 	}
 	if err = util.CopyProperties(&replyBillInfo.BillDetail, &billInfo); err != nil {
 		return nil, errcode.ErrCoverData.WithCause(err)
 	}
+	replyBillInfo.CheckoutToken, err = domainservice.SignCheckoutToken(userId, items, billInfo)
+	if err != nil {
+		return nil, err
+	}
 
 	return replyBillInfo, nil
 }
@@ -0,0 +1,149 @@
+package appservice
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+
+	"github.com/hd2yao/go-mall/api/reply"
+	"github.com/hd2yao/go-mall/api/request"
+	"github.com/hd2yao/go-mall/common/app"
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/logic/do"
+	"github.com/hd2yao/go-mall/logic/domainservice"
+)
+
+type CouponAppSvc struct {
+	ctx             context.Context
+	couponDomainSvc *domainservice.CouponDomainSvc
+}
+
+func NewCouponAppSvc(ctx context.Context) *CouponAppSvc {
+	return &CouponAppSvc{
+		ctx:             ctx,
+		couponDomainSvc: domainservice.NewCouponDomainSvc(ctx),
+	}
+}
+
+// CreateCouponTemplate 管理后台创建优惠券模板
+func (cas *CouponAppSvc) CreateCouponTemplate(templateRequest *request.CouponTemplateCreate) (*reply.CouponTemplate, error) {
+	template := &do.CouponTemplate{
+		Name:             templateRequest.Name,
+		CouponType:       templateRequest.CouponType,
+		DiscountMoney:    templateRequest.DiscountMoney,
+		DiscountRate:     templateRequest.DiscountRate,
+		MaxDiscountMoney: templateRequest.MaxDiscountMoney,
+		Threshold:        templateRequest.Threshold,
+		ScopeType:        templateRequest.ScopeType,
+		TotalNum:         templateRequest.TotalNum,
+		PerUserLimit:     templateRequest.PerUserLimit,
+	}
+	// 请求中的分类ID或商品ID列表保存成逗号分隔的字符串
+	template.ScopeIds = strings.Join(lo.Map(templateRequest.ScopeIds, func(id int64, _ int) string {
+		return strconv.FormatInt(id, 10)
+	}), ",")
+	var err error
+	if template.ValidStart, err = time.ParseInLocation(enum.TimeFormatHyphenedYMDHIS, templateRequest.ValidStart, time.Local); err != nil {
+		return nil, errcode.ErrParams.WithCause(err)
+	}
+	if template.ValidEnd, err = time.ParseInLocation(enum.TimeFormatHyphenedYMDHIS, templateRequest.ValidEnd, time.Local); err != nil {
+		return nil, errcode.ErrParams.WithCause(err)
+	}
+
+	if err = cas.couponDomainSvc.CreateCouponTemplate(template); err != nil {
+		return nil, err
+	}
+	return toReplyCouponTemplate(template)
+}
+
+// StopCouponTemplate 管理后台停止发放优惠券
+func (cas *CouponAppSvc) StopCouponTemplate(templateId int64) error {
+	return cas.couponDomainSvc.StopCouponTemplate(templateId)
+}
+
+// GetCouponTemplateList 管理后台优惠券模板列表
+func (cas *CouponAppSvc) GetCouponTemplateList(pagination *app.Pagination) ([]*reply.CouponTemplate, error) {
+	templates, err := cas.couponDomainSvc.GetCouponTemplateList(pagination)
+	if err != nil {
+		return nil, err
+	}
+	return toReplyCouponTemplates(templates)
+}
+
+// GetClaimableCoupons 当前可以领取的优惠券
+func (cas *CouponAppSvc) GetClaimableCoupons(pagination *app.Pagination) ([]*reply.CouponTemplate, error) {
+	templates, err := cas.couponDomainSvc.GetClaimableCoupons(pagination)
+	if err != nil {
+		return nil, err
+	}
+	return toReplyCouponTemplates(templates)
+}
+
+// ClaimCoupon 用户领取优惠券
+func (cas *CouponAppSvc) ClaimCoupon(templateId, userId int64) (*reply.UserCoupon, error) {
+	userCoupon, err := cas.couponDomainSvc.ClaimCoupon(templateId, userId)
+	if err != nil {
+		return nil, err
+	}
+	return toReplyUserCoupon(userCoupon)
+}
+
+// GetUserCoupons 用户的优惠券列表, status 为 0 时返回全部状态的优惠券
+func (cas *CouponAppSvc) GetUserCoupons(userId int64, status int, pagination *app.Pagination) ([]*reply.UserCoupon, error) {
+	userCoupons, err := cas.couponDomainSvc.GetUserCoupons(userId, status, pagination)
+	if err != nil {
+		return nil, err
+	}
+	replyCoupons := make([]*reply.UserCoupon, 0, len(userCoupons))
+	for _, userCoupon := range userCoupons {
+		replyCoupon, err := toReplyUserCoupon(userCoupon)
+		if err != nil {
+			return nil, err
+		}
+		replyCoupons = append(replyCoupons, replyCoupon)
+	}
+	return replyCoupons, nil
+}
+
+func toReplyCouponTemplates(templates []*do.CouponTemplate) ([]*reply.CouponTemplate, error) {
+	replyTemplates := make([]*reply.CouponTemplate, 0, len(templates))
+	for _, template := range templates {
+		replyTemplate, err := toReplyCouponTemplate(template)
+		if err != nil {
+			return nil, err
+		}
+		replyTemplates = append(replyTemplates, replyTemplate)
+	}
+	return replyTemplates, nil
+}
+
+func toReplyCouponTemplate(template *do.CouponTemplate) (*reply.CouponTemplate, error) {
+	replyTemplate := new(reply.CouponTemplate)
+	if err := util.CopyProperties(replyTemplate, template); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	replyTemplate.CouponTypeName = enum.CouponTypeName[template.CouponType]
+	replyTemplate.ScopeIds = template.ScopeIdList()
+	return replyTemplate, nil
+}
+
+func toReplyUserCoupon(userCoupon *do.UserCoupon) (*reply.UserCoupon, error) {
+	replyCoupon := new(reply.UserCoupon)
+	if err := util.CopyProperties(replyCoupon, userCoupon); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	replyCoupon.StatusName = enum.UserCouponStatusName[userCoupon.Status]
+	if userCoupon.Status != enum.UserCouponUsed {
+		replyCoupon.UsedAt = ""
+	}
+	var err error
+	if replyCoupon.Template, err = toReplyCouponTemplate(userCoupon.Template); err != nil {
+		return nil, err
+	}
+	return replyCoupon, nil
+}
@@ -0,0 +1,109 @@
+package appservice
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+
+	"github.com/hd2yao/go-mall/api/reply"
+	"github.com/hd2yao/go-mall/api/request"
+	"github.com/hd2yao/go-mall/common/app"
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/logic/do"
+	"github.com/hd2yao/go-mall/logic/domainservice"
+)
+
+type DiscountAppSvc struct {
+	ctx               context.Context
+	discountDomainSvc *domainservice.DiscountDomainSvc
+}
+
+func NewDiscountAppSvc(ctx context.Context) *DiscountAppSvc {
+	return &DiscountAppSvc{
+		ctx:               ctx,
+		discountDomainSvc: domainservice.NewDiscountDomainSvc(ctx),
+	}
+}
+
+// CreateDiscountCampaign 管理后台创建满减活动
+func (das *DiscountAppSvc) CreateDiscountCampaign(campaignRequest *request.DiscountCampaignCreate) (*reply.DiscountCampaign, error) {
+	campaign := &do.DiscountCampaign{
+		Name:         campaignRequest.Name,
+		ScopeType:    campaignRequest.ScopeType,
+		PerUserLimit: campaignRequest.PerUserLimit,
+	}
+	// 请求中的分类ID或商品ID列表保存成逗号分隔的字符串
+	campaign.ScopeIds = strings.Join(lo.Map(campaignRequest.ScopeIds, func(id int64, _ int) string {
+		return strconv.FormatInt(id, 10)
+	}), ",")
+	campaign.Tiers = lo.Map(campaignRequest.Tiers, func(tier *request.DiscountCampaignTierSave, _ int) *do.DiscountCampaignTier {
+		return &do.DiscountCampaignTier{
+			Threshold:     tier.Threshold,
+			DiscountMoney: tier.DiscountMoney,
+		}
+	})
+	var err error
+	if campaign.StartTime, err = time.ParseInLocation(enum.TimeFormatHyphenedYMDHIS, campaignRequest.StartTime, time.Local); err != nil {
+		return nil, errcode.ErrParams.WithCause(err)
+	}
+	if campaign.EndTime, err = time.ParseInLocation(enum.TimeFormatHyphenedYMDHIS, campaignRequest.EndTime, time.Local); err != nil {
+		return nil, errcode.ErrParams.WithCause(err)
+	}
+
+	if err = das.discountDomainSvc.CreateDiscountCampaign(campaign); err != nil {
+		return nil, err
+	}
+	return toReplyDiscountCampaign(campaign)
+}
+
+// StopDiscountCampaign 管理后台停止满减活动
+func (das *DiscountAppSvc) StopDiscountCampaign(campaignId int64) error {
+	return das.discountDomainSvc.StopDiscountCampaign(campaignId)
+}
+
+// GetDiscountCampaignList 管理后台满减活动列表
+func (das *DiscountAppSvc) GetDiscountCampaignList(pagination *app.Pagination) ([]*reply.DiscountCampaign, error) {
+	campaigns, err := das.discountDomainSvc.GetDiscountCampaignList(pagination)
+	if err != nil {
+		return nil, err
+	}
+	return toReplyDiscountCampaigns(campaigns)
+}
+
+// GetOngoingCampaigns 正在进行的满减活动
+func (das *DiscountAppSvc) GetOngoingCampaigns() ([]*reply.DiscountCampaign, error) {
+	campaigns, err := das.discountDomainSvc.GetOngoingCampaigns()
+	if err != nil {
+		return nil, err
+	}
+	return toReplyDiscountCampaigns(campaigns)
+}
+
+func toReplyDiscountCampaigns(campaigns []*do.DiscountCampaign) ([]*reply.DiscountCampaign, error) {
+	replyCampaigns := make([]*reply.DiscountCampaign, 0, len(campaigns))
+	for _, campaign := range campaigns {
+		replyCampaign, err := toReplyDiscountCampaign(campaign)
+		if err != nil {
+			return nil, err
+		}
+		replyCampaigns = append(replyCampaigns, replyCampaign)
+	}
+	return replyCampaigns, nil
+}
+
+func toReplyDiscountCampaign(campaign *do.DiscountCampaign) (*reply.DiscountCampaign, error) {
+	replyCampaign := new(reply.DiscountCampaign)
+	if err := util.CopyProperties(replyCampaign, campaign); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	replyCampaign.ScopeIds = campaign.ScopeIdList()
+	if replyCampaign.Tiers == nil {
+		replyCampaign.Tiers = []*reply.DiscountCampaignTier{}
+	}
+	return replyCampaign, nil
+}
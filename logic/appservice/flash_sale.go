@@ -0,0 +1,127 @@
+package appservice
+
+import (
+	"context"
+	"time"
+
+	"github.com/hd2yao/go-mall/api/reply"
+	"github.com/hd2yao/go-mall/api/request"
+	"github.com/hd2yao/go-mall/common/app"
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/logic/do"
+	"github.com/hd2yao/go-mall/logic/domainservice"
+)
+
+type FlashSaleAppSvc struct {
+	ctx                context.Context
+	flashSaleDomainSvc *domainservice.FlashSaleDomainSvc
+}
+
+func NewFlashSaleAppSvc(ctx context.Context) *FlashSaleAppSvc {
+	return &FlashSaleAppSvc{
+		ctx:                ctx,
+		flashSaleDomainSvc: domainservice.NewFlashSaleDomainSvc(ctx),
+	}
+}
+
+// CreateFlashSaleSession 管理后台创建秒杀场次
+func (fas *FlashSaleAppSvc) CreateFlashSaleSession(sessionRequest *request.FlashSaleSessionCreate) (*reply.FlashSaleSession, error) {
+	session := &do.FlashSaleSession{
+		Name:         sessionRequest.Name,
+		CommodityId:  sessionRequest.CommodityId,
+		FlashPrice:   sessionRequest.FlashPrice,
+		TotalStock:   sessionRequest.TotalStock,
+		PerUserLimit: sessionRequest.PerUserLimit,
+	}
+	var err error
+	if session.StartTime, err = time.ParseInLocation(enum.TimeFormatHyphenedYMDHIS, sessionRequest.StartTime, time.Local); err != nil {
+		return nil, errcode.ErrParams.WithCause(err)
+	}
+	if session.EndTime, err = time.ParseInLocation(enum.TimeFormatHyphenedYMDHIS, sessionRequest.EndTime, time.Local); err != nil {
+		return nil, errcode.ErrParams.WithCause(err)
+	}
+
+	if err = fas.flashSaleDomainSvc.CreateSession(session); err != nil {
+		return nil, err
+	}
+	session.RemainingStock = session.TotalStock
+	replySession := new(reply.FlashSaleSession)
+	if err = util.CopyProperties(replySession, session); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return replySession, nil
+}
+
+// StopFlashSaleSession 管理后台提前结束秒杀场次
+func (fas *FlashSaleAppSvc) StopFlashSaleSession(sessionId int64) error {
+	return fas.flashSaleDomainSvc.StopSession(sessionId)
+}
+
+// GetFlashSaleSessionList 管理后台秒杀场次列表
+func (fas *FlashSaleAppSvc) GetFlashSaleSessionList(pagination *app.Pagination) ([]*reply.FlashSaleSession, error) {
+	sessions, err := fas.flashSaleDomainSvc.GetSessionList(pagination)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.RemainingStock = session.TotalStock - session.SoldNum
+	}
+	replySessions := make([]*reply.FlashSaleSession, 0, len(sessions))
+	if err = util.CopyProperties(&replySessions, &sessions); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return replySessions, nil
+}
+
+// GetActiveFlashSaleSessions 正在抢购和即将开始的秒杀场次
+func (fas *FlashSaleAppSvc) GetActiveFlashSaleSessions() ([]*reply.FlashSaleSession, error) {
+	sessions, err := fas.flashSaleDomainSvc.GetActiveSessions()
+	if err != nil {
+		return nil, err
+	}
+	replySessions := make([]*reply.FlashSaleSession, 0, len(sessions))
+	if err = util.CopyProperties(&replySessions, &sessions); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return replySessions, nil
+}
+
+// Purchase 用户抢购秒杀商品, 返回排队中的处理结果, 客户端用其中的请求号轮询处理结果
+func (fas *FlashSaleAppSvc) Purchase(userId, sessionId int64, purchaseRequest *request.FlashSalePurchase) (*reply.FlashSaleResult, error) {
+	requestNo, err := fas.flashSaleDomainSvc.Purchase(userId, sessionId, purchaseRequest.CommodityNum, purchaseRequest.UserAddressId)
+	if err != nil {
+		return nil, err
+	}
+	return &reply.FlashSaleResult{
+		RequestNo:  requestNo,
+		Status:     enum.FlashSaleRequestQueued,
+		StatusName: enum.FlashSaleRequestStatusName[enum.FlashSaleRequestQueued],
+	}, nil
+}
+
+// GetPurchaseResult 查询秒杀请求的处理结果
+func (fas *FlashSaleAppSvc) GetPurchaseResult(userId int64, requestNo string) (*reply.FlashSaleResult, error) {
+	result, err := fas.flashSaleDomainSvc.GetPurchaseResult(userId, requestNo)
+	if err != nil {
+		return nil, err
+	}
+	return &reply.FlashSaleResult{
+		RequestNo:  result.RequestNo,
+		Status:     result.Status,
+		StatusName: enum.FlashSaleRequestStatusName[result.Status],
+		OrderNo:    result.OrderNo,
+		FailReason: result.FailReason,
+	}, nil
+}
+
+// ConsumeFlashSaleRequests 从下单队列中取出秒杀请求创建订单
+func (fas *FlashSaleAppSvc) ConsumeFlashSaleRequests() error {
+	return fas.flashSaleDomainSvc.ConsumeRequests()
+}
+
+// CompensateFlashSale 秒杀的补偿任务, 结束过期场次并退还没有创建订单或订单已取消、关闭的 Redis 库存
+func (fas *FlashSaleAppSvc) CompensateFlashSale(interval time.Duration) error {
+	return fas.flashSaleDomainSvc.Compensate(interval)
+}
@@ -0,0 +1,123 @@
+package appservice
+
+import (
+	"context"
+	"strings"
+
+	"github.com/samber/lo"
+
+	"github.com/hd2yao/go-mall/api/reply"
+	"github.com/hd2yao/go-mall/api/request"
+	"github.com/hd2yao/go-mall/common/app"
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/logic/do"
+	"github.com/hd2yao/go-mall/logic/domainservice"
+)
+
+type FreightAppSvc struct {
+	ctx              context.Context
+	freightDomainSvc *domainservice.FreightDomainSvc
+}
+
+func NewFreightAppSvc(ctx context.Context) *FreightAppSvc {
+	return &FreightAppSvc{
+		ctx:              ctx,
+		freightDomainSvc: domainservice.NewFreightDomainSvc(ctx),
+	}
+}
+
+// CreateFreightTemplate 管理后台创建运费模板
+func (fas *FreightAppSvc) CreateFreightTemplate(templateRequest *request.FreightTemplateSave) (*reply.FreightTemplate, error) {
+	template := toFreightTemplate(templateRequest)
+	if err := fas.freightDomainSvc.CreateFreightTemplate(template); err != nil {
+		return nil, err
+	}
+	return fas.GetFreightTemplate(template.ID)
+}
+
+// UpdateFreightTemplate 管理后台更新运费模板
+func (fas *FreightAppSvc) UpdateFreightTemplate(templateId int64, templateRequest *request.FreightTemplateSave) (*reply.FreightTemplate, error) {
+	template := toFreightTemplate(templateRequest)
+	template.ID = templateId
+	if err := fas.freightDomainSvc.UpdateFreightTemplate(template); err != nil {
+		return nil, err
+	}
+	return fas.GetFreightTemplate(templateId)
+}
+
+// DeleteFreightTemplate 管理后台删除运费模板
+func (fas *FreightAppSvc) DeleteFreightTemplate(templateId int64) error {
+	return fas.freightDomainSvc.DeleteFreightTemplate(templateId)
+}
+
+// GetFreightTemplate 管理后台查看运费模板详情
+func (fas *FreightAppSvc) GetFreightTemplate(templateId int64) (*reply.FreightTemplate, error) {
+	template, err := fas.freightDomainSvc.GetFreightTemplate(templateId)
+	if err != nil {
+		return nil, err
+	}
+	return toReplyFreightTemplate(template)
+}
+
+// GetFreightTemplateList 管理后台运费模板列表
+func (fas *FreightAppSvc) GetFreightTemplateList(pagination *app.Pagination) ([]*reply.FreightTemplate, error) {
+	templates, err := fas.freightDomainSvc.GetFreightTemplateList(pagination)
+	if err != nil {
+		return nil, err
+	}
+	replyTemplates := make([]*reply.FreightTemplate, 0, len(templates))
+	for _, template := range templates {
+		replyTemplate, err := toReplyFreightTemplate(template)
+		if err != nil {
+			return nil, err
+		}
+		replyTemplates = append(replyTemplates, replyTemplate)
+	}
+	return replyTemplates, nil
+}
+
+// toFreightTemplate 请求中的省份列表保存成逗号分隔的字符串
+func toFreightTemplate(templateRequest *request.FreightTemplateSave) *do.FreightTemplate {
+	template := &do.FreightTemplate{
+		Name:       templateRequest.Name,
+		ChargeType: templateRequest.ChargeType,
+		IsDefault:  templateRequest.IsDefault,
+	}
+	template.Rules = lo.Map(templateRequest.Rules, func(rule *request.FreightTemplateRuleSave, _ int) *do.FreightTemplateRule {
+		return &do.FreightTemplateRule{
+			Provinces:      strings.Join(rule.Provinces, ","),
+			FirstUnit:      rule.FirstUnit,
+			FirstFee:       rule.FirstFee,
+			AdditionalUnit: rule.AdditionalUnit,
+			AdditionalFee:  rule.AdditionalFee,
+			FreeThreshold:  rule.FreeThreshold,
+		}
+	})
+	return template
+}
+
+func toReplyFreightTemplate(template *do.FreightTemplate) (*reply.FreightTemplate, error) {
+	replyTemplate := new(reply.FreightTemplate)
+	if err := util.CopyProperties(replyTemplate, template); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	replyTemplate.ChargeTypeName = enum.FreightChargeTypeName[template.ChargeType]
+	replyTemplate.Rules = lo.Map(template.Rules, func(rule *do.FreightTemplateRule, _ int) *reply.FreightTemplateRule {
+		replyRule := &reply.FreightTemplateRule{
+			ID:             rule.ID,
+			Provinces:      []string{},
+			FirstUnit:      rule.FirstUnit,
+			FirstFee:       rule.FirstFee,
+			AdditionalUnit: rule.AdditionalUnit,
+			AdditionalFee:  rule.AdditionalFee,
+			FreeThreshold:  rule.FreeThreshold,
+		}
+		if rule.Provinces != "" {
+			replyRule.Provinces = strings.Split(rule.Provinces, ",")
+		}
+		return replyRule
+	})
+	return replyTemplate, nil
+}
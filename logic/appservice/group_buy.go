@@ -0,0 +1,151 @@
+package appservice
+
+import (
+	"context"
+	"time"
+
+	"github.com/samber/lo"
+
+	"github.com/hd2yao/go-mall/api/reply"
+	"github.com/hd2yao/go-mall/api/request"
+	"github.com/hd2yao/go-mall/common/app"
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/logic/do"
+	"github.com/hd2yao/go-mall/logic/domainservice"
+)
+
+type GroupBuyAppSvc struct {
+	ctx               context.Context
+	groupBuyDomainSvc *domainservice.GroupBuyDomainSvc
+}
+
+func NewGroupBuyAppSvc(ctx context.Context) *GroupBuyAppSvc {
+	return &GroupBuyAppSvc{
+		ctx:               ctx,
+		groupBuyDomainSvc: domainservice.NewGroupBuyDomainSvc(ctx),
+	}
+}
+
+// CreateGroupBuyActivity 管理后台创建拼团活动
+func (gas *GroupBuyAppSvc) CreateGroupBuyActivity(activityRequest *request.GroupBuyActivityCreate) (*reply.GroupBuyActivity, error) {
+	activity := &do.GroupBuyActivity{
+		Name:        activityRequest.Name,
+		CommodityId: activityRequest.CommodityId,
+		GroupPrice:  activityRequest.GroupPrice,
+		GroupSize:   activityRequest.GroupSize,
+		TimeLimit:   activityRequest.TimeLimit,
+	}
+	var err error
+	if activity.StartTime, err = time.ParseInLocation(enum.TimeFormatHyphenedYMDHIS, activityRequest.StartTime, time.Local); err != nil {
+		return nil, errcode.ErrParams.WithCause(err)
+	}
+	if activity.EndTime, err = time.ParseInLocation(enum.TimeFormatHyphenedYMDHIS, activityRequest.EndTime, time.Local); err != nil {
+		return nil, errcode.ErrParams.WithCause(err)
+	}
+
+	if err = gas.groupBuyDomainSvc.CreateActivity(activity); err != nil {
+		return nil, err
+	}
+	replyActivity := new(reply.GroupBuyActivity)
+	if err = util.CopyProperties(replyActivity, activity); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return replyActivity, nil
+}
+
+// StopGroupBuyActivity 管理后台停止拼团活动
+func (gas *GroupBuyAppSvc) StopGroupBuyActivity(activityId int64) error {
+	return gas.groupBuyDomainSvc.StopActivity(activityId)
+}
+
+// GetGroupBuyActivityList 管理后台拼团活动列表
+func (gas *GroupBuyAppSvc) GetGroupBuyActivityList(pagination *app.Pagination) ([]*reply.GroupBuyActivity, error) {
+	activities, err := gas.groupBuyDomainSvc.GetActivityList(pagination)
+	if err != nil {
+		return nil, err
+	}
+	replyActivities := make([]*reply.GroupBuyActivity, 0, len(activities))
+	if err = util.CopyProperties(&replyActivities, &activities); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return replyActivities, nil
+}
+
+// GetOngoingGroupBuyActivities 正在进行的拼团活动
+func (gas *GroupBuyAppSvc) GetOngoingGroupBuyActivities() ([]*reply.GroupBuyActivity, error) {
+	activities, err := gas.groupBuyDomainSvc.GetOngoingActivities()
+	if err != nil {
+		return nil, err
+	}
+	replyActivities := make([]*reply.GroupBuyActivity, 0, len(activities))
+	if err = util.CopyProperties(&replyActivities, &activities); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return replyActivities, nil
+}
+
+// OpenGroup 用户开团, 返回团长的订单号和拼团的分享码
+func (gas *GroupBuyAppSvc) OpenGroup(userId, activityId int64, openRequest *request.GroupBuyOpen) (*reply.GroupBuyOrder, error) {
+	group, order, err := gas.groupBuyDomainSvc.OpenGroup(userId, activityId, openRequest.CommodityNum, openRequest.UserAddressId)
+	if err != nil {
+		return nil, err
+	}
+	return toReplyGroupBuyOrder(group, order)
+}
+
+// JoinGroup 用户通过分享码参团, 返回参团的订单号
+func (gas *GroupBuyAppSvc) JoinGroup(userId int64, joinRequest *request.GroupBuyJoin) (*reply.GroupBuyOrder, error) {
+	group, order, err := gas.groupBuyDomainSvc.JoinGroup(userId, joinRequest.ShareCode, joinRequest.CommodityNum, joinRequest.UserAddressId)
+	if err != nil {
+		return nil, err
+	}
+	return toReplyGroupBuyOrder(group, order)
+}
+
+// GetGroup 用分享码查看拼团的进度
+func (gas *GroupBuyAppSvc) GetGroup(shareCode string) (*reply.GroupBuyGroup, error) {
+	group, err := gas.groupBuyDomainSvc.GetGroup(shareCode)
+	if err != nil {
+		return nil, err
+	}
+	replyGroup, err := toReplyGroupBuyGroup(group)
+	if err != nil {
+		return nil, err
+	}
+	// 只返回没有退出拼团的成员
+	members := lo.Filter(group.Members, func(member *do.GroupBuyMember, index int) bool {
+		return member.Status != enum.GroupBuyMemberQuit
+	})
+	if err = util.CopyProperties(&replyGroup.Members, &members); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return replyGroup, nil
+}
+
+// ExpireGroupBuys 处理超过成团时限的拼团
+func (gas *GroupBuyAppSvc) ExpireGroupBuys(scanInterval time.Duration) error {
+	return gas.groupBuyDomainSvc.ExpireGroups(scanInterval)
+}
+
+func toReplyGroupBuyOrder(group *do.GroupBuyGroup, order *do.Order) (*reply.GroupBuyOrder, error) {
+	replyGroup, err := toReplyGroupBuyGroup(group)
+	if err != nil {
+		return nil, err
+	}
+	return &reply.GroupBuyOrder{
+		OrderNo: order.OrderNo,
+		Group:   replyGroup,
+	}, nil
+}
+
+func toReplyGroupBuyGroup(group *do.GroupBuyGroup) (*reply.GroupBuyGroup, error) {
+	replyGroup := new(reply.GroupBuyGroup)
+	if err := util.CopyProperties(replyGroup, group); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	replyGroup.StatusName = enum.GroupBuyStatusName[group.Status]
+	replyGroup.Members = []*reply.GroupBuyMember{}
+	return replyGroup, nil
+}
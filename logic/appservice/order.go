@@ -2,6 +2,8 @@ package appservice
 
 import (
 	"context"
+	"net/url"
+	"time"
 
 	"github.com/hd2yao/go-mall/api/reply"
 	"github.com/hd2yao/go-mall/api/request"
@@ -9,6 +11,7 @@ import (
 	"github.com/hd2yao/go-mall/common/enum"
 	"github.com/hd2yao/go-mall/common/errcode"
 	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/logic/do"
 	"github.com/hd2yao/go-mall/logic/domainservice"
 )
 
@@ -41,10 +44,42 @@ func (oas *OrderAppSvc) CreateOrder(orderRequest *request.OrderCreate, userId in
 	}
 
 	// 创建订单
-	order, err := oas.orderDomainSvc.CreateOrder(cartItems, address)
+	order, err := oas.orderDomainSvc.CreateOrder(cartItems, address, orderRequest.CheckoutToken, orderRequest.CouponId)
 	if err != nil {
 		return nil, err
 	}
+	// 超时未支付的订单自动关闭
+	oas.orderDomainSvc.ScheduleUnpaidOrderClose(order.OrderNo)
+
+	orderReply := new(reply.OrderCreateReply)
+	orderReply.OrderNo = order.OrderNo
+	return orderReply, nil
+}
+
+// BuyNow 立即购买, 和购物车下单一样计算结算信息、扣减库存、保存地址快照, 不会改动用户的购物车
+func (oas *OrderAppSvc) BuyNow(buyNowRequest *request.OrderBuyNow, userId int64) (*reply.OrderCreateReply, error) {
+	buyItems := make([]*do.ShoppingCartItem, 0, len(buyNowRequest.Items))
+	if err := util.CopyProperties(&buyItems, &buyNowRequest.Items); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	cartDomainSvc := domainservice.NewCartDomainSvc(oas.ctx)
+	items, err := cartDomainSvc.GetBuyNowItems(buyItems, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	userDomainSvc := domainservice.NewUserDomainSvc(oas.ctx)
+	address, err := userDomainSvc.GetUserSingleAddress(userId, buyNowRequest.UserAddressId)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := oas.orderDomainSvc.CreateBuyNowOrder(items, address, buyNowRequest.CheckoutToken, buyNowRequest.CouponId)
+	if err != nil {
+		return nil, err
+	}
+	// 超时未支付的订单自动关闭
+	oas.orderDomainSvc.ScheduleUnpaidOrderClose(order.OrderNo)
 
 	orderReply := new(reply.OrderCreateReply)
 	orderReply.OrderNo = order.OrderNo
@@ -90,6 +125,35 @@ func (oas *OrderAppSvc) GetOrderInfo(orderNo string, userId int64) (*reply.Order
 	replyOrder.Address.UserName = util.MaskRealName(replyOrder.Address.UserName)
 	replyOrder.Address.UserPhone = util.MaskPhone(replyOrder.Address.UserPhone)
 
+	// 物流信息
+	shipment, err := oas.orderDomainSvc.GetOrderShipment(order.ID)
+	if err != nil {
+		return nil, err
+	}
+	if shipment != nil {
+		replyOrder.Shipment = new(reply.OrderShipment)
+		if err = util.CopyProperties(replyOrder.Shipment, shipment); err != nil {
+			return nil, errcode.ErrCoverData.WithCause(err)
+		}
+		if shipment.Status != enum.ShipmentStatusDelivered {
+			replyOrder.Shipment.DeliveredAt = ""
+		}
+	}
+
+	// 订单状态变更记录
+	statusLogs, err := oas.orderDomainSvc.GetOrderStatusLogs(order.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err = util.CopyProperties(&replyOrder.StatusLogs, &statusLogs); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	for _, statusLog := range replyOrder.StatusLogs {
+		statusLog.FromStatusName = enum.OrderStatusName[statusLog.FromStatus]
+		statusLog.ToStatusName = enum.OrderStatusName[statusLog.ToStatus]
+		statusLog.ActorName = enum.OrderActorName[statusLog.Actor]
+	}
+
 	return replyOrder, nil
 }
 
@@ -100,13 +164,184 @@ func (oas *OrderAppSvc) CancelOrder(orderNo string, userId int64) error {
 
 // OrderCreatePay 订单发起支付
 func (oas *OrderAppSvc) OrderCreatePay(payRequest *request.OrderPayCreate, userId int64) (replyData interface{}, err error) {
-	switch payRequest.PayType {
-	case enum.PayTypeWxPay: // 使用微信支付
-		payInfo, err := oas.orderDomainSvc.CreateOrderWxPay(payRequest.OrderNo, userId)
-		return payInfo, err
-	default:
-		err = errcode.ErrParams
+	payTemplate := domainservice.NewOrderPayTemplate(oas.ctx, userId, payRequest.OrderNo, payRequest.PayScene, payRequest.PayType)
+	return payTemplate.CreateOrderPay()
+}
+
+// ApplyRefund 用户申请退款
+func (oas *OrderAppSvc) ApplyRefund(orderNo string, refundRequest *request.OrderRefundApply, userId int64) (*reply.OrderRefundApplyReply, error) {
+	refundItems := make([]*do.OrderRefundItem, 0, len(refundRequest.Items))
+	if err := util.CopyProperties(&refundItems, &refundRequest.Items); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	refund, err := oas.orderDomainSvc.ApplyOrderRefund(orderNo, userId, refundRequest.Reason, refundItems)
+	if err != nil {
+		return nil, err
 	}
+	return &reply.OrderRefundApplyReply{RefundNo: refund.RefundNo}, nil
+}
 
-	return
+// GetOrderRefunds 用户查看订单的退款单
+func (oas *OrderAppSvc) GetOrderRefunds(orderNo string, userId int64) ([]*reply.OrderRefund, error) {
+	refunds, err := oas.orderDomainSvc.GetUserOrderRefunds(orderNo, userId)
+	if err != nil {
+		return nil, err
+	}
+	return oas.toReplyRefunds(refunds)
+}
+
+// AdminGetRefunds 管理后台查看退款单列表
+func (oas *OrderAppSvc) AdminGetRefunds(status int, pagination *app.Pagination) ([]*reply.OrderRefund, error) {
+	refunds, err := oas.orderDomainSvc.GetRefundList(status, pagination)
+	if err != nil {
+		return nil, err
+	}
+	return oas.toReplyRefunds(refunds)
+}
+
+// AdminApproveRefund 商家同意退款
+func (oas *OrderAppSvc) AdminApproveRefund(refundNo string) error {
+	return oas.orderDomainSvc.ApproveOrderRefund(refundNo)
+}
+
+// AdminRejectRefund 商家拒绝退款
+func (oas *OrderAppSvc) AdminRejectRefund(refundNo string, rejectRequest *request.OrderRefundReject) error {
+	return oas.orderDomainSvc.RejectOrderRefund(refundNo, rejectRequest.RejectReason)
+}
+
+// ReconcileUnpaidOrders 支付对账
+func (oas *OrderAppSvc) ReconcileUnpaidOrders(interval time.Duration) error {
+	return oas.orderDomainSvc.ReconcileUnpaidOrders(interval)
+}
+
+// ConfirmReceipt 用户确认收货
+func (oas *OrderAppSvc) ConfirmReceipt(orderNo string, userId int64) error {
+	return oas.orderDomainSvc.ConfirmOrderReceipt(orderNo, userId)
+}
+
+// AutoConfirmDeliveredOrders 自动确认收货
+func (oas *OrderAppSvc) AutoConfirmDeliveredOrders() error {
+	return oas.orderDomainSvc.AutoConfirmDeliveredOrders()
+}
+
+// AutoCompleteOrders 过了评价期的订单自动完成
+func (oas *OrderAppSvc) AutoCompleteOrders() error {
+	return oas.orderDomainSvc.AutoCompleteOrders()
+}
+
+// AdminPickOrder 商家检货完成
+func (oas *OrderAppSvc) AdminPickOrder(orderNo string) error {
+	return oas.orderDomainSvc.PickOrder(orderNo)
+}
+
+// AdminShipOrder 商家发货
+func (oas *OrderAppSvc) AdminShipOrder(orderNo string, shipRequest *request.OrderShip) (*reply.OrderShipment, error) {
+	shipment, err := oas.orderDomainSvc.ShipOrder(orderNo, shipRequest.CarrierCode, shipRequest.TrackingNo)
+	if err != nil {
+		return nil, err
+	}
+	replyShipment := new(reply.OrderShipment)
+	if err = util.CopyProperties(replyShipment, shipment); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	replyShipment.DeliveredAt = ""
+	return replyShipment, nil
+}
+
+// AdminDeliverOrder 订单送达
+func (oas *OrderAppSvc) AdminDeliverOrder(orderNo string) error {
+	return oas.orderDomainSvc.DeliverOrder(orderNo)
+}
+
+// AdminCloseOrder 商家关闭订单
+func (oas *OrderAppSvc) AdminCloseOrder(orderNo string, closeRequest *request.OrderMerchantClose) error {
+	return oas.orderDomainSvc.MerchantCloseOrder(orderNo, closeRequest.Reason)
+}
+
+func (oas *OrderAppSvc) toReplyRefunds(refunds []*do.OrderRefund) ([]*reply.OrderRefund, error) {
+	replyRefunds := make([]*reply.OrderRefund, 0, len(refunds))
+	if err := util.CopyProperties(&replyRefunds, &refunds); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	for _, replyRefund := range replyRefunds {
+		replyRefund.FrontStatus = enum.RefundFrontStatus[replyRefund.Status]
+		if replyRefund.Status != enum.RefundStatusSuccess {
+			replyRefund.RefundedAt = ""
+		}
+	}
+	return replyRefunds, nil
+}
+
+// WxPayNotify 处理微信支付结果通知
+func (oas *OrderAppSvc) WxPayNotify(notifyRequest *request.WxPayNotifyRequest, rawBody string) error {
+	if notifyRequest.Body.EventType != "TRANSACTION.SUCCESS" {
+		// 目前只关注支付成功的通知
+		return nil
+	}
+	header := notifyRequest.Header
+	return oas.orderDomainSvc.HandleWxPayNotify(header.Timestamp, header.Nonce, header.Signature, rawBody)
+}
+
+// CloseDueUnpaidOrders 关闭关单延迟队列中到期的超时未支付订单
+func (oas *OrderAppSvc) CloseDueUnpaidOrders() error {
+	return oas.orderDomainSvc.CloseDueUnpaidOrders()
+}
+
+// EnqueueTimeoutUnpaidOrders 从数据库扫描超时未支付的订单放入关单延迟队列
+func (oas *OrderAppSvc) EnqueueTimeoutUnpaidOrders(scanInterval time.Duration) error {
+	return oas.orderDomainSvc.EnqueueTimeoutUnpaidOrders(scanInterval)
+}
+
+// WxRefundNotify 处理微信支付退款结果通知
+func (oas *OrderAppSvc) WxRefundNotify(notifyRequest *request.WxPayNotifyRequest, rawBody string) error {
+	header := notifyRequest.Header
+	return oas.orderDomainSvc.HandleWxRefundNotify(header.Timestamp, header.Nonce, header.Signature, rawBody)
+}
+
+// AliPayNotify 处理支付宝异步通知
+func (oas *OrderAppSvc) AliPayNotify(notifyForm url.Values) error {
+	return oas.orderDomainSvc.HandleAliPayNotify(notifyForm)
+}
+
+// CheckDailyWxTradeBill 下载前一天的微信支付交易账单对账
+func (oas *OrderAppSvc) CheckDailyWxTradeBill() error {
+	return oas.orderDomainSvc.CheckDailyWxTradeBill()
+}
+
+// AdminCheckWxTradeBill 管理后台手动对指定日期的微信支付交易账单对账, 返回对账发现的差异
+func (oas *OrderAppSvc) AdminCheckWxTradeBill(checkRequest *request.PayBillCheck) ([]*reply.PayBillMismatch, error) {
+	billDate, err := time.ParseInLocation(enum.TimeFormatHyphenedYMD, checkRequest.BillDate, time.Local)
+	if err != nil {
+		return nil, errcode.ErrParams.WithCause(err)
+	}
+	now := time.Now()
+	if !billDate.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)) {
+		// 支付平台只能下载今天之前的账单
+		return nil, errcode.ErrParams
+	}
+	mismatches, err := oas.orderDomainSvc.ReconcileWxTradeBill(billDate)
+	if err != nil {
+		return nil, err
+	}
+	return oas.toReplyPayBillMismatches(mismatches)
+}
+
+// AdminGetPayBillMismatches 管理后台查看对账差异列表
+func (oas *OrderAppSvc) AdminGetPayBillMismatches(billDate string, mismatchType int, pagination *app.Pagination) ([]*reply.PayBillMismatch, error) {
+	mismatches, err := oas.orderDomainSvc.GetPayBillMismatchList(billDate, mismatchType, pagination)
+	if err != nil {
+		return nil, err
+	}
+	return oas.toReplyPayBillMismatches(mismatches)
+}
+
+func (oas *OrderAppSvc) toReplyPayBillMismatches(mismatches []*do.PayBillMismatch) ([]*reply.PayBillMismatch, error) {
+	replyMismatches := make([]*reply.PayBillMismatch, 0, len(mismatches))
+	if err := util.CopyProperties(&replyMismatches, &mismatches); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	for _, replyMismatch := range replyMismatches {
+		replyMismatch.MismatchTypeName = enum.PayBillMismatchName[replyMismatch.MismatchType]
+	}
+	return replyMismatches, nil
 }
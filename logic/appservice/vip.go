@@ -0,0 +1,99 @@
+package appservice
+
+import (
+	"context"
+	"time"
+
+	"github.com/hd2yao/go-mall/api/reply"
+	"github.com/hd2yao/go-mall/api/request"
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/logic/do"
+	"github.com/hd2yao/go-mall/logic/domainservice"
+)
+
+type VipAppSvc struct {
+	ctx          context.Context
+	vipDomainSvc *domainservice.VipDomainSvc
+}
+
+func NewVipAppSvc(ctx context.Context) *VipAppSvc {
+	return &VipAppSvc{
+		ctx:          ctx,
+		vipDomainSvc: domainservice.NewVipDomainSvc(ctx),
+	}
+}
+
+// GetUserVip 用户的会员信息
+func (vas *VipAppSvc) GetUserVip(userId int64) (*reply.UserVip, error) {
+	userVip, err := vas.vipDomainSvc.GetUserVip(userId)
+	if err != nil {
+		return nil, err
+	}
+	replyUserVip := &reply.UserVip{
+		IsVip:      userVip.Valid(time.Now()),
+		TotalSpend: userVip.TotalSpend,
+	}
+	if replyUserVip.IsVip {
+		if replyUserVip.Tier, err = toReplyVipTier(userVip.Tier); err != nil {
+			return nil, err
+		}
+		replyUserVip.ExpireAt = userVip.ExpireAt.Format(enum.TimeFormatHyphenedYMDHIS)
+	}
+	if userVip.NextTier != nil {
+		if replyUserVip.NextTier, err = toReplyVipTier(userVip.NextTier); err != nil {
+			return nil, err
+		}
+		replyUserVip.NextTierNeedSpend = userVip.NextTier.UpgradeSpend - userVip.TotalSpend
+	}
+	return replyUserVip, nil
+}
+
+// GetVipTiers 会员等级列表
+func (vas *VipAppSvc) GetVipTiers() ([]*reply.VipTier, error) {
+	tiers, err := vas.vipDomainSvc.GetVipTiers()
+	if err != nil {
+		return nil, err
+	}
+	replyTiers := make([]*reply.VipTier, 0, len(tiers))
+	if err = util.CopyProperties(&replyTiers, &tiers); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return replyTiers, nil
+}
+
+// CreateVipTier 管理后台创建会员等级
+func (vas *VipAppSvc) CreateVipTier(tierRequest *request.VipTierSave) (*reply.VipTier, error) {
+	tier := new(do.VipTier)
+	if err := util.CopyProperties(tier, tierRequest); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	if err := vas.vipDomainSvc.CreateVipTier(tier); err != nil {
+		return nil, err
+	}
+	return toReplyVipTier(tier)
+}
+
+// UpdateVipTier 管理后台更新会员等级
+func (vas *VipAppSvc) UpdateVipTier(tierId int64, tierRequest *request.VipTierSave) error {
+	tier := new(do.VipTier)
+	if err := util.CopyProperties(tier, tierRequest); err != nil {
+		return errcode.ErrCoverData.WithCause(err)
+	}
+	tier.ID = tierId
+	return vas.vipDomainSvc.UpdateVipTier(tier)
+}
+
+// DeleteVipTier 管理后台删除会员等级
+func (vas *VipAppSvc) DeleteVipTier(tierId int64) error {
+	return vas.vipDomainSvc.DeleteVipTier(tierId)
+}
+
+func toReplyVipTier(tier *do.VipTier) (*reply.VipTier, error) {
+	replyTier := new(reply.VipTier)
+	if err := util.CopyProperties(replyTier, tier); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return replyTier, nil
+}
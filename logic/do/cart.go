@@ -10,6 +10,8 @@ type ShoppingCartItem struct {
 	CommodityImg          string // 商品图片
 	CommoditySellingPrice int    // 商品售价
 	CommodityNum          int    // 商品数量
+	CommodityWeight       int    // 商品重量（克）, 按重量计算运费时使用
+	CommodityCategoryId   int64  // 商品分类ID, 判断优惠券等是否适用时使用
 	CreatedAt             time.Time
 	UpdatedAt             time.Time
 }
@@ -27,7 +29,52 @@ type CartBillInfo struct {
 		DiscountMoney int
 		Threshold     int // 使用门槛, 比如满1000 可用
 	}
-	VipDiscountMoney   int // VIP减免的金额
-	OriginalTotalPrice int // 减免、优惠前的总金额
-	TotalPrice         int // 实际要支付的总金额
+	VipDiscountMoney   int             // VIP减免的金额
+	FreightMoney       int             // 运费
+	OriginalTotalPrice int             // 减免、优惠前的商品总金额
+	TotalPrice         int             // 实际要支付的总金额, 包含运费
+	Items              []*CartBillItem // 每个购物项分摊到的减免金额, 顺序与结算的购物项一致
+	Steps              []*CartBillStep // 按优惠叠加顺序计算每项优惠减免的步骤
+}
+
+// CartBillStep 账单中一项优惠的计算步骤, 说明这项优惠按什么金额计算、减免了多少
+type CartBillStep struct {
+	Promotion   string // 优惠名称 vip-会员折扣 coupon-优惠券 discount-满减活动
+	Description string // 这一步的说明, 比如使用的优惠券、没有减免或被互斥的原因
+	Base        string // 计算减免的基准金额 original-商品原价 reduced-减去前面各项优惠后的金额
+	BaseMoney   int    // 基准金额
+	DeductMoney int    // 这项优惠减免的金额
+	TotalPrice  int    // 减去这项优惠后的商品金额
+}
+
+// CartBillItem 购物项的账单明细, 把订单级别的各项减免按金额比例分摊到每个购物项上
+// 创建订单时保存到订单明细中, 部分退款时按分摊后的实付金额计算可退金额
+type CartBillItem struct {
+	CommodityId         int64
+	CommodityNum        int
+	BillMoney           int // 减免、优惠前的金额
+	VipDiscountMoney    int // 分摊到的 VIP 减免金额
+	CouponDiscountMoney int // 分摊到的优惠券减免金额
+	DiscountMoney       int // 分摊到的满减活动减免金额
+	PayMoney            int // 分摊后的实付金额
+}
+
+// CheckoutSnapshot 查看账单时的结算快照, 签名后作为结算凭证返回给客户端
+// 创建订单时重新计算的账单和快照不一致说明确认订单后商品价格或优惠发生了变化
+type CheckoutSnapshot struct {
+	UserId             int64                   `json:"uid"`
+	Items              []*CheckoutSnapshotItem `json:"items"`
+	CouponId           int64                   `json:"coupon_id"`
+	DiscountId         int64                   `json:"discount_id"`
+	VipDiscountMoney   int                     `json:"vip_discount_money"`
+	FreightMoney       int                     `json:"freight_money"`
+	OriginalTotalPrice int                     `json:"original_total_price"`
+	TotalPrice         int                     `json:"total_price"`
+	ExpireAt           int64                   `json:"expire_at"` // 过期时间的 Unix 时间戳
+}
+
+type CheckoutSnapshotItem struct {
+	CommodityId  int64 `json:"commodity_id"`
+	CommodityNum int   `json:"commodity_num"`
+	Price        int   `json:"price"` // 商品售价
 }
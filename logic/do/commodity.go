@@ -37,6 +37,7 @@ type Commodity struct {
 	OriginalPrice int       `json:"original_price"`
 	SellingPrice  int       `json:"selling_price"`
 	StockNum      int       `json:"stock_num"`
+	Weight        int       `json:"weight"`
 	Tag           string    `json:"tag"`
 	SellStatus    int       `json:"sell_status"`
 	CreatedAt     time.Time `json:"created_at"`
@@ -0,0 +1,91 @@
+package do
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+
+	"github.com/hd2yao/go-mall/common/enum"
+)
+
+type CouponTemplate struct {
+	ID               int64
+	Name             string
+	CouponType       int
+	DiscountMoney    int
+	DiscountRate     int
+	MaxDiscountMoney int
+	Threshold        int
+	ScopeType        int
+	ScopeIds         string // 适用的分类ID或商品ID, 多个用逗号分隔
+	TotalNum         int
+	ClaimedNum       int
+	PerUserLimit     int
+	ValidStart       time.Time
+	ValidEnd         time.Time
+	Status           int
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+type UserCoupon struct {
+	ID         int64
+	CouponId   int64
+	UserId     int64
+	Status     int
+	OrderNo    string
+	ValidStart time.Time
+	ValidEnd   time.Time
+	UsedAt     time.Time
+	CreatedAt  time.Time
+	Template   *CouponTemplate // 优惠券模板, 包含优惠券的名称、面额和适用范围
+}
+
+// ScopeIdList 适用的分类ID或商品ID列表
+func (ct *CouponTemplate) ScopeIdList() []int64 {
+	if ct.ScopeIds == "" {
+		return []int64{}
+	}
+	return lo.FilterMap(strings.Split(ct.ScopeIds, ","), func(id string, _ int) (int64, bool) {
+		scopeId, err := strconv.ParseInt(id, 10, 64)
+		return scopeId, err == nil
+	})
+}
+
+// InScope 购物项的商品是否在优惠券的适用范围内
+func (ct *CouponTemplate) InScope(item *ShoppingCartItem) bool {
+	switch ct.ScopeType {
+	case enum.CouponScopeCategory:
+		return lo.Contains(ct.ScopeIdList(), item.CommodityCategoryId)
+	case enum.CouponScopeCommodity:
+		return lo.Contains(ct.ScopeIdList(), item.CommodityId)
+	default:
+		return true
+	}
+}
+
+// Discount 适用商品的金额为 applicableMoney 时优惠券能减免的金额, 不满足使用门槛时为 0
+func (ct *CouponTemplate) Discount(applicableMoney int) int {
+	if applicableMoney <= 0 || applicableMoney < ct.Threshold {
+		return 0
+	}
+	discount := 0
+	switch ct.CouponType {
+	case enum.CouponTypeFixed:
+		discount = ct.DiscountMoney
+	case enum.CouponTypePercent:
+		discount = int(math.Round(float64(applicableMoney) * float64(ct.DiscountRate) / 100.0))
+		if ct.MaxDiscountMoney > 0 && discount > ct.MaxDiscountMoney {
+			discount = ct.MaxDiscountMoney
+		}
+	}
+	return min(discount, applicableMoney)
+}
+
+// Usable 用户优惠券在 now 时是否可以使用
+func (uc *UserCoupon) Usable(now time.Time) bool {
+	return uc.Status == enum.UserCouponUnused && !now.Before(uc.ValidStart) && now.Before(uc.ValidEnd)
+}
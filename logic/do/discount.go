@@ -0,0 +1,77 @@
+package do
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+
+	"github.com/hd2yao/go-mall/common/enum"
+)
+
+type DiscountCampaign struct {
+	ID           int64
+	Name         string
+	ScopeType    int
+	ScopeIds     string // 适用的分类ID或商品ID, 多个用逗号分隔
+	PerUserLimit int
+	StartTime    time.Time
+	EndTime      time.Time
+	Status       int
+	Tiers        []*DiscountCampaignTier // 按门槛从低到高排序
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type DiscountCampaignTier struct {
+	ID            int64
+	CampaignId    int64
+	Threshold     int
+	DiscountMoney int
+}
+
+type OrderDiscountRecord struct {
+	ID            int64
+	CampaignId    int64
+	UserId        int64
+	OrderNo       string
+	DiscountMoney int
+	Status        int
+	CreatedAt     time.Time
+}
+
+// ScopeIdList 适用的分类ID或商品ID列表
+func (dc *DiscountCampaign) ScopeIdList() []int64 {
+	if dc.ScopeIds == "" {
+		return []int64{}
+	}
+	return lo.FilterMap(strings.Split(dc.ScopeIds, ","), func(id string, _ int) (int64, bool) {
+		scopeId, err := strconv.ParseInt(id, 10, 64)
+		return scopeId, err == nil
+	})
+}
+
+// InScope 购物项的商品是否在活动的适用范围内
+// categoryPath 为商品所在分类和它的所有上级分类, 活动指定了上级分类时下面所有子分类的商品都适用
+func (dc *DiscountCampaign) InScope(item *ShoppingCartItem, categoryPath []int64) bool {
+	switch dc.ScopeType {
+	case enum.DiscountScopeCategory:
+		return len(lo.Intersect(dc.ScopeIdList(), categoryPath)) > 0
+	case enum.DiscountScopeCommodity:
+		return lo.Contains(dc.ScopeIdList(), item.CommodityId)
+	default:
+		return true
+	}
+}
+
+// MatchTier 适用商品的金额为 applicableMoney 时能达到的最高阶梯, 一个阶梯都没有达到时返回 nil
+func (dc *DiscountCampaign) MatchTier(applicableMoney int) *DiscountCampaignTier {
+	var matched *DiscountCampaignTier
+	for _, tier := range dc.Tiers {
+		if applicableMoney > 0 && applicableMoney >= tier.Threshold && (matched == nil || tier.Threshold > matched.Threshold) {
+			matched = tier
+		}
+	}
+	return matched
+}
@@ -0,0 +1,59 @@
+package do
+
+import "time"
+
+type FlashSaleSession struct {
+	ID           int64
+	Name         string
+	CommodityId  int64
+	FlashPrice   int
+	TotalStock   int
+	SoldNum      int
+	PerUserLimit int
+	StartTime    time.Time
+	EndTime      time.Time
+	Status       int
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+
+	RemainingStock int // Redis 中的剩余库存, 用户查看场次列表时使用
+}
+
+// Ongoing 场次在 now 时是否可以抢购
+func (s *FlashSaleSession) Ongoing(now time.Time) bool {
+	return !now.Before(s.StartTime) && now.Before(s.EndTime)
+}
+
+// FlashSaleRequest 放入下单队列的秒杀请求, Redis 预扣库存成功后由后台任务异步创建订单
+type FlashSaleRequest struct {
+	RequestNo     string `json:"request_no"`
+	SessionId     int64  `json:"session_id"`
+	UserId        int64  `json:"user_id"`
+	CommodityNum  int    `json:"commodity_num"`
+	UserAddressId int64  `json:"user_address_id"`
+	QueuedAt      int64  `json:"queued_at"` // 放入队列的时间(秒), 超过请求超时时间还没处理完时认为请求已丢失
+}
+
+// FlashSaleResult 秒杀请求的处理结果
+type FlashSaleResult struct {
+	RequestNo  string `json:"request_no"`
+	UserId     int64  `json:"user_id"`
+	Status     int    `json:"status"`      // 1-排队中 2-已创建订单 3-创建订单失败
+	OrderNo    string `json:"order_no"`    // 创建的订单号
+	FailReason string `json:"fail_reason"` // 失败原因
+}
+
+type FlashSaleOrder struct {
+	ID            int64
+	RequestNo     string
+	SessionId     int64
+	UserId        int64
+	CommodityId   int64
+	CommodityNum  int
+	OrderNo       string
+	Status        int
+	FailReason    string
+	StockReturned int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
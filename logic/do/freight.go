@@ -0,0 +1,58 @@
+package do
+
+import (
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+type FreightTemplate struct {
+	ID         int64
+	Name       string
+	ChargeType int
+	IsDefault  int
+	Rules      []*FreightTemplateRule
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type FreightTemplateRule struct {
+	ID             int64
+	TemplateId     int64
+	Provinces      string // 适用的省份, 多个用逗号分隔, 为空时适用于其他省份
+	FirstUnit      int
+	FirstFee       int
+	AdditionalUnit int
+	AdditionalFee  int
+	FreeThreshold  int
+}
+
+// MatchRule 找到收货省份适用的计费规则, 优先使用单独设置了这个省份的规则, 没有时使用其他省份的规则
+// 两种规则都没有时返回 nil, 表示这个省份不支持配送
+func (ft *FreightTemplate) MatchRule(provinceName string) *FreightTemplateRule {
+	var otherRule *FreightTemplateRule
+	for _, rule := range ft.Rules {
+		if rule.Provinces == "" {
+			otherRule = rule
+			continue
+		}
+		if lo.Contains(strings.Split(rule.Provinces, ","), provinceName) {
+			return rule
+		}
+	}
+	return otherRule
+}
+
+// Fee 按计费单位数计算运费, goodsMoney 为商品优惠后的金额, 达到包邮门槛时免运费
+// 不足首件(首重)按首件(首重)计费, 超出的部分按续件(续重)向上取整计费
+func (ftr *FreightTemplateRule) Fee(units int, goodsMoney int) int {
+	if units <= 0 || (ftr.FreeThreshold > 0 && goodsMoney >= ftr.FreeThreshold) {
+		return 0
+	}
+	fee := ftr.FirstFee
+	if units > ftr.FirstUnit && ftr.AdditionalUnit > 0 {
+		fee += (units - ftr.FirstUnit + ftr.AdditionalUnit - 1) / ftr.AdditionalUnit * ftr.AdditionalFee
+	}
+	return fee
+}
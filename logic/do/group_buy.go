@@ -0,0 +1,51 @@
+package do
+
+import "time"
+
+type GroupBuyActivity struct {
+	ID          int64
+	Name        string
+	CommodityId int64
+	GroupPrice  int
+	GroupSize   int
+	TimeLimit   int
+	StartTime   time.Time
+	EndTime     time.Time
+	Status      int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Ongoing 活动在 now 时是否可以开团
+func (a *GroupBuyActivity) Ongoing(now time.Time) bool {
+	return !now.Before(a.StartTime) && now.Before(a.EndTime)
+}
+
+type GroupBuyGroup struct {
+	ID           int64
+	ActivityId   int64
+	CommodityId  int64
+	ShareCode    string
+	LeaderUserId int64
+	GroupSize    int
+	MemberNum    int
+	PaidNum      int
+	ExpireAt     time.Time
+	Status       int
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+
+	Members []*GroupBuyMember
+}
+
+type GroupBuyMember struct {
+	ID        int64
+	GroupId   int64
+	UserId    int64
+	OrderNo   string
+	IsLeader  int
+	Status    int
+	RefundNo  string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -0,0 +1,10 @@
+package do
+
+// IdempotencyRecord 幂等键对应的第一次请求的处理状态和响应
+type IdempotencyRecord struct {
+	Status      string `json:"status"`       // processing-处理中 done-已完成
+	RequestHash string `json:"request_hash"` // 第一次请求的请求体摘要, 同一个幂等键不能用于请求体不同的请求
+	HttpStatus  int    `json:"http_status"`
+	ContentType string `json:"content_type"`
+	Body        string `json:"body"`
+}
@@ -1,22 +1,26 @@
 package do
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Order struct {
-	ID          int64
-	OrderNo     string
-	PayTransId  string
-	PayType     int
-	UserId      int64
-	BillMoney   int
-	PayMoney    int
-	PayState    int
-	OrderStatus int
-	Address     *OrderAddress
-	Items       []*OrderItem
-	PaidAt      time.Time
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID           int64
+	OrderNo      string
+	PayTransId   string
+	PayType      int
+	UserId       int64
+	BillMoney    int
+	PayMoney     int
+	FreightMoney int
+	PayState     int
+	OrderStatus  int
+	Address      *OrderAddress
+	Items        []*OrderItem
+	PaidAt       time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
 }
 
 type OrderAddress struct {
@@ -32,12 +36,44 @@ type OrderAddress struct {
 }
 
 type OrderItem struct {
+	ID                    int64
 	OrderId               int64
 	CommodityId           int64
 	CommodityName         string
 	CommodityImg          string
 	CommoditySellingPrice int
 	CommodityNum          int
+	BillMoney             int // 减免、优惠前的金额
+	VipDiscountMoney      int // 分摊到的 VIP 减免金额
+	CouponDiscountMoney   int // 分摊到的优惠券减免金额
+	DiscountMoney         int // 分摊到的满减活动减免金额
+	PayMoney              int // 分摊后的实付金额
+	RefundedNum           int // 已退款的数量
+	RefundedMoney         int // 已退款的金额
+}
+
+// RefundMoney 计算订单明细再退 refundNum 件商品时的退款金额
+// 按累计退款数量计算应退的累计金额再减去已退款的金额, 订单明细全部退完时累计退款金额正好等于它的实付金额
+func (oi *OrderItem) RefundMoney(refundNum int) int {
+	refundedTotal := oi.PayMoney * (oi.RefundedNum + refundNum) / oi.CommodityNum
+	return refundedTotal - oi.RefundedMoney
+}
+
+// OrderPayResult 向支付平台查询到的订单支付结果
+type OrderPayResult struct {
+	Paid       bool   // 是否已经支付成功
+	Closed     bool   // 支付平台的交易是否已经关闭, 关闭后用户不能再支付
+	TradeState string // 支付平台返回的交易状态
+	PayTransId string
+	PaidMoney  int
+	PaidAt     time.Time
+}
+
+// OrderPrepay 发起支付后缓存的预支付信息, 预支付信息有效期内重复发起支付时直接返回给客户端
+type OrderPrepay struct {
+	PayType  int             `json:"pay_type"`
+	PayScene string          `json:"pay_scene"`
+	PayInfo  json.RawMessage `json:"pay_info"` // 支付平台返回的调起支付需要的信息, 按原样返回给客户端
 }
 
 func OrderNew() *Order {
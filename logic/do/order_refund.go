@@ -0,0 +1,39 @@
+package do
+
+import "time"
+
+// OrderRefund 订单退款领域对象
+type OrderRefund struct {
+	ID                int64
+	RefundNo          string
+	OrderId           int64
+	OrderNo           string
+	UserId            int64
+	PayType           int
+	RefundMoney       int
+	Reason            string
+	RejectReason      string
+	Status            int
+	OrderStatusBefore int
+	RefundTransId     string
+	RefundedAt        time.Time
+	Items             []*OrderRefundItem
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+// OrderRefundItem 退款单明细
+type OrderRefundItem struct {
+	RefundId    int64
+	OrderItemId int64
+	CommodityId int64
+	RefundNum   int
+	RefundMoney int
+}
+
+// RefundResult 支付平台受理退款后返回的结果
+type RefundResult struct {
+	RefundTransId string    // 支付平台的退款单号
+	Success       bool      // 退款是否已经成功, 为 false 时需要等待支付平台的退款结果通知
+	RefundedAt    time.Time // 退款成功的时间
+}
@@ -0,0 +1,19 @@
+package do
+
+import "time"
+
+// OrderShipment 订单发货记录
+type OrderShipment struct {
+	ID          int64
+	ShipmentNo  string
+	OrderId     int64
+	OrderNo     string
+	CarrierCode string
+	CarrierName string
+	TrackingNo  string
+	Status      int
+	ShippedAt   time.Time
+	DeliveredAt time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
@@ -0,0 +1,16 @@
+package do
+
+import "time"
+
+// OrderStatusLog 订单状态变更记录
+type OrderStatusLog struct {
+	ID         int64
+	OrderId    int64
+	OrderNo    string
+	FromStatus int
+	ToStatus   int
+	Actor      int
+	ActorId    int64
+	Reason     string
+	CreatedAt  time.Time
+}
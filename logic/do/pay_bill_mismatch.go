@@ -0,0 +1,21 @@
+package do
+
+import "time"
+
+// PayBillMismatch 支付账单对账差异
+type PayBillMismatch struct {
+	ID                int64
+	BillDate          string
+	PayType           int
+	OrderNo           string
+	MismatchType      int
+	LocalPayTransId   string
+	ChannelTransId    string
+	LocalPayMoney     int
+	ChannelPayMoney   int
+	LocalPayState     int
+	ChannelTradeState string
+	Remark            string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
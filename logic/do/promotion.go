@@ -0,0 +1,30 @@
+package do
+
+import "github.com/samber/lo"
+
+// PromotionStackStep 优惠叠加规则中的一项优惠
+type PromotionStackStep struct {
+	Name string // 优惠名称 vip-会员折扣 coupon-优惠券 discount-满减活动
+	Base string // 计算减免的基准金额 original-商品原价 reduced-减去前面各项优惠后的金额
+}
+
+// PromotionStackRules 优惠叠加规则, 按 Steps 的顺序依次计算每项优惠的减免
+type PromotionStackRules struct {
+	Steps      []*PromotionStackStep
+	Exclusions [][]string // 互斥的优惠, 同一组中按叠加顺序只使用第一项有减免的优惠
+}
+
+// ExcludedBy 找出已经使用的优惠 applied 中和 name 互斥的优惠, 没有互斥的优惠时返回空字符串
+func (r *PromotionStackRules) ExcludedBy(name string, applied []string) string {
+	for _, group := range r.Exclusions {
+		if !lo.Contains(group, name) {
+			continue
+		}
+		if excluded, ok := lo.Find(applied, func(appliedName string) bool {
+			return appliedName != name && lo.Contains(group, appliedName)
+		}); ok {
+			return excluded
+		}
+	}
+	return ""
+}
@@ -0,0 +1,36 @@
+package do
+
+import "time"
+
+type VipTier struct {
+	ID           int64
+	Name         string
+	Level        int
+	DiscountRate int
+	UpgradeSpend int
+	ValidDays    int
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type UserVip struct {
+	UserId     int64
+	TierId     int64
+	TotalSpend int
+	ExpireAt   time.Time
+	Tier       *VipTier // 当前的会员等级, 还不是会员或者等级已被删除时为 nil
+	NextTier   *VipTier // 下一个可以升级到的等级, 已经是最高等级时为 nil
+}
+
+// Valid 会员在 now 时是否有效
+func (uv *UserVip) Valid(now time.Time) bool {
+	return uv.Tier != nil && now.Before(uv.ExpireAt)
+}
+
+// OffRate 会员在 now 时享受的折扣减免百分比, 会员无效时为 0
+func (uv *UserVip) OffRate(now time.Time) int {
+	if !uv.Valid(now) {
+		return 0
+	}
+	return uv.Tier.DiscountRate
+}
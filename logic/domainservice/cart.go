@@ -148,6 +148,29 @@ func (cds *CartDomainSvc) GetCheckedCartItems(cartItemIds []int64, userId int64)
 	return userCartItems, nil
 }
 
+// GetBuyNowItems 立即购买时把商品和购买数量转换成购物项(没有购物项 ID), 用来和购物车下单一样计算结算信息
+func (cds *CartDomainSvc) GetBuyNowItems(buyItems []*do.ShoppingCartItem, userId int64) ([]*do.ShoppingCartItem, error) {
+	if len(buyItems) == 0 {
+		return nil, errcode.ErrCartItemParam
+	}
+	// 同一个商品只能出现一次, 否则商品信息和购物项对不上
+	if len(lo.UniqBy(buyItems, func(item *do.ShoppingCartItem) int64 { return item.CommodityId })) != len(buyItems) {
+		return nil, errcode.ErrCartItemParam
+	}
+	for _, item := range buyItems {
+		item.CartItemId = 0
+		item.UserId = userId
+	}
+
+	// 填充购物项的商品信息, 同时校验商品是否存在, 商品不存在时返回的 ErrCartItemParam 原样返回
+	err := cds.fillInCommodityInfo(buyItems)
+	if err != nil {
+		return nil, err
+	}
+
+	return buyItems, nil
+}
+
 // fillInCommodityInfo 为购物项填充商品信息
 func (cds *CartDomainSvc) fillInCommodityInfo(cartItems []*do.ShoppingCartItem) error {
 	// 获取购物项中的商品 ID
@@ -175,6 +198,8 @@ func (cds *CartDomainSvc) fillInCommodityInfo(cartItems []*do.ShoppingCartItem)
 		cartItem.CommodityName = commodityMap[cartItem.CommodityId].Name
 		cartItem.CommodityImg = commodityMap[cartItem.CommodityId].CoverImg
 		cartItem.CommoditySellingPrice = commodityMap[cartItem.CommodityId].SellingPrice
+		cartItem.CommodityWeight = commodityMap[cartItem.CommodityId].Weight
+		cartItem.CommodityCategoryId = commodityMap[cartItem.CommodityId].CategoryId
 	}
 
 	return nil
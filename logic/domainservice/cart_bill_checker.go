@@ -1,19 +1,27 @@
 package domainservice
 
 import (
+	"context"
+	"fmt"
 	"math"
+	"slices"
 
 	"github.com/samber/lo"
 
+	"github.com/hd2yao/go-mall/common/enum"
 	"github.com/hd2yao/go-mall/common/errcode"
 	"github.com/hd2yao/go-mall/logic/do"
 )
 
 type CartBillChecker struct {
+	ctx           context.Context
 	UserId        int64
+	UserAddress   *do.UserAddressInfo // 收货地址, 为空时不计算运费
 	checkingItems []*do.ShoppingCartItem
-	Coupon        struct { // 可用的优惠券
-		CouponId      int64
+	// 用户选择的优惠券ID, 0 表示自动选择减免金额最多的优惠券, enum.BillNoCoupon 表示不使用优惠券
+	SelectedCouponId int64
+	Coupon           struct { // 可用的优惠券
+		CouponId      int64 // 用户优惠券ID, 创建订单时锁定这张优惠券
 		CouponName    string
 		DiscountMoney int // 减免金额, 单位: 分
 		Threshold     int // 使用门槛, 单位: 分, 设置成 1000 表示满10元可用
@@ -25,59 +33,221 @@ type CartBillChecker struct {
 		Threshold     int
 	}
 	VipOffRate int // VIP的折扣  8 折  = 20% off
+	Freight    struct { // 收货地址适用的运费规则
+		ChargeType int                     // 计费方式 1-按件数 2-按重量
+		Rule       *do.FreightTemplateRule // 为空时不收运费
+	}
+
+	usableCoupons     []*do.UserCoupon        // 用户当前可以使用的优惠券, 计算账单时按基准金额从中选出使用的优惠券
+	discountCampaigns []*do.DiscountCampaign  // 用户可以参加的满减活动, 计算账单时从中选出减免金额最多的活动
+	categoryPaths     map[int64][]int64       // 购物项商品所在的分类和它的所有上级分类, 判断是否在活动的分类范围内时使用
+	stackRules        *do.PromotionStackRules // 优惠叠加规则
+	promotions        []promotionChecker      // 按叠加规则的顺序参与计算的优惠 checker
+	handler           cartBillCheckHandler
+}
 
-	handler cartBillCheckHandler
+// NewCartBillChecker 创建购物项的结算检查器, userAddress 为空时(比如还没有选择收货地址)不计算运费
+func NewCartBillChecker(ctx context.Context, items []*do.ShoppingCartItem, userId int64, userAddress *do.UserAddressInfo) *CartBillChecker {
+	checker := new(CartBillChecker)
+	checker.ctx = ctx
+	checker.UserId = userId
+	checker.UserAddress = userAddress
+	checker.checkingItems = items
+	checker.stackRules = PromotionStackRules(ctx)
+	checker.handler = &checkerStarter{}
+	// 通过责任链设置 要检查的各种优惠项, 优惠按叠加规则配置的顺序注册到责任链中, 运费始终放在最后
+	var next cartBillCheckHandler = checker.handler
+	for _, step := range checker.stackRules.Steps {
+		promotion := promotionCheckers[step.Name]()
+		checker.promotions = append(checker.promotions, promotion)
+		next = next.SetNext(promotion)
+	}
+	next.SetNext(&freightChecker{})
+	return checker
 }
 
-func NewCartBillChecker(items []*do.ShoppingCartItem, userId int64) *CartBillChecker {
+// newFreightBillChecker 创建只计算运费的结算检查器, 用于秒杀这类已经是活动价、不再叠加其他优惠的订单
+func newFreightBillChecker(ctx context.Context, items []*do.ShoppingCartItem, userId int64, userAddress *do.UserAddressInfo) *CartBillChecker {
 	checker := new(CartBillChecker)
+	checker.ctx = ctx
 	checker.UserId = userId
+	checker.UserAddress = userAddress
 	checker.checkingItems = items
+	checker.stackRules = &do.PromotionStackRules{}
 	checker.handler = &checkerStarter{}
-	// 通过责任链设置 要检查的各种优惠项
-	checker.handler.SetNext(&couponChecker{}).
-		SetNext(&discountChecker{}).
-		SetNext(&vipChecker{})
+	checker.handler.SetNext(&freightChecker{})
 	return checker
 }
 
 // GetBill 获取账单信息
+// 按优惠叠加规则的顺序依次计算每项优惠的减免, 每一步的基准金额是商品原价或者减去前面各项优惠后的金额,
+// 和已经使用的优惠互斥的优惠不再减免, 每一步的计算过程记录在账单的 Steps 中
 func (cbc *CartBillChecker) GetBill() (*do.CartBillInfo, error) {
 	err := cbc.handler.RunChecker(cbc)
 	if err != nil {
-		return nil, errcode.Wrap("CartBillCheckerError", err)
+		return nil, err
 	}
 
 	// 计算商品使用减免前的总价
-	originalTotalPrice := lo.Reduce(cbc.checkingItems, func(agg int, item *do.ShoppingCartItem, index int) int {
-		return agg + item.CommoditySellingPrice*item.CommodityNum
-	}, 0)
+	itemMoneys := lo.Map(cbc.checkingItems, func(item *do.ShoppingCartItem, index int) int {
+		return item.CommoditySellingPrice * item.CommodityNum
+	})
+	originalTotalPrice := lo.Sum(itemMoneys)
+	reducedMoneys := slices.Clone(itemMoneys)
 
-	// VIP 能减免的金额
-	vipDiscountMoney := int(math.Round(float64(originalTotalPrice) * float64(cbc.VipOffRate) / 100.0))
-	totalPrice := originalTotalPrice - vipDiscountMoney
+	billInfo := new(do.CartBillInfo)
+	promotionShares := make(map[string][]int, len(cbc.promotions))
+	appliedPromotions := make([]string, 0, len(cbc.promotions))
+	for i, stackStep := range cbc.stackRules.Steps {
+		baseMoneys := itemMoneys
+		if stackStep.Base == enum.PromotionBaseReduced {
+			baseMoneys = slices.Clone(reducedMoneys)
+		}
+		billStep := &do.CartBillStep{
+			Promotion: stackStep.Name,
+			Base:      stackStep.Base,
+			BaseMoney: lo.Sum(baseMoneys),
+		}
 
-	// 满足优惠卷使用条件
-	if cbc.Coupon.Threshold != 0 && originalTotalPrice > cbc.Coupon.Threshold {
-		// 优惠券能减免的金额
-		totalPrice -= cbc.Coupon.DiscountMoney
+		if excludedBy := cbc.stackRules.ExcludedBy(stackStep.Name, appliedPromotions); excludedBy != "" {
+			billStep.Description = fmt.Sprintf("%s与已使用的%s互斥, 不再减免", enum.PromotionName[stackStep.Name], enum.PromotionName[excludedBy])
+		} else {
+			description, shares, err := cbc.promotions[i].Deduct(cbc, baseMoneys)
+			if err != nil {
+				return nil, err
+			}
+			billStep.Description = description
+			if shares != nil {
+				// 按商品原价计算的多项优惠叠加后不能超过商品的金额, 每个购物项的减免最多减到 0
+				shares = lo.Map(shares, func(share int, index int) int {
+					return min(share, reducedMoneys[index])
+				})
+				for index, share := range shares {
+					reducedMoneys[index] -= share
+				}
+				promotionShares[stackStep.Name] = shares
+				billStep.DeductMoney = lo.Sum(shares)
+			}
+			if billStep.DeductMoney > 0 {
+				appliedPromotions = append(appliedPromotions, stackStep.Name)
+			}
+		}
+		billStep.TotalPrice = lo.Sum(reducedMoneys)
+		billInfo.Steps = append(billInfo.Steps, billStep)
 	}
 
-	// 满足满减活动使用条件
-	if cbc.Discount.Threshold != 0 && totalPrice > cbc.Discount.Threshold {
-		// 满减活动能减免的金额
-		totalPrice -= cbc.Discount.DiscountMoney
+	// 没有使用的优惠不记录到账单中, 创建订单时不会锁定没有使用的优惠券、不会记录没有参加的满减活动
+	vipShares := cbc.promotionShares(promotionShares, enum.PromotionVip)
+	couponShares := cbc.promotionShares(promotionShares, enum.PromotionCoupon)
+	discountShares := cbc.promotionShares(promotionShares, enum.PromotionDiscount)
+	if lo.Sum(couponShares) > 0 {
+		billInfo.Coupon = cbc.Coupon
+		billInfo.Coupon.DiscountMoney = lo.Sum(couponShares)
+	}
+	if lo.Sum(discountShares) > 0 {
+		billInfo.Discount = cbc.Discount
+		billInfo.Discount.DiscountMoney = lo.Sum(discountShares)
 	}
+	billInfo.VipDiscountMoney = lo.Sum(vipShares)
 
-	billInfo := new(do.CartBillInfo)
-	billInfo.Coupon = cbc.Coupon
-	billInfo.Discount = cbc.Discount
-	billInfo.VipDiscountMoney = vipDiscountMoney
+	// 运费按商品优惠后的金额判断是否包邮, 运费不参与优惠
+	totalPrice := lo.Sum(reducedMoneys)
+	freightMoney := cbc.freightMoney(totalPrice)
+	totalPrice += freightMoney
+
+	billInfo.FreightMoney = freightMoney
 	billInfo.TotalPrice = totalPrice
 	billInfo.OriginalTotalPrice = originalTotalPrice
+	billInfo.Items = cbc.buildBillItems(itemMoneys, vipShares, couponShares, discountShares)
 	return billInfo, nil
 }
 
+// promotionShares 优惠 name 分摊到每个购物项上的减免金额, 没有参与计算的优惠每一项都是 0
+func (cbc *CartBillChecker) promotionShares(promotionShares map[string][]int, name string) []int {
+	if shares, ok := promotionShares[name]; ok {
+		return shares
+	}
+	return make([]int, len(cbc.checkingItems))
+}
+
+// applyDiscountCampaign 从用户可以参加的满减活动中选出减免金额最多的活动设置到 Discount 中
+// baseMoneys 为每个购物项计算满减的基准金额, 返回活动减免的金额和用来分摊减免金额的每个购物项的权重
+func (cbc *CartBillChecker) applyDiscountCampaign(baseMoneys []int) (int, []int) {
+	bestMoney, bestWeights := 0, make([]int, len(baseMoneys))
+	for _, campaign := range cbc.discountCampaigns {
+		weights := lo.Map(cbc.checkingItems, func(item *do.ShoppingCartItem, index int) int {
+			if !campaign.InScope(item, cbc.categoryPaths[item.CommodityCategoryId]) {
+				return 0
+			}
+			return baseMoneys[index]
+		})
+		applicableMoney := lo.Sum(weights)
+		tier := campaign.MatchTier(applicableMoney)
+		if tier == nil {
+			continue
+		}
+		discountMoney := min(tier.DiscountMoney, applicableMoney)
+		if discountMoney > bestMoney {
+			bestMoney, bestWeights = discountMoney, weights
+			cbc.Discount.DiscountId = campaign.ID
+			cbc.Discount.DiscountName = campaign.Name
+			cbc.Discount.DiscountMoney = discountMoney
+			cbc.Discount.Threshold = tier.Threshold
+		}
+	}
+	return bestMoney, bestWeights
+}
+
+// freightMoney 按收货地址适用的运费规则计算运费, goodsMoney 为商品优惠后的金额
+func (cbc *CartBillChecker) freightMoney(goodsMoney int) int {
+	if cbc.Freight.Rule == nil {
+		return 0
+	}
+	units := lo.SumBy(cbc.checkingItems, func(item *do.ShoppingCartItem) int {
+		if cbc.Freight.ChargeType == enum.FreightChargeByWeight {
+			return item.CommodityWeight * item.CommodityNum
+		}
+		return item.CommodityNum
+	})
+	return cbc.Freight.Rule.Fee(units, goodsMoney)
+}
+
+// buildBillItems 生成每个购物项的账单明细, 各项减免已经按金额比例分摊到每个购物项上
+func (cbc *CartBillChecker) buildBillItems(itemMoneys, vipShares, couponShares, discountShares []int) []*do.CartBillItem {
+	billItems := make([]*do.CartBillItem, 0, len(cbc.checkingItems))
+	for i, item := range cbc.checkingItems {
+		billItem := &do.CartBillItem{
+			CommodityId:         item.CommodityId,
+			CommodityNum:        item.CommodityNum,
+			BillMoney:           itemMoneys[i],
+			VipDiscountMoney:    vipShares[i],
+			CouponDiscountMoney: couponShares[i],
+			DiscountMoney:       discountShares[i],
+		}
+		billItem.PayMoney = billItem.BillMoney - billItem.VipDiscountMoney - billItem.CouponDiscountMoney - billItem.DiscountMoney
+		billItems = append(billItems, billItem)
+	}
+	return billItems
+}
+
+// AllocateMoney 把金额 total 按 weights 的比例分摊, 分摊结果之和始终等于 total
+// 按累计比例四舍五入后再相减, 避免每一份单独取整后出现误差累积
+func AllocateMoney(total int, weights []int) []int {
+	shares := make([]int, len(weights))
+	weightSum := lo.Sum(weights)
+	if total == 0 || weightSum == 0 {
+		return shares
+	}
+	cumWeight, allocated := 0, 0
+	for i, weight := range weights {
+		cumWeight += weight
+		cumShare := int(math.Round(float64(total) * float64(cumWeight) / float64(weightSum)))
+		shares[i] = cumShare - allocated
+		allocated = cumShare
+	}
+	return shares
+}
+
 type cartBillCheckHandler interface {
 	RunChecker(*CartBillChecker) error
 	SetNext(cartBillCheckHandler) cartBillCheckHandler
@@ -96,10 +266,10 @@ func (n *cartCommonChecker) SetNext(handler cartBillCheckHandler) cartBillCheckH
 
 // RunChecker 启动责任链，并传递给 nextHandler
 // 执行 nextHandler，若无误，则调用 RunChecker 进行传递
+// checker 返回的错误不再包装, 否则 ErrFreightUndeliverable 这类业务错误的错误码会被 Wrap 的错误码覆盖, 控制器用 errors.Is 判断不出来
 func (n *cartCommonChecker) RunChecker(billChecker *CartBillChecker) error {
 	if n.nextHandler != nil {
 		if err := n.nextHandler.Check(billChecker); err != nil {
-			err = errcode.Wrap("cartCommonChecker", err)
 			return err
 		}
 		return n.nextHandler.RunChecker(billChecker)
@@ -128,22 +298,39 @@ type couponChecker struct {
 	cartCommonChecker
 }
 
-// Check 检查优惠券，如果用户有可用优惠券，则设置到 CartBillChecker 中，在本项目中可理解为执行 execute
-func (cc *couponChecker) Check(cbc *CartBillChecker) error {
-	// TODO: 查询用户是否有可用优惠券
-	// 这里是 Mock 逻辑
-	// dao.GetUserCoupon(cbc.UserId)
-	cbc.Coupon = struct {
-		CouponId      int64
-		CouponName    string
-		DiscountMoney int
-		Threshold     int
-	}{
-		CouponId:      1,
-		DiscountMoney: 100, // 假设可用优惠券 ID 为 1， 减免 100
-		Threshold:     100,
+// Check 查询用户当前可以使用的优惠券, 设置到 CartBillChecker 中, 计算账单时按基准金额选出使用的优惠券
+func (cc *couponChecker) Check(cbc *CartBillChecker) (err error) {
+	if cbc.SelectedCouponId == enum.BillNoCoupon {
+		return nil
 	}
-	return nil
+	cbc.usableCoupons, err = NewCouponDomainSvc(cbc.ctx).GetUsableCoupons(cbc.UserId)
+	return err
+}
+
+// Deduct 按适用商品的基准金额选出使用的优惠券, 减免金额只分摊到在优惠券适用范围内的购物项上
+// 用户选择了优惠券时只使用这张优惠券, 不满足使用条件时返回 ErrCouponUnavailable
+func (cc *couponChecker) Deduct(cbc *CartBillChecker, baseMoneys []int) (string, []int, error) {
+	if cbc.SelectedCouponId == enum.BillNoCoupon {
+		return "不使用优惠券", nil, nil
+	}
+	coupon, discountMoney, err := SelectBillCoupon(cbc.usableCoupons, cbc.checkingItems, baseMoneys, cbc.SelectedCouponId)
+	if err != nil {
+		return "", nil, err
+	}
+	if coupon == nil {
+		return "没有可以使用的优惠券", nil, nil
+	}
+	cbc.Coupon.CouponId = coupon.ID
+	cbc.Coupon.CouponName = coupon.Template.Name
+	cbc.Coupon.DiscountMoney = discountMoney
+	cbc.Coupon.Threshold = coupon.Template.Threshold
+	shares := AllocateMoney(discountMoney, lo.Map(cbc.checkingItems, func(item *do.ShoppingCartItem, index int) int {
+		if !coupon.Template.InScope(item) {
+			return 0
+		}
+		return baseMoneys[index]
+	}))
+	return fmt.Sprintf("使用优惠券「%s」", coupon.Template.Name), shares, nil
 }
 
 // discountChecker 折扣减免 checker
@@ -151,22 +338,34 @@ type discountChecker struct {
 	cartCommonChecker
 }
 
-// Check 检查折扣减免，如果用户有可用折扣减免，则设置到 CartBillChecker 中，在本项目中可理解为执行 execute
+// Check 查询用户可以参加的满减活动, 设置到 CartBillChecker 中, 计算账单时按适用的购物项金额选出使用的活动和阶梯
 func (dc *discountChecker) Check(cbc *CartBillChecker) error {
-	// TODO: 查用户是否有可用的减免活动
-	// 这里是Mock逻辑
-	// dao.GetApplicableDiscount(cbc.UserId)
-	cbc.Discount = struct {
-		DiscountId    int64
-		DiscountName  string
-		DiscountMoney int
-		Threshold     int
-	}{
-		DiscountId:    1,
-		DiscountMoney: 100, // 假设可用优惠券ID为1， 减免100
-		Threshold:     1000,
+	campaigns, err := NewDiscountDomainSvc(cbc.ctx).GetUserAvailableCampaigns(cbc.UserId)
+	if err != nil {
+		return err
 	}
-	return nil
+	cbc.discountCampaigns = campaigns
+	if !lo.ContainsBy(campaigns, func(campaign *do.DiscountCampaign) bool {
+		return campaign.ScopeType == enum.DiscountScopeCategory
+	}) {
+		return nil
+	}
+	// 有指定分类的活动时查询商品所在分类的上级分类, 活动指定上级分类时子分类下的商品也适用
+	cbc.categoryPaths, err = NewCommodityDomainSvc(cbc.ctx).GetCategoryPaths(lo.Map(cbc.checkingItems, func(item *do.ShoppingCartItem, _ int) int64 {
+		return item.CommodityCategoryId
+	}))
+	return err
+}
+
+// Deduct 按适用商品的基准金额判断门槛, 选出减免金额最多的满减活动, 减免金额只分摊到适用的购物项上
+func (dc *discountChecker) Deduct(cbc *CartBillChecker, baseMoneys []int) (string, []int, error) {
+	discountMoney, weights := cbc.applyDiscountCampaign(baseMoneys)
+	if discountMoney == 0 {
+		return "没有达到满减活动的门槛", nil, nil
+	}
+	description := fmt.Sprintf("参加满减活动「%s」, 满 %.2f 元减 %.2f 元",
+		cbc.Discount.DiscountName, float64(cbc.Discount.Threshold)/100, float64(discountMoney)/100)
+	return description, AllocateMoney(discountMoney, weights), nil
 }
 
 // vipChecker VIP checker
@@ -174,13 +373,44 @@ type vipChecker struct {
 	cartCommonChecker
 }
 
-// Check 检查 VIP，如果用户是 VIP，则设置到 CartBillChecker 中，在本项目中可理解为执行 execute
-func (vc *vipChecker) Check(cbc *CartBillChecker) error {
-	// TODO: 判断用户是不是会员, 有没有会员折扣
-	//if isVip(userId) {
-	//  cbc.VipOffRate = 12 // 比如vip减免12%
-	//  return nil
-	//}
-	cbc.VipOffRate = 0 // 不是vip不减免
+// Check 检查 VIP，如果用户是有效期内的会员，则把会员等级的折扣设置到 CartBillChecker 中，在本项目中可理解为执行 execute
+func (vc *vipChecker) Check(cbc *CartBillChecker) (err error) {
+	cbc.VipOffRate, err = NewVipDomainSvc(cbc.ctx).GetUserVipOffRate(cbc.UserId)
+	return err
+}
+
+// Deduct 按基准金额和会员折扣计算 VIP 减免的金额, 按每个购物项的基准金额比例分摊
+func (vc *vipChecker) Deduct(cbc *CartBillChecker, baseMoneys []int) (string, []int, error) {
+	if cbc.VipOffRate == 0 {
+		return "不是会员或会员等级没有折扣", nil, nil
+	}
+	vipDiscountMoney := int(math.Round(float64(lo.Sum(baseMoneys)) * float64(cbc.VipOffRate) / 100.0))
+	return fmt.Sprintf("会员折扣减免 %d%%", cbc.VipOffRate), AllocateMoney(vipDiscountMoney, baseMoneys), nil
+}
+
+// freightChecker 运费 checker
+type freightChecker struct {
+	cartCommonChecker
+}
+
+// Check 按默认运费模板找到收货地址所在省份适用的计费规则, 设置到 CartBillChecker 中
+// 没有收货地址或者没有设置默认运费模板时不收运费, 模板中没有收货省份适用的规则时这个地址不支持配送
+func (fc *freightChecker) Check(cbc *CartBillChecker) error {
+	if cbc.UserAddress == nil {
+		return nil
+	}
+	template, err := NewFreightDomainSvc(cbc.ctx).GetDefaultFreightTemplate()
+	if err != nil {
+		return err
+	}
+	if template == nil {
+		return nil
+	}
+	rule := template.MatchRule(cbc.UserAddress.ProvinceName)
+	if rule == nil {
+		return errcode.ErrFreightUndeliverable
+	}
+	cbc.Freight.ChargeType = template.ChargeType
+	cbc.Freight.Rule = rule
 	return nil
 }
@@ -0,0 +1,122 @@
+package domainservice
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/logger"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/config"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+const defaultCheckoutTokenTTL = 15 * time.Minute
+
+var errCheckoutTokenSecretEmpty = errors.New("app.order.checkout_token_secret is not configured")
+
+// checkoutTokenSecret 结算凭证的签名密钥
+// 没有配置密钥时返回错误, 不能使用写在代码中的默认密钥, 否则任何人都可以用它伪造结算凭证
+func checkoutTokenSecret() (string, error) {
+	if config.App.Order.CheckoutTokenSecret == "" {
+		return "", errCheckoutTokenSecretEmpty
+	}
+	return config.App.Order.CheckoutTokenSecret, nil
+}
+
+// CheckCheckoutTokenSecret 服务启动时检查是否配置了结算凭证的签名密钥, 没有配置时服务不启动
+func CheckCheckoutTokenSecret() error {
+	_, err := checkoutTokenSecret()
+	return err
+}
+
+// CheckoutTokenTTL 结算凭证的有效期
+func CheckoutTokenTTL() time.Duration {
+	if config.App.Order.CheckoutTokenTTL > 0 {
+		return config.App.Order.CheckoutTokenTTL
+	}
+	return defaultCheckoutTokenTTL
+}
+
+// newCheckoutSnapshot 用结算的购物项和账单生成结算快照, 快照不包含过期时间
+func newCheckoutSnapshot(userId int64, items []*do.ShoppingCartItem, billInfo *do.CartBillInfo) *do.CheckoutSnapshot {
+	snapshot := &do.CheckoutSnapshot{
+		UserId:             userId,
+		Items:              make([]*do.CheckoutSnapshotItem, 0, len(items)),
+		CouponId:           billInfo.Coupon.CouponId,
+		DiscountId:         billInfo.Discount.DiscountId,
+		VipDiscountMoney:   billInfo.VipDiscountMoney,
+		FreightMoney:       billInfo.FreightMoney,
+		OriginalTotalPrice: billInfo.OriginalTotalPrice,
+		TotalPrice:         billInfo.TotalPrice,
+	}
+	for _, item := range items {
+		snapshot.Items = append(snapshot.Items, &do.CheckoutSnapshotItem{
+			CommodityId:  item.CommodityId,
+			CommodityNum: item.CommodityNum,
+			Price:        item.CommoditySellingPrice,
+		})
+	}
+	// 按商品 ID 排序, 查看账单和创建订单时购物项的顺序可能不一样
+	sort.Slice(snapshot.Items, func(i, j int) bool {
+		return snapshot.Items[i].CommodityId < snapshot.Items[j].CommodityId
+	})
+	return snapshot
+}
+
+// SignCheckoutToken 查看账单时生成结算凭证: base64(结算快照) + "." + HMAC 签名
+// 凭证中记录了商品价格、使用的优惠和总金额, 创建订单时用 VerifyCheckoutToken 校验账单是否发生了变化
+func SignCheckoutToken(userId int64, items []*do.ShoppingCartItem, billInfo *do.CartBillInfo) (string, error) {
+	secret, err := checkoutTokenSecret()
+	if err != nil {
+		return "", errcode.Wrap("SignCheckoutTokenError", err)
+	}
+	snapshot := newCheckoutSnapshot(userId, items, billInfo)
+	snapshot.ExpireAt = time.Now().Add(CheckoutTokenTTL()).Unix()
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return "", errcode.Wrap("SignCheckoutTokenError", err)
+	}
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+	return encodedPayload + "." + util.HmacSHA256HashString(encodedPayload, secret), nil
+}
+
+// VerifyCheckoutToken 校验结算凭证, 签名不对、已过期或不是这个用户的凭证时返回 ErrOrderCheckoutInvalid
+// 凭证中的结算快照和重新计算的账单不一致时返回 ErrOrderBillChanged, 客户端需要重新查看账单确认金额
+// 没有配置签名密钥时所有凭证都校验不通过
+func (ods *OrderDomainSvc) VerifyCheckoutToken(token string, userId int64, items []*do.ShoppingCartItem, billInfo *do.CartBillInfo) error {
+	secret, err := checkoutTokenSecret()
+	if err != nil {
+		logger.New(ods.ctx).Error("VerifyCheckoutTokenError", "err", err)
+		return errcode.ErrOrderCheckoutInvalid
+	}
+	encodedPayload, sign, found := strings.Cut(token, ".")
+	if !found || !hmac.Equal([]byte(sign), []byte(util.HmacSHA256HashString(encodedPayload, secret))) {
+		return errcode.ErrOrderCheckoutInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return errcode.ErrOrderCheckoutInvalid
+	}
+	snapshot := new(do.CheckoutSnapshot)
+	if err = json.Unmarshal(payload, snapshot); err != nil {
+		return errcode.ErrOrderCheckoutInvalid
+	}
+	if snapshot.UserId != userId || time.Now().Unix() > snapshot.ExpireAt {
+		return errcode.ErrOrderCheckoutInvalid
+	}
+
+	current := newCheckoutSnapshot(userId, items, billInfo)
+	current.ExpireAt = snapshot.ExpireAt
+	if !reflect.DeepEqual(snapshot, current) {
+		logger.New(ods.ctx).Info("CheckoutBillChanged", "checkout", snapshot, "current", current)
+		return errcode.ErrOrderBillChanged
+	}
+	return nil
+}
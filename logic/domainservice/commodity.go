@@ -6,6 +6,8 @@ import (
 	"errors"
 	"sort"
 
+	"github.com/samber/lo"
+
 	"github.com/hd2yao/go-mall/common/app"
 	"github.com/hd2yao/go-mall/common/errcode"
 	"github.com/hd2yao/go-mall/common/logger"
@@ -120,6 +122,38 @@ func (cds *CommodityDomainSvc) GetSubCategories(parentId int64) ([]*do.Commodity
 	return categories, nil
 }
 
+// GetCategoryPaths 获取每个分类和它的所有上级分类的ID, 判断商品是否在指定分类范围内的活动时使用
+// 返回的 map 的 key 为 categoryIds 中的分类ID, value 从分类自身开始依次向上到一级分类
+func (cds *CommodityDomainSvc) GetCategoryPaths(categoryIds []int64) (map[int64][]int64, error) {
+	parentMap := make(map[int64]int64)
+	queryIds := lo.Uniq(categoryIds)
+	// 分类最多有三级, 每次查询一级的上级分类
+	for len(queryIds) > 0 {
+		categoryModels, err := cds.commodityDao.GetCategoriesByIds(queryIds)
+		if err != nil {
+			return nil, errcode.Wrap("GetCategoryPathsError", err)
+		}
+		queryIds = queryIds[:0]
+		for _, categoryModel := range categoryModels {
+			parentMap[categoryModel.ID] = categoryModel.ParentId
+			if _, ok := parentMap[categoryModel.ParentId]; categoryModel.ParentId > 0 && !ok {
+				queryIds = append(queryIds, categoryModel.ParentId)
+			}
+		}
+		queryIds = lo.Uniq(queryIds)
+	}
+
+	categoryPaths := make(map[int64][]int64, len(categoryIds))
+	for _, categoryId := range categoryIds {
+		path := make([]int64, 0, 3)
+		for id := categoryId; id > 0 && !lo.Contains(path, id); id = parentMap[id] {
+			path = append(path, id)
+		}
+		categoryPaths[categoryId] = path
+	}
+	return categoryPaths, nil
+}
+
 // InitCommodityData 初始化商品信息测试数据
 func (cds *CommodityDomainSvc) InitCommodityData() error {
 	commodity, err := cds.commodityDao.GetOneCommodity()
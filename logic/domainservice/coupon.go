@@ -0,0 +1,217 @@
+package domainservice
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+
+	"github.com/hd2yao/go-mall/common/app"
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/dal/dao"
+	"github.com/hd2yao/go-mall/dal/model"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+type CouponDomainSvc struct {
+	ctx       context.Context
+	couponDao *dao.CouponDao
+}
+
+func NewCouponDomainSvc(ctx context.Context) *CouponDomainSvc {
+	return &CouponDomainSvc{
+		ctx:       ctx,
+		couponDao: dao.NewCouponDao(ctx),
+	}
+}
+
+// CreateCouponTemplate 创建优惠券模板
+func (cds *CouponDomainSvc) CreateCouponTemplate(template *do.CouponTemplate) error {
+	if !template.ValidEnd.After(template.ValidStart) {
+		return errcode.ErrCouponParams
+	}
+	if (template.CouponType == enum.CouponTypeFixed && template.DiscountMoney <= 0) ||
+		(template.CouponType == enum.CouponTypePercent && (template.DiscountRate <= 0 || template.DiscountRate >= 100)) {
+		return errcode.ErrCouponParams
+	}
+	if template.ScopeType != enum.CouponScopeAll && len(template.ScopeIdList()) == 0 {
+		return errcode.ErrCouponParams
+	}
+	template.Status = enum.CouponTemplateIssuing
+	if err := cds.couponDao.CreateTemplate(template); err != nil {
+		return errcode.Wrap("CreateCouponTemplateError", err)
+	}
+	return nil
+}
+
+// StopCouponTemplate 停止发放优惠券, 已经领取的优惠券仍然可以使用
+func (cds *CouponDomainSvc) StopCouponTemplate(templateId int64) error {
+	templateModel, err := cds.couponDao.GetTemplate(templateId)
+	if err != nil {
+		return errcode.Wrap("StopCouponTemplateError", err)
+	}
+	if templateModel == nil {
+		return errcode.ErrCouponNotExists
+	}
+	if err = cds.couponDao.UpdateTemplateStatus(templateId, enum.CouponTemplateStopped); err != nil {
+		return errcode.Wrap("StopCouponTemplateError", err)
+	}
+	return nil
+}
+
+// GetCouponTemplateList 管理后台分页获取优惠券模板列表
+func (cds *CouponDomainSvc) GetCouponTemplateList(pagination *app.Pagination) ([]*do.CouponTemplate, error) {
+	templateModels, totalRows, err := cds.couponDao.GetTemplateList(pagination.Offset(), pagination.GetPageSize())
+	if err != nil {
+		return nil, errcode.Wrap("GetCouponTemplateListError", err)
+	}
+	pagination.SetTotalRows(int(totalRows))
+	templates := make([]*do.CouponTemplate, 0, len(templateModels))
+	if err = util.CopyProperties(&templates, &templateModels); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return templates, nil
+}
+
+// GetClaimableCoupons 分页获取当前可以领取的优惠券
+func (cds *CouponDomainSvc) GetClaimableCoupons(pagination *app.Pagination) ([]*do.CouponTemplate, error) {
+	templateModels, totalRows, err := cds.couponDao.GetClaimableTemplates(time.Now(), pagination.Offset(), pagination.GetPageSize())
+	if err != nil {
+		return nil, errcode.Wrap("GetClaimableCouponsError", err)
+	}
+	pagination.SetTotalRows(int(totalRows))
+	templates := make([]*do.CouponTemplate, 0, len(templateModels))
+	if err = util.CopyProperties(&templates, &templateModels); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return templates, nil
+}
+
+// ClaimCoupon 用户领取优惠券, 优惠券不存在、已领完或已达到领取上限时原样返回 dao 层的业务错误
+func (cds *CouponDomainSvc) ClaimCoupon(templateId, userId int64) (*do.UserCoupon, error) {
+	userCouponModel, err := cds.couponDao.ClaimCoupon(templateId, userId, time.Now())
+	if err != nil {
+		if errors.Is(err, errcode.ErrCouponNotExists) || errors.Is(err, errcode.ErrCouponClaimFailed) {
+			return nil, err
+		}
+		return nil, errcode.Wrap("ClaimCouponError", err)
+	}
+	userCoupons, err := cds.toUserCoupons([]*model.UserCoupon{userCouponModel})
+	if err != nil {
+		return nil, err
+	}
+	return userCoupons[0], nil
+}
+
+// GetUserCoupons 分页获取用户的优惠券, status 小于等于 0 时获取全部状态的优惠券
+func (cds *CouponDomainSvc) GetUserCoupons(userId int64, status int, pagination *app.Pagination) ([]*do.UserCoupon, error) {
+	userCouponModels, totalRows, err := cds.couponDao.GetUserCoupons(userId, status, pagination.Offset(), pagination.GetPageSize())
+	if err != nil {
+		return nil, errcode.Wrap("GetUserCouponsError", err)
+	}
+	pagination.SetTotalRows(int(totalRows))
+	return cds.toUserCoupons(userCouponModels)
+}
+
+// GetUsableCoupons 获取用户当前可以使用的优惠券
+func (cds *CouponDomainSvc) GetUsableCoupons(userId int64) ([]*do.UserCoupon, error) {
+	userCouponModels, err := cds.couponDao.GetUsableUserCoupons(userId, time.Now())
+	if err != nil {
+		return nil, errcode.Wrap("GetUsableCouponsError", err)
+	}
+	return cds.toUserCoupons(userCouponModels)
+}
+
+// LockOrderCoupon 在创建订单的事务中锁定订单使用的优惠券, 优惠券已经不可用时返回 ErrCouponUnavailable
+func (cds *CouponDomainSvc) LockOrderCoupon(tx *gorm.DB, userCouponId, userId int64, orderNo string) error {
+	locked, err := cds.couponDao.LockUserCoupon(tx, userCouponId, userId, orderNo, time.Now())
+	if err != nil {
+		return errcode.Wrap("LockOrderCouponError", err)
+	}
+	if !locked {
+		return errcode.ErrCouponUnavailable
+	}
+	return nil
+}
+
+// RedeemOrderCoupon 在订单支付成功的事务中核销订单锁定的优惠券
+func (cds *CouponDomainSvc) RedeemOrderCoupon(tx *gorm.DB, orderNo string, paidAt time.Time) error {
+	if err := cds.couponDao.RedeemOrderCoupon(tx, orderNo, paidAt); err != nil {
+		return errcode.Wrap("RedeemOrderCouponError", err)
+	}
+	return nil
+}
+
+// ReleaseOrderCoupon 在订单取消或关闭的事务中释放订单锁定的优惠券
+func (cds *CouponDomainSvc) ReleaseOrderCoupon(tx *gorm.DB, orderNo string) error {
+	if err := cds.couponDao.ReleaseOrderCoupon(tx, orderNo); err != nil {
+		return errcode.Wrap("ReleaseOrderCouponError", err)
+	}
+	return nil
+}
+
+// toUserCoupons 转换成领域对象并填充优惠券的模板
+func (cds *CouponDomainSvc) toUserCoupons(userCouponModels []*model.UserCoupon) ([]*do.UserCoupon, error) {
+	userCoupons := make([]*do.UserCoupon, 0, len(userCouponModels))
+	if len(userCouponModels) == 0 {
+		return userCoupons, nil
+	}
+	if err := util.CopyProperties(&userCoupons, &userCouponModels); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	templateModels, err := cds.couponDao.GetTemplatesByIds(lo.Uniq(lo.Map(userCoupons, func(coupon *do.UserCoupon, _ int) int64 {
+		return coupon.CouponId
+	})))
+	if err != nil {
+		return nil, errcode.Wrap("GetCouponTemplatesError", err)
+	}
+	templates := make([]*do.CouponTemplate, 0, len(templateModels))
+	if err = util.CopyProperties(&templates, &templateModels); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	templateMap := lo.KeyBy(templates, func(template *do.CouponTemplate) int64 {
+		return template.ID
+	})
+	// 模板被删除的优惠券不再展示
+	return lo.Filter(userCoupons, func(coupon *do.UserCoupon, _ int) bool {
+		coupon.Template = templateMap[coupon.CouponId]
+		return coupon.Template != nil
+	}), nil
+}
+
+// SelectBillCoupon 从用户可用的优惠券中选出结算时使用的优惠券, 返回优惠券和能减免的金额
+// baseMoneys 为每个购物项计算优惠券减免的基准金额, 按适用商品的基准金额判断使用门槛
+// selectedId 大于 0 时使用用户选择的优惠券, 不满足使用条件时返回 ErrCouponUnavailable; 否则选择减免金额最多的优惠券
+// 减免金额相同时优先使用先过期的优惠券, 没有能用的优惠券时返回 nil
+func SelectBillCoupon(coupons []*do.UserCoupon, items []*do.ShoppingCartItem, baseMoneys []int, selectedId int64) (*do.UserCoupon, int, error) {
+	var bestCoupon *do.UserCoupon
+	bestDiscount := 0
+	for _, coupon := range coupons {
+		if selectedId > 0 && coupon.ID != selectedId {
+			continue
+		}
+		discount := coupon.Template.Discount(CouponApplicableMoney(coupon.Template, items, baseMoneys))
+		if discount > bestDiscount || (discount > 0 && discount == bestDiscount && coupon.ValidEnd.Before(bestCoupon.ValidEnd)) {
+			bestCoupon, bestDiscount = coupon, discount
+		}
+	}
+	if selectedId > 0 && bestCoupon == nil {
+		return nil, 0, errcode.ErrCouponUnavailable
+	}
+	return bestCoupon, bestDiscount, nil
+}
+
+// CouponApplicableMoney 购物项中在优惠券适用范围内的商品的基准金额之和
+func CouponApplicableMoney(template *do.CouponTemplate, items []*do.ShoppingCartItem, baseMoneys []int) int {
+	applicableMoney := 0
+	for i, item := range items {
+		if template.InScope(item) {
+			applicableMoney += baseMoneys[i]
+		}
+	}
+	return applicableMoney
+}
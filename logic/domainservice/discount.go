@@ -0,0 +1,166 @@
+package domainservice
+
+import (
+	"context"
+	"time"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+
+	"github.com/hd2yao/go-mall/common/app"
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/dal/dao"
+	"github.com/hd2yao/go-mall/dal/model"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+type DiscountDomainSvc struct {
+	ctx         context.Context
+	discountDao *dao.DiscountDao
+}
+
+func NewDiscountDomainSvc(ctx context.Context) *DiscountDomainSvc {
+	return &DiscountDomainSvc{
+		ctx:         ctx,
+		discountDao: dao.NewDiscountDao(ctx),
+	}
+}
+
+// CreateDiscountCampaign 创建满减活动
+func (dds *DiscountDomainSvc) CreateDiscountCampaign(campaign *do.DiscountCampaign) error {
+	if err := checkDiscountCampaign(campaign); err != nil {
+		return err
+	}
+	campaign.Status = enum.DiscountCampaignActive
+	if err := dds.discountDao.CreateCampaign(campaign); err != nil {
+		return errcode.Wrap("CreateDiscountCampaignError", err)
+	}
+	return nil
+}
+
+// StopDiscountCampaign 停止满减活动, 停止后下单不再享受活动的减免
+func (dds *DiscountDomainSvc) StopDiscountCampaign(campaignId int64) error {
+	campaignModel, err := dds.discountDao.GetCampaign(campaignId)
+	if err != nil {
+		return errcode.Wrap("StopDiscountCampaignError", err)
+	}
+	if campaignModel == nil {
+		return errcode.ErrDiscountNotExists
+	}
+	if err = dds.discountDao.UpdateCampaignStatus(campaignId, enum.DiscountCampaignStopped); err != nil {
+		return errcode.Wrap("StopDiscountCampaignError", err)
+	}
+	return nil
+}
+
+// GetDiscountCampaignList 管理后台分页获取满减活动列表, 包含每个活动的阶梯
+func (dds *DiscountDomainSvc) GetDiscountCampaignList(pagination *app.Pagination) ([]*do.DiscountCampaign, error) {
+	campaignModels, totalRows, err := dds.discountDao.GetCampaignList(pagination.Offset(), pagination.GetPageSize())
+	if err != nil {
+		return nil, errcode.Wrap("GetDiscountCampaignListError", err)
+	}
+	pagination.SetTotalRows(int(totalRows))
+	return dds.toDiscountCampaigns(campaignModels)
+}
+
+// GetOngoingCampaigns 获取正在进行的满减活动
+func (dds *DiscountDomainSvc) GetOngoingCampaigns() ([]*do.DiscountCampaign, error) {
+	campaignModels, err := dds.discountDao.GetOngoingCampaigns(time.Now())
+	if err != nil {
+		return nil, errcode.Wrap("GetOngoingCampaignsError", err)
+	}
+	return dds.toDiscountCampaigns(campaignModels)
+}
+
+// GetUserAvailableCampaigns 获取用户可以参加的满减活动: 正在进行并且用户还没有达到参加次数上限
+func (dds *DiscountDomainSvc) GetUserAvailableCampaigns(userId int64) ([]*do.DiscountCampaign, error) {
+	campaigns, err := dds.GetOngoingCampaigns()
+	if err != nil || len(campaigns) == 0 {
+		return campaigns, err
+	}
+	participations, err := dds.discountDao.GetUserParticipations(userId, lo.Map(campaigns, func(campaign *do.DiscountCampaign, _ int) int64 {
+		return campaign.ID
+	}))
+	if err != nil {
+		return nil, errcode.Wrap("GetUserAvailableCampaignsError", err)
+	}
+	return lo.Filter(campaigns, func(campaign *do.DiscountCampaign, _ int) bool {
+		return campaign.PerUserLimit == 0 || participations[campaign.ID] < campaign.PerUserLimit
+	}), nil
+}
+
+// RecordOrderDiscount 在创建订单的事务中记录订单参加的满减活动
+// 用户并发下单已经达到活动的参加次数上限时返回 ErrDiscountLimitExceeded
+func (dds *DiscountDomainSvc) RecordOrderDiscount(tx *gorm.DB, record *do.OrderDiscountRecord) error {
+	campaignModel, err := dds.discountDao.GetCampaign(record.CampaignId)
+	if err != nil {
+		return errcode.Wrap("RecordOrderDiscountError", err)
+	}
+	if campaignModel == nil {
+		return errcode.ErrDiscountNotExists
+	}
+	created, err := dds.discountDao.CreateOrderRecord(tx, record, campaignModel.PerUserLimit)
+	if err != nil {
+		return errcode.Wrap("RecordOrderDiscountError", err)
+	}
+	if !created {
+		return errcode.ErrDiscountLimitExceeded
+	}
+	return nil
+}
+
+// ReleaseOrderDiscount 在订单取消或关闭的事务中释放订单的满减活动记录, 让用户可以再次参加
+func (dds *DiscountDomainSvc) ReleaseOrderDiscount(tx *gorm.DB, orderNo string) error {
+	if err := dds.discountDao.ReleaseOrderRecord(tx, orderNo); err != nil {
+		return errcode.Wrap("ReleaseOrderDiscountError", err)
+	}
+	return nil
+}
+
+// toDiscountCampaigns 转换成领域对象并填充每个活动的阶梯
+func (dds *DiscountDomainSvc) toDiscountCampaigns(campaignModels []*model.DiscountCampaign) ([]*do.DiscountCampaign, error) {
+	campaigns := make([]*do.DiscountCampaign, 0, len(campaignModels))
+	if len(campaignModels) == 0 {
+		return campaigns, nil
+	}
+	if err := util.CopyProperties(&campaigns, &campaignModels); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	tierModels, err := dds.discountDao.GetCampaignTiers(lo.Map(campaigns, func(campaign *do.DiscountCampaign, _ int) int64 {
+		return campaign.ID
+	}))
+	if err != nil {
+		return nil, errcode.Wrap("GetDiscountCampaignTiersError", err)
+	}
+	tiers := make([]*do.DiscountCampaignTier, 0, len(tierModels))
+	if err = util.CopyProperties(&tiers, &tierModels); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	campaignTiers := lo.GroupBy(tiers, func(tier *do.DiscountCampaignTier) int64 {
+		return tier.CampaignId
+	})
+	for _, campaign := range campaigns {
+		campaign.Tiers = campaignTiers[campaign.ID]
+	}
+	return campaigns, nil
+}
+
+// checkDiscountCampaign 检查满减活动的参数: 活动时间有效, 指定了适用的分类或商品, 阶梯的门槛不重复并且减免金额小于门槛
+func checkDiscountCampaign(campaign *do.DiscountCampaign) error {
+	if !campaign.EndTime.After(campaign.StartTime) || len(campaign.Tiers) == 0 {
+		return errcode.ErrDiscountParams
+	}
+	if campaign.ScopeType != enum.DiscountScopeAll && len(campaign.ScopeIdList()) == 0 {
+		return errcode.ErrDiscountParams
+	}
+	thresholdSet := make(map[int]struct{})
+	for _, tier := range campaign.Tiers {
+		if _, ok := thresholdSet[tier.Threshold]; ok || tier.DiscountMoney <= 0 || tier.DiscountMoney >= tier.Threshold {
+			return errcode.ErrDiscountParams
+		}
+		thresholdSet[tier.Threshold] = struct{}{}
+	}
+	return nil
+}
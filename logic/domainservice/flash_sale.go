@@ -0,0 +1,480 @@
+package domainservice
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/hd2yao/go-mall/common/app"
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/logger"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/config"
+	"github.com/hd2yao/go-mall/dal/cache"
+	"github.com/hd2yao/go-mall/dal/dao"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+const (
+	defaultFlashSaleRequestTimeout = time.Minute
+	defaultFlashSaleResultTTL      = 30 * time.Minute
+	flashSaleStockCacheExtra       = 24 * time.Hour // 场次结束后 Redis 中的库存和限购数量再保留的时间
+	flashSaleConsumeBatchSize      = 50             // 每次从下单队列中取出的秒杀请求数
+	flashSaleCompensateBatchSize   = 200            // 每次补偿处理的下单记录或场次数
+)
+
+// FlashSaleRequestTimeout 秒杀请求超过这个时间还没处理完时认为请求已丢失, 退还预扣的库存
+func FlashSaleRequestTimeout() time.Duration {
+	if config.App.FlashSale.RequestTimeout > 0 {
+		return config.App.FlashSale.RequestTimeout
+	}
+	return defaultFlashSaleRequestTimeout
+}
+
+// flashSaleResultTTL 秒杀请求的处理结果在 Redis 中的保存时间
+func flashSaleResultTTL() time.Duration {
+	if config.App.FlashSale.ResultTTL > 0 {
+		return config.App.FlashSale.ResultTTL
+	}
+	return defaultFlashSaleResultTTL
+}
+
+type FlashSaleDomainSvc struct {
+	ctx          context.Context
+	flashSaleDao *dao.FlashSaleDao
+}
+
+func NewFlashSaleDomainSvc(ctx context.Context) *FlashSaleDomainSvc {
+	return &FlashSaleDomainSvc{
+		ctx:          ctx,
+		flashSaleDao: dao.NewFlashSaleDao(ctx),
+	}
+}
+
+// CreateSession 创建秒杀场次, 在同一个事务中从商品库存中划出秒杀库存, 创建后把库存加载到 Redis
+func (fds *FlashSaleDomainSvc) CreateSession(session *do.FlashSaleSession) error {
+	if session.FlashPrice <= 0 || session.TotalStock <= 0 || session.PerUserLimit <= 0 ||
+		!session.EndTime.After(session.StartTime) || !session.EndTime.After(time.Now()) {
+		return errcode.ErrFlashSaleParams
+	}
+	commodity := NewCommodityDomainSvc(fds.ctx).GetCommodityInfo(session.CommodityId)
+	if commodity == nil || commodity.ID == 0 {
+		return errcode.ErrCommodityNotExists
+	}
+	session.SoldNum = 0
+	session.Status = enum.FlashSaleSessionActive
+
+	err := dao.DBMaster().Transaction(func(tx *gorm.DB) error {
+		err := dao.NewCommodityDao(fds.ctx).ReduceStuckInOrderCreate(tx, []*do.OrderItem{
+			{CommodityId: session.CommodityId, CommodityNum: session.TotalStock},
+		})
+		if err != nil {
+			return err
+		}
+		return fds.flashSaleDao.CreateSession(tx, session)
+	})
+	if err != nil {
+		if errors.Is(err, errcode.ErrCommodityStockOut) {
+			return errcode.ErrCommodityStockOut
+		}
+		return errcode.Wrap("CreateFlashSaleSessionError", err)
+	}
+	// 加载失败时在用户第一次抢购时重新加载
+	if err = fds.warmUpStock(session); err != nil {
+		logger.New(fds.ctx).Error("WarmUpFlashSaleStockError", "sessionId", session.ID, "err", err)
+	}
+	return nil
+}
+
+// StopSession 管理员提前结束秒杀场次
+func (fds *FlashSaleDomainSvc) StopSession(sessionId int64) error {
+	sessionModel, err := fds.flashSaleDao.GetSession(sessionId)
+	if err != nil {
+		return errcode.Wrap("StopFlashSaleSessionError", err)
+	}
+	if sessionModel == nil {
+		return errcode.ErrFlashSaleNotExists
+	}
+	return fds.settleSession(sessionId)
+}
+
+// SettleEndedSessions 结束已经过了结束时间的秒杀场次
+func (fds *FlashSaleDomainSvc) SettleEndedSessions() error {
+	sessionIds, err := fds.flashSaleDao.GetEndedActiveSessionIds(time.Now(), flashSaleCompensateBatchSize)
+	if err != nil {
+		return errcode.Wrap("SettleEndedFlashSaleSessionsError", err)
+	}
+	for _, sessionId := range sessionIds {
+		if err = fds.settleSession(sessionId); err != nil {
+			logger.New(fds.ctx).Error("SettleFlashSaleSessionError", "sessionId", sessionId, "err", err)
+		}
+	}
+	return nil
+}
+
+// settleSession 结束秒杀场次, 把没有卖出的库存还给商品
+// 已经在下单队列中的请求会因为场次已结束创建订单失败, 之后取消或关闭的秒杀订单释放的库存也直接还给商品
+func (fds *FlashSaleDomainSvc) settleSession(sessionId int64) error {
+	err := dao.DBMaster().Transaction(func(tx *gorm.DB) error {
+		session, err := fds.flashSaleDao.LockSession(tx, sessionId)
+		if err != nil || session == nil || session.Status == enum.FlashSaleSessionStopped {
+			return err
+		}
+		if unsold := session.TotalStock - session.SoldNum; unsold > 0 {
+			err = dao.NewCommodityDao(fds.ctx).RecoverOrderCommodityStuckInTx(tx, []*do.OrderItem{
+				{CommodityId: session.CommodityId, CommodityNum: unsold},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		session.TotalStock = session.SoldNum
+		session.Status = enum.FlashSaleSessionStopped
+		return fds.flashSaleDao.UpdateSession(tx, session)
+	})
+	if err != nil {
+		return errcode.Wrap("SettleFlashSaleSessionError", err)
+	}
+	return nil
+}
+
+// GetSessionList 管理后台分页获取秒杀场次列表
+func (fds *FlashSaleDomainSvc) GetSessionList(pagination *app.Pagination) ([]*do.FlashSaleSession, error) {
+	sessionModels, totalRows, err := fds.flashSaleDao.GetSessionList(pagination.Offset(), pagination.GetPageSize())
+	if err != nil {
+		return nil, errcode.Wrap("GetFlashSaleSessionListError", err)
+	}
+	pagination.SetTotalRows(int(totalRows))
+	sessions := make([]*do.FlashSaleSession, 0, len(sessionModels))
+	if err = util.CopyProperties(&sessions, &sessionModels); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return sessions, nil
+}
+
+// GetActiveSessions 获取正在抢购和还没开始的秒杀场次, 剩余库存从 Redis 中读取
+func (fds *FlashSaleDomainSvc) GetActiveSessions() ([]*do.FlashSaleSession, error) {
+	sessionModels, err := fds.flashSaleDao.GetActiveSessions(time.Now())
+	if err != nil {
+		return nil, errcode.Wrap("GetActiveFlashSaleSessionsError", err)
+	}
+	sessions := make([]*do.FlashSaleSession, 0, len(sessionModels))
+	if err = util.CopyProperties(&sessions, &sessionModels); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	for _, session := range sessions {
+		stock, err := cache.GetFlashSaleStock(fds.ctx, session.ID)
+		if err != nil {
+			return nil, errcode.Wrap("GetActiveFlashSaleSessionsError", err)
+		}
+		if stock < 0 { // 库存还没加载到 Redis
+			stock = session.TotalStock - session.SoldNum
+		}
+		session.RemainingStock = stock
+	}
+	return sessions, nil
+}
+
+// Purchase 用户抢购秒杀商品, 在 Redis 中预扣库存成功后把请求放入下单队列, 返回用来轮询处理结果的请求号
+// 这一步不访问数据库中的商品库存, 订单由后台任务从下单队列中取出请求后异步创建
+func (fds *FlashSaleDomainSvc) Purchase(userId, sessionId int64, commodityNum int, userAddressId int64) (string, error) {
+	sessionModel, err := fds.flashSaleDao.GetSession(sessionId)
+	if err != nil {
+		return "", errcode.Wrap("FlashSalePurchaseError", err)
+	}
+	if sessionModel == nil {
+		return "", errcode.ErrFlashSaleNotExists
+	}
+	session := new(do.FlashSaleSession)
+	if err = util.CopyProperties(session, sessionModel); err != nil {
+		return "", errcode.ErrCoverData.WithCause(err)
+	}
+	if session.Status != enum.FlashSaleSessionActive || !session.Ongoing(time.Now()) {
+		return "", errcode.ErrFlashSaleNotOngoing
+	}
+	if commodityNum > session.PerUserLimit {
+		return "", errcode.ErrFlashSaleLimitExceeded
+	}
+	// 先校验收货地址, 避免预扣库存后才因为地址不对创建订单失败
+	if _, err = NewUserDomainSvc(fds.ctx).GetUserSingleAddress(userId, userAddressId); err != nil {
+		return "", err
+	}
+
+	request := &do.FlashSaleRequest{
+		SessionId:     sessionId,
+		UserId:        userId,
+		CommodityNum:  commodityNum,
+		UserAddressId: userAddressId,
+		QueuedAt:      time.Now().Unix(),
+	}
+	if request.RequestNo, err = genSerialNo(userId); err != nil {
+		return "", errcode.Wrap("FlashSalePurchaseError", err)
+	}
+	expireAt := session.EndTime.Add(flashSaleStockCacheExtra)
+	deductResult, err := cache.DeductFlashSaleStock(fds.ctx, request, session.PerUserLimit, flashSaleResultTTL(), expireAt)
+	if err == nil && deductResult == enum.FlashSaleDeductNotWarmed {
+		// 库存还没有加载到 Redis (比如创建场次时加载失败或者缓存丢失), 加载后重新预扣
+		if err = fds.warmUpStock(session); err != nil {
+			return "", errcode.Wrap("FlashSalePurchaseError", err)
+		}
+		deductResult, err = cache.DeductFlashSaleStock(fds.ctx, request, session.PerUserLimit, flashSaleResultTTL(), expireAt)
+	}
+	if err != nil {
+		return "", errcode.Wrap("FlashSalePurchaseError", err)
+	}
+	switch deductResult {
+	case enum.FlashSaleDeductOk:
+		return request.RequestNo, nil
+	case enum.FlashSaleDeductLimitReached:
+		return "", errcode.ErrFlashSaleLimitExceeded
+	case enum.FlashSaleDeductStockOut:
+		return "", errcode.ErrFlashSaleStockOut
+	default:
+		return "", errcode.Wrap("FlashSalePurchaseError", errors.New("flash sale stock is not warmed up"))
+	}
+}
+
+// warmUpStock 把场次的剩余库存和用户已抢购的数量加载到 Redis, 已经加载过时不做任何修改
+func (fds *FlashSaleDomainSvc) warmUpStock(session *do.FlashSaleSession) error {
+	userBought, err := fds.flashSaleDao.GetSessionUserBought(session.ID)
+	if err != nil {
+		return err
+	}
+	_, err = cache.WarmUpFlashSaleStock(fds.ctx, session.ID, session.TotalStock-session.SoldNum, userBought,
+		session.EndTime.Add(flashSaleStockCacheExtra))
+	return err
+}
+
+// GetPurchaseResult 用户轮询秒杀请求的处理结果, 结果在 Redis 中过期后从下单记录中查询
+func (fds *FlashSaleDomainSvc) GetPurchaseResult(userId int64, requestNo string) (*do.FlashSaleResult, error) {
+	result, err := cache.GetFlashSaleResult(fds.ctx, requestNo)
+	if err != nil {
+		return nil, errcode.Wrap("GetFlashSaleResultError", err)
+	}
+	if result != nil {
+		if result.UserId != userId {
+			return nil, errcode.ErrFlashSaleRequestNotExists
+		}
+		return result, nil
+	}
+
+	recordModel, err := fds.flashSaleDao.GetOrderRecordByRequestNo(requestNo)
+	if err != nil {
+		return nil, errcode.Wrap("GetFlashSaleResultError", err)
+	}
+	if recordModel == nil || recordModel.UserId != userId {
+		return nil, errcode.ErrFlashSaleRequestNotExists
+	}
+	result = &do.FlashSaleResult{
+		RequestNo:  recordModel.RequestNo,
+		UserId:     recordModel.UserId,
+		Status:     enum.FlashSaleRequestSuccess,
+		OrderNo:    recordModel.OrderNo,
+		FailReason: recordModel.FailReason,
+	}
+	if recordModel.Status == enum.FlashSaleOrderFailed {
+		result.Status = enum.FlashSaleRequestFailed
+	}
+	return result, nil
+}
+
+// ConsumeRequests 从下单队列中取出秒杀请求创建订单, 多个服务实例可以同时执行, 每个请求只会被一个实例取出
+func (fds *FlashSaleDomainSvc) ConsumeRequests() error {
+	requests, err := cache.PopFlashSaleRequests(fds.ctx, flashSaleConsumeBatchSize)
+	if err != nil {
+		return errcode.Wrap("ConsumeFlashSaleRequestsError", err)
+	}
+	for _, request := range requests {
+		if err = fds.processRequest(request); err != nil {
+			// 请求还在待处理的请求中, 超时后由补偿任务退还预扣的库存
+			logger.New(fds.ctx).Error("ProcessFlashSaleRequestError", "requestNo", request.RequestNo, "err", err)
+		}
+	}
+	return nil
+}
+
+// processRequest 为秒杀请求创建订单, 创建失败时记录失败原因并退还 Redis 中预扣的库存
+func (fds *FlashSaleDomainSvc) processRequest(request *do.FlashSaleRequest) error {
+	log := logger.New(fds.ctx)
+	result := &do.FlashSaleResult{
+		RequestNo: request.RequestNo,
+		UserId:    request.UserId,
+		Status:    enum.FlashSaleRequestSuccess,
+	}
+	orderDomainSvc := NewOrderDomainSvc(fds.ctx)
+	order, err := orderDomainSvc.CreateFlashSaleOrder(request)
+	if err == nil {
+		result.OrderNo = order.OrderNo
+		// 超时未支付的订单自动关闭, 关闭时释放秒杀库存
+		orderDomainSvc.ScheduleUnpaidOrderClose(order.OrderNo)
+	} else {
+		log.Warn("CreateFlashSaleOrderFailed", "requestNo", request.RequestNo, "err", err)
+		result.Status = enum.FlashSaleRequestFailed
+		result.FailReason = flashSaleFailReason(err)
+		if err = fds.failRequest(request, result.FailReason); err != nil {
+			return err
+		}
+	}
+	return cache.FinishFlashSaleRequest(fds.ctx, result, flashSaleResultTTL())
+}
+
+// failRequest 保存创建订单失败的下单记录并退还 Redis 中预扣的库存
+// 退还失败时下单记录还是未退还库存的状态, 由补偿任务重试
+func (fds *FlashSaleDomainSvc) failRequest(request *do.FlashSaleRequest, failReason string) error {
+	record := &do.FlashSaleOrder{
+		RequestNo:    request.RequestNo,
+		SessionId:    request.SessionId,
+		UserId:       request.UserId,
+		CommodityNum: request.CommodityNum,
+		Status:       enum.FlashSaleOrderFailed,
+		FailReason:   failReason,
+	}
+	if err := fds.flashSaleDao.CreateOrderRecord(dao.DBMaster(), record); err != nil {
+		return errcode.Wrap("SaveFlashSaleFailedRecordError", err)
+	}
+	if err := fds.returnRecordStock(record.ID, record.SessionId, record.UserId, record.CommodityNum); err != nil {
+		logger.New(fds.ctx).Error("ReturnFlashSaleStockError", "requestNo", request.RequestNo, "err", err)
+	}
+	return nil
+}
+
+// returnRecordStock 退还下单记录在 Redis 中预扣的库存和用户的限购数量, 然后把记录标记为已退还
+func (fds *FlashSaleDomainSvc) returnRecordStock(recordId, sessionId, userId int64, num int) error {
+	if err := cache.ReturnFlashSaleStock(fds.ctx, sessionId, userId, num); err != nil {
+		return err
+	}
+	_, err := fds.flashSaleDao.SetOrderRecordStockReturned(recordId)
+	return err
+}
+
+// ReleaseOrderStock 在订单取消或关闭的事务中释放秒杀订单占用的库存, 不是秒杀订单时返回 false, 由调用方恢复商品库存
+// 场次还没结束时库存还给场次, Redis 中的库存由补偿任务在事务提交后退还; 场次已经结束时库存直接还给商品
+func (fds *FlashSaleDomainSvc) ReleaseOrderStock(tx *gorm.DB, orderNo string) (bool, error) {
+	record, err := fds.flashSaleDao.LockOrderRecordByOrderNo(tx, orderNo)
+	if err != nil {
+		return false, errcode.Wrap("ReleaseFlashSaleOrderStockError", err)
+	}
+	if record == nil {
+		return false, nil
+	}
+	if record.Status != enum.FlashSaleOrderSuccess {
+		return true, nil
+	}
+	session, err := fds.flashSaleDao.LockSession(tx, record.SessionId)
+	if err != nil {
+		return false, errcode.Wrap("ReleaseFlashSaleOrderStockError", err)
+	}
+	session.SoldNum -= record.CommodityNum
+	stockReturned := 0
+	if session.Status == enum.FlashSaleSessionStopped {
+		session.TotalStock -= record.CommodityNum
+		err = dao.NewCommodityDao(fds.ctx).RecoverOrderCommodityStuckInTx(tx, []*do.OrderItem{
+			{CommodityId: session.CommodityId, CommodityNum: record.CommodityNum},
+		})
+		if err != nil {
+			return false, errcode.Wrap("ReleaseFlashSaleOrderStockError", err)
+		}
+		stockReturned = 1
+	}
+	if err = fds.flashSaleDao.UpdateSession(tx, session); err != nil {
+		return false, errcode.Wrap("ReleaseFlashSaleOrderStockError", err)
+	}
+	if err = fds.flashSaleDao.UpdateOrderRecordStatus(tx, record.ID, enum.FlashSaleOrderReleased, stockReturned); err != nil {
+		return false, errcode.Wrap("ReleaseFlashSaleOrderStockError", err)
+	}
+	return true, nil
+}
+
+// Compensate 秒杀的补偿任务, 通过 Redis 锁保证在执行间隔内只有一个服务实例执行
+// 1. 结束过了结束时间的场次, 把没有卖出的库存还给商品
+// 2. 处理超时还没处理完的秒杀请求(比如服务在取出请求后重启了), 退还预扣的库存
+// 3. 退还创建订单失败或者订单取消、关闭后还没退还的 Redis 库存
+func (fds *FlashSaleDomainSvc) Compensate(interval time.Duration) error {
+	locked, err := cache.LockFlashSaleJob(fds.ctx, "compensate", interval)
+	if err != nil {
+		return errcode.Wrap("CompensateFlashSaleError", err)
+	}
+	if !locked {
+		return nil
+	}
+	if err = fds.SettleEndedSessions(); err != nil {
+		return err
+	}
+	if err = fds.compensateLostRequests(); err != nil {
+		return err
+	}
+	return fds.returnUnreturnedStock()
+}
+
+// compensateLostRequests 处理超过请求超时时间还在待处理的请求中的秒杀请求
+// 已经有下单记录时按记录保存处理结果, 没有时按创建订单失败处理并退还预扣的库存
+func (fds *FlashSaleDomainSvc) compensateLostRequests() error {
+	log := logger.New(fds.ctx)
+	requests, err := cache.GetPendingFlashSaleRequests(fds.ctx)
+	if err != nil {
+		return errcode.Wrap("CompensateLostFlashSaleRequestsError", err)
+	}
+	timeoutBefore := time.Now().Add(-FlashSaleRequestTimeout()).Unix()
+	for _, request := range requests {
+		if request.QueuedAt > timeoutBefore {
+			continue
+		}
+		recordModel, err := fds.flashSaleDao.GetOrderRecordByRequestNo(request.RequestNo)
+		if err != nil {
+			return errcode.Wrap("CompensateLostFlashSaleRequestsError", err)
+		}
+		result := &do.FlashSaleResult{
+			RequestNo: request.RequestNo,
+			UserId:    request.UserId,
+			Status:    enum.FlashSaleRequestFailed,
+		}
+		if recordModel != nil {
+			// 下单记录已经保存, 只是没有保存处理结果
+			result.OrderNo = recordModel.OrderNo
+			result.FailReason = recordModel.FailReason
+			if recordModel.Status != enum.FlashSaleOrderFailed {
+				result.Status = enum.FlashSaleRequestSuccess
+			}
+		} else {
+			result.FailReason = "抢购请求处理超时"
+			if err = fds.failRequest(request, result.FailReason); err != nil {
+				log.Error("CompensateLostFlashSaleRequestError", "requestNo", request.RequestNo, "err", err)
+				continue
+			}
+			log.Warn("FlashSaleRequestLost", "requestNo", request.RequestNo)
+		}
+		if err = cache.FinishFlashSaleRequest(fds.ctx, result, flashSaleResultTTL()); err != nil {
+			return errcode.Wrap("CompensateLostFlashSaleRequestsError", err)
+		}
+	}
+	return nil
+}
+
+// returnUnreturnedStock 退还创建订单失败或已释放的下单记录还没退还的 Redis 库存
+func (fds *FlashSaleDomainSvc) returnUnreturnedStock() error {
+	records, err := fds.flashSaleDao.GetStockUnreturnedRecords(flashSaleCompensateBatchSize)
+	if err != nil {
+		return errcode.Wrap("ReturnUnreturnedFlashSaleStockError", err)
+	}
+	for _, record := range records {
+		if err = fds.returnRecordStock(record.ID, record.SessionId, record.UserId, record.CommodityNum); err != nil {
+			return errcode.Wrap("ReturnUnreturnedFlashSaleStockError", err)
+		}
+	}
+	if len(records) > 0 {
+		logger.New(fds.ctx).Info("ReturnUnreturnedFlashSaleStock", "count", len(records))
+	}
+	return nil
+}
+
+// flashSaleFailReason 创建订单失败时返回给用户的原因, 不是预定义的业务错误时不返回内部的错误信息
+func flashSaleFailReason(err error) string {
+	var appErr *errcode.AppError
+	if errors.As(err, &appErr) && appErr.Code() > 0 {
+		return appErr.Msg()
+	}
+	return "系统繁忙, 请稍后重试"
+}
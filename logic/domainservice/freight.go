@@ -0,0 +1,155 @@
+package domainservice
+
+import (
+	"context"
+	"strings"
+
+	"github.com/samber/lo"
+
+	"github.com/hd2yao/go-mall/common/app"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/dal/dao"
+	"github.com/hd2yao/go-mall/dal/model"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+type FreightDomainSvc struct {
+	ctx        context.Context
+	freightDao *dao.FreightDao
+}
+
+func NewFreightDomainSvc(ctx context.Context) *FreightDomainSvc {
+	return &FreightDomainSvc{
+		ctx:        ctx,
+		freightDao: dao.NewFreightDao(ctx),
+	}
+}
+
+// CreateFreightTemplate 创建运费模板
+func (fds *FreightDomainSvc) CreateFreightTemplate(template *do.FreightTemplate) error {
+	if err := checkFreightTemplateRules(template.Rules); err != nil {
+		return err
+	}
+	if err := fds.freightDao.CreateTemplate(template); err != nil {
+		return errcode.Wrap("CreateFreightTemplateError", err)
+	}
+	return nil
+}
+
+// UpdateFreightTemplate 更新运费模板, 模板的计费规则整体替换
+func (fds *FreightDomainSvc) UpdateFreightTemplate(template *do.FreightTemplate) error {
+	if err := checkFreightTemplateRules(template.Rules); err != nil {
+		return err
+	}
+	if _, err := fds.GetFreightTemplate(template.ID); err != nil {
+		return err
+	}
+	if err := fds.freightDao.UpdateTemplate(template); err != nil {
+		return errcode.Wrap("UpdateFreightTemplateError", err)
+	}
+	return nil
+}
+
+// DeleteFreightTemplate 删除运费模板
+func (fds *FreightDomainSvc) DeleteFreightTemplate(templateId int64) error {
+	if _, err := fds.GetFreightTemplate(templateId); err != nil {
+		return err
+	}
+	if err := fds.freightDao.DeleteTemplate(templateId); err != nil {
+		return errcode.Wrap("DeleteFreightTemplateError", err)
+	}
+	return nil
+}
+
+// GetFreightTemplate 获取运费模板详情, 模板不存在时返回 ErrFreightTemplateNotExists
+func (fds *FreightDomainSvc) GetFreightTemplate(templateId int64) (*do.FreightTemplate, error) {
+	templateModel, ruleModels, err := fds.freightDao.GetTemplate(templateId)
+	if err != nil {
+		return nil, errcode.Wrap("GetFreightTemplateError", err)
+	}
+	if templateModel == nil {
+		return nil, errcode.ErrFreightTemplateNotExists
+	}
+	return toFreightTemplate(templateModel, ruleModels)
+}
+
+// GetDefaultFreightTemplate 获取下单时使用的默认运费模板, 没有设置默认模板时返回 nil, 表示不收运费
+func (fds *FreightDomainSvc) GetDefaultFreightTemplate() (*do.FreightTemplate, error) {
+	templateModel, ruleModels, err := fds.freightDao.GetDefaultTemplate()
+	if err != nil {
+		return nil, errcode.Wrap("GetDefaultFreightTemplateError", err)
+	}
+	if templateModel == nil {
+		return nil, nil
+	}
+	return toFreightTemplate(templateModel, ruleModels)
+}
+
+// GetFreightTemplateList 管理后台分页获取运费模板列表, 包含每个模板的计费规则
+func (fds *FreightDomainSvc) GetFreightTemplateList(pagination *app.Pagination) ([]*do.FreightTemplate, error) {
+	templateModels, totalRows, err := fds.freightDao.GetTemplateList(pagination.Offset(), pagination.GetPageSize())
+	if err != nil {
+		return nil, errcode.Wrap("GetFreightTemplateListError", err)
+	}
+	pagination.SetTotalRows(int(totalRows))
+	templates := make([]*do.FreightTemplate, 0, len(templateModels))
+	if len(templateModels) == 0 {
+		return templates, nil
+	}
+	if err = util.CopyProperties(&templates, &templateModels); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+
+	ruleModels, err := fds.freightDao.GetTemplateRules(lo.Map(templates, func(template *do.FreightTemplate, _ int) int64 {
+		return template.ID
+	}))
+	if err != nil {
+		return nil, errcode.Wrap("GetFreightTemplateListError", err)
+	}
+	rules := make([]*do.FreightTemplateRule, 0, len(ruleModels))
+	if err = util.CopyProperties(&rules, &ruleModels); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	templateRules := lo.GroupBy(rules, func(rule *do.FreightTemplateRule) int64 {
+		return rule.TemplateId
+	})
+	for _, template := range templates {
+		template.Rules = templateRules[template.ID]
+	}
+	return templates, nil
+}
+
+func toFreightTemplate(templateModel *model.FreightTemplate, ruleModels []*model.FreightTemplateRule) (*do.FreightTemplate, error) {
+	template := new(do.FreightTemplate)
+	if err := util.CopyProperties(template, templateModel); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	template.Rules = make([]*do.FreightTemplateRule, 0, len(ruleModels))
+	if err := util.CopyProperties(&template.Rules, &ruleModels); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return template, nil
+}
+
+// checkFreightTemplateRules 检查模板的计费规则: 同一个省份只能出现在一个规则中, 最多只能有一个适用于其他省份的规则
+func checkFreightTemplateRules(rules []*do.FreightTemplateRule) error {
+	provinceSet := make(map[string]struct{})
+	otherRules := 0
+	for _, rule := range rules {
+		if rule.Provinces == "" {
+			otherRules++
+			continue
+		}
+		for _, province := range strings.Split(rule.Provinces, ",") {
+			if _, ok := provinceSet[province]; ok || province == "" {
+				return errcode.ErrFreightTemplateParams
+			}
+			provinceSet[province] = struct{}{}
+		}
+	}
+	if otherRules > 1 {
+		return errcode.ErrFreightTemplateParams
+	}
+	return nil
+}
@@ -0,0 +1,409 @@
+package domainservice
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/hd2yao/go-mall/common/app"
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/logger"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/dal/cache"
+	"github.com/hd2yao/go-mall/dal/dao"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+const (
+	groupBuyShareCodeLength = 10  // 拼团分享码的长度
+	groupBuyExpireBatchSize = 100 // 每次处理的过期拼团数和拼团失败的成员数
+)
+
+type GroupBuyDomainSvc struct {
+	ctx         context.Context
+	groupBuyDao *dao.GroupBuyDao
+}
+
+func NewGroupBuyDomainSvc(ctx context.Context) *GroupBuyDomainSvc {
+	return &GroupBuyDomainSvc{
+		ctx:         ctx,
+		groupBuyDao: dao.NewGroupBuyDao(ctx),
+	}
+}
+
+// CreateActivity 管理后台创建拼团活动
+func (gds *GroupBuyDomainSvc) CreateActivity(activity *do.GroupBuyActivity) error {
+	if activity.GroupPrice <= 0 || activity.GroupSize < 2 || activity.TimeLimit <= 0 ||
+		!activity.EndTime.After(activity.StartTime) || !activity.EndTime.After(time.Now()) {
+		return errcode.ErrGroupBuyParams
+	}
+	commodity := NewCommodityDomainSvc(gds.ctx).GetCommodityInfo(activity.CommodityId)
+	if commodity == nil || commodity.ID == 0 {
+		return errcode.ErrCommodityNotExists
+	}
+	activity.Status = enum.GroupBuyActivityActive
+	if err := gds.groupBuyDao.CreateActivity(activity); err != nil {
+		return errcode.Wrap("CreateGroupBuyActivityError", err)
+	}
+	return nil
+}
+
+// StopActivity 停止拼团活动, 停止后不能再开团, 已经开的团在成团时限内还可以参团
+func (gds *GroupBuyDomainSvc) StopActivity(activityId int64) error {
+	activityModel, err := gds.groupBuyDao.GetActivity(activityId)
+	if err != nil {
+		return errcode.Wrap("StopGroupBuyActivityError", err)
+	}
+	if activityModel == nil {
+		return errcode.ErrGroupBuyActivityNotExists
+	}
+	if err = gds.groupBuyDao.UpdateActivityStatus(activityId, enum.GroupBuyActivityStopped); err != nil {
+		return errcode.Wrap("StopGroupBuyActivityError", err)
+	}
+	return nil
+}
+
+// GetActivityList 管理后台分页获取拼团活动列表
+func (gds *GroupBuyDomainSvc) GetActivityList(pagination *app.Pagination) ([]*do.GroupBuyActivity, error) {
+	activityModels, totalRows, err := gds.groupBuyDao.GetActivityList(pagination.Offset(), pagination.GetPageSize())
+	if err != nil {
+		return nil, errcode.Wrap("GetGroupBuyActivityListError", err)
+	}
+	pagination.SetTotalRows(int(totalRows))
+	activities := make([]*do.GroupBuyActivity, 0, len(activityModels))
+	if err = util.CopyProperties(&activities, &activityModels); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return activities, nil
+}
+
+// GetOngoingActivities 获取正在进行的拼团活动
+func (gds *GroupBuyDomainSvc) GetOngoingActivities() ([]*do.GroupBuyActivity, error) {
+	activityModels, err := gds.groupBuyDao.GetOngoingActivities(time.Now())
+	if err != nil {
+		return nil, errcode.Wrap("GetOngoingGroupBuyActivitiesError", err)
+	}
+	activities := make([]*do.GroupBuyActivity, 0, len(activityModels))
+	if err = util.CopyProperties(&activities, &activityModels); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return activities, nil
+}
+
+// getActivity 获取拼团活动, 活动不存在时返回 ErrGroupBuyActivityNotExists
+func (gds *GroupBuyDomainSvc) getActivity(activityId int64) (*do.GroupBuyActivity, error) {
+	activityModel, err := gds.groupBuyDao.GetActivity(activityId)
+	if err != nil {
+		return nil, errcode.Wrap("GetGroupBuyActivityError", err)
+	}
+	if activityModel == nil {
+		return nil, errcode.ErrGroupBuyActivityNotExists
+	}
+	activity := new(do.GroupBuyActivity)
+	if err = util.CopyProperties(activity, activityModel); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return activity, nil
+}
+
+// OpenGroup 用户按拼团价开团, 创建拼团和团长的订单, 其他用户通过返回的拼团中的分享码参团
+func (gds *GroupBuyDomainSvc) OpenGroup(userId, activityId int64, commodityNum int, userAddressId int64) (*do.GroupBuyGroup, *do.Order, error) {
+	activity, err := gds.getActivity(activityId)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	if activity.Status != enum.GroupBuyActivityActive || !activity.Ongoing(now) {
+		return nil, nil, errcode.ErrGroupBuyActivityNotOngoing
+	}
+	userAddress, err := NewUserDomainSvc(gds.ctx).GetUserSingleAddress(userId, userAddressId)
+	if err != nil {
+		return nil, nil, err
+	}
+	orderDomainSvc := NewOrderDomainSvc(gds.ctx)
+	order, err := orderDomainSvc.newGroupBuyOrder(activity, commodityNum, userAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+	group := &do.GroupBuyGroup{
+		ActivityId:   activity.ID,
+		CommodityId:  activity.CommodityId,
+		ShareCode:    util.RandomString(groupBuyShareCodeLength),
+		LeaderUserId: userId,
+		GroupSize:    activity.GroupSize,
+		MemberNum:    1,
+		ExpireAt:     now.Add(time.Duration(activity.TimeLimit) * time.Minute),
+		Status:       enum.GroupBuyForming,
+	}
+
+	err = dao.DBMaster().Transaction(func(tx *gorm.DB) error {
+		if err := orderDomainSvc.createGroupBuyOrder(tx, order); err != nil {
+			return err
+		}
+		if err := gds.groupBuyDao.CreateGroup(tx, group); err != nil {
+			return err
+		}
+		return gds.groupBuyDao.CreateMember(tx, &do.GroupBuyMember{
+			GroupId:  group.ID,
+			UserId:   userId,
+			OrderNo:  order.OrderNo,
+			IsLeader: 1,
+			Status:   enum.GroupBuyMemberJoined,
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	orderDomainSvc.ScheduleUnpaidOrderClose(order.OrderNo)
+	return group, order, nil
+}
+
+// JoinGroup 用户通过分享码参团, 按拼团价创建订单
+// 拼团中没有退出的成员数达到成团人数后不能再参团, 成员的订单取消或超时未支付关闭后空出的名额可以由其他用户参团
+func (gds *GroupBuyDomainSvc) JoinGroup(userId int64, shareCode string, commodityNum int, userAddressId int64) (*do.GroupBuyGroup, *do.Order, error) {
+	groupModel, err := gds.groupBuyDao.GetGroupByShareCode(shareCode)
+	if err != nil {
+		return nil, nil, errcode.Wrap("JoinGroupBuyError", err)
+	}
+	if groupModel == nil {
+		return nil, nil, errcode.ErrGroupBuyNotExists
+	}
+	if !groupJoinable(groupModel.Status, groupModel.MemberNum, groupModel.GroupSize, groupModel.ExpireAt) {
+		return nil, nil, errcode.ErrGroupBuyNotJoinable
+	}
+	// 活动停止后已经开的团还可以参团, 参团的价格和开团时一样按活动的拼团价计算
+	activity, err := gds.getActivity(groupModel.ActivityId)
+	if err != nil {
+		return nil, nil, err
+	}
+	userAddress, err := NewUserDomainSvc(gds.ctx).GetUserSingleAddress(userId, userAddressId)
+	if err != nil {
+		return nil, nil, err
+	}
+	orderDomainSvc := NewOrderDomainSvc(gds.ctx)
+	order, err := orderDomainSvc.newGroupBuyOrder(activity, commodityNum, userAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	group := new(do.GroupBuyGroup)
+	err = dao.DBMaster().Transaction(func(tx *gorm.DB) error {
+		// 先创建订单扣减商品库存再锁定拼团, 和取消订单时加锁的顺序一致
+		if err := orderDomainSvc.createGroupBuyOrder(tx, order); err != nil {
+			return err
+		}
+		lockedGroup, err := gds.groupBuyDao.LockGroup(tx, groupModel.ID)
+		if err != nil {
+			return err
+		}
+		if !groupJoinable(lockedGroup.Status, lockedGroup.MemberNum, lockedGroup.GroupSize, lockedGroup.ExpireAt) {
+			return errcode.ErrGroupBuyNotJoinable
+		}
+		joined, err := gds.groupBuyDao.HasActiveMember(tx, lockedGroup.ID, userId)
+		if err != nil {
+			return err
+		}
+		if joined {
+			return errcode.ErrGroupBuyAlreadyJoined
+		}
+		err = gds.groupBuyDao.CreateMember(tx, &do.GroupBuyMember{
+			GroupId: lockedGroup.ID,
+			UserId:  userId,
+			OrderNo: order.OrderNo,
+			Status:  enum.GroupBuyMemberJoined,
+		})
+		if err != nil {
+			return err
+		}
+		lockedGroup.MemberNum++
+		if err = gds.groupBuyDao.UpdateGroup(tx, lockedGroup); err != nil {
+			return err
+		}
+		return util.CopyProperties(group, lockedGroup)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	orderDomainSvc.ScheduleUnpaidOrderClose(order.OrderNo)
+	return group, order, nil
+}
+
+// groupJoinable 拼团是否还可以参团
+func groupJoinable(status, memberNum, groupSize int, expireAt time.Time) bool {
+	return status == enum.GroupBuyForming && memberNum < groupSize && time.Now().Before(expireAt)
+}
+
+// GetGroup 用分享码获取拼团和它的成员
+func (gds *GroupBuyDomainSvc) GetGroup(shareCode string) (*do.GroupBuyGroup, error) {
+	groupModel, err := gds.groupBuyDao.GetGroupByShareCode(shareCode)
+	if err != nil {
+		return nil, errcode.Wrap("GetGroupBuyError", err)
+	}
+	if groupModel == nil {
+		return nil, errcode.ErrGroupBuyNotExists
+	}
+	group := new(do.GroupBuyGroup)
+	if err = util.CopyProperties(group, groupModel); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	memberModels, err := gds.groupBuyDao.GetGroupMembers(group.ID)
+	if err != nil {
+		return nil, errcode.Wrap("GetGroupBuyError", err)
+	}
+	if err = util.CopyProperties(&group.Members, &memberModels); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return group, nil
+}
+
+// getOrderGroup 获取订单所在的拼团, 不是拼团订单时返回 nil
+func (gds *GroupBuyDomainSvc) getOrderGroup(orderNo string) (*do.GroupBuyGroup, error) {
+	member, err := gds.groupBuyDao.GetMemberByOrderNo(orderNo)
+	if err != nil || member == nil {
+		return nil, err
+	}
+	groupModel, err := gds.groupBuyDao.GetGroup(member.GroupId)
+	if err != nil || groupModel == nil {
+		return nil, err
+	}
+	group := new(do.GroupBuyGroup)
+	if err = util.CopyProperties(group, groupModel); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return group, nil
+}
+
+// CheckOrderPayable 发起支付前检查拼团订单所在的拼团是否还在拼团中, 拼团已经结束时不能再支付, 不是拼团订单时不做检查
+func (gds *GroupBuyDomainSvc) CheckOrderPayable(orderNo string) error {
+	group, err := gds.getOrderGroup(orderNo)
+	if err != nil {
+		return errcode.Wrap("CheckGroupBuyOrderPayableError", err)
+	}
+	if group != nil && (group.Status != enum.GroupBuyForming || !time.Now().Before(group.ExpireAt)) {
+		return errcode.ErrGroupBuyNotJoinable
+	}
+	return nil
+}
+
+// CheckOrderGroupSucceeded 拼团订单在拼团成功前不能申请退款, 也不能检货发货, 不是拼团订单时不做检查
+func (gds *GroupBuyDomainSvc) CheckOrderGroupSucceeded(orderNo string) error {
+	group, err := gds.getOrderGroup(orderNo)
+	if err != nil {
+		return errcode.Wrap("CheckGroupBuyOrderSucceededError", err)
+	}
+	if group != nil && group.Status != enum.GroupBuySuccess {
+		return errcode.ErrGroupBuyNotSucceeded
+	}
+	return nil
+}
+
+// MarkOrderMemberPaid 在订单支付成功的事务中把拼团成员设置为已支付, 成团时限内支付的成员达到成团人数时拼团成功
+// 拼团已经失败后才支付的成员由处理过期拼团的任务退款
+func (gds *GroupBuyDomainSvc) MarkOrderMemberPaid(tx *gorm.DB, orderNo string, paidAt time.Time) error {
+	member, err := gds.groupBuyDao.LockMemberByOrderNo(tx, orderNo)
+	if err != nil {
+		return errcode.Wrap("MarkGroupBuyMemberPaidError", err)
+	}
+	if member == nil || member.Status != enum.GroupBuyMemberJoined {
+		return nil
+	}
+	if _, err = gds.groupBuyDao.UpdateMemberFromStatus(tx, member.ID, enum.GroupBuyMemberJoined, map[string]interface{}{
+		"status": enum.GroupBuyMemberPaid,
+	}); err != nil {
+		return errcode.Wrap("MarkGroupBuyMemberPaidError", err)
+	}
+	group, err := gds.groupBuyDao.LockGroup(tx, member.GroupId)
+	if err != nil {
+		return errcode.Wrap("MarkGroupBuyMemberPaidError", err)
+	}
+	group.PaidNum++
+	if group.Status == enum.GroupBuyForming && group.PaidNum >= group.GroupSize && !paidAt.After(group.ExpireAt) {
+		group.Status = enum.GroupBuySuccess
+		logger.New(gds.ctx).Info("GroupBuySucceeded", "groupId", group.ID, "shareCode", group.ShareCode)
+	}
+	if err = gds.groupBuyDao.UpdateGroup(tx, group); err != nil {
+		return errcode.Wrap("MarkGroupBuyMemberPaidError", err)
+	}
+	return nil
+}
+
+// ReleaseOrderMember 在订单取消或关闭的事务中让还没支付的拼团成员退出拼团, 空出的名额可以由其他用户参团
+func (gds *GroupBuyDomainSvc) ReleaseOrderMember(tx *gorm.DB, orderNo string) error {
+	member, err := gds.groupBuyDao.LockMemberByOrderNo(tx, orderNo)
+	if err != nil {
+		return errcode.Wrap("ReleaseGroupBuyMemberError", err)
+	}
+	if member == nil || member.Status != enum.GroupBuyMemberJoined {
+		return nil
+	}
+	if _, err = gds.groupBuyDao.UpdateMemberFromStatus(tx, member.ID, enum.GroupBuyMemberJoined, map[string]interface{}{
+		"status": enum.GroupBuyMemberQuit,
+	}); err != nil {
+		return errcode.Wrap("ReleaseGroupBuyMemberError", err)
+	}
+	group, err := gds.groupBuyDao.LockGroup(tx, member.GroupId)
+	if err != nil {
+		return errcode.Wrap("ReleaseGroupBuyMemberError", err)
+	}
+	group.MemberNum--
+	if err = gds.groupBuyDao.UpdateGroup(tx, group); err != nil {
+		return errcode.Wrap("ReleaseGroupBuyMemberError", err)
+	}
+	return nil
+}
+
+// ExpireGroups 处理超过成团时限的拼团, 通过 Redis 锁保证在执行间隔内只有一个服务实例执行
+// 1. 把过了成团时限还在拼团中的拼团设置为拼团失败
+// 2. 关闭拼团失败的拼团中还没支付的成员的订单, 为已经支付的成员退款
+func (gds *GroupBuyDomainSvc) ExpireGroups(scanInterval time.Duration) error {
+	locked, err := cache.LockGroupBuyExpireScan(gds.ctx, scanInterval)
+	if err != nil {
+		return errcode.Wrap("ExpireGroupBuyError", err)
+	}
+	if !locked {
+		return nil
+	}
+	log := logger.New(gds.ctx)
+
+	groupIds, err := gds.groupBuyDao.GetExpiredFormingGroupIds(time.Now(), groupBuyExpireBatchSize)
+	if err != nil {
+		return errcode.Wrap("ExpireGroupBuyError", err)
+	}
+	for _, groupId := range groupIds {
+		if err = gds.failGroup(groupId); err != nil {
+			log.Error("FailGroupBuyError", "groupId", groupId, "err", err)
+		}
+	}
+
+	memberModels, err := gds.groupBuyDao.GetFailedGroupUnsettledMembers(groupBuyExpireBatchSize)
+	if err != nil {
+		return errcode.Wrap("ExpireGroupBuyError", err)
+	}
+	members := make([]*do.GroupBuyMember, 0, len(memberModels))
+	if err = util.CopyProperties(&members, &memberModels); err != nil {
+		return errcode.ErrCoverData.WithCause(err)
+	}
+	orderDomainSvc := NewOrderDomainSvc(gds.ctx)
+	for _, member := range members {
+		// 处理失败的成员在下次执行时重试
+		if err = orderDomainSvc.settleGroupBuyFailedOrder(member); err != nil {
+			log.Error("SettleGroupBuyFailedOrderError", "orderNo", member.OrderNo, "err", err)
+		}
+	}
+	return nil
+}
+
+// failGroup 把过了成团时限还在拼团中的拼团设置为拼团失败
+func (gds *GroupBuyDomainSvc) failGroup(groupId int64) error {
+	return dao.DBMaster().Transaction(func(tx *gorm.DB) error {
+		group, err := gds.groupBuyDao.LockGroup(tx, groupId)
+		if err != nil || group == nil || group.Status != enum.GroupBuyForming || time.Now().Before(group.ExpireAt) {
+			return err
+		}
+		group.Status = enum.GroupBuyFailed
+		logger.New(gds.ctx).Info("GroupBuyFailed", "groupId", group.ID, "paidNum", group.PaidNum, "groupSize", group.GroupSize)
+		return gds.groupBuyDao.UpdateGroup(tx, group)
+	})
+}
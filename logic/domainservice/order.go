@@ -2,18 +2,15 @@ package domainservice
 
 import (
 	"context"
-	"fmt"
-	"time"
 
 	"github.com/samber/lo"
+	"gorm.io/gorm"
 
 	"github.com/hd2yao/go-mall/common/app"
 	"github.com/hd2yao/go-mall/common/enum"
 	"github.com/hd2yao/go-mall/common/errcode"
 	"github.com/hd2yao/go-mall/common/util"
 	"github.com/hd2yao/go-mall/dal/dao"
-	"github.com/hd2yao/go-mall/dal/model"
-	"github.com/hd2yao/go-mall/library"
 	"github.com/hd2yao/go-mall/logic/do"
 )
 
@@ -29,28 +26,38 @@ func NewOrderDomainSvc(ctx context.Context) *OrderDomainSvc {
 	}
 }
 
-// CreateOrder 创建订单
-func (ods *OrderDomainSvc) CreateOrder(items []*do.ShoppingCartItem, userAddress *do.UserAddressInfo) (*do.Order, error) {
+// CreateOrder 用购物车中的购物项创建订单, 订单创建成功后删除购物车中的这些购物项
+// checkoutToken 为查看账单时返回的结算凭证, 重新计算的账单和凭证中的不一致时不创建订单
+// couponId 为用户选择的优惠券, 需要和查看账单时传的一致, 0 表示自动选择, enum.BillNoCoupon 表示不使用优惠券
+func (ods *OrderDomainSvc) CreateOrder(items []*do.ShoppingCartItem, userAddress *do.UserAddressInfo, checkoutToken string, couponId int64) (*do.Order, error) {
+	return ods.createOrder(items, userAddress, checkoutToken, couponId, true)
+}
+
+// CreateBuyNowOrder 立即购买, 不经过购物车直接用商品和购买数量创建订单, 不会改动用户的购物车
+// checkoutToken 为立即购买查看账单时返回的结算凭证, 和购物车下单一样校验
+func (ods *OrderDomainSvc) CreateBuyNowOrder(items []*do.ShoppingCartItem, userAddress *do.UserAddressInfo, checkoutToken string, couponId int64) (*do.Order, error) {
+	return ods.createOrder(items, userAddress, checkoutToken, couponId, false)
+}
+
+// createOrder 创建订单, 重新计算的账单和结算凭证中的不一致时不创建订单, fromCart 为 true 时在创建订单的事务中删除购物车中购买的购物项
+func (ods *OrderDomainSvc) createOrder(items []*do.ShoppingCartItem, userAddress *do.UserAddressInfo, checkoutToken string, couponId int64, fromCart bool) (*do.Order, error) {
 	// 计算订单商品的总价、优惠金额等结算信息
-	billInfo, err := NewCartBillChecker(items, userAddress.UserId).GetBill()
+	billChecker := NewCartBillChecker(ods.ctx, items, userAddress.UserId, userAddress)
+	billChecker.SelectedCouponId = couponId
+	billInfo, err := billChecker.GetBill()
 	if err != nil {
-		return nil, errcode.Wrap("CreateOrderError", err)
+		return nil, err
 	}
 	if billInfo.OriginalTotalPrice <= 0 {
 		return nil, errcode.ErrCartItemParam
 	}
-
-	order := do.OrderNew()
-	order.UserId = userAddress.UserId
-	order.OrderNo = util.GenOrderNo(order.UserId)
-	order.BillMoney = billInfo.OriginalTotalPrice
-	order.PayMoney = billInfo.TotalPrice
-	order.OrderStatus = enum.OrderStatusCreated
-	if err = util.CopyProperties(&order.Items, &items); err != nil {
-		return nil, errcode.ErrCoverData.WithCause(err)
+	if err = ods.VerifyCheckoutToken(checkoutToken, userAddress.UserId, items, billInfo); err != nil {
+		return nil, err
 	}
-	if err = util.CopyProperties(&order.Address, &userAddress); err != nil {
-		return nil, errcode.ErrCoverData.WithCause(err)
+
+	order, err := ods.newOrder(items, userAddress, billInfo)
+	if err != nil {
+		return nil, err
 	}
 
 	// 手动开启事务
@@ -71,22 +78,35 @@ func (ods *OrderDomainSvc) CreateOrder(items []*do.ShoppingCartItem, userAddress
 	if err != nil {
 		return nil, err
 	}
-	// 2. 删除购物车中的购买的购物项
-	cartDao := dao.NewCartDao(ods.ctx)
-	cartItemIds := lo.Map(items, func(item *do.ShoppingCartItem, index int) int64 {
-		return item.CartItemId
-	})
-	err = cartDao.DeleteMultiCartItemInTx(tx, cartItemIds)
-	if err != nil {
-		return nil, err
+	// 2. 删除购物车中的购买的购物项, 立即购买的订单没有购物项
+	if fromCart {
+		cartDao := dao.NewCartDao(ods.ctx)
+		cartItemIds := lo.Map(items, func(item *do.ShoppingCartItem, index int) int64 {
+			return item.CartItemId
+		})
+		err = cartDao.DeleteMultiCartItemInTx(tx, cartItemIds)
+		if err != nil {
+			return nil, err
+		}
 	}
 	// 3. 记录 Coupon 使用信息 并 锁定优惠券，等支付成功后再核销
 	if billInfo.Coupon.CouponId > 0 {
-		// couponDao.LockCoupon(tx, coupon)
+		err = NewCouponDomainSvc(ods.ctx).LockOrderCoupon(tx, billInfo.Coupon.CouponId, order.UserId, order.OrderNo)
+		if err != nil {
+			return nil, err
+		}
 	}
-	// 4. 记录满减券使用信息
+	// 4. 记录满减活动参加信息, 订单取消或关闭时释放
 	if billInfo.Discount.DiscountId > 0 {
-		// discountDao.recordDiscount(tx, discount)
+		err = NewDiscountDomainSvc(ods.ctx).RecordOrderDiscount(tx, &do.OrderDiscountRecord{
+			CampaignId:    billInfo.Discount.DiscountId,
+			UserId:        order.UserId,
+			OrderNo:       order.OrderNo,
+			DiscountMoney: billInfo.Discount.DiscountMoney,
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 	// 5. 减少订单购买商品的库存 -- 会锁行记录，把这一步放到创建订单步骤的最后，减少行记录加锁的时间
 	commodityDao := dao.NewCommodityDao(ods.ctx)
@@ -101,6 +121,37 @@ func (ods *OrderDomainSvc) CreateOrder(items []*do.ShoppingCartItem, userAddress
 	return order, nil
 }
 
+// newOrder 按结算信息生成待创建的订单, 每个订单明细记录分摊到的减免金额
+func (ods *OrderDomainSvc) newOrder(items []*do.ShoppingCartItem, userAddress *do.UserAddressInfo, billInfo *do.CartBillInfo) (*do.Order, error) {
+	var err error
+	order := do.OrderNew()
+	order.UserId = userAddress.UserId
+	if order.OrderNo, err = genSerialNo(order.UserId); err != nil {
+		return nil, err
+	}
+	order.BillMoney = billInfo.OriginalTotalPrice
+	order.PayMoney = billInfo.TotalPrice
+	order.FreightMoney = billInfo.FreightMoney
+	order.OrderStatus = enum.OrderStatusCreated
+	if err = util.CopyProperties(&order.Items, &items); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	// 保存每个订单明细分摊到的减免金额, 部分退款时按它计算可退金额
+	for i, item := range order.Items {
+		billItem := billInfo.Items[i]
+		item.BillMoney = billItem.BillMoney
+		item.VipDiscountMoney = billItem.VipDiscountMoney
+		item.CouponDiscountMoney = billItem.CouponDiscountMoney
+		item.DiscountMoney = billItem.DiscountMoney
+		item.PayMoney = billItem.PayMoney
+	}
+	if err = util.CopyProperties(&order.Address, &userAddress); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+
+	return order, nil
+}
+
 // GetUserOrders 获取用户订单
 func (ods *OrderDomainSvc) GetUserOrders(userId int64, pagination *app.Pagination) ([]*do.Order, error) {
 	offset := pagination.Offset()
@@ -188,66 +239,49 @@ func (ods *OrderDomainSvc) CancelUserOrder(orderNo string, userId int64) error {
 	if err != nil {
 		return err
 	}
-	if order.OrderStatus >= enum.OrderStatusPaid {
+	if !CanTransitOrderStatus(order.OrderStatus, enum.OrderStatusUserQuit, enum.OrderActorUser) {
 		// 已经支付，用户不能取消 -- 需要申请退款
 		return errcode.ErrOrderCanNotBeChanged
 	}
 
-	// 更新订单状态为用户主动取消
-	err = ods.orderDao.UpdateOrderStatus(order.ID, enum.OrderStatusUserQuit)
+	tx := dao.DBMaster().Begin()
+	panicked := true
+	defer func() {
+		if err != nil || panicked {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	// 更新订单状态为用户主动取消, 订单同时被超时关闭或者支付成功时不再取消, 保证库存只恢复一次
+	statusLog := newOrderStatusLog(order, enum.OrderStatusUserQuit, enum.OrderActorUser, userId, "用户取消订单")
+	updated, err := ods.TransitOrderStatus(tx, statusLog, nil)
 	if err != nil {
-		return errcode.Wrap("CancelUserOrderError", err)
+		return err
+	}
+	if !updated {
+		err = errcode.ErrOrderCanNotBeChanged
+		return err
 	}
 
-	// 恢复商品库存
-	commodityDao := dao.NewCommodityDao(ods.ctx)
-	err = commodityDao.RecoverOrderCommodityStuck(order.Items)
-	return err
-}
+	// 恢复商品库存, 秒杀订单的库存还给秒杀场次
+	if err = ods.releaseOrderCommodityStock(tx, order); err != nil {
+		return err
+	}
+	// 释放订单锁定的优惠券和参加的满减活动, 拼团订单的成员退出拼团
+	if err = NewCouponDomainSvc(ods.ctx).ReleaseOrderCoupon(tx, order.OrderNo); err != nil {
+		return err
+	}
+	if err = NewDiscountDomainSvc(ods.ctx).ReleaseOrderDiscount(tx, order.OrderNo); err != nil {
+		return err
+	}
+	if err = NewGroupBuyDomainSvc(ods.ctx).ReleaseOrderMember(tx, order.OrderNo); err != nil {
+		return err
+	}
 
-func (ods *OrderDomainSvc) CreateOrderWxPay(orderNo string, userId int64) (payInfo *library.WxPayInvokeInfo, err error) {
-	order, err := ods.GetSpecifiedUserOrder(orderNo, userId)
-	if err != nil {
-		return
-	}
-	if order.OrderStatus != enum.OrderStatusCreated { // 订单不是初始状态，不能发起支付
-		err = errcode.ErrOrderParams
-		return
-	}
-
-	order.PayType = enum.PayTypeNotConfirmed
-	order.OrderStatus = enum.OrderStatusUnPaid
-	order.PayState = enum.PayStateUnPaid
-
-	orderModel := new(model.Order)
-	if err = util.CopyProperties(orderModel, order); err != nil {
-		err = errcode.ErrCoverData.WithCause(err)
-		return
-	}
-	if err = ods.orderDao.UpdateOrder(orderModel); err != nil {
-		err = errcode.Wrap("CreteOrderWxPayError", err)
-		return
-	}
-
-	// 用userId获取对应的Openid, 这里先Mock一个
-	//openId := "QsudfrhgrDYDEEA1344EF"
-	//wxPayLib := library.NewWxPayLib(ods.ctx, library.WxPayConfig{
-	//	AppId:           config.App.WechatPay.AppId,
-	//	MchId:           config.App.WechatPay.MchId,
-	//	PrivateSerialNo: config.App.WechatPay.PrivateSerialNo,
-	//	AesKey:          config.App.WechatPay.AesKey,
-	//	NotifyUrl:       config.App.WechatPay.NotifyUrl,
-	//})
-	//payInfo, err = wxPayLib.CreateOrderPay(order, openId)
-	payInfo = &library.WxPayInvokeInfo{
-		AppId:     "123456",
-		TimeStamp: fmt.Sprintf("%v", time.Now().Unix()),
-		NonceStr:  "e61463f8efa94090b1f366cccfbbb444",
-		Package:   "prepay_id=wx21201855730335ac86f8c43d1889123400",
-		SignType:  "RSA",
-		PaySign:   "oR9d8PuhnIc+YZ8cBHFCwfgpaK9gd7vaRvkYD7rthRAZ/X+QBhcCYL21N7cHCTUxbQ+EAt6Uy+lwSN22f5YZvI45MLko8Pfso0jm46v5hqcVwrk6uddkGuT+Cdvu4WBqDzaDjnNa5UK3GfE1Wfl2gHxIIY5lLdUgWFts17D4WuolLLkiFZV+JSHMvH7eaLdT9N5GBovBwu5yYKUR7skR8Fu+LozcSqQixnlEZUfyE55feLOQTUYzLmR9pNtPbPsu6WVhbNHMS3Ss2+AehHvz+n64GDmXxbX++IOBvm2olHu3PsOUGRwhudhVf7UcGcunXt8cqNjKNqZLhLw4jq/xDg==",
-	}
-	return
+	panicked = false
+	return nil
 }
 
 // StartOrderWxPay 把订单设置为开始支付的状态, 支付方式为微信支付
@@ -255,26 +289,64 @@ func (ods *OrderDomainSvc) StartOrderWxPay(orderNo string, userId int64) error {
 	return ods.setOrderStartPay(orderNo, userId, enum.PayTypeWxPay)
 }
 
+// StartOrderAliPay 把订单设置为开始支付的状态, 支付方式为支付宝
+func (ods *OrderDomainSvc) StartOrderAliPay(orderNo string, userId int64) error {
+	return ods.setOrderStartPay(orderNo, userId, enum.PayTypeAliPay)
+}
+
 // setOrderStartPay 把订单设置为开始支付的状态
+// 已经发起过支付的订单 (预支付失败或者用户更换了支付方式、支付场景) 可以重新发起支付
 func (ods *OrderDomainSvc) setOrderStartPay(orderNo string, userId int64, payType int) error {
 	order, err := ods.GetSpecifiedUserOrder(orderNo, userId)
 	if err != nil {
 		return err
 	}
-	if order.OrderStatus != enum.OrderStatusCreated { // 订单不是初始状态，不能发起支付
+	if order.OrderStatus == enum.OrderStatusUnPaid {
+		return ods.switchOrderPayType(order, payType)
+	}
+	if !CanTransitOrderStatus(order.OrderStatus, enum.OrderStatusUnPaid, enum.OrderActorUser) { // 订单不是初始状态，不能发起支付
 		return errcode.ErrOrderParams
 	}
 
-	order.PayType = payType
-	order.OrderStatus = enum.OrderStatusUnPaid // 订单状态--待支付
-	order.PayState = enum.PayStateUnPaid       // 支付状态--未支付
-	orderModel := new(model.Order)
-	if err = util.CopyProperties(orderModel, order); err != nil {
-		return errcode.ErrCoverData.WithCause(err)
+	// 订单状态为已创建时才更新, 防止把已经超时关闭或者用户取消的订单重新设置为待支付
+	return dao.DBMaster().Transaction(func(tx *gorm.DB) error {
+		statusLog := newOrderStatusLog(order, enum.OrderStatusUnPaid, enum.OrderActorUser, userId, "用户发起支付")
+		updated, err := ods.TransitOrderStatus(tx, statusLog, map[string]interface{}{
+			"pay_type":  payType,
+			"pay_state": enum.PayStateUnPaid, // 支付状态--未支付
+		})
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return errcode.ErrOrderParams
+		}
+		return nil
+	})
+}
+
+// switchOrderPayType 重新发起支付时更换订单的支付方式
+// 更换支付方式前先关闭原支付方式在支付平台上的交易, 防止用户在两个支付平台都完成支付
+func (ods *OrderDomainSvc) switchOrderPayType(order *do.Order, payType int) error {
+	if order.PayType == payType {
+		return nil
 	}
-	if err = ods.orderDao.UpdateOrder(orderModel); err != nil {
-		return errcode.Wrap("CreteOrderWxPayError", err)
+	paid, err := ods.closeOrderPayTrade(order)
+	if err != nil {
+		return err
+	}
+	if paid {
+		// 原支付方式的交易已经支付成功, 订单不需要再支付
+		return errcode.ErrOrderParams
+	}
+	updated, err := ods.orderDao.UpdateOrderFromStatus(dao.DBMaster(), order.ID, enum.OrderStatusUnPaid, map[string]interface{}{
+		"pay_type": payType,
+	})
+	if err != nil {
+		return errcode.Wrap("SwitchOrderPayTypeError", err)
+	}
+	if !updated {
+		return errcode.ErrOrderParams
 	}
-
 	return nil
 }
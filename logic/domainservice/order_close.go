@@ -0,0 +1,191 @@
+package domainservice
+
+import (
+	"time"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/logger"
+	"github.com/hd2yao/go-mall/config"
+	"github.com/hd2yao/go-mall/dal/cache"
+	"github.com/hd2yao/go-mall/dal/dao"
+	"github.com/hd2yao/go-mall/library"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+const (
+	defaultUnpaidCloseTimeout = 30 * time.Minute
+	orderCloseBatchSize       = 100         // 每次从关单延迟队列中取出的订单数
+	orderCloseRetryDelay      = time.Minute // 关单失败后重新放回延迟队列的等待时间
+	unpaidOrderScanLimit      = 500         // 每次从数据库扫描的超时未支付订单数
+)
+
+// UnpaidCloseTimeout 未支付订单自动关闭的超时时间
+func UnpaidCloseTimeout() time.Duration {
+	if config.App.Order.UnpaidCloseTimeout > 0 {
+		return config.App.Order.UnpaidCloseTimeout
+	}
+	return defaultUnpaidCloseTimeout
+}
+
+// ScheduleUnpaidOrderClose 把新创建的订单放入关单延迟队列, 到了超时时间还没支付的订单会被自动关闭
+// 放入失败时只记录日志, 订单会在从数据库扫描超时未支付订单时被重新放入队列
+func (ods *OrderDomainSvc) ScheduleUnpaidOrderClose(orderNo string) {
+	closeAt := time.Now().Add(UnpaidCloseTimeout())
+	if err := cache.AddOrderToCloseQueue(ods.ctx, orderNo, closeAt); err != nil {
+		logger.New(ods.ctx).Error("ScheduleUnpaidOrderCloseError", "orderNo", orderNo, "err", err)
+	}
+}
+
+// CloseDueUnpaidOrders 从关单延迟队列中取出到了关闭时间的订单并关闭
+// 多个服务实例同时执行时每个订单只会被一个实例取出, 关闭失败的订单会重新放回队列稍后重试
+func (ods *OrderDomainSvc) CloseDueUnpaidOrders() error {
+	log := logger.New(ods.ctx)
+	orderNos, err := cache.PopDueCloseOrders(ods.ctx, time.Now(), orderCloseBatchSize)
+	if err != nil {
+		return errcode.Wrap("CloseDueUnpaidOrdersError", err)
+	}
+	for _, orderNo := range orderNos {
+		if err = ods.CloseTimeoutUnpaidOrder(orderNo); err != nil {
+			log.Error("CloseTimeoutUnpaidOrderError", "orderNo", orderNo, "err", err)
+			if err = cache.AddOrderToCloseQueue(ods.ctx, orderNo, time.Now().Add(orderCloseRetryDelay)); err != nil {
+				log.Error("RetryCloseUnpaidOrderError", "orderNo", orderNo, "err", err)
+			}
+		}
+	}
+	return nil
+}
+
+// CloseTimeoutUnpaidOrder 关闭超时未支付的订单并恢复商品库存
+func (ods *OrderDomainSvc) CloseTimeoutUnpaidOrder(orderNo string) error {
+	log := logger.New(ods.ctx)
+	orderModel, err := ods.orderDao.GetOrderByNo(orderNo)
+	if err != nil {
+		return errcode.Wrap("CloseTimeoutUnpaidOrderError", err)
+	}
+	if orderModel.ID == 0 {
+		log.Warn("CloseTimeoutUnpaidOrderNotFound", "orderNo", orderNo)
+		return nil
+	}
+	if !CanTransitOrderStatus(orderModel.OrderStatus, enum.OrderStatusUnpaidClose, enum.OrderActorSystem) {
+		// 订单已经支付或者已经被取消
+		return nil
+	}
+	if closeAt := orderModel.CreatedAt.Add(UnpaidCloseTimeout()); time.Now().Before(closeAt) {
+		// 还没到关闭时间(超时时间的配置变长了), 按新的关闭时间放回队列
+		return cache.AddOrderToCloseQueue(ods.ctx, orderNo, closeAt)
+	}
+	order, err := ods.GetSpecifiedUserOrder(orderNo, orderModel.UserId)
+	if err != nil {
+		return err
+	}
+
+	statusLog := newOrderStatusLog(order, enum.OrderStatusUnpaidClose, enum.OrderActorSystem, 0, "超时未支付自动关闭")
+	paid, err := ods.closeUnpaidOrder(order, statusLog)
+	if err != nil {
+		// 订单状态在查询之后被修改了(比如用户刚发起了支付)时也返回错误, 让订单放回队列稍后重新检查
+		return err
+	}
+	if paid {
+		return nil
+	}
+	log.Info("TimeoutUnpaidOrderClosed", "orderNo", orderNo)
+	return nil
+}
+
+// closeOrderPayTrade 关闭订单在当前支付方式的支付平台上的交易
+// 交易已经支付成功时把订单更新为已支付并返回 paid 为 true
+func (ods *OrderDomainSvc) closeOrderPayTrade(order *do.Order) (paid bool, err error) {
+	payQuerier, err := NewOrderPayQuerier(order.PayType)
+	if err != nil {
+		return false, err
+	}
+	payResult, err := payQuerier.QueryOrderPay(ods.ctx, order)
+	if err != nil {
+		return false, err
+	}
+	if payResult.Paid {
+		// 没有收到或者没有处理成功支付结果通知的订单, 按查询到的支付结果更新为已支付
+		logger.New(ods.ctx).Warn("UnpaidOrderAlreadyPaid", "orderNo", order.OrderNo, "payTransId", payResult.PayTransId)
+		return true, ods.SetOrderPaySuccess(order.OrderNo, order.PayType, payResult.PayTransId, payResult.PaidMoney, payResult.PaidAt)
+	}
+	// 预支付失败时支付平台上没有这笔交易, 不需要关闭
+	if !payResult.Closed && payResult.TradeState != library.WxTradeStateNotExist {
+		if err = payQuerier.CloseOrderPay(ods.ctx, order); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// closeUnpaidOrder 按状态变更记录 statusLog 关闭未支付的订单, 恢复商品库存并释放订单使用的优惠券和满减活动, 拼团订单的成员退出拼团
+// 已经发起支付的订单先向支付平台查询支付结果, 已经支付成功的订单更新为已支付并返回 paid 为 true, 否则先关闭支付平台的交易再关闭订单
+func (ods *OrderDomainSvc) closeUnpaidOrder(order *do.Order, statusLog *do.OrderStatusLog) (paid bool, err error) {
+	if order.OrderStatus == enum.OrderStatusUnPaid {
+		if paid, err = ods.closeOrderPayTrade(order); err != nil || paid {
+			return paid, err
+		}
+	}
+
+	tx := dao.DBMaster().Begin()
+	panicked := true
+	defer func() {
+		if err != nil || panicked {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	// 只有订单状态没有被并发的请求修改时才关闭, 保证库存只恢复一次
+	updated, err := ods.TransitOrderStatus(tx, statusLog, nil)
+	if err != nil {
+		return false, err
+	}
+	if !updated {
+		err = errcode.ErrOrderCanNotBeChanged
+		return false, err
+	}
+	if err = ods.releaseOrderCommodityStock(tx, order); err != nil {
+		return false, errcode.Wrap("CloseUnpaidOrderError", err)
+	}
+	if err = NewCouponDomainSvc(ods.ctx).ReleaseOrderCoupon(tx, order.OrderNo); err != nil {
+		return false, err
+	}
+	if err = NewDiscountDomainSvc(ods.ctx).ReleaseOrderDiscount(tx, order.OrderNo); err != nil {
+		return false, err
+	}
+	if err = NewGroupBuyDomainSvc(ods.ctx).ReleaseOrderMember(tx, order.OrderNo); err != nil {
+		return false, err
+	}
+
+	panicked = false
+	return false, nil
+}
+
+// EnqueueTimeoutUnpaidOrders 从数据库扫描超时未支付的订单放入关单延迟队列, 兜底放入队列失败或者在 Redis 中丢失的订单
+// 通过 Redis 锁保证在扫描间隔内只有一个服务实例执行扫描
+func (ods *OrderDomainSvc) EnqueueTimeoutUnpaidOrders(scanInterval time.Duration) error {
+	locked, err := cache.LockUnpaidOrderScan(ods.ctx, scanInterval)
+	if err != nil {
+		return errcode.Wrap("EnqueueTimeoutUnpaidOrdersError", err)
+	}
+	if !locked {
+		return nil
+	}
+
+	orderNos, err := ods.orderDao.GetTimeoutUnpaidOrderNos(time.Now().Add(-UnpaidCloseTimeout()), unpaidOrderScanLimit)
+	if err != nil {
+		return errcode.Wrap("EnqueueTimeoutUnpaidOrdersError", err)
+	}
+	now := time.Now()
+	for _, orderNo := range orderNos {
+		if err = cache.AddOrderToCloseQueueNX(ods.ctx, orderNo, now); err != nil {
+			return errcode.Wrap("EnqueueTimeoutUnpaidOrdersError", err)
+		}
+	}
+	if len(orderNos) > 0 {
+		logger.New(ods.ctx).Info("EnqueueTimeoutUnpaidOrders", "count", len(orderNos))
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package domainservice
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/dal/dao"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+// CreateFlashSaleOrder 为 Redis 预扣库存成功的秒杀请求创建订单
+// 商品按秒杀价购买, 不使用会员折扣、优惠券和满减活动, 只计算运费; 商品库存在创建场次时已经划给场次, 这里只增加场次的已售数量
+// 返回的业务错误会作为失败原因返回给用户
+func (ods *OrderDomainSvc) CreateFlashSaleOrder(request *do.FlashSaleRequest) (*do.Order, error) {
+	flashSaleDao := dao.NewFlashSaleDao(ods.ctx)
+	sessionModel, err := flashSaleDao.GetSession(request.SessionId)
+	if err != nil {
+		return nil, errcode.Wrap("CreateFlashSaleOrderError", err)
+	}
+	if sessionModel == nil {
+		return nil, errcode.ErrFlashSaleNotExists
+	}
+	userAddress, err := NewUserDomainSvc(ods.ctx).GetUserSingleAddress(request.UserId, request.UserAddressId)
+	if err != nil {
+		return nil, err
+	}
+	items, err := NewCartDomainSvc(ods.ctx).GetBuyNowItems([]*do.ShoppingCartItem{
+		{CommodityId: sessionModel.CommodityId, CommodityNum: request.CommodityNum},
+	}, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		item.CommoditySellingPrice = sessionModel.FlashPrice
+	}
+
+	billInfo, err := newFreightBillChecker(ods.ctx, items, request.UserId, userAddress).GetBill()
+	if err != nil {
+		return nil, err
+	}
+	order, err := ods.newOrder(items, userAddress, billInfo)
+	if err != nil {
+		return nil, err
+	}
+	record := &do.FlashSaleOrder{
+		RequestNo:    request.RequestNo,
+		SessionId:    request.SessionId,
+		UserId:       request.UserId,
+		CommodityId:  sessionModel.CommodityId,
+		CommodityNum: request.CommodityNum,
+		OrderNo:      order.OrderNo,
+		Status:       enum.FlashSaleOrderSuccess,
+	}
+
+	tx := dao.DBMaster().Begin()
+	panicked := true
+	defer func() {
+		if err != nil || panicked {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	// 1. 创建订单
+	if err = ods.orderDao.CreateOrder(tx, order); err != nil {
+		return nil, err
+	}
+	// 2. 保存下单记录, 请求号唯一, 同一个请求不会重复创建订单
+	if err = flashSaleDao.CreateOrderRecord(tx, record); err != nil {
+		return nil, err
+	}
+	// 3. 增加场次的已售数量, 场次已经结束时不再创建订单 -- 会锁场次的行记录, 放到最后减少加锁的时间
+	incremented, err := flashSaleDao.IncrSessionSold(tx, request.SessionId, request.CommodityNum)
+	if err != nil {
+		return nil, err
+	}
+	if !incremented {
+		err = errcode.ErrFlashSaleNotOngoing
+		return nil, err
+	}
+
+	panicked = false
+	return order, nil
+}
+
+// releaseOrderCommodityStock 在订单取消或关闭的事务中释放订单占用的库存
+// 秒杀订单的库存还给秒杀场次, 其他订单恢复商品库存
+func (ods *OrderDomainSvc) releaseOrderCommodityStock(tx *gorm.DB, order *do.Order) error {
+	flashSale, err := NewFlashSaleDomainSvc(ods.ctx).ReleaseOrderStock(tx, order.OrderNo)
+	if err != nil {
+		return err
+	}
+	if flashSale {
+		return nil
+	}
+	return dao.NewCommodityDao(ods.ctx).RecoverOrderCommodityStuckInTx(tx, order.Items)
+}
@@ -0,0 +1,204 @@
+package domainservice
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/dal/dao"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+// getOrderByNo 根据订单号获取订单详情, 用于商家管理订单, 不校验订单所属的用户
+func (ods *OrderDomainSvc) getOrderByNo(orderNo string) (*do.Order, error) {
+	orderModel, err := ods.orderDao.GetOrderByNo(orderNo)
+	if err != nil {
+		return nil, errcode.Wrap("GetOrderByNoError", err)
+	}
+	if orderModel.ID == 0 {
+		return nil, errcode.ErrOrderParams
+	}
+	return ods.GetSpecifiedUserOrder(orderNo, orderModel.UserId)
+}
+
+// PickOrder 商家检货完成, 已支付的订单变更为检货完成, 拼团订单要等拼团成功后才能检货
+func (ods *OrderDomainSvc) PickOrder(orderNo string) error {
+	order, err := ods.getOrderByNo(orderNo)
+	if err != nil {
+		return err
+	}
+	if err = NewGroupBuyDomainSvc(ods.ctx).CheckOrderGroupSucceeded(order.OrderNo); err != nil {
+		return err
+	}
+
+	tx := dao.DBMaster().Begin()
+	panicked := true
+	defer func() {
+		if err != nil || panicked {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	statusLog := newOrderStatusLog(order, enum.OrderStatusChecked, enum.OrderActorMerchant, 0, "商家检货完成")
+	updated, err := ods.TransitOrderStatus(tx, statusLog, nil)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		err = errcode.ErrOrderCanNotBeChanged
+		return err
+	}
+
+	panicked = false
+	return nil
+}
+
+// ShipOrder 商家发货, 检货完成的订单变更为已发货并创建发货记录
+func (ods *OrderDomainSvc) ShipOrder(orderNo, carrierCode, trackingNo string) (*do.OrderShipment, error) {
+	carrierName, ok := enum.ShipmentCarrierName[carrierCode]
+	if !ok {
+		return nil, errcode.ErrOrderCarrierUnsupported
+	}
+	order, err := ods.getOrderByNo(orderNo)
+	if err != nil {
+		return nil, err
+	}
+
+	shipmentNo, err := genSerialNo(order.UserId)
+	if err != nil {
+		return nil, err
+	}
+	shipment := &do.OrderShipment{
+		ShipmentNo:  shipmentNo,
+		OrderId:     order.ID,
+		OrderNo:     order.OrderNo,
+		CarrierCode: carrierCode,
+		CarrierName: carrierName,
+		TrackingNo:  trackingNo,
+		Status:      enum.ShipmentStatusShipped,
+		ShippedAt:   time.Now(),
+	}
+
+	tx := dao.DBMaster().Begin()
+	panicked := true
+	defer func() {
+		if err != nil || panicked {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	statusLog := newOrderStatusLog(order, enum.OrderStatusShipped, enum.OrderActorMerchant, 0, "商家发货, "+carrierName+": "+trackingNo)
+	updated, err := ods.TransitOrderStatus(tx, statusLog, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		err = errcode.ErrOrderCanNotBeChanged
+		return nil, err
+	}
+	if err = dao.NewOrderShipmentDao(ods.ctx).CreateShipment(tx, shipment); err != nil {
+		return nil, errcode.Wrap("ShipOrderError", err)
+	}
+
+	panicked = false
+	return shipment, nil
+}
+
+// DeliverOrder 订单送达, 已发货或配送中的订单变更为已送达, 同时把发货记录设置为已送达
+func (ods *OrderDomainSvc) DeliverOrder(orderNo string) error {
+	order, err := ods.getOrderByNo(orderNo)
+	if err != nil {
+		return err
+	}
+
+	tx := dao.DBMaster().Begin()
+	panicked := true
+	defer func() {
+		if err != nil || panicked {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	statusLog := newOrderStatusLog(order, enum.OrderStatusDelivered, enum.OrderActorMerchant, 0, "订单已送达")
+	updated, err := ods.TransitOrderStatus(tx, statusLog, nil)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		err = errcode.ErrOrderCanNotBeChanged
+		return err
+	}
+	if err = ods.setShipmentDelivered(tx, order.ID); err != nil {
+		return err
+	}
+
+	panicked = false
+	return nil
+}
+
+// setShipmentDelivered 在事务 tx 中把订单运输中的发货记录设置为已送达
+func (ods *OrderDomainSvc) setShipmentDelivered(tx *gorm.DB, orderId int64) error {
+	shipmentDao := dao.NewOrderShipmentDao(ods.ctx)
+	shipment, err := shipmentDao.GetOrderShipment(orderId)
+	if err != nil {
+		return errcode.Wrap("SetShipmentDeliveredError", err)
+	}
+	if shipment.ID == 0 {
+		return nil
+	}
+	_, err = shipmentDao.UpdateShipmentFromStatus(tx, shipment.ID, enum.ShipmentStatusShipped, map[string]interface{}{
+		"status":       enum.ShipmentStatusDelivered,
+		"delivered_at": time.Now(),
+	})
+	if err != nil {
+		return errcode.Wrap("SetShipmentDeliveredError", err)
+	}
+	return nil
+}
+
+// MerchantCloseOrder 商家关闭未支付的订单并恢复商品库存, 已支付的订单需要通过退款处理
+func (ods *OrderDomainSvc) MerchantCloseOrder(orderNo, reason string) error {
+	order, err := ods.getOrderByNo(orderNo)
+	if err != nil {
+		return err
+	}
+	if !CanTransitOrderStatus(order.OrderStatus, enum.OrderStatusMerchantClose, enum.OrderActorMerchant) {
+		return errcode.ErrOrderCanNotBeChanged
+	}
+
+	statusLog := newOrderStatusLog(order, enum.OrderStatusMerchantClose, enum.OrderActorMerchant, 0, "商家关闭订单: "+reason)
+	paid, err := ods.closeUnpaidOrder(order, statusLog)
+	if err != nil {
+		return err
+	}
+	if paid {
+		// 查询到用户已经完成了支付, 订单已经更新为已支付, 不能再关闭
+		return errcode.ErrOrderCanNotBeChanged
+	}
+	return nil
+}
+
+// GetOrderShipment 获取订单的发货记录, 订单还没发货时返回 nil
+func (ods *OrderDomainSvc) GetOrderShipment(orderId int64) (*do.OrderShipment, error) {
+	shipmentModel, err := dao.NewOrderShipmentDao(ods.ctx).GetOrderShipment(orderId)
+	if err != nil {
+		return nil, errcode.Wrap("GetOrderShipmentError", err)
+	}
+	if shipmentModel.ID == 0 {
+		return nil, nil
+	}
+	shipment := new(do.OrderShipment)
+	if err = util.CopyProperties(shipment, shipmentModel); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return shipment, nil
+}
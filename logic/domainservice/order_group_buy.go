@@ -0,0 +1,170 @@
+package domainservice
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/logger"
+	"github.com/hd2yao/go-mall/dal/dao"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+// newGroupBuyOrder 按拼团活动的拼团价生成开团或参团的订单
+// 拼团订单不使用会员折扣、优惠券和满减活动, 只计算运费
+func (ods *OrderDomainSvc) newGroupBuyOrder(activity *do.GroupBuyActivity, commodityNum int, userAddress *do.UserAddressInfo) (*do.Order, error) {
+	items, err := NewCartDomainSvc(ods.ctx).GetBuyNowItems([]*do.ShoppingCartItem{
+		{CommodityId: activity.CommodityId, CommodityNum: commodityNum},
+	}, userAddress.UserId)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		item.CommoditySellingPrice = activity.GroupPrice
+	}
+	billInfo, err := newFreightBillChecker(ods.ctx, items, userAddress.UserId, userAddress).GetBill()
+	if err != nil {
+		return nil, err
+	}
+	return ods.newOrder(items, userAddress, billInfo)
+}
+
+// createGroupBuyOrder 在开团或参团的事务中创建订单并减少商品库存
+func (ods *OrderDomainSvc) createGroupBuyOrder(tx *gorm.DB, order *do.Order) error {
+	if err := ods.orderDao.CreateOrder(tx, order); err != nil {
+		return err
+	}
+	return dao.NewCommodityDao(ods.ctx).ReduceStuckInOrderCreate(tx, order.Items)
+}
+
+// settleGroupBuyFailedOrder 处理拼团失败的成员的订单: 还没支付的订单关闭, 已经支付的订单全额退款
+func (ods *OrderDomainSvc) settleGroupBuyFailedOrder(member *do.GroupBuyMember) error {
+	switch member.Status {
+	case enum.GroupBuyMemberJoined:
+		return ods.closeGroupBuyFailedOrder(member)
+	case enum.GroupBuyMemberPaid:
+		return ods.refundGroupBuyFailedOrder(member)
+	case enum.GroupBuyMemberRefunding:
+		return ods.approveGroupBuyFailedRefund(member)
+	}
+	return nil
+}
+
+// closeGroupBuyFailedOrder 关闭拼团失败的成员还没支付的订单, 关闭时成员退出拼团
+// 关闭前查询到订单已经支付时订单更新为已支付, 成员在下次处理时退款
+func (ods *OrderDomainSvc) closeGroupBuyFailedOrder(member *do.GroupBuyMember) error {
+	order, err := ods.GetSpecifiedUserOrder(member.OrderNo, member.UserId)
+	if err != nil {
+		return err
+	}
+	if !CanTransitOrderStatus(order.OrderStatus, enum.OrderStatusUnpaidClose, enum.OrderActorSystem) {
+		return nil
+	}
+	statusLog := newOrderStatusLog(order, enum.OrderStatusUnpaidClose, enum.OrderActorSystem, 0, "拼团失败自动关闭")
+	paid, err := ods.closeUnpaidOrder(order, statusLog)
+	if err != nil {
+		return err
+	}
+	if paid {
+		logger.New(ods.ctx).Info("GroupBuyFailedOrderPaid", "orderNo", order.OrderNo)
+	}
+	return nil
+}
+
+// refundGroupBuyFailedOrder 为拼团失败的成员已经支付的订单全额退款, 不需要商家审核直接向支付平台发起退款
+func (ods *OrderDomainSvc) refundGroupBuyFailedOrder(member *do.GroupBuyMember) error {
+	order, err := ods.GetSpecifiedUserOrder(member.OrderNo, member.UserId)
+	if err != nil {
+		return err
+	}
+	if order.PayState != enum.PayStatePaid || !CanTransitOrderStatus(order.OrderStatus, enum.OrderStatusRefunding, enum.OrderActorSystem) {
+		return errcode.ErrOrderCanNotRefund
+	}
+	refundItems, err := genOrderRefundItems(order, nil)
+	if err != nil {
+		return err
+	}
+	refundNo, err := genSerialNo(order.UserId)
+	if err != nil {
+		return err
+	}
+	refund := &do.OrderRefund{
+		RefundNo:          refundNo,
+		OrderId:           order.ID,
+		OrderNo:           order.OrderNo,
+		UserId:            order.UserId,
+		PayType:           order.PayType,
+		RefundMoney:       orderRefundMoney(order, refundItems),
+		Reason:            "拼团失败自动退款",
+		Status:            enum.RefundStatusApplied,
+		Items:             refundItems,
+		OrderStatusBefore: order.OrderStatus,
+	}
+	if err = ods.createGroupBuyFailedRefund(order, member, refund); err != nil {
+		return err
+	}
+	member.Status, member.RefundNo = enum.GroupBuyMemberRefunding, refundNo
+	return ods.approveGroupBuyFailedRefund(member)
+}
+
+// createGroupBuyFailedRefund 在事务中把订单变更为退款中、创建退款单并在拼团成员上记录退款单号
+// 向支付平台发起退款失败时, 下次处理时用记录的退款单号重新发起
+func (ods *OrderDomainSvc) createGroupBuyFailedRefund(order *do.Order, member *do.GroupBuyMember, refund *do.OrderRefund) (err error) {
+	tx := dao.DBMaster().Begin()
+	panicked := true
+	defer func() {
+		if err != nil || panicked {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	statusLog := newOrderStatusLog(order, enum.OrderStatusRefunding, enum.OrderActorSystem, 0, refund.Reason)
+	updated, err := ods.TransitOrderStatus(tx, statusLog, nil)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		err = errcode.ErrOrderCanNotBeChanged
+		return err
+	}
+	if err = dao.NewOrderRefundDao(ods.ctx).CreateRefund(tx, refund); err != nil {
+		return errcode.Wrap("CreateGroupBuyFailedRefundError", err)
+	}
+	updated, err = dao.NewGroupBuyDao(ods.ctx).UpdateMemberFromStatus(tx, member.ID, enum.GroupBuyMemberPaid, map[string]interface{}{
+		"status":    enum.GroupBuyMemberRefunding,
+		"refund_no": refund.RefundNo,
+	})
+	if err != nil {
+		return errcode.Wrap("CreateGroupBuyFailedRefundError", err)
+	}
+	if !updated {
+		err = errcode.ErrOrderCanNotBeChanged
+		return err
+	}
+
+	panicked = false
+	return nil
+}
+
+// approveGroupBuyFailedRefund 向支付平台发起拼团失败的退款, 发起后把拼团成员设置为已退款
+// 退款单已经发起过(比如上次发起后更新成员状态失败)时直接更新成员状态
+func (ods *OrderDomainSvc) approveGroupBuyFailedRefund(member *do.GroupBuyMember) error {
+	refund, err := ods.getRefund(member.RefundNo)
+	if err != nil {
+		return err
+	}
+	if refund.Status == enum.RefundStatusApplied {
+		if err = ods.ApproveOrderRefund(refund.RefundNo); err != nil {
+			return err
+		}
+	}
+	_, err = dao.NewGroupBuyDao(ods.ctx).UpdateMemberFromStatus(dao.DBMaster(), member.ID, enum.GroupBuyMemberRefunding, map[string]interface{}{
+		"status": enum.GroupBuyMemberRefunded,
+	})
+	if err != nil {
+		return errcode.Wrap("ApproveGroupBuyFailedRefundError", err)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package domainservice
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/logger"
+	"github.com/hd2yao/go-mall/dal/dao"
+	"github.com/hd2yao/go-mall/dal/model"
+	"github.com/hd2yao/go-mall/library"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+// 支付结果通知的时间戳与服务器时间允许的最大偏差, 超过时认为是重放的通知
+const payNotifyTimestampTolerance = 5 * time.Minute
+
+// HandleWxPayNotify 处理微信支付的支付结果通知
+// 验证通知签名、解密通知数据后把订单更新为支付成功
+// 微信支付文档: https://pay.weixin.qq.com/docs/merchant/apis/jsapi-payment/payment-notice.html
+func (ods *OrderDomainSvc) HandleWxPayNotify(timestamp, nonce, signature, rawBody string) error {
+	log := logger.New(ods.ctx)
+	notifyTime, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errcode.ErrOrderPayNotifyInvalid.WithCause(err)
+	}
+	if time.Since(time.Unix(notifyTime, 0)).Abs() > payNotifyTimestampTolerance {
+		return errcode.ErrOrderPayNotifyInvalid.WithCause(errors.New("notify timestamp expired"))
+	}
+
+	wxPayConfig := newWxPayConfig()
+	wpl := library.NewWxPayLib(ods.ctx, *wxPayConfig)
+	verified, err := wpl.ValidateNotifySignature(timestamp, nonce, signature, rawBody)
+	if err != nil || !verified {
+		return errcode.ErrOrderPayNotifyInvalid.WithCause(err)
+	}
+
+	payResult, err := wpl.DecryptNotifyResourceData(rawBody)
+	if err != nil {
+		return errcode.ErrOrderPayNotifyInvalid.WithCause(err)
+	}
+	log.Info("WxPayNotifyResource", "payResult", payResult)
+	if payResult.Mchid != wxPayConfig.MchId {
+		return errcode.ErrOrderPayNotifyInvalid.WithCause(fmt.Errorf("mchid not match, notify mchid: %s", payResult.Mchid))
+	}
+	if payResult.TradeState != "SUCCESS" {
+		// 只有支付成功才会有通知, 其他状态直接忽略
+		log.Warn("WxPayNotifyTradeStateIgnored", "orderNo", payResult.OutTradeNo, "tradeState", payResult.TradeState)
+		return nil
+	}
+
+	return ods.SetOrderPaySuccess(payResult.OutTradeNo, enum.PayTypeWxPay, payResult.TransactionID, payResult.Amount.Total, payResult.SuccessTime)
+}
+
+// HandleAliPayNotify 处理支付宝的异步通知
+// 验证通知签名后, 交易状态为支付成功时把订单更新为已支付
+// 支付宝文档: https://opendocs.alipay.com/open/270/105902
+func (ods *OrderDomainSvc) HandleAliPayNotify(notifyForm url.Values) error {
+	log := logger.New(ods.ctx)
+	aliPayConfig := newAliPayConfig()
+	verified, err := newAliPayGateway(ods.ctx, *aliPayConfig).VerifyNotifySignature(notifyForm)
+	if err != nil || !verified {
+		return errcode.ErrOrderPayNotifyInvalid.WithCause(err)
+	}
+	log.Info("AliPayNotifyForm", "form", notifyForm)
+
+	if notifyForm.Get("app_id") != aliPayConfig.AppId {
+		return errcode.ErrOrderPayNotifyInvalid.WithCause(fmt.Errorf("app_id not match, notify app_id: %s", notifyForm.Get("app_id")))
+	}
+	tradeStatus := notifyForm.Get("trade_status")
+	if tradeStatus != "TRADE_SUCCESS" && tradeStatus != "TRADE_FINISHED" {
+		// 交易创建、关闭等状态的通知不需要处理
+		log.Warn("AliPayNotifyTradeStatusIgnored", "orderNo", notifyForm.Get("out_trade_no"), "tradeStatus", tradeStatus)
+		return nil
+	}
+	paidMoney, err := library.AliPayAmountToCent(notifyForm.Get("total_amount"))
+	if err != nil {
+		return errcode.ErrOrderPayNotifyInvalid.WithCause(err)
+	}
+	paidAt, err := time.ParseInLocation(enum.TimeFormatHyphenedYMDHIS, notifyForm.Get("gmt_payment"), time.Local)
+	if err != nil {
+		paidAt = time.Now()
+	}
+
+	return ods.SetOrderPaySuccess(notifyForm.Get("out_trade_no"), enum.PayTypeAliPay, notifyForm.Get("trade_no"), paidMoney, paidAt)
+}
+
+// SetOrderPaySuccess 支付平台确认支付成功后, 把订单设置为已支付
+// 同一笔支付重复通知时直接返回成功, 保证幂等
+func (ods *OrderDomainSvc) SetOrderPaySuccess(orderNo string, payType int, payTransId string, paidMoney int, paidAt time.Time) error {
+	log := logger.New(ods.ctx)
+	orderModel, err := ods.orderDao.GetOrderByNo(orderNo)
+	if err != nil {
+		return errcode.Wrap("SetOrderPaySuccessError", err)
+	}
+	if orderModel == nil || orderModel.ID == 0 {
+		return errcode.ErrOrderPayNotifyInvalid.WithCause(fmt.Errorf("order not found, orderNo: %s", orderNo))
+	}
+	if payTransId != "" && orderModel.PayTransId == payTransId {
+		// 同一笔支付的重复通知, 包括订单关闭后才收到、已经发起了退款的支付
+		return nil
+	}
+	if orderModel.PayState == enum.PayStatePaid || orderModel.PayTransId != "" {
+		// 同一订单出现了两笔不同的支付, 需要人工介入处理
+		log.Error("OrderRepeatedPayment", "orderNo", orderNo, "payTransId", orderModel.PayTransId, "newPayTransId", payTransId)
+		return nil
+	}
+	if orderModel.PayMoney != paidMoney {
+		return errcode.ErrOrderPayNotifyInvalid.WithCause(fmt.Errorf("pay money not match, order pay money: %d, paid money: %d", orderModel.PayMoney, paidMoney))
+	}
+	if lo.Contains(closedOrderStatus, orderModel.OrderStatus) {
+		// 订单已关闭后才收到支付成功的通知, 记录这笔支付并给用户全额退款, 通知正常应答, 避免支付平台一直重发
+		log.Error("ClosedOrderPaid", "orderNo", orderNo, "orderStatus", orderModel.OrderStatus, "payTransId", payTransId)
+		return ods.refundClosedOrderPayment(orderModel, payType, payTransId, paidAt)
+	}
+	if !CanTransitOrderStatus(orderModel.OrderStatus, enum.OrderStatusPaid, enum.OrderActorPayment) {
+		log.Error("OrderPaidStatusInvalid", "orderNo", orderNo, "orderStatus", orderModel.OrderStatus, "payTransId", payTransId)
+		return errcode.ErrOrderCanNotBeChanged
+	}
+
+	var updated bool
+	err = dao.DBMaster().Transaction(func(tx *gorm.DB) error {
+		statusLog := &do.OrderStatusLog{
+			OrderId:    orderModel.ID,
+			OrderNo:    orderModel.OrderNo,
+			FromStatus: orderModel.OrderStatus,
+			ToStatus:   enum.OrderStatusPaid,
+			Actor:      enum.OrderActorPayment,
+			Reason:     "支付成功, 支付平台交易号: " + payTransId,
+		}
+		updated, err = ods.TransitOrderStatus(tx, statusLog, map[string]interface{}{
+			"pay_type":     payType,
+			"pay_trans_id": payTransId,
+			"pay_state":    enum.PayStatePaid,
+			"paid_at":      paidAt,
+		})
+		if err != nil || !updated {
+			return err
+		}
+		// 拼团订单的成员设置为已支付, 支付的成员达到成团人数时拼团成功
+		if err = NewGroupBuyDomainSvc(ods.ctx).MarkOrderMemberPaid(tx, orderNo, paidAt); err != nil {
+			return err
+		}
+		// 核销订单锁定的优惠券
+		return NewCouponDomainSvc(ods.ctx).RedeemOrderCoupon(tx, orderNo, paidAt)
+	})
+	if err != nil {
+		return err
+	}
+	if !updated {
+		// 并发的重复通知已经把订单更新成了已支付
+		log.Info("OrderAlreadyPaid", "orderNo", orderNo, "payTransId", payTransId)
+	}
+	return nil
+}
+
+// refundClosedOrderPayment 订单关闭后才收到支付成功的通知时, 在订单上记录这笔支付, 并创建全额退款的退款单向支付平台发起退款
+// 订单保持关闭的状态, 退款单的 OrderStatusBefore 记录订单关闭时的状态, 退款成功后只把订单的支付状态设置为已退款
+// 向支付平台发起退款失败时退款单保持待审核的状态, 由商家在管理后台的退款单列表中重新发起退款
+func (ods *OrderDomainSvc) refundClosedOrderPayment(orderModel *model.Order, payType int, payTransId string, paidAt time.Time) error {
+	refundNo, err := genSerialNo(orderModel.UserId)
+	if err != nil {
+		return err
+	}
+	refund := &do.OrderRefund{
+		RefundNo:          refundNo,
+		OrderId:           orderModel.ID,
+		OrderNo:           orderModel.OrderNo,
+		UserId:            orderModel.UserId,
+		PayType:           payType,
+		RefundMoney:       orderModel.PayMoney,
+		Reason:            "订单关闭后收到支付, 自动全额退款",
+		Status:            enum.RefundStatusApplied,
+		OrderStatusBefore: orderModel.OrderStatus,
+	}
+	err = dao.DBMaster().Transaction(func(tx *gorm.DB) error {
+		updated, err := ods.orderDao.UpdateOrderFromStatus(tx, orderModel.ID, orderModel.OrderStatus, map[string]interface{}{
+			"pay_type":     payType,
+			"pay_trans_id": payTransId,
+			"pay_state":    enum.PayStatePaid,
+			"paid_at":      paidAt,
+		})
+		if err != nil {
+			return errcode.Wrap("RefundClosedOrderPaymentError", err)
+		}
+		if !updated {
+			return errcode.ErrOrderCanNotBeChanged
+		}
+		if err = dao.NewOrderRefundDao(ods.ctx).CreateRefund(tx, refund); err != nil {
+			return errcode.Wrap("RefundClosedOrderPaymentError", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = ods.ApproveOrderRefund(refundNo); err != nil {
+		// 支付已经记录并创建了退款单, 通知正常应答, 退款由商家跟进处理
+		logger.New(ods.ctx).Error("RefundClosedOrderPaymentError", "orderNo", orderModel.OrderNo, "refundNo", refundNo, "err", err)
+	}
+	return nil
+}
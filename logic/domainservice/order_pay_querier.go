@@ -0,0 +1,118 @@
+package domainservice
+
+import (
+	"context"
+	"time"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/library"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+// OrderPayQuerierContract 根据订单的支付方式向对应的支付平台查询订单的支付结果、关闭支付平台的交易
+type OrderPayQuerierContract interface {
+	// QueryOrderPay 查询订单在支付平台的支付结果
+	QueryOrderPay(ctx context.Context, order *do.Order) (*do.OrderPayResult, error)
+	// CloseOrderPay 关闭支付平台的交易, 关闭后用户不能再支付这个订单
+	CloseOrderPay(ctx context.Context, order *do.Order) error
+}
+
+// _UTPayQuerier 单元测试设置的 OrderPayQuerierContract, 设置后不再按支付方式选择支付平台
+var _UTPayQuerier OrderPayQuerierContract
+
+// SetUTOrderPayQuerier 让单元测试能把支付平台的查询覆盖成不请求支付平台的实现, 传 nil 时恢复按支付方式选择支付平台
+func SetUTOrderPayQuerier(querier OrderPayQuerierContract) {
+	_UTPayQuerier = querier
+}
+
+// NewOrderPayQuerier 根据订单的支付方式选择支付平台
+func NewOrderPayQuerier(payType int) (OrderPayQuerierContract, error) {
+	if _UTPayQuerier != nil {
+		return _UTPayQuerier, nil
+	}
+	switch payType {
+	case enum.PayTypeWxPay:
+		return new(WxPayQuerier), nil
+	case enum.PayTypeAliPay:
+		return new(AliPayQuerier), nil
+	default:
+		return nil, errcode.ErrOrderParams
+	}
+}
+
+// WxPayQuerier 查询、关闭微信支付的交易
+type WxPayQuerier struct {
+}
+
+func (querier *WxPayQuerier) QueryOrderPay(ctx context.Context, order *do.Order) (*do.OrderPayResult, error) {
+	wxPayGateway := newWxPayGateway(ctx, *newWxPayConfig())
+	transaction, err := wxPayGateway.QueryOrder(order.OrderNo)
+	if err != nil {
+		return nil, errcode.Wrap("WxPayQuerierQueryOrderPayError", err)
+	}
+	if transaction.TradeState != library.WxTradeStateSuccess {
+		return &do.OrderPayResult{
+			Paid:       false,
+			Closed:     transaction.TradeState == library.WxTradeStateClosed,
+			TradeState: transaction.TradeState,
+		}, nil
+	}
+	return &do.OrderPayResult{
+		Paid:       true,
+		TradeState: transaction.TradeState,
+		PayTransId: transaction.TransactionID,
+		PaidMoney:  transaction.Amount.Total,
+		PaidAt:     transaction.SuccessTime,
+	}, nil
+}
+
+func (querier *WxPayQuerier) CloseOrderPay(ctx context.Context, order *do.Order) error {
+	wxPayGateway := newWxPayGateway(ctx, *newWxPayConfig())
+	if err := wxPayGateway.CloseOrder(order.OrderNo); err != nil {
+		return errcode.Wrap("WxPayQuerierCloseOrderPayError", err)
+	}
+	return nil
+}
+
+// AliPayQuerier 查询、关闭支付宝的交易
+type AliPayQuerier struct {
+}
+
+func (querier *AliPayQuerier) QueryOrderPay(ctx context.Context, order *do.Order) (*do.OrderPayResult, error) {
+	aliPayGateway := newAliPayGateway(ctx, *newAliPayConfig())
+	queryReply, err := aliPayGateway.QueryTrade(order.OrderNo)
+	if err != nil {
+		return nil, errcode.Wrap("AliPayQuerierQueryOrderPayError", err)
+	}
+	if queryReply.TradeStatus != library.AliPayTradeStatusSuccess && queryReply.TradeStatus != library.AliPayTradeStatusFinished {
+		return &do.OrderPayResult{
+			Paid:       false,
+			Closed:     queryReply.TradeStatus == library.AliPayTradeStatusClosed,
+			TradeState: queryReply.TradeStatus,
+		}, nil
+	}
+	paidMoney, err := library.AliPayAmountToCent(queryReply.TotalAmount)
+	if err != nil {
+		return nil, errcode.Wrap("AliPayQuerierQueryOrderPayError", err)
+	}
+	paidAt, err := time.ParseInLocation(enum.TimeFormatHyphenedYMDHIS, queryReply.SendPayDate, time.Local)
+	if err != nil {
+		paidAt = time.Now()
+	}
+	return &do.OrderPayResult{
+		Paid:       true,
+		TradeState: queryReply.TradeStatus,
+		PayTransId: queryReply.TradeNo,
+		PaidMoney:  paidMoney,
+		PaidAt:     paidAt,
+	}, nil
+}
+
+func (querier *AliPayQuerier) CloseOrderPay(ctx context.Context, order *do.Order) error {
+	aliPayGateway := newAliPayGateway(ctx, *newAliPayConfig())
+	if err := aliPayGateway.CloseTrade(order.OrderNo); err != nil {
+		return errcode.Wrap("AliPayQuerierCloseOrderPayError", err)
+	}
+	return nil
+}
@@ -2,15 +2,27 @@ package domainservice
 
 import (
 	"context"
-	"errors"
+	"encoding/json"
+	"net/url"
+	"time"
 
 	"github.com/hd2yao/go-mall/common/enum"
 	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/logger"
+	"github.com/hd2yao/go-mall/common/util"
 	"github.com/hd2yao/go-mall/config"
+	"github.com/hd2yao/go-mall/dal/cache"
+	"github.com/hd2yao/go-mall/dal/dao"
 	"github.com/hd2yao/go-mall/library"
 	"github.com/hd2yao/go-mall/logic/do"
 )
 
+const (
+	orderPayLockTTL      = 10 * time.Second       // 发起支付的防重锁的过期时间, 正常情况下请求结束时会主动释放
+	orderPayWaitTimeout  = 3 * time.Second        // 另一个支付请求正在下单时, 等待它结束的最长时间
+	orderPayWaitInterval = 100 * time.Millisecond // 等待另一个支付请求结束时, 检查预支付信息的间隔
+)
+
 // OrderPayTemplateContract 订单支付的模版--对订单支付执行过程的抽象, 模版方法中决定流程步骤的执行顺序
 type OrderPayTemplateContract interface {
 	CreateOrderPay() (interface{}, error) // 模版方法
@@ -19,8 +31,10 @@ type OrderPayTemplateContract interface {
 
 // OrderPayHandlerContract 订单支付的处理器接口--对订单支付各个主要步骤的抽象
 type OrderPayHandlerContract interface {
-	// CheckRepetition 防重校--检查是否为重复支付请求
-	CheckRepetition() error
+	// CheckRepetition 防重校--检查是否为重复支付请求, 预支付信息还有效时返回缓存的预支付信息
+	CheckRepetition() (interface{}, error)
+	// ReleaseRepetition 发起支付结束, 缓存发起成功的预支付信息并释放防重锁
+	ReleaseRepetition(payInfo interface{}, payErr error)
 	// ValidateOrder 检验Order参数是否符合预期
 	ValidateOrder() error
 	// LoadPayAndUserConfig 加载支付配置和支付平台需要的一些用户信息--比如微信的 openID
@@ -43,28 +57,35 @@ type OrderPayTemplate struct {
 	OrderPayHandlerContract
 }
 
-func (template OrderPayTemplate) CreateOrderPay() (interface{}, error) {
-	// 防止用户端重复操作
-	if err := template.CheckRepetition(); err != nil {
+func (template OrderPayTemplate) CreateOrderPay() (response interface{}, err error) {
+	// 防止用户端重复操作, 重复的请求直接返回上次发起支付得到的预支付信息
+	prepayInfo, err := template.CheckRepetition()
+	if err != nil {
 		return nil, err
 	}
+	if prepayInfo != nil {
+		return prepayInfo, nil
+	}
+	defer func() {
+		template.ReleaseRepetition(response, err)
+	}()
 
 	// 校验参数是否符合预期
-	if err := template.ValidateOrder(); err != nil {
+	if err = template.ValidateOrder(); err != nil {
 		return nil, err
 	}
 
 	// 加载支付配置和支付平台需要的一些用户信息
-	if err := template.LoadPayAndUserConfig(); err != nil {
+	if err = template.LoadPayAndUserConfig(); err != nil {
 		return nil, err
 	}
 
 	// 加载支付策略--例如微信的小程序支付
-	if err := template.LoadOrderPayStrategy(); err != nil {
+	if err = template.LoadOrderPayStrategy(); err != nil {
 		return nil, err
 	}
 
-	response, err := template.HandleOrderPay()
+	response, err = template.HandleOrderPay()
 	if err != nil {
 		return nil, err
 	}
@@ -73,10 +94,10 @@ func (template OrderPayTemplate) CreateOrderPay() (interface{}, error) {
 }
 
 type OrderPayConfig struct {
-	PayUserId   int64
-	WxOpenId    string
-	WxPayConfig *library.WxPayConfig
-	//AliPayConfig
+	PayUserId    int64
+	WxOpenId     string
+	WxPayConfig  *library.WxPayConfig
+	AliPayConfig *library.AliPayConfig
 }
 
 // CommonOrderPayHandler 支付处理的通用类，只实现参数校验这样的每个支付方式都需要做的通用操作
@@ -84,6 +105,7 @@ type OrderPayConfig struct {
 type CommonOrderPayHandler struct {
 	ctx       context.Context
 	Scene     string // 支付场景 H5、app、小程序 jsapi(公众号、线下、PC 网页)等 -- 对应支付平台不同的支付场景
+	PayType   int    // 支付方式
 	UserId    int64
 	OrderNo   string // 业务订单号
 	Order     *do.Order
@@ -91,11 +113,78 @@ type CommonOrderPayHandler struct {
 	// 这里还可以继续定义 AliPayConfig、WechatPayConfig 等等
 
 	PayStrategy OrderPayStrategyContract // 支付策略
+
+	repetitionToken string // 本次请求持有的防重锁的值
 }
 
-func (handler *CommonOrderPayHandler) CheckRepetition() error {
-	// 根据自己的业务量, 用 Redis 或者更高级的方式做防重校验
-	return nil
+// CheckRepetition 用 Redis 做防重校验, 多个服务实例同时收到同一个用户对同一个订单的支付请求时只有一个能向支付平台下单
+// 同样的支付方式和支付场景已经发起过支付并且订单还在等待支付时, 直接返回缓存的预支付信息, 不再请求支付平台
+// 另一个请求正在向支付平台下单时等待它结束, 返回它缓存的预支付信息, 等待超时返回 ErrOrderPayInProgress
+func (handler *CommonOrderPayHandler) CheckRepetition() (interface{}, error) {
+	waitUntil := time.Now().Add(orderPayWaitTimeout)
+	for {
+		prepayInfo, err := handler.getCachedPrepayInfo()
+		if err != nil || prepayInfo != nil {
+			return prepayInfo, err
+		}
+
+		token := util.RandomString(16)
+		locked, err := cache.LockOrderPay(handler.ctx, handler.UserId, handler.OrderNo, token, orderPayLockTTL)
+		if err != nil {
+			return nil, errcode.Wrap("CheckOrderPayRepetitionError", err)
+		}
+		if locked {
+			handler.repetitionToken = token
+			return nil, nil
+		}
+		if time.Now().After(waitUntil) {
+			// 同一个订单的另一个支付请求正在向支付平台下单
+			return nil, errcode.ErrOrderPayInProgress
+		}
+		time.Sleep(orderPayWaitInterval)
+	}
+}
+
+// getCachedPrepayInfo 获取同样的支付方式和支付场景缓存的预支付信息, 没有缓存或者订单已经不再等待支付时返回 nil
+func (handler *CommonOrderPayHandler) getCachedPrepayInfo() (interface{}, error) {
+	prepay, err := cache.GetOrderPrepay(handler.ctx, handler.UserId, handler.OrderNo)
+	if err != nil {
+		return nil, errcode.Wrap("CheckOrderPayRepetitionError", err)
+	}
+	if prepay == nil || prepay.PayType != handler.PayType || prepay.PayScene != handler.Scene {
+		return nil, nil
+	}
+	// 订单支付成功或者关闭后预支付信息就失效了, 缓存还没过期时也不能再返回
+	orderModel, err := dao.NewOrderDao(handler.ctx).GetOrderByNo(handler.OrderNo)
+	if err != nil {
+		return nil, errcode.Wrap("CheckOrderPayRepetitionError", err)
+	}
+	if orderModel.UserId != handler.UserId || orderModel.OrderStatus != enum.OrderStatusUnPaid || orderModel.PayType != handler.PayType {
+		return nil, nil
+	}
+	return prepay.PayInfo, nil
+}
+
+// ReleaseRepetition 缓存发起成功的预支付信息, 缓存在订单超时关闭时过期, 然后释放防重锁
+func (handler *CommonOrderPayHandler) ReleaseRepetition(payInfo interface{}, payErr error) {
+	log := logger.New(handler.ctx)
+	if payErr == nil && payInfo != nil && handler.Order != nil {
+		ttl := time.Until(handler.Order.CreatedAt.Add(UnpaidCloseTimeout()))
+		if ttl > 0 {
+			prepay := &do.OrderPrepay{PayType: handler.PayType, PayScene: handler.Scene}
+			prepayInfo, err := json.Marshal(payInfo)
+			if err == nil {
+				prepay.PayInfo = prepayInfo
+				err = cache.SetOrderPrepay(handler.ctx, handler.UserId, handler.OrderNo, prepay, ttl)
+			}
+			if err != nil {
+				log.Error("SetOrderPrepayError", "orderNo", handler.OrderNo, "err", err)
+			}
+		}
+	}
+	if err := cache.UnlockOrderPay(handler.ctx, handler.UserId, handler.OrderNo, handler.repetitionToken); err != nil {
+		log.Error("UnlockOrderPayError", "orderNo", handler.OrderNo, "err", err)
+	}
 }
 
 func (handler *CommonOrderPayHandler) ValidateOrder() error {
@@ -103,9 +192,14 @@ func (handler *CommonOrderPayHandler) ValidateOrder() error {
 	if err != nil {
 		return err
 	}
-	if order.OrderStatus > enum.OrderStatusCreated {
+	// 待支付的订单可以重新发起支付, 比如预支付失败后重试或者更换支付方式、支付场景
+	if order.OrderStatus != enum.OrderStatusUnPaid && !CanTransitOrderStatus(order.OrderStatus, enum.OrderStatusUnPaid, enum.OrderActorUser) {
 		return errcode.ErrOrderParams // 订单状态错误，不能发起支付
 	}
+	// 拼团已经结束的拼团订单不能再支付
+	if err = NewGroupBuyDomainSvc(handler.ctx).CheckOrderPayable(order.OrderNo); err != nil {
+		return err
+	}
 	handler.Order = order
 	return nil
 }
@@ -131,13 +225,7 @@ type WxOrderPayHandler struct {
 }
 
 func (wxHandler *WxOrderPayHandler) LoadPayAndUserConfig() error {
-	wxHandler.PayConfig.WxPayConfig = &library.WxPayConfig{
-		AppId:           config.App.WechatPay.AppId,
-		MchId:           config.App.WechatPay.MchId,
-		PrivateSerialNo: config.App.WechatPay.PrivateSerialNo,
-		AesKey:          config.App.WechatPay.AesKey,
-		NotifyUrl:       config.App.WechatPay.NotifyUrl,
-	}
+	wxHandler.PayConfig.WxPayConfig = newWxPayConfig()
 	wxHandler.PayConfig.PayUserId = wxHandler.UserId
 	// 用userId获取对应的Openid, 这里先Mock一个
 	// xxx.GetUserOpenId(wxHandler.userId)
@@ -149,18 +237,54 @@ func (wxHandler *WxOrderPayHandler) LoadPayAndUserConfig() error {
 func (wxHandler *WxOrderPayHandler) LoadOrderPayStrategy() error {
 	switch wxHandler.Scene {
 	case "app": // app 支付
-		return errcode.ErrOrderUnsupportedPayScene
-	// 加载 app 的支付策略类
+		wxHandler.PayStrategy = new(WxAppPayStrategy)
 	case "jsapi": // 网页支付
 		// 加载封装了微信支付 JSAPI 的策略类
 		wxHandler.PayStrategy = new(WxJSPayStrategy)
+	case "native": // PC 网页扫码支付
+		wxHandler.PayStrategy = new(WxNativePayStrategy)
 	default:
-		return errcode.ErrOrderParams.WithCause(errors.New("unsupported platform"))
+		return errcode.ErrOrderUnsupportedPayScene
 	}
 
 	return nil
 }
 
+// newWxPayConfig 从应用配置中加载微信支付的配置
+func newWxPayConfig() *library.WxPayConfig {
+	return &library.WxPayConfig{
+		AppId:           config.App.WechatPay.AppId,
+		MchId:           config.App.WechatPay.MchId,
+		PrivateSerialNo: config.App.WechatPay.PrivateSerialNo,
+		AesKey:          config.App.WechatPay.AesKey,
+		NotifyUrl:       config.App.WechatPay.NotifyUrl,
+		RefundNotifyUrl: config.App.WechatPay.RefundNotifyUrl,
+		ApiBaseUrl:      config.App.WechatPay.ApiBaseUrl,
+
+		PrivateKeyPath:        config.App.WechatPay.PrivateKeyPath,
+		PlatformPublicKeyPath: config.App.WechatPay.PlatformPublicKeyPath,
+	}
+}
+
+// WxPayGatewayContract 微信支付网关, 微信支付的接口和本地沙箱网关都实现了这个接口
+type WxPayGatewayContract interface {
+	CreateOrderPay(order *do.Order, userOpenId string) (*library.WxPayInvokeInfo, error)
+	CreateAppOrderPay(order *do.Order) (*library.WxAppPayInvokeInfo, error)
+	CreateNativeOrderPay(order *do.Order) (*library.WxNativePayInfo, error)
+	CreateRefund(order *do.Order, refund *do.OrderRefund) (*library.WxRefundReply, error)
+	QueryOrder(orderNo string) (*library.WxPayNotifyResourceData, error)
+	CloseOrder(orderNo string) error
+	DownloadTradeBill(billDate time.Time) ([]*library.WxTradeBillRecord, error)
+}
+
+// newWxPayGateway 根据配置选择要使用的微信支付网关
+func newWxPayGateway(ctx context.Context, payConfig library.WxPayConfig) WxPayGatewayContract {
+	if config.App.WechatPay.Gateway == enum.WxPayGatewaySandbox {
+		return library.NewWxPaySandboxLib(ctx, payConfig)
+	}
+	return library.NewWxPayLib(ctx, payConfig)
+}
+
 // WxJSPayStrategy 微信JSAPI 支付接口实现
 type WxJSPayStrategy struct {
 }
@@ -171,8 +295,8 @@ func (strategy *WxJSPayStrategy) CreatePay(ctx context.Context, order *do.Order,
 		return nil, err
 	}
 
-	wpl := library.NewWxPayLib(ctx, *payConfig.WxPayConfig)
-	reply, err := wpl.CreateOrderPay(order, payConfig.WxOpenId)
+	wxPayGateway := newWxPayGateway(ctx, *payConfig.WxPayConfig)
+	reply, err := wxPayGateway.CreateOrderPay(order, payConfig.WxOpenId)
 	if err != nil {
 		err = errcode.Wrap("WxJSPayStrategyCreatePayError", err)
 	}
@@ -180,8 +304,149 @@ func (strategy *WxJSPayStrategy) CreatePay(ctx context.Context, order *do.Order,
 	return reply, err
 }
 
-// type WxAppPayStrategy
-// ......
+// WxAppPayStrategy 微信 APP 支付接口实现
+type WxAppPayStrategy struct {
+}
+
+func (strategy *WxAppPayStrategy) CreatePay(ctx context.Context, order *do.Order, payConfig *OrderPayConfig) (interface{}, error) {
+	ods := NewOrderDomainSvc(ctx)
+	if err := ods.StartOrderWxPay(order.OrderNo, order.UserId); err != nil {
+		return nil, err
+	}
+
+	wxPayGateway := newWxPayGateway(ctx, *payConfig.WxPayConfig)
+	reply, err := wxPayGateway.CreateAppOrderPay(order)
+	if err != nil {
+		err = errcode.Wrap("WxAppPayStrategyCreatePayError", err)
+	}
+
+	return reply, err
+}
+
+// WxNativePayStrategy 微信 Native 支付接口实现, 返回用于生成支付二维码的 code_url
+type WxNativePayStrategy struct {
+}
+
+func (strategy *WxNativePayStrategy) CreatePay(ctx context.Context, order *do.Order, payConfig *OrderPayConfig) (interface{}, error) {
+	ods := NewOrderDomainSvc(ctx)
+	if err := ods.StartOrderWxPay(order.OrderNo, order.UserId); err != nil {
+		return nil, err
+	}
+
+	wxPayGateway := newWxPayGateway(ctx, *payConfig.WxPayConfig)
+	reply, err := wxPayGateway.CreateNativeOrderPay(order)
+	if err != nil {
+		err = errcode.Wrap("WxNativePayStrategyCreatePayError", err)
+	}
+
+	return reply, err
+}
+
+// AliOrderPayHandler 支付宝订单支付处理类
+type AliOrderPayHandler struct {
+	CommonOrderPayHandler
+}
+
+func (aliHandler *AliOrderPayHandler) LoadPayAndUserConfig() error {
+	aliHandler.PayConfig.AliPayConfig = newAliPayConfig()
+	aliHandler.PayConfig.PayUserId = aliHandler.UserId
+	return nil
+}
+
+func (aliHandler *AliOrderPayHandler) LoadOrderPayStrategy() error {
+	switch aliHandler.Scene {
+	case "page": // 电脑网站支付
+		aliHandler.PayStrategy = new(AliPagePayStrategy)
+	case "wap": // 手机网站支付
+		aliHandler.PayStrategy = new(AliWapPayStrategy)
+	case "app": // APP 支付
+		aliHandler.PayStrategy = new(AliAppPayStrategy)
+	default:
+		return errcode.ErrOrderUnsupportedPayScene
+	}
+
+	return nil
+}
+
+// newAliPayConfig 从应用配置中加载支付宝的配置
+func newAliPayConfig() *library.AliPayConfig {
+	return &library.AliPayConfig{
+		AppId:      config.App.AliPay.AppId,
+		GatewayUrl: config.App.AliPay.GatewayUrl,
+		NotifyUrl:  config.App.AliPay.NotifyUrl,
+		ReturnUrl:  config.App.AliPay.ReturnUrl,
+
+		PrivateKeyPath:      config.App.AliPay.PrivateKeyPath,
+		AliPayPublicKeyPath: config.App.AliPay.AliPayPublicKeyPath,
+	}
+}
+
+// AliPayGatewayContract 支付宝网关, 支付宝开放平台的接口和本地沙箱网关都实现了这个接口
+type AliPayGatewayContract interface {
+	CreatePagePay(order *do.Order) (*library.AliPayInvokeInfo, error)
+	CreateWapPay(order *do.Order) (*library.AliPayInvokeInfo, error)
+	CreateAppPay(order *do.Order) (*library.AliPayInvokeInfo, error)
+	CreateRefund(order *do.Order, refund *do.OrderRefund) (*library.AliPayRefundReply, error)
+	QueryTrade(orderNo string) (*library.AliPayTradeQueryReply, error)
+	CloseTrade(orderNo string) error
+	VerifyNotifySignature(notifyForm url.Values) (bool, error)
+}
+
+// newAliPayGateway 根据配置选择要使用的支付宝网关
+func newAliPayGateway(ctx context.Context, payConfig library.AliPayConfig) AliPayGatewayContract {
+	if config.App.AliPay.Gateway == enum.AliPayGatewaySandbox {
+		return library.NewAliPaySandboxLib(ctx, payConfig)
+	}
+	return library.NewAliPayLib(ctx, payConfig)
+}
+
+// AliPagePayStrategy 支付宝电脑网站支付接口实现
+type AliPagePayStrategy struct {
+}
+
+func (strategy *AliPagePayStrategy) CreatePay(ctx context.Context, order *do.Order, payConfig *OrderPayConfig) (interface{}, error) {
+	if err := NewOrderDomainSvc(ctx).StartOrderAliPay(order.OrderNo, order.UserId); err != nil {
+		return nil, err
+	}
+
+	reply, err := newAliPayGateway(ctx, *payConfig.AliPayConfig).CreatePagePay(order)
+	if err != nil {
+		err = errcode.Wrap("AliPagePayStrategyCreatePayError", err)
+	}
+	return reply, err
+}
+
+// AliWapPayStrategy 支付宝手机网站支付接口实现
+type AliWapPayStrategy struct {
+}
+
+func (strategy *AliWapPayStrategy) CreatePay(ctx context.Context, order *do.Order, payConfig *OrderPayConfig) (interface{}, error) {
+	if err := NewOrderDomainSvc(ctx).StartOrderAliPay(order.OrderNo, order.UserId); err != nil {
+		return nil, err
+	}
+
+	reply, err := newAliPayGateway(ctx, *payConfig.AliPayConfig).CreateWapPay(order)
+	if err != nil {
+		err = errcode.Wrap("AliWapPayStrategyCreatePayError", err)
+	}
+	return reply, err
+}
+
+// AliAppPayStrategy 支付宝 APP 支付接口实现
+type AliAppPayStrategy struct {
+}
+
+func (strategy *AliAppPayStrategy) CreatePay(ctx context.Context, order *do.Order, payConfig *OrderPayConfig) (interface{}, error) {
+	if err := NewOrderDomainSvc(ctx).StartOrderAliPay(order.OrderNo, order.UserId); err != nil {
+		return nil, err
+	}
+
+	reply, err := newAliPayGateway(ctx, *payConfig.AliPayConfig).CreateAppPay(order)
+	if err != nil {
+		err = errcode.Wrap("AliAppPayStrategyCreatePayError", err)
+	}
+	return reply, err
+}
 
 // NewOrderPayTemplate
 // 创建订单支付模版的工厂方法
@@ -199,6 +464,17 @@ func NewOrderPayTemplate(ctx context.Context, userId int64, orderNo, payScene st
 		payHandler.UserId = userId
 		payHandler.OrderNo = orderNo
 		payHandler.Scene = payScene
+		payHandler.PayType = payType
+		payHandler.PayConfig = new(OrderPayConfig)
+		payTemplate.OrderPayHandlerContract = payHandler
+	case enum.PayTypeAliPay:
+		payHandler := new(AliOrderPayHandler)
+		payHandler.ctx = ctx
+		payHandler.UserId = userId
+		payHandler.OrderNo = orderNo
+		payHandler.Scene = payScene
+		payHandler.PayType = payType
+		payHandler.PayConfig = new(OrderPayConfig)
 		payTemplate.OrderPayHandlerContract = payHandler
 	}
 
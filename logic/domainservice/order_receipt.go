@@ -0,0 +1,127 @@
+package domainservice
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/logger"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/config"
+	"github.com/hd2yao/go-mall/dal/dao"
+	"github.com/hd2yao/go-mall/dal/model"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+const (
+	defaultAutoConfirmReceiptDays = 10
+	defaultReviewWindowDays       = 15
+	autoFinishOrderBatchSize      = 200 // 每次扫描的需要自动确认收货或自动完成的订单数
+)
+
+// AutoConfirmReceiptDays 已送达的订单自动确认收货的天数
+func AutoConfirmReceiptDays() int {
+	if config.App.Order.AutoConfirmReceiptDays > 0 {
+		return config.App.Order.AutoConfirmReceiptDays
+	}
+	return defaultAutoConfirmReceiptDays
+}
+
+// ReviewWindowDays 确认收货后的评价期天数, 过了评价期的订单自动完成
+func ReviewWindowDays() int {
+	if config.App.Order.ReviewWindowDays > 0 {
+		return config.App.Order.ReviewWindowDays
+	}
+	return defaultReviewWindowDays
+}
+
+// ConfirmOrderReceipt 用户确认收货, 已发货、配送中或已送达的订单变更为已确认收货
+func (ods *OrderDomainSvc) ConfirmOrderReceipt(orderNo string, userId int64) error {
+	order, err := ods.GetSpecifiedUserOrder(orderNo, userId)
+	if err != nil {
+		return err
+	}
+	if !CanTransitOrderStatus(order.OrderStatus, enum.OrderStatusConfirmReceipt, enum.OrderActorUser) {
+		return errcode.ErrOrderCanNotBeChanged
+	}
+
+	return dao.DBMaster().Transaction(func(tx *gorm.DB) error {
+		statusLog := newOrderStatusLog(order, enum.OrderStatusConfirmReceipt, enum.OrderActorUser, userId, "用户确认收货")
+		updated, err := ods.TransitOrderStatus(tx, statusLog, nil)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return errcode.ErrOrderCanNotBeChanged
+		}
+		// 商家还没有把订单设置为已送达时用户就确认收货了
+		return ods.setShipmentDelivered(tx, order.ID)
+	})
+}
+
+// AutoConfirmDeliveredOrders 已送达超过 AutoConfirmReceiptDays 天还没有确认收货的订单, 由系统自动确认收货
+func (ods *OrderDomainSvc) AutoConfirmDeliveredOrders() error {
+	days := AutoConfirmReceiptDays()
+	reason := fmt.Sprintf("送达超过 %d 天未确认收货, 系统自动确认收货", days)
+	return ods.autoTransitStaleOrders(enum.OrderStatusDelivered, enum.OrderStatusConfirmReceipt, days, reason)
+}
+
+// AutoCompleteOrders 确认收货超过评价期的订单, 由系统设置为已完成
+func (ods *OrderDomainSvc) AutoCompleteOrders() error {
+	days := ReviewWindowDays()
+	reason := fmt.Sprintf("确认收货超过 %d 天, 评价期结束, 订单完成", days)
+	return ods.autoTransitStaleOrders(enum.OrderStatusConfirmReceipt, enum.OrderStatusCompleted, days, reason)
+}
+
+// autoTransitStaleOrders 把进入 fromStatus 状态超过 days 天的订单由系统变更为 toStatus 状态
+// 状态按原状态条件更新, 多个服务实例同时执行时同一个订单只会被变更一次, 单个订单变更失败只记录日志, 下次扫描时重试
+func (ods *OrderDomainSvc) autoTransitStaleOrders(fromStatus, toStatus, days int, reason string) error {
+	log := logger.New(ods.ctx)
+	enteredBefore := time.Now().AddDate(0, 0, -days)
+	orderModels, err := ods.orderDao.GetOrdersByStatusEnteredBefore(fromStatus, enteredBefore, autoFinishOrderBatchSize)
+	if err != nil {
+		return errcode.Wrap("AutoTransitStaleOrdersError", err)
+	}
+	orders := make([]*do.Order, 0, len(orderModels))
+	if err = util.CopyProperties(&orders, &orderModels); err != nil {
+		return errcode.ErrCoverData.WithCause(err)
+	}
+
+	transited := 0
+	for _, order := range orders {
+		var updated bool
+		err = dao.DBMaster().Transaction(func(tx *gorm.DB) error {
+			statusLog := newOrderStatusLog(order, toStatus, enum.OrderActorSystem, 0, reason)
+			updated, err = ods.TransitOrderStatus(tx, statusLog, nil)
+			if err != nil || !updated || toStatus != enum.OrderStatusCompleted {
+				return err
+			}
+			return ods.accrueOrderVipSpend(tx, order)
+		})
+		if err != nil {
+			log.Error("AutoTransitOrderStatusError", "orderNo", order.OrderNo, "toStatus", toStatus, "err", err)
+		} else if updated {
+			transited++
+		}
+	}
+	if transited > 0 {
+		log.Info("AutoTransitStaleOrders", "fromStatus", fromStatus, "toStatus", toStatus, "count", transited)
+	}
+	return nil
+}
+
+// accrueOrderVipSpend 订单完成时把订单的实付金额计入用户的会员累计消费, 不包含运费和已退款的金额
+func (ods *OrderDomainSvc) accrueOrderVipSpend(tx *gorm.DB, order *do.Order) error {
+	orderItems, err := ods.orderDao.GetOrderItems(order.ID)
+	if err != nil {
+		return errcode.Wrap("AccrueOrderVipSpendError", err)
+	}
+	spend := lo.SumBy(orderItems, func(item *model.OrderItem) int {
+		return item.PayMoney - item.RefundedMoney
+	})
+	return NewVipDomainSvc(ods.ctx).AccrueOrderSpend(tx, order.UserId, spend)
+}
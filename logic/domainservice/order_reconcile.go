@@ -0,0 +1,97 @@
+package domainservice
+
+import (
+	"time"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/logger"
+	"github.com/hd2yao/go-mall/config"
+	"github.com/hd2yao/go-mall/dal/cache"
+)
+
+const (
+	defaultUnpaidReconcileAfter = 5 * time.Minute
+	payReconcileBatchSize       = 100 // 每次对账的订单数
+)
+
+// 支付对账的处理结果, 记录在对账日志中
+const (
+	payReconcileSettled = "settled" // 支付平台已支付, 订单更新为已支付
+	payReconcileClosed  = "closed"  // 支付平台的交易已关闭, 订单关闭并恢复库存
+	payReconcileWaiting = "waiting" // 用户还没有完成支付, 等待支付结果通知或者超时关单
+	payReconcileSkipped = "skipped" // 订单已经不是待支付状态
+)
+
+// UnpaidReconcileAfter 发起支付超过这个时间还没有收到支付结果的订单需要主动查询支付结果
+func UnpaidReconcileAfter() time.Duration {
+	if config.App.Order.UnpaidReconcileAfter > 0 {
+		return config.App.Order.UnpaidReconcileAfter
+	}
+	return defaultUnpaidReconcileAfter
+}
+
+// ReconcileUnpaidOrders 支付对账, 向支付平台查询发起支付后长时间没有收到支付结果通知的订单
+// 已经支付成功的订单更新为已支付, 支付平台的交易已经关闭的订单关闭并恢复库存
+// 单个订单对账失败只记录日志, 下次执行时重新对账; 通过 Redis 锁保证在 interval 内只有一个服务实例执行对账
+func (ods *OrderDomainSvc) ReconcileUnpaidOrders(interval time.Duration) error {
+	locked, err := cache.LockPayReconcile(ods.ctx, interval)
+	if err != nil {
+		return errcode.Wrap("ReconcileUnpaidOrdersError", err)
+	}
+	if !locked {
+		return nil
+	}
+
+	log := logger.New(ods.ctx)
+	orderModels, err := ods.orderDao.GetOrdersByStatusUpdatedBefore(enum.OrderStatusUnPaid, time.Now().Add(-UnpaidReconcileAfter()), payReconcileBatchSize)
+	if err != nil {
+		return errcode.Wrap("ReconcileUnpaidOrdersError", err)
+	}
+	for _, orderModel := range orderModels {
+		if _, err = ods.ReconcileOrderPay(orderModel.OrderNo); err != nil {
+			log.Error("PayReconcileError", "orderNo", orderModel.OrderNo, "err", err)
+		}
+	}
+	return nil
+}
+
+// ReconcileOrderPay 向支付平台查询待支付订单的支付结果, 按查询结果更新订单, 返回对账的处理结果
+func (ods *OrderDomainSvc) ReconcileOrderPay(orderNo string) (decision string, err error) {
+	log := logger.New(ods.ctx)
+	order, err := ods.getOrderByNo(orderNo)
+	if err != nil {
+		return "", err
+	}
+	if order.OrderStatus != enum.OrderStatusUnPaid {
+		log.Info("PayReconcileDecision", "orderNo", orderNo, "orderStatus", order.OrderStatus, "decision", payReconcileSkipped)
+		return payReconcileSkipped, nil
+	}
+
+	payQuerier, err := NewOrderPayQuerier(order.PayType)
+	if err != nil {
+		return "", err
+	}
+	payResult, err := payQuerier.QueryOrderPay(ods.ctx, order)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case payResult.Paid:
+		err = ods.SetOrderPaySuccess(orderNo, order.PayType, payResult.PayTransId, payResult.PaidMoney, payResult.PaidAt)
+		decision = payReconcileSettled
+	case payResult.Closed:
+		statusLog := newOrderStatusLog(order, enum.OrderStatusUnpaidClose, enum.OrderActorSystem, 0, "支付对账: 支付平台的交易已关闭")
+		_, err = ods.closeUnpaidOrder(order, statusLog)
+		decision = payReconcileClosed
+	default:
+		decision = payReconcileWaiting
+	}
+	if err != nil {
+		return "", err
+	}
+	log.Info("PayReconcileDecision", "orderNo", orderNo, "payType", order.PayType, "tradeState", payResult.TradeState,
+		"payTransId", payResult.PayTransId, "decision", decision)
+	return decision, nil
+}
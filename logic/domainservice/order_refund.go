@@ -0,0 +1,584 @@
+package domainservice
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+
+	"github.com/hd2yao/go-mall/common/app"
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/logger"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/dal/dao"
+	"github.com/hd2yao/go-mall/library"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+// ApplyOrderRefund 用户申请退款, 申请后订单进入退款中的状态, 等待商家审核
+// applyItems 为要退款的商品和数量, 为空时退订单中所有还没退款的商品
+func (ods *OrderDomainSvc) ApplyOrderRefund(orderNo string, userId int64, reason string, applyItems []*do.OrderRefundItem) (*do.OrderRefund, error) {
+	order, err := ods.GetSpecifiedUserOrder(orderNo, userId)
+	if err != nil {
+		return nil, err
+	}
+	if order.PayState != enum.PayStatePaid || !CanTransitOrderStatus(order.OrderStatus, enum.OrderStatusRefunding, enum.OrderActorUser) {
+		return nil, errcode.ErrOrderCanNotRefund
+	}
+	// 拼团订单在拼团成功前不能申请退款, 拼团失败时由系统自动退款
+	if err = NewGroupBuyDomainSvc(ods.ctx).CheckOrderGroupSucceeded(order.OrderNo); err != nil {
+		return nil, err
+	}
+	refundItems, err := genOrderRefundItems(order, applyItems)
+	if err != nil {
+		return nil, err
+	}
+
+	refundNo, err := genSerialNo(userId)
+	if err != nil {
+		return nil, err
+	}
+	refund := &do.OrderRefund{
+		RefundNo:    refundNo,
+		OrderId:     order.ID,
+		OrderNo:     order.OrderNo,
+		UserId:      userId,
+		PayType:     order.PayType,
+		RefundMoney: orderRefundMoney(order, refundItems),
+		Reason:      reason,
+		Status:      enum.RefundStatusApplied,
+		Items:       refundItems,
+		// 记录申请前的订单状态, 拒绝退款、退款失败或者部分退款成功后恢复
+		OrderStatusBefore: order.OrderStatus,
+	}
+
+	tx := dao.DBMaster().Begin()
+	panicked := true
+	defer func() {
+		if err != nil || panicked {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	// 只有订单状态没有被并发的请求修改时才能申请成功, 保证一个订单同时只有一个进行中的退款
+	statusLog := newOrderStatusLog(order, enum.OrderStatusRefunding, enum.OrderActorUser, userId, "用户申请退款: "+reason)
+	updated, err := ods.TransitOrderStatus(tx, statusLog, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		err = errcode.ErrOrderCanNotBeChanged
+		return nil, err
+	}
+	refundDao := dao.NewOrderRefundDao(ods.ctx)
+	if err = refundDao.CreateRefund(tx, refund); err != nil {
+		return nil, errcode.Wrap("ApplyOrderRefundError", err)
+	}
+
+	panicked = false
+	return refund, nil
+}
+
+// genOrderRefundItems 根据申请退款的商品和数量生成退款明细
+// 每个订单明细的退款金额按创建订单时分摊后的实付金额计算, 保证退款金额不会超过用户实际支付的金额
+func genOrderRefundItems(order *do.Order, applyItems []*do.OrderRefundItem) ([]*do.OrderRefundItem, error) {
+	fillOrderItemsPayMoney(order)
+	if len(applyItems) == 0 {
+		// 没有指定商品时退所有还没退款的商品
+		for _, orderItem := range order.Items {
+			if remainNum := orderItem.CommodityNum - orderItem.RefundedNum; remainNum > 0 {
+				applyItems = append(applyItems, &do.OrderRefundItem{CommodityId: orderItem.CommodityId, RefundNum: remainNum})
+			}
+		}
+		if len(applyItems) == 0 {
+			return nil, errcode.ErrOrderCanNotRefund
+		}
+	}
+
+	orderItemMap := lo.KeyBy(order.Items, func(item *do.OrderItem) int64 {
+		return item.CommodityId
+	})
+	refundItems := make([]*do.OrderRefundItem, 0, len(applyItems))
+	for _, applyItem := range applyItems {
+		orderItem, ok := orderItemMap[applyItem.CommodityId]
+		if !ok || applyItem.RefundNum <= 0 || applyItem.RefundNum > orderItem.CommodityNum-orderItem.RefundedNum {
+			return nil, errcode.ErrOrderRefundItemInvalid
+		}
+		if lo.ContainsBy(refundItems, func(item *do.OrderRefundItem) bool { return item.OrderItemId == orderItem.ID }) {
+			return nil, errcode.ErrOrderRefundItemInvalid
+		}
+		refundItems = append(refundItems, &do.OrderRefundItem{
+			OrderItemId: orderItem.ID,
+			CommodityId: orderItem.CommodityId,
+			RefundNum:   applyItem.RefundNum,
+			RefundMoney: orderItem.RefundMoney(applyItem.RefundNum),
+		})
+	}
+	return refundItems, nil
+}
+
+// orderRefundMoney 退款单的退款金额, 为退款明细的退款金额之和
+// 商品的实付金额分摊时不包含运费, 退款后订单的商品全部退完时 (全额退款或者退最后一部分商品) 再加上订单的运费
+func orderRefundMoney(order *do.Order, refundItems []*do.OrderRefundItem) int {
+	refundMoney := lo.SumBy(refundItems, func(item *do.OrderRefundItem) int { return item.RefundMoney })
+	refundNumMap := lo.SliceToMap(refundItems, func(item *do.OrderRefundItem) (int64, int) {
+		return item.OrderItemId, item.RefundNum
+	})
+	allRefunded := lo.EveryBy(order.Items, func(item *do.OrderItem) bool {
+		return item.RefundedNum+refundNumMap[item.ID] >= item.CommodityNum
+	})
+	if allRefunded {
+		refundMoney += order.FreightMoney
+	}
+	return refundMoney
+}
+
+// fillOrderItemsPayMoney 订单明细没有保存分摊的实付金额时 (支持部分退款之前创建的订单),
+// 把订单的实付金额按商品金额的比例分摊到每个订单明细上
+func fillOrderItemsPayMoney(order *do.Order) {
+	if lo.SomeBy(order.Items, func(item *do.OrderItem) bool { return item.BillMoney > 0 }) {
+		return
+	}
+	itemMoneys := lo.Map(order.Items, func(item *do.OrderItem, index int) int {
+		return item.CommoditySellingPrice * item.CommodityNum
+	})
+	payMoneys := AllocateMoney(order.PayMoney-order.FreightMoney, itemMoneys)
+	for i, item := range order.Items {
+		item.BillMoney = itemMoneys[i]
+		item.PayMoney = payMoneys[i]
+	}
+}
+
+// GetUserOrderRefunds 获取用户订单的退款单列表
+func (ods *OrderDomainSvc) GetUserOrderRefunds(orderNo string, userId int64) ([]*do.OrderRefund, error) {
+	orderModel, err := ods.orderDao.GetOrderByNo(orderNo)
+	if err != nil {
+		return nil, errcode.Wrap("GetUserOrderRefundsError", err)
+	}
+	if orderModel.ID == 0 || orderModel.UserId != userId {
+		return nil, errcode.ErrOrderParams
+	}
+
+	refundModels, err := dao.NewOrderRefundDao(ods.ctx).GetOrderRefunds(orderModel.ID)
+	if err != nil {
+		return nil, errcode.Wrap("GetUserOrderRefundsError", err)
+	}
+	refunds := make([]*do.OrderRefund, 0, len(refundModels))
+	if err = util.CopyProperties(&refunds, &refundModels); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	if err = ods.fillRefundsItems(refunds); err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
+
+// GetRefundList 管理后台按状态查询退款单, status 小于 0 时查询全部
+func (ods *OrderDomainSvc) GetRefundList(status int, pagination *app.Pagination) ([]*do.OrderRefund, error) {
+	refundModels, totalRows, err := dao.NewOrderRefundDao(ods.ctx).GetRefundList(status, pagination.Offset(), pagination.GetPageSize())
+	if err != nil {
+		return nil, errcode.Wrap("GetRefundListError", err)
+	}
+	pagination.SetTotalRows(int(totalRows))
+	refunds := make([]*do.OrderRefund, 0, len(refundModels))
+	if err = util.CopyProperties(&refunds, &refundModels); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	if err = ods.fillRefundsItems(refunds); err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
+
+// ApproveOrderRefund 商家同意退款, 通过订单支付方式对应的退款策略向支付平台发起退款
+func (ods *OrderDomainSvc) ApproveOrderRefund(refundNo string) error {
+	refundDao := dao.NewOrderRefundDao(ods.ctx)
+	refund, err := ods.getRefund(refundNo)
+	if err != nil {
+		return err
+	}
+	if refund.Status != enum.RefundStatusApplied {
+		return errcode.ErrOrderRefundCanNotChanged
+	}
+	order, err := ods.GetSpecifiedUserOrder(refund.OrderNo, refund.UserId)
+	if err != nil {
+		return err
+	}
+	refundStrategy, err := NewOrderRefundStrategy(order.PayType)
+	if err != nil {
+		return err
+	}
+
+	err = dao.DBMaster().Transaction(func(tx *gorm.DB) error {
+		updated, err := refundDao.UpdateRefundFromStatus(tx, refund.ID, enum.RefundStatusApplied, map[string]interface{}{
+			"status": enum.RefundStatusProcessing,
+		})
+		if err != nil {
+			return errcode.Wrap("ApproveOrderRefundError", err)
+		}
+		if !updated {
+			return errcode.ErrOrderRefundCanNotChanged
+		}
+		if _, err = ods.orderDao.UpdateOrderFromStatus(tx, order.ID, refundingOrderStatus(refund), map[string]interface{}{
+			"pay_state": enum.PayStateRefunding,
+		}); err != nil {
+			return errcode.Wrap("ApproveOrderRefundError", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	refundResult, err := refundStrategy.CreateRefund(ods.ctx, order, refund)
+	if err != nil {
+		// 支付平台没有受理退款, 把退款单恢复成待审核, 商家可以再次发起
+		logger.New(ods.ctx).Error("CreateRefundError", "refundNo", refundNo, "err", err)
+		if restoreErr := ods.restoreAppliedRefund(refund); restoreErr != nil {
+			// 恢复失败时退款单停留在退款中, 需要人工核对支付平台的退款记录后处理
+			logger.New(ods.ctx).Error("RestoreAppliedRefundError", "refundNo", refundNo, "orderNo", refund.OrderNo, "err", restoreErr)
+		}
+		return err
+	}
+	if refundResult.Success {
+		return ods.SetRefundSuccess(refundNo, refundResult.RefundTransId, refundResult.RefundedAt)
+	}
+
+	// 退款处理中, 等待支付平台的退款结果通知
+	_, err = refundDao.UpdateRefundFromStatus(dao.DBMaster(), refund.ID, enum.RefundStatusProcessing, map[string]interface{}{
+		"refund_trans_id": refundResult.RefundTransId,
+	})
+	if err != nil {
+		return errcode.Wrap("ApproveOrderRefundError", err)
+	}
+	return nil
+}
+
+// RejectOrderRefund 商家拒绝退款, 订单恢复成申请退款前的状态
+func (ods *OrderDomainSvc) RejectOrderRefund(refundNo, rejectReason string) error {
+	refund, err := ods.getRefund(refundNo)
+	if err != nil {
+		return err
+	}
+	if refund.Status != enum.RefundStatusApplied || isClosedOrderRefund(refund) {
+		// 订单关闭后收到的支付必须退还给用户, 不能拒绝
+		return errcode.ErrOrderRefundCanNotChanged
+	}
+
+	tx := dao.DBMaster().Begin()
+	panicked := true
+	defer func() {
+		if err != nil || panicked {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	updated, err := dao.NewOrderRefundDao(ods.ctx).UpdateRefundFromStatus(tx, refund.ID, enum.RefundStatusApplied, map[string]interface{}{
+		"status":        enum.RefundStatusRejected,
+		"reject_reason": rejectReason,
+	})
+	if err != nil {
+		return errcode.Wrap("RejectOrderRefundError", err)
+	}
+	if !updated {
+		return errcode.ErrOrderRefundCanNotChanged
+	}
+	statusLog := newRefundOrderStatusLog(refund, refund.OrderStatusBefore, enum.OrderActorMerchant, "商家拒绝退款: "+rejectReason)
+	if _, err = ods.TransitOrderStatus(tx, statusLog, nil); err != nil {
+		return err
+	}
+
+	panicked = false
+	return nil
+}
+
+// SetRefundSuccess 支付平台退款成功后, 把退款单和订单设置为已退款并恢复商品库存
+// 同一笔退款重复通知时直接返回成功, 保证幂等
+func (ods *OrderDomainSvc) SetRefundSuccess(refundNo, refundTransId string, refundedAt time.Time) error {
+	log := logger.New(ods.ctx)
+	refund, err := ods.getRefund(refundNo)
+	if err != nil {
+		return err
+	}
+	if refund.Status == enum.RefundStatusSuccess {
+		return nil
+	}
+	if refund.Status != enum.RefundStatusProcessing {
+		log.Error("RefundStatusNotProcessing", "refundNo", refundNo, "status", refund.Status, "refundTransId", refundTransId)
+		return errcode.ErrOrderRefundCanNotChanged
+	}
+	order, err := ods.GetSpecifiedUserOrder(refund.OrderNo, refund.UserId)
+	if err != nil {
+		return err
+	}
+	if refundedAt.IsZero() {
+		refundedAt = time.Now()
+	}
+
+	updated, err := ods.finishRefund(order, refund, refundTransId, refundedAt)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		// 并发的重复通知已经处理了这笔退款
+		return nil
+	}
+
+	if isClosedOrderRefund(refund) {
+		// 订单关闭时已经恢复过商品库存
+		return nil
+	}
+	// 恢复退款商品的库存, 退款已经成功了, 恢复失败时记录日志人工处理, 不影响退款结果
+	stockItems := order.Items
+	if len(refund.Items) > 0 {
+		stockItems = lo.Map(refund.Items, func(item *do.OrderRefundItem, index int) *do.OrderItem {
+			return &do.OrderItem{CommodityId: item.CommodityId, CommodityNum: item.RefundNum}
+		})
+	}
+	if err = dao.NewCommodityDao(ods.ctx).RecoverOrderCommodityStuck(stockItems); err != nil {
+		log.Error("RefundRecoverStockError", "refundNo", refundNo, "orderNo", order.OrderNo, "err", err)
+	}
+	return nil
+}
+
+// finishRefund 在事务中把退款单设置为退款成功并累加订单明细的已退款数量和金额
+// 订单的商品全部退完时订单设置为已退款, 部分退款时订单恢复成申请退款前的状态
+func (ods *OrderDomainSvc) finishRefund(order *do.Order, refund *do.OrderRefund, refundTransId string, refundedAt time.Time) (updated bool, err error) {
+	tx := dao.DBMaster().Begin()
+	panicked := true
+	defer func() {
+		if err != nil || panicked {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	updated, err = dao.NewOrderRefundDao(ods.ctx).UpdateRefundFromStatus(tx, refund.ID, enum.RefundStatusProcessing, map[string]interface{}{
+		"status":          enum.RefundStatusSuccess,
+		"refund_trans_id": refundTransId,
+		"refunded_at":     refundedAt,
+	})
+	if err != nil {
+		return false, errcode.Wrap("SetRefundSuccessError", err)
+	}
+	if !updated {
+		panicked = false
+		return false, nil
+	}
+	if isClosedOrderRefund(refund) {
+		// 订单关闭后收到的支付退款成功, 订单保持关闭的状态, 只更新支付状态
+		if _, err = ods.orderDao.UpdateOrderFromStatus(tx, order.ID, refund.OrderStatusBefore, map[string]interface{}{
+			"pay_state": enum.PayStateRefunded,
+		}); err != nil {
+			return false, errcode.Wrap("SetRefundSuccessError", err)
+		}
+		panicked = false
+		return true, nil
+	}
+	refundedNumMap := lo.SliceToMap(order.Items, func(item *do.OrderItem) (int64, int) {
+		return item.ID, item.RefundedNum
+	})
+	for _, refundItem := range refund.Items {
+		itemUpdated, err := ods.orderDao.AddOrderItemRefunded(tx, refundItem.OrderItemId, refundItem.RefundNum, refundItem.RefundMoney)
+		if err != nil {
+			return false, errcode.Wrap("SetRefundSuccessError", err)
+		}
+		if !itemUpdated {
+			return false, errcode.ErrOrderRefundItemInvalid
+		}
+		refundedNumMap[refundItem.OrderItemId] += refundItem.RefundNum
+	}
+	// 没有退款明细的退款单 (支持部分退款之前申请的) 退的是整个订单
+	allRefunded := len(refund.Items) == 0 || lo.EveryBy(order.Items, func(item *do.OrderItem) bool {
+		return refundedNumMap[item.ID] >= item.CommodityNum
+	})
+	statusLog := newRefundOrderStatusLog(refund, enum.OrderStatusRefunded, enum.OrderActorPayment, "退款成功, 退款单号: "+refund.RefundNo)
+	orderUpdates := map[string]interface{}{"pay_state": enum.PayStateRefunded}
+	if !allRefunded {
+		statusLog = newRefundOrderStatusLog(refund, refund.OrderStatusBefore, enum.OrderActorPayment, "部分退款成功, 退款单号: "+refund.RefundNo)
+		orderUpdates = map[string]interface{}{"pay_state": enum.PayStatePaid}
+	}
+	if _, err = ods.TransitOrderStatus(tx, statusLog, orderUpdates); err != nil {
+		return false, err
+	}
+
+	panicked = false
+	return true, nil
+}
+
+// SetRefundFailed 支付平台退款失败或退款关闭后, 把退款单设置为退款失败, 订单恢复成申请退款前的状态
+func (ods *OrderDomainSvc) SetRefundFailed(refundNo, refundStatus string) error {
+	logger.New(ods.ctx).Error("OrderRefundFailed", "refundNo", refundNo, "refundStatus", refundStatus)
+	refund, err := ods.getRefund(refundNo)
+	if err != nil {
+		return err
+	}
+	if refund.Status == enum.RefundStatusFailed {
+		return nil
+	}
+	if isClosedOrderRefund(refund) {
+		// 订单关闭后收到的支付退款失败时, 退款单恢复成待审核, 由商家在管理后台重新发起退款
+		return ods.restoreAppliedRefund(refund)
+	}
+
+	tx := dao.DBMaster().Begin()
+	panicked := true
+	defer func() {
+		if err != nil || panicked {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	updated, err := dao.NewOrderRefundDao(ods.ctx).UpdateRefundFromStatus(tx, refund.ID, enum.RefundStatusProcessing, map[string]interface{}{
+		"status": enum.RefundStatusFailed,
+	})
+	if err != nil {
+		return errcode.Wrap("SetRefundFailedError", err)
+	}
+	if !updated {
+		return errcode.ErrOrderRefundCanNotChanged
+	}
+	statusLog := newRefundOrderStatusLog(refund, refund.OrderStatusBefore, enum.OrderActorPayment, "退款失败: "+refundStatus)
+	if _, err = ods.TransitOrderStatus(tx, statusLog, map[string]interface{}{
+		"pay_state": enum.PayStatePaid,
+	}); err != nil {
+		return err
+	}
+
+	panicked = false
+	return nil
+}
+
+// restoreAppliedRefund 把退款中的退款单恢复成待审核, 订单的支付状态恢复成已支付, 订单状态保持不变
+func (ods *OrderDomainSvc) restoreAppliedRefund(refund *do.OrderRefund) error {
+	return dao.DBMaster().Transaction(func(tx *gorm.DB) error {
+		updated, err := dao.NewOrderRefundDao(ods.ctx).UpdateRefundFromStatus(tx, refund.ID, enum.RefundStatusProcessing, map[string]interface{}{
+			"status": enum.RefundStatusApplied,
+		})
+		if err != nil {
+			return errcode.Wrap("RestoreAppliedRefundError", err)
+		}
+		if !updated {
+			// 重复的退款失败通知已经把退款单恢复成了待审核
+			return nil
+		}
+		if _, err = ods.orderDao.UpdateOrderFromStatus(tx, refund.OrderId, refundingOrderStatus(refund), map[string]interface{}{
+			"pay_state": enum.PayStatePaid,
+		}); err != nil {
+			return errcode.Wrap("RestoreAppliedRefundError", err)
+		}
+		return nil
+	})
+}
+
+// isClosedOrderRefund 是否是订单关闭后收到支付时自动创建的退款单, 这类退款单不改变订单的状态
+func isClosedOrderRefund(refund *do.OrderRefund) bool {
+	return lo.Contains(closedOrderStatus, refund.OrderStatusBefore)
+}
+
+// refundingOrderStatus 退款处理中时订单的状态
+func refundingOrderStatus(refund *do.OrderRefund) int {
+	if isClosedOrderRefund(refund) {
+		return refund.OrderStatusBefore
+	}
+	return enum.OrderStatusRefunding
+}
+
+// HandleWxRefundNotify 处理微信支付的退款结果通知
+// 微信支付文档: https://pay.weixin.qq.com/docs/merchant/apis/jsapi-payment/refund-result-notice.html
+func (ods *OrderDomainSvc) HandleWxRefundNotify(timestamp, nonce, signature, rawBody string) error {
+	notifyTime, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errcode.ErrOrderPayNotifyInvalid.WithCause(err)
+	}
+	if time.Since(time.Unix(notifyTime, 0)).Abs() > payNotifyTimestampTolerance {
+		return errcode.ErrOrderPayNotifyInvalid.WithCause(errors.New("notify timestamp expired"))
+	}
+
+	wxPayConfig := newWxPayConfig()
+	wpl := library.NewWxPayLib(ods.ctx, *wxPayConfig)
+	verified, err := wpl.ValidateNotifySignature(timestamp, nonce, signature, rawBody)
+	if err != nil || !verified {
+		return errcode.ErrOrderPayNotifyInvalid.WithCause(err)
+	}
+	refundResult, err := wpl.DecryptRefundNotifyResourceData(rawBody)
+	if err != nil {
+		return errcode.ErrOrderPayNotifyInvalid.WithCause(err)
+	}
+	logger.New(ods.ctx).Info("WxRefundNotifyResource", "refundResult", refundResult)
+	if refundResult.Mchid != wxPayConfig.MchId {
+		return errcode.ErrOrderPayNotifyInvalid.WithCause(fmt.Errorf("mchid not match, notify mchid: %s", refundResult.Mchid))
+	}
+
+	switch refundResult.RefundStatus {
+	case library.WxRefundStatusSuccess:
+		return ods.SetRefundSuccess(refundResult.OutRefundNo, refundResult.RefundId, refundResult.SuccessTime)
+	case library.WxRefundStatusClosed, library.WxRefundStatusAbnormal:
+		return ods.SetRefundFailed(refundResult.OutRefundNo, refundResult.RefundStatus)
+	default:
+		return nil
+	}
+}
+
+// newRefundOrderStatusLog 生成退款单对应的订单由退款中变更为 toStatus 状态的变更记录
+func newRefundOrderStatusLog(refund *do.OrderRefund, toStatus, actor int, reason string) *do.OrderStatusLog {
+	return &do.OrderStatusLog{
+		OrderId:    refund.OrderId,
+		OrderNo:    refund.OrderNo,
+		FromStatus: enum.OrderStatusRefunding,
+		ToStatus:   toStatus,
+		Actor:      actor,
+		Reason:     reason,
+	}
+}
+
+// getRefund 根据退款单号获取退款单
+func (ods *OrderDomainSvc) getRefund(refundNo string) (*do.OrderRefund, error) {
+	refundModel, err := dao.NewOrderRefundDao(ods.ctx).GetRefundByNo(refundNo)
+	if err != nil {
+		return nil, errcode.Wrap("GetRefundError", err)
+	}
+	if refundModel.ID == 0 {
+		return nil, errcode.ErrOrderRefundNotExist
+	}
+	refund := new(do.OrderRefund)
+	if err = util.CopyProperties(refund, refundModel); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	if err = ods.fillRefundsItems([]*do.OrderRefund{refund}); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// fillRefundsItems 填充退款单的退款明细
+func (ods *OrderDomainSvc) fillRefundsItems(refunds []*do.OrderRefund) error {
+	if len(refunds) == 0 {
+		return nil
+	}
+	refundIds := lo.Map(refunds, func(refund *do.OrderRefund, index int) int64 {
+		return refund.ID
+	})
+	refundItemsMap, err := dao.NewOrderRefundDao(ods.ctx).GetMultiRefundsItems(refundIds)
+	if err != nil {
+		return errcode.Wrap("GetRefundItemsError", err)
+	}
+	for _, refund := range refunds {
+		refundItems := refundItemsMap[refund.ID]
+		if err = util.CopyProperties(&refund.Items, &refundItems); err != nil {
+			return errcode.ErrCoverData.WithCause(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package domainservice
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/library"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+// OrderRefundStrategyContract 退款策略, 根据订单的支付方式调用对应支付平台的退款接口
+type OrderRefundStrategyContract interface {
+	// CreateRefund 向支付平台发起退款
+	CreateRefund(ctx context.Context, order *do.Order, refund *do.OrderRefund) (*do.RefundResult, error)
+}
+
+// NewOrderRefundStrategy 根据订单的支付方式选择退款策略
+func NewOrderRefundStrategy(payType int) (OrderRefundStrategyContract, error) {
+	switch payType {
+	case enum.PayTypeWxPay:
+		return new(WxRefundStrategy), nil
+	case enum.PayTypeAliPay:
+		return new(AliRefundStrategy), nil
+	default:
+		return nil, errcode.ErrOrderCanNotRefund
+	}
+}
+
+// WxRefundStrategy 微信支付退款, 微信支付的退款是异步的, 受理成功后需要等待退款结果通知
+type WxRefundStrategy struct {
+}
+
+func (strategy *WxRefundStrategy) CreateRefund(ctx context.Context, order *do.Order, refund *do.OrderRefund) (*do.RefundResult, error) {
+	wxPayGateway := newWxPayGateway(ctx, *newWxPayConfig())
+	refundReply, err := wxPayGateway.CreateRefund(order, refund)
+	if err != nil {
+		return nil, errcode.Wrap("WxRefundStrategyCreateRefundError", err)
+	}
+	if refundReply.Status == library.WxRefundStatusClosed || refundReply.Status == library.WxRefundStatusAbnormal {
+		return nil, errcode.Wrap("WxRefundStrategyCreateRefundError", fmt.Errorf("refund status: %s", refundReply.Status))
+	}
+
+	return &do.RefundResult{
+		RefundTransId: refundReply.RefundId,
+		Success:       refundReply.Status == library.WxRefundStatusSuccess,
+		RefundedAt:    refundReply.SuccessTime,
+	}, nil
+}
+
+// AliRefundStrategy 支付宝退款, 支付宝的退款接口同步返回退款结果
+type AliRefundStrategy struct {
+}
+
+func (strategy *AliRefundStrategy) CreateRefund(ctx context.Context, order *do.Order, refund *do.OrderRefund) (*do.RefundResult, error) {
+	aliPayGateway := newAliPayGateway(ctx, *newAliPayConfig())
+	refundReply, err := aliPayGateway.CreateRefund(order, refund)
+	if err != nil {
+		return nil, errcode.Wrap("AliRefundStrategyCreateRefundError", err)
+	}
+
+	refundedAt, err := time.ParseInLocation(enum.TimeFormatHyphenedYMDHIS, refundReply.GmtRefundPay, time.Local)
+	if err != nil {
+		refundedAt = time.Now()
+	}
+	return &do.RefundResult{
+		RefundTransId: refundReply.TradeNo,
+		Success:       true,
+		RefundedAt:    refundedAt,
+	}, nil
+}
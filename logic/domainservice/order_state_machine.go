@@ -0,0 +1,136 @@
+package domainservice
+
+import (
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/dal/dao"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+// orderTransition 订单状态的一次变更
+type orderTransition struct {
+	From int
+	To   int
+}
+
+// 可以申请退款的订单状态, 订单完成后不能再申请退款
+var refundableOrderStatus = []int{
+	enum.OrderStatusPaid,
+	enum.OrderStatusChecked,
+	enum.OrderStatusShipped,
+	enum.OrderStatusOnDelivery,
+	enum.OrderStatusDelivered,
+	enum.OrderStatusConfirmReceipt,
+}
+
+// 订单关闭的状态, 关闭后收到的支付会自动全额退款, 退款不改变订单的状态
+var closedOrderStatus = []int{
+	enum.OrderStatusUserQuit,
+	enum.OrderStatusUnpaidClose,
+	enum.OrderStatusMerchantClose,
+}
+
+// orderStateMachine 订单状态机, 记录订单允许的状态变更以及可以触发变更的操作方
+// 不在表中的状态变更都是不允许的, 修改订单状态前都要先经过状态机的校验
+var orderStateMachine = newOrderStateMachine()
+
+func newOrderStateMachine() map[orderTransition][]int {
+	machine := map[orderTransition][]int{
+		// 发起支付
+		{enum.OrderStatusCreated, enum.OrderStatusUnPaid}: {enum.OrderActorUser},
+		// 支付成功, 支付结果通知或者主动查询到的支付结果
+		{enum.OrderStatusCreated, enum.OrderStatusPaid}: {enum.OrderActorPayment},
+		{enum.OrderStatusUnPaid, enum.OrderStatusPaid}:  {enum.OrderActorPayment},
+		// 未支付的订单被用户取消、超时关闭或者商家关闭
+		{enum.OrderStatusCreated, enum.OrderStatusUserQuit}:      {enum.OrderActorUser},
+		{enum.OrderStatusUnPaid, enum.OrderStatusUserQuit}:       {enum.OrderActorUser},
+		{enum.OrderStatusCreated, enum.OrderStatusUnpaidClose}:   {enum.OrderActorSystem},
+		{enum.OrderStatusUnPaid, enum.OrderStatusUnpaidClose}:    {enum.OrderActorSystem},
+		{enum.OrderStatusCreated, enum.OrderStatusMerchantClose}: {enum.OrderActorMerchant},
+		{enum.OrderStatusUnPaid, enum.OrderStatusMerchantClose}:  {enum.OrderActorMerchant},
+		// 商家履约: 检货、发货、配送、送达
+		{enum.OrderStatusPaid, enum.OrderStatusChecked}:         {enum.OrderActorMerchant},
+		{enum.OrderStatusChecked, enum.OrderStatusShipped}:      {enum.OrderActorMerchant},
+		{enum.OrderStatusShipped, enum.OrderStatusOnDelivery}:   {enum.OrderActorMerchant, enum.OrderActorSystem},
+		{enum.OrderStatusShipped, enum.OrderStatusDelivered}:    {enum.OrderActorMerchant, enum.OrderActorSystem},
+		{enum.OrderStatusOnDelivery, enum.OrderStatusDelivered}: {enum.OrderActorMerchant, enum.OrderActorSystem},
+		// 用户确认收货, 超过期限未确认的由系统自动确认
+		{enum.OrderStatusShipped, enum.OrderStatusConfirmReceipt}:    {enum.OrderActorUser, enum.OrderActorSystem},
+		{enum.OrderStatusOnDelivery, enum.OrderStatusConfirmReceipt}: {enum.OrderActorUser, enum.OrderActorSystem},
+		{enum.OrderStatusDelivered, enum.OrderStatusConfirmReceipt}:  {enum.OrderActorUser, enum.OrderActorSystem},
+		// 过了评价期的订单由系统设置为已完成
+		{enum.OrderStatusConfirmReceipt, enum.OrderStatusCompleted}: {enum.OrderActorSystem},
+		// 退款成功后订单的商品全部退完
+		{enum.OrderStatusRefunding, enum.OrderStatusRefunded}: {enum.OrderActorPayment},
+	}
+	for _, status := range refundableOrderStatus {
+		// 用户申请退款
+		machine[orderTransition{status, enum.OrderStatusRefunding}] = []int{enum.OrderActorUser}
+		// 商家拒绝退款、退款失败或者部分退款成功后订单恢复成申请退款前的状态
+		machine[orderTransition{enum.OrderStatusRefunding, status}] = []int{enum.OrderActorMerchant, enum.OrderActorPayment}
+	}
+	// 拼团失败后由系统为已支付的成员退款
+	paidToRefunding := orderTransition{enum.OrderStatusPaid, enum.OrderStatusRefunding}
+	machine[paidToRefunding] = append(machine[paidToRefunding], enum.OrderActorSystem)
+	return machine
+}
+
+// CanTransitOrderStatus 订单状态是否允许由操作方 actor 从 from 变更为 to
+func CanTransitOrderStatus(from, to, actor int) bool {
+	actors, ok := orderStateMachine[orderTransition{From: from, To: to}]
+	return ok && lo.Contains(actors, actor)
+}
+
+// TransitOrderStatus 在事务 tx 中把订单从 statusLog.FromStatus 变更为 statusLog.ToStatus 并记录状态变更日志
+// updates 为需要和订单状态一起更新的其他字段, 状态机不允许的变更返回 ErrOrderCanNotBeChanged
+// 返回值 updated 为 false 时表示订单的状态已经被并发的请求修改, 此时不会记录日志
+func (ods *OrderDomainSvc) TransitOrderStatus(tx *gorm.DB, statusLog *do.OrderStatusLog, updates map[string]interface{}) (updated bool, err error) {
+	if !CanTransitOrderStatus(statusLog.FromStatus, statusLog.ToStatus, statusLog.Actor) {
+		return false, errcode.ErrOrderCanNotBeChanged
+	}
+	orderUpdates := map[string]interface{}{"order_status": statusLog.ToStatus}
+	for column, value := range updates {
+		orderUpdates[column] = value
+	}
+	updated, err = ods.orderDao.UpdateOrderFromStatus(tx, statusLog.OrderId, statusLog.FromStatus, orderUpdates)
+	if err != nil {
+		return false, errcode.Wrap("TransitOrderStatusError", err)
+	}
+	if !updated {
+		return false, nil
+	}
+	if err = dao.NewOrderStatusLogDao(ods.ctx).CreateStatusLog(tx, statusLog); err != nil {
+		return false, errcode.Wrap("TransitOrderStatusError", err)
+	}
+	return true, nil
+}
+
+// newOrderStatusLog 生成订单 order 由操作方 actor 变更为 toStatus 状态的变更记录
+func newOrderStatusLog(order *do.Order, toStatus, actor int, actorId int64, reason string) *do.OrderStatusLog {
+	return &do.OrderStatusLog{
+		OrderId:    order.ID,
+		OrderNo:    order.OrderNo,
+		FromStatus: order.OrderStatus,
+		ToStatus:   toStatus,
+		Actor:      actor,
+		ActorId:    actorId,
+		Reason:     reason,
+	}
+}
+
+// GetOrderStatusLogs 获取订单的状态变更记录
+func (ods *OrderDomainSvc) GetOrderStatusLogs(orderId int64) ([]*do.OrderStatusLog, error) {
+	statusLogModels, err := dao.NewOrderStatusLogDao(ods.ctx).GetOrderStatusLogs(orderId)
+	if err != nil {
+		return nil, errcode.Wrap("GetOrderStatusLogsError", err)
+	}
+	statusLogs := make([]*do.OrderStatusLog, 0, len(statusLogModels))
+	if err = util.CopyProperties(&statusLogs, &statusLogModels); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return statusLogs, nil
+}
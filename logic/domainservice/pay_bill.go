@@ -0,0 +1,181 @@
+package domainservice
+
+import (
+	"time"
+
+	"github.com/samber/lo"
+
+	"github.com/hd2yao/go-mall/common/app"
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/logger"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/config"
+	"github.com/hd2yao/go-mall/dal/cache"
+	"github.com/hd2yao/go-mall/dal/dao"
+	"github.com/hd2yao/go-mall/library"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+const (
+	defaultPayBillCheckHour = 10
+	// 对账成功后锁作为已对账的标记, 保留到账单日期之后的第二天
+	payBillCheckLockTTL = 48 * time.Hour
+)
+
+// 本地订单在支付平台支付成功后的支付状态, 退款中和已退款的订单也是支付成功过的
+var paidPayStates = []int{enum.PayStatePaid, enum.PayStateRefunding, enum.PayStateRefunded}
+
+// PayBillCheckHour 每天这个时间(点)之后下载前一天的交易账单对账
+func PayBillCheckHour() int {
+	if config.App.Order.PayBillCheckHour > 0 {
+		return config.App.Order.PayBillCheckHour
+	}
+	return defaultPayBillCheckHour
+}
+
+// CheckDailyWxTradeBill 每天 PayBillCheckHour 点之后下载前一天的微信支付交易账单和本地订单对账
+// 通过 Redis 锁保证一天的账单只对一次账, 对账失败时释放锁, 下次检查时重新对账
+func (ods *OrderDomainSvc) CheckDailyWxTradeBill() error {
+	now := time.Now()
+	if now.Hour() < PayBillCheckHour() {
+		return nil
+	}
+	billDate := now.AddDate(0, 0, -1)
+	billDateStr := billDate.Format(enum.TimeFormatHyphenedYMD)
+	locked, err := cache.LockPayBillCheck(ods.ctx, enum.PayTypeWxPay, billDateStr, payBillCheckLockTTL)
+	if err != nil {
+		return errcode.Wrap("CheckDailyWxTradeBillError", err)
+	}
+	if !locked {
+		return nil
+	}
+
+	if _, err = ods.ReconcileWxTradeBill(billDate); err != nil {
+		if unlockErr := cache.UnlockPayBillCheck(ods.ctx, enum.PayTypeWxPay, billDateStr); unlockErr != nil {
+			logger.New(ods.ctx).Error("UnlockPayBillCheckError", "billDate", billDateStr, "err", unlockErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// ReconcileWxTradeBill 下载 billDate 当天的微信支付交易账单, 按订单号、支付平台交易号、支付金额和本地订单比对
+// 比对出的差异保存到对账差异表, 同一天的账单重新对账时覆盖上一次的结果
+func (ods *OrderDomainSvc) ReconcileWxTradeBill(billDate time.Time) ([]*do.PayBillMismatch, error) {
+	wxPayGateway := newWxPayGateway(ods.ctx, *newWxPayConfig())
+	records, err := wxPayGateway.DownloadTradeBill(billDate)
+	if err != nil {
+		return nil, errcode.Wrap("ReconcileWxTradeBillError", err)
+	}
+	mismatches, err := ods.diffWxTradeBill(billDate, records)
+	if err != nil {
+		return nil, err
+	}
+
+	billDateStr := billDate.Format(enum.TimeFormatHyphenedYMD)
+	if err = dao.NewPayBillDao(ods.ctx).ReplaceMismatches(billDateStr, enum.PayTypeWxPay, mismatches); err != nil {
+		return nil, errcode.Wrap("ReconcileWxTradeBillError", err)
+	}
+	logger.New(ods.ctx).Info("WxTradeBillReconciled", "billDate", billDateStr, "records", len(records), "mismatches", len(mismatches))
+	return mismatches, nil
+}
+
+// diffWxTradeBill 比对交易账单中支付成功的交易和本地的订单
+// 账单中的交易以订单号查找本地订单, 找不到为本地缺失, 本地未支付、交易号不一致为状态不一致, 金额不一致为金额不一致
+// 本地在账单日期内支付成功的订单在账单中找不到时为渠道缺失
+func (ods *OrderDomainSvc) diffWxTradeBill(billDate time.Time, records []*library.WxTradeBillRecord) ([]*do.PayBillMismatch, error) {
+	billDateStr := billDate.Format(enum.TimeFormatHyphenedYMD)
+	paidRecords := lo.Filter(records, func(record *library.WxTradeBillRecord, _ int) bool {
+		return record.TradeState == library.WxBillTradeStateSuccess
+	})
+	orderModels, err := ods.orderDao.GetOrdersByNos(lo.Map(paidRecords, func(record *library.WxTradeBillRecord, _ int) string {
+		return record.OutTradeNo
+	}))
+	if err != nil {
+		return nil, errcode.Wrap("DiffWxTradeBillError", err)
+	}
+	localOrders := make([]*do.Order, 0, len(orderModels))
+	if err = util.CopyProperties(&localOrders, &orderModels); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	localOrderMap := lo.KeyBy(localOrders, func(order *do.Order) string {
+		return order.OrderNo
+	})
+
+	mismatches := make([]*do.PayBillMismatch, 0)
+	for _, record := range paidRecords {
+		mismatch := &do.PayBillMismatch{
+			BillDate:          billDateStr,
+			PayType:           enum.PayTypeWxPay,
+			OrderNo:           record.OutTradeNo,
+			ChannelTransId:    record.TransactionId,
+			ChannelPayMoney:   record.OrderTotal,
+			ChannelTradeState: record.TradeState,
+		}
+		order, ok := localOrderMap[record.OutTradeNo]
+		if !ok {
+			mismatch.MismatchType = enum.PayBillMismatchLocalMissing
+			mismatch.Remark = "支付平台交易成功, 本地没有这个订单"
+			mismatches = append(mismatches, mismatch)
+			continue
+		}
+		mismatch.LocalPayTransId = order.PayTransId
+		mismatch.LocalPayMoney = order.PayMoney
+		mismatch.LocalPayState = order.PayState
+		switch {
+		case !lo.Contains(paidPayStates, order.PayState):
+			mismatch.MismatchType = enum.PayBillMismatchStatus
+			mismatch.Remark = "支付平台交易成功, 本地订单未支付"
+		case order.PayMoney != record.OrderTotal:
+			mismatch.MismatchType = enum.PayBillMismatchAmount
+			mismatch.Remark = "本地订单的支付金额和账单中的订单金额不一致"
+		case order.PayTransId != record.TransactionId:
+			mismatch.MismatchType = enum.PayBillMismatchStatus
+			mismatch.Remark = "本地订单的支付平台交易号和账单不一致"
+		default:
+			continue
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+
+	// 本地在账单日期内支付成功的订单, 账单中必须有对应的交易
+	dayStart := time.Date(billDate.Year(), billDate.Month(), billDate.Day(), 0, 0, 0, 0, time.Local)
+	paidOrderModels, err := ods.orderDao.GetPaidOrdersBetween(enum.PayTypeWxPay, dayStart, dayStart.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, errcode.Wrap("DiffWxTradeBillError", err)
+	}
+	billOrderNos := lo.SliceToMap(paidRecords, func(record *library.WxTradeBillRecord) (string, struct{}) {
+		return record.OutTradeNo, struct{}{}
+	})
+	for _, orderModel := range paidOrderModels {
+		if _, ok := billOrderNos[orderModel.OrderNo]; ok || !lo.Contains(paidPayStates, orderModel.PayState) {
+			continue
+		}
+		mismatches = append(mismatches, &do.PayBillMismatch{
+			BillDate:        billDateStr,
+			PayType:         enum.PayTypeWxPay,
+			OrderNo:         orderModel.OrderNo,
+			MismatchType:    enum.PayBillMismatchChannelMissing,
+			LocalPayTransId: orderModel.PayTransId,
+			LocalPayMoney:   orderModel.PayMoney,
+			LocalPayState:   orderModel.PayState,
+			Remark:          "本地订单已支付, 账单中没有这笔交易",
+		})
+	}
+	return mismatches, nil
+}
+
+// GetPayBillMismatchList 管理后台查询对账差异, billDate 为空时查询全部日期, mismatchType 小于等于 0 时查询全部类型
+func (ods *OrderDomainSvc) GetPayBillMismatchList(billDate string, mismatchType int, pagination *app.Pagination) ([]*do.PayBillMismatch, error) {
+	mismatchModels, totalRows, err := dao.NewPayBillDao(ods.ctx).GetMismatchList(billDate, mismatchType, pagination.Offset(), pagination.GetPageSize())
+	if err != nil {
+		return nil, errcode.Wrap("GetPayBillMismatchListError", err)
+	}
+	pagination.SetTotalRows(int(totalRows))
+	mismatches := make([]*do.PayBillMismatch, 0, len(mismatchModels))
+	if err = util.CopyProperties(&mismatches, &mismatchModels); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return mismatches, nil
+}
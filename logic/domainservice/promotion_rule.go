@@ -0,0 +1,70 @@
+package domainservice
+
+import (
+	"context"
+
+	"github.com/samber/lo"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/logger"
+	"github.com/hd2yao/go-mall/config"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+// promotionChecker 参与优惠叠加计算的 checker
+// Check 在责任链中查询用户可以使用的优惠, Deduct 在计算账单时按优惠叠加规则给定的基准金额计算减免
+type promotionChecker interface {
+	cartBillCheckHandler
+	// Deduct 按每个购物项的基准金额 baseMoneys 计算这项优惠的减免, 返回这一步的说明和分摊到每个购物项上的减免金额
+	Deduct(cbc *CartBillChecker, baseMoneys []int) (description string, shares []int, err error)
+}
+
+// promotionCheckers 注册的优惠 checker, 只有注册过的优惠才能配置到优惠叠加规则中
+var promotionCheckers = make(map[string]func() promotionChecker)
+
+// registerPromotionChecker 注册优惠 checker, 新增一种优惠时实现 promotionChecker 并在这里注册, 再把它加到优惠叠加规则的配置中
+func registerPromotionChecker(name string, newChecker func() promotionChecker) {
+	promotionCheckers[name] = newChecker
+}
+
+func init() {
+	registerPromotionChecker(enum.PromotionVip, func() promotionChecker { return &vipChecker{} })
+	registerPromotionChecker(enum.PromotionCoupon, func() promotionChecker { return &couponChecker{} })
+	registerPromotionChecker(enum.PromotionDiscount, func() promotionChecker { return &discountChecker{} })
+}
+
+// defaultPromotionStackRules 没有配置优惠叠加规则时使用的规则:
+// 会员折扣和优惠券都按商品原价计算, 满减活动按减去会员折扣和优惠券后的金额判断门槛
+func defaultPromotionStackRules() *do.PromotionStackRules {
+	return &do.PromotionStackRules{
+		Steps: []*do.PromotionStackStep{
+			{Name: enum.PromotionVip, Base: enum.PromotionBaseOriginal},
+			{Name: enum.PromotionCoupon, Base: enum.PromotionBaseOriginal},
+			{Name: enum.PromotionDiscount, Base: enum.PromotionBaseReduced},
+		},
+	}
+}
+
+// PromotionStackRules 读取配置的优惠叠加规则, 规则中有没注册的优惠、重复的优惠或者基准金额不对时记录日志并使用默认规则
+func PromotionStackRules(ctx context.Context) *do.PromotionStackRules {
+	stacking := config.App.Promotion.Stacking
+	if len(stacking) == 0 {
+		return defaultPromotionStackRules()
+	}
+	rules := &do.PromotionStackRules{
+		Steps:      make([]*do.PromotionStackStep, 0, len(stacking)),
+		Exclusions: config.App.Promotion.Exclusions,
+	}
+	for _, step := range stacking {
+		_, registered := promotionCheckers[step.Name]
+		duplicated := lo.ContainsBy(rules.Steps, func(item *do.PromotionStackStep) bool {
+			return item.Name == step.Name
+		})
+		if !registered || duplicated || (step.Base != enum.PromotionBaseOriginal && step.Base != enum.PromotionBaseReduced) {
+			logger.New(ctx).Error("PromotionStackRulesInvalid", "name", step.Name, "base", step.Base)
+			return defaultPromotionStackRules()
+		}
+		rules.Steps = append(rules.Steps, &do.PromotionStackStep{Name: step.Name, Base: step.Base})
+	}
+	return rules
+}
@@ -0,0 +1,135 @@
+package domainservice
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/logger"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/dal/cache"
+)
+
+// serialNoGenerator 订单号、退款单号、发货单号的生成器, 服务启动时通过 StartSerialNoGenerator 获取机器ID
+var serialNoGenerator = new(leasedSnowflake)
+
+// leasedSnowflake 机器ID由 Redis 租约分配的雪花算法生成器
+type leasedSnowflake struct {
+	mu        sync.RWMutex
+	snowflake *util.Snowflake
+	token     string
+	// 租约在本地的过期时间, 续约失败超过这个时间后不再生成单号, 避免和之后拿到同一个机器ID的实例生成重复的单号
+	leaseDeadline time.Time
+}
+
+// StartSerialNoGenerator 获取单号生成器的机器ID, 在后台定时续约, ctx 取消后释放机器ID
+func StartSerialNoGenerator(ctx context.Context) error {
+	if err := serialNoGenerator.acquire(ctx); err != nil {
+		return err
+	}
+	go serialNoGenerator.keepAlive(ctx)
+	return nil
+}
+
+// genSerialNo 生成单号: 日期 + 雪花算法序列号 + 用户分片后缀, 用于订单号、退款单号和发货单号
+func genSerialNo(userId int64) (string, error) {
+	serialNoGenerator.mu.RLock()
+	snowflake, leaseDeadline := serialNoGenerator.snowflake, serialNoGenerator.leaseDeadline
+	serialNoGenerator.mu.RUnlock()
+	if snowflake == nil || time.Now().After(leaseDeadline) {
+		return "", errcode.Wrap("GenSerialNoError", errors.New("id worker lease is not held"))
+	}
+	serialNo, err := snowflake.NextNo(userId)
+	if err != nil {
+		return "", errcode.Wrap("GenSerialNoError", err)
+	}
+	return serialNo, nil
+}
+
+// acquire 获取一个空闲的机器ID, 生成器从这个机器ID上一次记录的生成时间之后开始生成单号
+func (g *leasedSnowflake) acquire(ctx context.Context) error {
+	token := util.RandomString(16)
+	leaseStart := time.Now()
+	workerId, lastMs, err := cache.AcquireIdWorker(ctx, token, util.SnowflakeMaxWorkerId, enum.IdWorkerLeaseDuration)
+	if err != nil {
+		return errcode.Wrap("AcquireIdWorkerError", err)
+	}
+	if workerId < 0 {
+		return errcode.Wrap("AcquireIdWorkerError", errors.New("all id workers are in use"))
+	}
+	snowflake, err := util.NewSnowflake(workerId, lastMs)
+	if err != nil {
+		return errcode.Wrap("AcquireIdWorkerError", err)
+	}
+
+	g.mu.Lock()
+	g.snowflake = snowflake
+	g.token = token
+	g.leaseDeadline = leaseStart.Add(enum.IdWorkerLeaseDuration)
+	g.mu.Unlock()
+	logger.New(ctx).Info("IdWorkerAcquired", "workerId", workerId, "lastMs", lastMs)
+	return nil
+}
+
+// keepAlive 每隔三分之一租约有效期续约一次, 租约丢失后重新获取机器ID, ctx 取消后释放机器ID
+func (g *leasedSnowflake) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(enum.IdWorkerLeaseDuration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			g.release()
+			return
+		case <-ticker.C:
+			g.renew(ctx)
+		}
+	}
+}
+
+func (g *leasedSnowflake) renew(ctx context.Context) {
+	log := logger.New(ctx)
+	g.mu.RLock()
+	snowflake, token := g.snowflake, g.token
+	g.mu.RUnlock()
+	if snowflake == nil {
+		if err := g.acquire(ctx); err != nil {
+			log.Error("ReacquireIdWorkerError", "err", err)
+		}
+		return
+	}
+
+	renewStart := time.Now()
+	renewed, err := cache.RenewIdWorker(ctx, snowflake.WorkerId(), token, snowflake.LastMs(), enum.IdWorkerLeaseDuration)
+	if err != nil {
+		// 租约在本地过期前下次续约时重试
+		log.Error("RenewIdWorkerError", "workerId", snowflake.WorkerId(), "err", err)
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if renewed {
+		g.leaseDeadline = renewStart.Add(enum.IdWorkerLeaseDuration)
+		return
+	}
+	// 租约已经被其他实例获取, 停止使用这个机器ID, 下次续约时重新获取
+	g.snowflake = nil
+	log.Error("IdWorkerLeaseLost", "workerId", snowflake.WorkerId())
+}
+
+func (g *leasedSnowflake) release() {
+	g.mu.Lock()
+	snowflake, token := g.snowflake, g.token
+	g.snowflake = nil
+	g.mu.Unlock()
+	if snowflake == nil {
+		return
+	}
+	// 服务停止时 ctx 已经取消, 使用新的 context 释放
+	ctx := context.Background()
+	if err := cache.ReleaseIdWorker(ctx, snowflake.WorkerId(), token, snowflake.LastMs()); err != nil {
+		logger.New(ctx).Error("ReleaseIdWorkerError", "workerId", snowflake.WorkerId(), "err", err)
+	}
+}
@@ -0,0 +1,195 @@
+package domainservice
+
+import (
+	"context"
+	"time"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/logger"
+	"github.com/hd2yao/go-mall/common/util"
+	"github.com/hd2yao/go-mall/dal/dao"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+type VipDomainSvc struct {
+	ctx    context.Context
+	vipDao *dao.VipDao
+}
+
+func NewVipDomainSvc(ctx context.Context) *VipDomainSvc {
+	return &VipDomainSvc{
+		ctx:    ctx,
+		vipDao: dao.NewVipDao(ctx),
+	}
+}
+
+// CreateVipTier 创建会员等级
+func (vds *VipDomainSvc) CreateVipTier(tier *do.VipTier) error {
+	if err := vds.checkVipTier(tier); err != nil {
+		return err
+	}
+	if err := vds.vipDao.CreateTier(tier); err != nil {
+		return errcode.Wrap("CreateVipTierError", err)
+	}
+	return nil
+}
+
+// UpdateVipTier 更新会员等级, 已经是这个等级的会员按新的折扣享受减免, 有效期在下次续期时按新的有效天数计算
+func (vds *VipDomainSvc) UpdateVipTier(tier *do.VipTier) error {
+	tierModel, err := vds.vipDao.GetTier(tier.ID)
+	if err != nil {
+		return errcode.Wrap("UpdateVipTierError", err)
+	}
+	if tierModel == nil {
+		return errcode.ErrVipTierNotExists
+	}
+	if err = vds.checkVipTier(tier); err != nil {
+		return err
+	}
+	if err = vds.vipDao.UpdateTier(tier); err != nil {
+		return errcode.Wrap("UpdateVipTierError", err)
+	}
+	return nil
+}
+
+// DeleteVipTier 删除会员等级, 这个等级的会员不再享受会员折扣, 下次完成订单时按累计消费重新匹配等级
+func (vds *VipDomainSvc) DeleteVipTier(tierId int64) error {
+	tierModel, err := vds.vipDao.GetTier(tierId)
+	if err != nil {
+		return errcode.Wrap("DeleteVipTierError", err)
+	}
+	if tierModel == nil {
+		return errcode.ErrVipTierNotExists
+	}
+	if err = vds.vipDao.DeleteTier(tierId); err != nil {
+		return errcode.Wrap("DeleteVipTierError", err)
+	}
+	return nil
+}
+
+// GetVipTiers 获取所有会员等级, 按等级从低到高排序
+func (vds *VipDomainSvc) GetVipTiers() ([]*do.VipTier, error) {
+	tierModels, err := vds.vipDao.GetAllTiers()
+	if err != nil {
+		return nil, errcode.Wrap("GetVipTiersError", err)
+	}
+	tiers := make([]*do.VipTier, 0, len(tierModels))
+	if err = util.CopyProperties(&tiers, &tierModels); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	return tiers, nil
+}
+
+// GetUserVip 获取用户的会员信息, 包含当前的会员等级和下一个可以升级到的等级
+func (vds *VipDomainSvc) GetUserVip(userId int64) (*do.UserVip, error) {
+	userVipModel, err := vds.vipDao.GetUserVip(userId)
+	if err != nil {
+		return nil, errcode.Wrap("GetUserVipError", err)
+	}
+	userVip := &do.UserVip{UserId: userId}
+	if userVipModel != nil {
+		if err = util.CopyProperties(userVip, userVipModel); err != nil {
+			return nil, errcode.ErrCoverData.WithCause(err)
+		}
+	}
+	tiers, err := vds.GetVipTiers()
+	if err != nil {
+		return nil, err
+	}
+	userVip.Tier, _ = lo.Find(tiers, func(tier *do.VipTier) bool {
+		return tier.ID == userVip.TierId
+	})
+
+	currentLevel := 0
+	if userVip.Valid(time.Now()) {
+		currentLevel = userVip.Tier.Level
+	}
+	userVip.NextTier, _ = lo.Find(tiers, func(tier *do.VipTier) bool {
+		return tier.Level > currentLevel && tier.UpgradeSpend > userVip.TotalSpend
+	})
+	return userVip, nil
+}
+
+// GetUserVipOffRate 获取用户享受的会员折扣减免百分比, 不是会员或者会员已过期时为 0
+func (vds *VipDomainSvc) GetUserVipOffRate(userId int64) (int, error) {
+	userVip, err := vds.GetUserVip(userId)
+	if err != nil {
+		return 0, err
+	}
+	return userVip.OffRate(time.Now()), nil
+}
+
+// AccrueOrderSpend 在订单完成的事务中累计用户的消费金额, 并按累计消费匹配会员等级
+// 匹配到的等级高于当前等级, 或者用户还不是会员、会员已过期时升级为匹配到的等级; 匹配到当前等级时续期
+// 管理员调高了等级门槛导致匹配到的等级低于当前有效的等级时保持当前等级不降级
+func (vds *VipDomainSvc) AccrueOrderSpend(tx *gorm.DB, userId int64, spend int) error {
+	userVip, err := vds.vipDao.LockUserVip(tx, userId)
+	if err != nil {
+		return errcode.Wrap("AccrueOrderSpendError", err)
+	}
+	userVip.TotalSpend += spend
+
+	tiers, err := vds.GetVipTiers()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	currentTier, _ := lo.Find(tiers, func(tier *do.VipTier) bool {
+		return tier.ID == userVip.TierId
+	})
+	currentValid := currentTier != nil && now.Before(userVip.ExpireAt)
+	if matchedTier := matchVipTier(tiers, userVip.TotalSpend); matchedTier != nil {
+		if !currentValid || matchedTier.Level >= currentTier.Level {
+			if userVip.TierId != matchedTier.ID {
+				logger.New(vds.ctx).Info("UserVipUpgraded", "userId", userId, "fromTierId", userVip.TierId, "toTierId", matchedTier.ID)
+			}
+			userVip.TierId = matchedTier.ID
+			userVip.ExpireAt = vipExpireAt(matchedTier, now)
+		}
+	}
+
+	if err = vds.vipDao.UpdateUserVip(tx, userVip); err != nil {
+		return errcode.Wrap("AccrueOrderSpendError", err)
+	}
+	return nil
+}
+
+// checkVipTier 检查会员等级的参数, 等级不能和其他会员等级重复
+func (vds *VipDomainSvc) checkVipTier(tier *do.VipTier) error {
+	if tier.Level <= 0 || tier.DiscountRate < 0 || tier.DiscountRate >= 100 || tier.UpgradeSpend < 0 || tier.ValidDays < 0 {
+		return errcode.ErrVipTierParams
+	}
+	tiers, err := vds.GetVipTiers()
+	if err != nil {
+		return err
+	}
+	if lo.ContainsBy(tiers, func(item *do.VipTier) bool {
+		return item.ID != tier.ID && item.Level == tier.Level
+	}) {
+		return errcode.ErrVipTierParams
+	}
+	return nil
+}
+
+// matchVipTier 找出累计消费 totalSpend 可以达到的最高会员等级, tiers 需要按等级从低到高排序
+func matchVipTier(tiers []*do.VipTier, totalSpend int) *do.VipTier {
+	var matched *do.VipTier
+	for _, tier := range tiers {
+		if tier.UpgradeSpend <= totalSpend {
+			matched = tier
+		}
+	}
+	return matched
+}
+
+// vipExpireAt 在 now 时成为 tier 等级的会员或者续期后的过期时间
+func vipExpireAt(tier *do.VipTier, now time.Time) time.Time {
+	if tier.ValidDays == 0 {
+		return enum.VipPermanentExpireAt
+	}
+	return now.AddDate(0, 0, tier.ValidDays)
+}
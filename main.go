@@ -15,6 +15,8 @@ import (
 	"github.com/hd2yao/go-mall/common/enum"
 	"github.com/hd2yao/go-mall/common/logger"
 	"github.com/hd2yao/go-mall/config"
+	"github.com/hd2yao/go-mall/job"
+	"github.com/hd2yao/go-mall/logic/domainservice"
 )
 
 func main() {
@@ -28,7 +30,7 @@ func main() {
 	config := cors.DefaultConfig()
 	config.AllowOrigins = []string{"*"} // 允许所有来源，生产环境建议设置具体的域名
 	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "go-mall-token", "platform"}
+	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "go-mall-token", "platform", enum.IdempotencyKeyHeader}
 	config.AllowCredentials = true
 	g.Use(cors.New(config))
 
@@ -40,6 +42,22 @@ func main() {
 
 	log := logger.New(context.Background())
 
+	// 没有配置结算凭证的签名密钥时不启动服务, 避免结算凭证被伪造
+	if err := domainservice.CheckCheckoutTokenSecret(); err != nil {
+		log.Error("CheckCheckoutTokenSecretError", "err", err)
+		return
+	}
+
+	// 启动后台任务, 服务关闭时一起停止
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	// 获取单号生成器的机器ID, 服务关闭时释放
+	if err := domainservice.StartSerialNoGenerator(jobCtx); err != nil {
+		log.Error("StartSerialNoGeneratorError", "err", err)
+		stopJobs()
+		return
+	}
+	job.Start(jobCtx)
+
 	// 创建系统信号接收器
 	done := make(chan os.Signal)
 	// 接收系统信号 os.Interrupt, syscall.SIGINT, syscall.SIGTERM，在收到信号后将信号发送到 done channel
@@ -47,6 +65,7 @@ func main() {
 
 	go func() {
 		<-done // 等待信号
+		stopJobs()
 		// 当 done 通道接收到系统信号时，执行 server.Shutdown() 进行优雅关闭
 		if err := server.Shutdown(context.Background()); err != nil {
 			log.Error("ShutdownServerError", "err", err)
@@ -0,0 +1,29 @@
+package dao
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/dal/dao"
+)
+
+func TestCouponDao_LockUserCoupon(t *testing.T) {
+	var userCouponId, userId int64 = 3, 1
+	orderNo := "20240903374062590406950001"
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `user_coupons` SET")).
+		WithArgs(orderNo, enum.UserCouponLocked, AnyTime{}, userCouponId, userId, enum.UserCouponUnused, AnyTime{}, AnyTime{}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	cd := dao.NewCouponDao(context.TODO())
+	locked, err := cd.LockUserCoupon(dao.DBMaster(), userCouponId, userId, orderNo, time.Now())
+	assert.Nil(t, err)
+	assert.True(t, locked)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
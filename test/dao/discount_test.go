@@ -0,0 +1,44 @@
+package dao
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/dal/dao"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+func TestDiscountDao_CreateOrderRecord(t *testing.T) {
+	record := &do.OrderDiscountRecord{
+		CampaignId:    2,
+		UserId:        1,
+		OrderNo:       "20240903374062590406950001",
+		DiscountMoney: 2000,
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `order_discount_records`")).
+		WithArgs(record.CampaignId, record.UserId, enum.DiscountRecordUsed).
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `order_discount_records`")).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectCommit()
+	dd := dao.NewDiscountDao(context.TODO())
+	created, err := dd.CreateOrderRecord(dao.DBMaster(), record, 1)
+	assert.Nil(t, err)
+	assert.True(t, created)
+	assert.Equal(t, int64(7), record.ID)
+
+	// 已经达到参加次数上限时不再创建记录
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `order_discount_records`")).
+		WithArgs(record.CampaignId, record.UserId, enum.DiscountRecordUsed).
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	created, err = dd.CreateOrderRecord(dao.DBMaster(), record, 1)
+	assert.Nil(t, err)
+	assert.False(t, created)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
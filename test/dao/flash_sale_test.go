@@ -0,0 +1,29 @@
+package dao
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/dal/dao"
+)
+
+func TestFlashSaleDao_IncrSessionSold(t *testing.T) {
+	var sessionId int64 = 3
+	num := 2
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `flash_sale_sessions` SET `sold_num`=sold_num + ?")).
+		WithArgs(num, AnyTime{}, sessionId, enum.FlashSaleSessionActive, num).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	fd := dao.NewFlashSaleDao(context.TODO())
+	// 剩余库存不足或者场次已经结束时没有更新任何行
+	incremented, err := fd.IncrSessionSold(dao.DBMaster(), sessionId, num)
+	assert.Nil(t, err)
+	assert.False(t, incremented)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
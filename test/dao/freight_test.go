@@ -0,0 +1,40 @@
+package dao
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/dal/dao"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+func TestFreightDao_CreateTemplate(t *testing.T) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `freight_templates` SET `is_default`=?")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `freight_templates`")).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `freight_template_rules`")).
+		WillReturnResult(sqlmock.NewResult(5, 2))
+	mock.ExpectCommit()
+	fd := dao.NewFreightDao(context.TODO())
+	template := &do.FreightTemplate{
+		Name:       "默认运费",
+		ChargeType: enum.FreightChargeByPiece,
+		IsDefault:  1,
+		Rules: []*do.FreightTemplateRule{
+			{Provinces: "新疆,西藏", FirstUnit: 1, FirstFee: 2000, AdditionalUnit: 1, AdditionalFee: 1000},
+			{FirstUnit: 1, FirstFee: 1000, AdditionalUnit: 1, AdditionalFee: 500, FreeThreshold: 9900},
+		},
+	}
+	err := fd.CreateTemplate(template)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), template.ID)
+	assert.Equal(t, int64(3), template.Rules[1].TemplateId)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,32 @@
+package dao
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/dal/dao"
+)
+
+func TestGroupBuyDao_UpdateMemberFromStatus(t *testing.T) {
+	var memberId int64 = 4
+	refundNo := "20017438172315680000101"
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `group_buy_members` SET")).
+		WithArgs(refundNo, enum.GroupBuyMemberRefunding, AnyTime{}, memberId, enum.GroupBuyMemberPaid).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	gd := dao.NewGroupBuyDao(context.TODO())
+	// 成员的状态已经被其他实例修改时没有更新任何行
+	updated, err := gd.UpdateMemberFromStatus(dao.DBMaster(), memberId, enum.GroupBuyMemberPaid, map[string]interface{}{
+		"status":    enum.GroupBuyMemberRefunding,
+		"refund_no": refundNo,
+	})
+	assert.Nil(t, err)
+	assert.False(t, updated)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
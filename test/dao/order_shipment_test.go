@@ -0,0 +1,30 @@
+package dao
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/dal/dao"
+)
+
+func TestOrderShipmentDao_UpdateShipmentFromStatus(t *testing.T) {
+	var shipmentId int64 = 1
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `order_shipments` SET")).
+		WithArgs(AnyTime{}, enum.ShipmentStatusDelivered, AnyTime{}, shipmentId, enum.ShipmentStatusShipped).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	sd := dao.NewOrderShipmentDao(context.TODO())
+	updated, err := sd.UpdateShipmentFromStatus(dao.DBMaster(), shipmentId, enum.ShipmentStatusShipped, map[string]interface{}{
+		"status":       enum.ShipmentStatusDelivered,
+		"delivered_at": time.Now(),
+	})
+	assert.Nil(t, err)
+	assert.True(t, updated)
+}
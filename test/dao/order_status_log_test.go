@@ -0,0 +1,29 @@
+package dao
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/dal/dao"
+)
+
+func TestOrderStatusLogDao_GetOrderStatusLogs(t *testing.T) {
+	var orderId int64 = 1
+	now := time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `order_status_logs` WHERE order_id = ? ORDER BY id")).
+		WithArgs(orderId).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "order_id", "order_no", "from_status", "to_status", "actor", "actor_id", "reason", "created_at"}).
+				AddRow(1, orderId, "12345675555", 0, 1, 1, 1, "用户发起支付", now).
+				AddRow(2, orderId, "12345675555", 1, 2, 4, 0, "支付成功", now),
+		)
+	statusLogs, err := dao.NewOrderStatusLogDao(context.TODO()).GetOrderStatusLogs(orderId)
+	assert.Nil(t, err)
+	assert.Len(t, statusLogs, 2)
+	assert.Equal(t, 2, statusLogs[1].ToStatus)
+}
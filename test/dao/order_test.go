@@ -21,8 +21,8 @@ func TestOrderDao_GetUserOrders(t *testing.T) {
 	emptyPayTime := time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)
 
 	orders := []*model.Order{
-		{1, "12345675555", "", 1, 1, 100, 100, 0, 0, emptyPayTime, orderDel, now, now},
-		{2, "12345675556", "", 1, 1, 100, 100, 0, 0, emptyPayTime, orderDel, now, now},
+		{1, "12345675555", "", 1, 1, 100, 100, 0, 0, 0, emptyPayTime, orderDel, now, now},
+		{2, "12345675556", "", 1, 1, 100, 100, 0, 0, 0, emptyPayTime, orderDel, now, now},
 	}
 	od := dao.NewOrderDao(context.TODO())
 	var userId int64 = 1
@@ -31,13 +31,13 @@ func TestOrderDao_GetUserOrders(t *testing.T) {
 	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `orders`")).WithArgs(userId, orderDel, limit, offset).
 		WillReturnRows(
 			sqlmock.NewRows([]string{"id", "order_no", "pay_trans_id", "pay_type", "user_id", "bill_money", "pay_money",
-				"pay_state", "order_status", "paid_at", "is_del", "created_at", "updated_at"}).
+				"freight_money", "pay_state", "order_status", "paid_at", "is_del", "created_at", "updated_at"}).
 				AddRow(
 					orders[0].ID, orders[0].OrderNo, orders[0].PayTransId, orders[0].PayType, orders[0].UserId, orders[0].BillMoney, orders[0].PayMoney,
-					orders[0].PayState, orders[0].OrderStatus, orders[0].PaidAt, orders[0].IsDel, orders[0].CreatedAt, orders[0].UpdatedAt,
+					orders[0].FreightMoney, orders[0].PayState, orders[0].OrderStatus, orders[0].PaidAt, orders[0].IsDel, orders[0].CreatedAt, orders[0].UpdatedAt,
 				).AddRow(
 				orders[1].ID, orders[1].OrderNo, orders[1].PayTransId, orders[1].PayType, orders[1].UserId, orders[1].BillMoney, orders[1].PayMoney,
-				orders[1].PayState, orders[1].OrderStatus, orders[1].PaidAt, orders[1].IsDel, orders[1].CreatedAt, orders[1].UpdatedAt,
+				orders[1].FreightMoney, orders[1].PayState, orders[1].OrderStatus, orders[1].PaidAt, orders[1].IsDel, orders[1].CreatedAt, orders[1].UpdatedAt,
 			),
 		)
 	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `orders`")).WithArgs(userId, orderDel).
@@ -62,6 +62,36 @@ func TestOrderDao_UpdateOrderStatus(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestOrderDao_AddOrderItemRefunded(t *testing.T) {
+	var orderItemId int64 = 1
+	refundNum := 2
+	refundMoney := 1998
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `order_items` SET")).
+		WithArgs(refundMoney, refundNum, AnyTime{}, orderItemId, refundNum).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	od := dao.NewOrderDao(context.TODO())
+	updated, err := od.AddOrderItemRefunded(dao.DBMaster(), orderItemId, refundNum, refundMoney)
+	assert.Nil(t, err)
+	assert.True(t, updated)
+}
+
+// TestOrderDao_GetOrdersByStatusEnteredBefore 按状态变更记录中进入当前状态的时间筛选订单, 不使用订单的 updated_at
+func TestOrderDao_GetOrdersByStatusEnteredBefore(t *testing.T) {
+	status := 5
+	limit := 200
+	enteredBefore := time.Now().AddDate(0, 0, -10)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT orders.* FROM `orders` JOIN order_status_logs ON order_status_logs.order_id = orders.id AND order_status_logs.to_status = orders.order_status "+
+		"WHERE orders.order_status = ? AND `orders`.`is_del` = ? GROUP BY `orders`.`id` HAVING MAX(order_status_logs.created_at) < ? ORDER BY orders.id LIMIT ?")).
+		WithArgs(status, 0, enteredBefore, limit).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_no", "order_status"}).AddRow(1, "12345675555", status))
+	orders, err := dao.NewOrderDao(context.TODO()).GetOrdersByStatusEnteredBefore(status, enteredBefore, limit)
+	assert.Nil(t, err)
+	assert.Len(t, orders, 1)
+	assert.Equal(t, "12345675555", orders[0].OrderNo)
+}
+
 // 定义一个AnyTime 类型，实现 sqlmock.Argument接口
 // 参考自：https://qiita.com/isao_e_dev/items/c9da34c6d1f99a112207
 type AnyTime struct{}
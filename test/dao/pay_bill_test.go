@@ -0,0 +1,37 @@
+package dao
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/dal/dao"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+func TestPayBillDao_ReplaceMismatches(t *testing.T) {
+	billDate := "2024-09-03"
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `pay_bill_mismatches` WHERE bill_date = ? AND pay_type = ?")).
+		WithArgs(billDate, enum.PayTypeWxPay).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `pay_bill_mismatches`")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	pbd := dao.NewPayBillDao(context.TODO())
+	err := pbd.ReplaceMismatches(billDate, enum.PayTypeWxPay, []*do.PayBillMismatch{{
+		BillDate:        billDate,
+		PayType:         enum.PayTypeWxPay,
+		OrderNo:         "20240903374062590406950001",
+		MismatchType:    enum.PayBillMismatchChannelMissing,
+		LocalPayMoney:   549700,
+		LocalPayState:   enum.PayStatePaid,
+		LocalPayTransId: "4200002024090300000001",
+	}})
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,33 @@
+package dao
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/dal/dao"
+	"github.com/hd2yao/go-mall/dal/model"
+)
+
+func TestVipDao_UpdateUserVip(t *testing.T) {
+	userVip := &model.UserVip{
+		ID:         3,
+		UserId:     1,
+		TierId:     2,
+		TotalSpend: 529900,
+		ExpireAt:   time.Now().AddDate(0, 0, 365),
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `user_vips` SET")).
+		WithArgs(AnyTime{}, userVip.TierId, userVip.TotalSpend, AnyTime{}, userVip.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	vd := dao.NewVipDao(context.TODO())
+	err := vd.UpdateUserVip(dao.DBMaster(), userVip)
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,96 @@
+package domainservice
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/dal/dao"
+	"github.com/hd2yao/go-mall/logic/do"
+	"github.com/hd2yao/go-mall/logic/domainservice"
+)
+
+var (
+	mock sqlmock.Sqlmock
+	err  error
+	db   *sql.DB
+)
+
+// TestMain 和 test/dao 一样把项目使用的 DB 替换成 sqlmock 的 DB 连接
+func TestMain(m *testing.M) {
+	db, mock, err = sqlmock.New()
+	if err != nil {
+		panic(err)
+	}
+	// 领域服务的一个方法会查询多张表, 查询的先后顺序不是测试关注的内容
+	mock.MatchExpectationsInOrder(false)
+
+	dbConn, _ := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      db,
+		SkipInitializeWithVersion: true,
+		DefaultStringSize:         0,
+	}))
+	dao.SetDBMasterConn(dbConn)
+	dao.SetDBSlaveConn(dbConn)
+
+	os.Exit(m.Run())
+}
+
+// TestCartBillChecker_GetBillUndeliverable 收货地址不在默认运费模板的配送范围内时, GetBill 原样返回 ErrFreightUndeliverable
+func TestCartBillChecker_GetBillUndeliverable(t *testing.T) {
+	var userId int64 = 1
+	mock.ExpectQuery(regexp.QuoteMeta("FROM `user_vips`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM `vip_tiers`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM `discount_campaigns`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM `freight_templates`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "charge_type", "is_default"}).
+			AddRow(3, "偏远地区运费", enum.FreightChargeByPiece, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM `freight_template_rules`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "template_id", "provinces", "first_unit", "first_fee"}).
+			AddRow(5, 3, "新疆,西藏", 1, 2000))
+
+	items := []*do.ShoppingCartItem{
+		{CommodityId: 12, CommoditySellingPrice: 549700, CommodityNum: 1},
+	}
+	userAddress := &do.UserAddressInfo{ID: 2, UserId: userId, ProvinceName: "北京"}
+	billChecker := domainservice.NewCartBillChecker(context.TODO(), items, userId, userAddress)
+	billChecker.SelectedCouponId = enum.BillNoCoupon
+	_, err := billChecker.GetBill()
+	assert.True(t, errors.Is(err, errcode.ErrFreightUndeliverable))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+// TestCartBillChecker_GetBillCouponUnavailable 用户选择的优惠券不可用时, GetBill 原样返回 ErrCouponUnavailable
+func TestCartBillChecker_GetBillCouponUnavailable(t *testing.T) {
+	var userId int64 = 1
+	mock.ExpectQuery(regexp.QuoteMeta("FROM `user_vips`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM `vip_tiers`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM `user_coupons`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM `discount_campaigns`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	items := []*do.ShoppingCartItem{
+		{CommodityId: 12, CommoditySellingPrice: 549700, CommodityNum: 1},
+	}
+	billChecker := domainservice.NewCartBillChecker(context.TODO(), items, userId, nil)
+	billChecker.SelectedCouponId = 99
+	_, err := billChecker.GetBill()
+	assert.True(t, errors.Is(err, errcode.ErrCouponUnavailable))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,95 @@
+package domainservice
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/config"
+	"github.com/hd2yao/go-mall/logic/do"
+	"github.com/hd2yao/go-mall/logic/domainservice"
+)
+
+func newCheckoutBill() ([]*do.ShoppingCartItem, *do.CartBillInfo) {
+	items := []*do.ShoppingCartItem{
+		{CommodityId: 12, CommoditySellingPrice: 549700, CommodityNum: 1},
+		{CommodityId: 8, CommoditySellingPrice: 9900, CommodityNum: 2},
+	}
+	billInfo := &do.CartBillInfo{
+		OriginalTotalPrice: 569500,
+		TotalPrice:         559500,
+		FreightMoney:       1000,
+	}
+	billInfo.Coupon.CouponId = 3
+	return items, billInfo
+}
+
+func TestCheckoutToken_SignAndVerify(t *testing.T) {
+	var userId int64 = 1
+	items, billInfo := newCheckoutBill()
+	token, err := domainservice.SignCheckoutToken(userId, items, billInfo)
+	assert.Nil(t, err)
+
+	ods := domainservice.NewOrderDomainSvc(context.TODO())
+	// 创建订单时购物项的顺序和查看账单时不一样也能校验通过
+	reorderedItems := []*do.ShoppingCartItem{items[1], items[0]}
+	assert.Nil(t, ods.VerifyCheckoutToken(token, userId, reorderedItems, billInfo))
+
+	// 不是这个用户的凭证
+	assert.ErrorIs(t, ods.VerifyCheckoutToken(token, 2, items, billInfo), errcode.ErrOrderCheckoutInvalid)
+	// 账单的金额发生了变化
+	changedBill := *billInfo
+	changedBill.TotalPrice = 549500
+	assert.ErrorIs(t, ods.VerifyCheckoutToken(token, userId, items, &changedBill), errcode.ErrOrderBillChanged)
+}
+
+func TestCheckoutToken_Tampered(t *testing.T) {
+	var userId int64 = 1
+	items, billInfo := newCheckoutBill()
+	token, err := domainservice.SignCheckoutToken(userId, items, billInfo)
+	assert.Nil(t, err)
+	ods := domainservice.NewOrderDomainSvc(context.TODO())
+
+	// 修改凭证中的结算快照后沿用原来的签名
+	encodedPayload, sign, _ := strings.Cut(token, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	assert.Nil(t, err)
+	tamperedPayload := strings.Replace(string(payload), "559500", "1", 1)
+	assert.NotEqual(t, string(payload), tamperedPayload)
+	tamperedToken := base64.RawURLEncoding.EncodeToString([]byte(tamperedPayload)) + "." + sign
+	assert.ErrorIs(t, ods.VerifyCheckoutToken(tamperedToken, userId, items, billInfo), errcode.ErrOrderCheckoutInvalid)
+
+	// 签名被改动或者凭证格式不对
+	assert.ErrorIs(t, ods.VerifyCheckoutToken(encodedPayload+"."+strings.Repeat("0", len(sign)), userId, items, billInfo), errcode.ErrOrderCheckoutInvalid)
+	assert.ErrorIs(t, ods.VerifyCheckoutToken(encodedPayload, userId, items, billInfo), errcode.ErrOrderCheckoutInvalid)
+
+	// 用其他密钥签名的凭证校验不通过
+	secret := config.App.Order.CheckoutTokenSecret
+	config.App.Order.CheckoutTokenSecret = "another-secret"
+	forgedToken, err := domainservice.SignCheckoutToken(userId, items, billInfo)
+	config.App.Order.CheckoutTokenSecret = secret
+	assert.Nil(t, err)
+	assert.ErrorIs(t, ods.VerifyCheckoutToken(forgedToken, userId, items, billInfo), errcode.ErrOrderCheckoutInvalid)
+}
+
+// TestCheckoutToken_EmptySecret 没有配置签名密钥时不能生成凭证, 所有凭证都校验不通过
+func TestCheckoutToken_EmptySecret(t *testing.T) {
+	var userId int64 = 1
+	items, billInfo := newCheckoutBill()
+	token, err := domainservice.SignCheckoutToken(userId, items, billInfo)
+	assert.Nil(t, err)
+
+	secret := config.App.Order.CheckoutTokenSecret
+	config.App.Order.CheckoutTokenSecret = ""
+	defer func() { config.App.Order.CheckoutTokenSecret = secret }()
+
+	assert.NotNil(t, domainservice.CheckCheckoutTokenSecret())
+	_, err = domainservice.SignCheckoutToken(userId, items, billInfo)
+	assert.NotNil(t, err)
+	ods := domainservice.NewOrderDomainSvc(context.TODO())
+	assert.ErrorIs(t, ods.VerifyCheckoutToken(token, userId, items, billInfo), errcode.ErrOrderCheckoutInvalid)
+}
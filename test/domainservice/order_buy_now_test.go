@@ -0,0 +1,136 @@
+package domainservice
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/logic/do"
+	"github.com/hd2yao/go-mall/logic/domainservice"
+)
+
+func newBuyNowItems(userId int64) []*do.ShoppingCartItem {
+	return []*do.ShoppingCartItem{
+		{UserId: userId, CommodityId: 12, CommodityName: "Apple iPhone 15", CommoditySellingPrice: 5000, CommodityNum: 2},
+	}
+}
+
+func newBuyNowAddress(userId int64) *do.UserAddressInfo {
+	return &do.UserAddressInfo{
+		ID:            2,
+		UserId:        userId,
+		UserName:      "张三",
+		UserPhone:     "13800000000",
+		ProvinceName:  "北京",
+		CityName:      "北京市",
+		RegionName:    "朝阳区",
+		DetailAddress: "建国路 1 号",
+	}
+}
+
+// expectBuyNowBillQueries 用户不是会员, 没有满减活动, 默认运费模板首件 10 元续件 5 元
+func expectBuyNowBillQueries() {
+	mock.ExpectQuery(regexp.QuoteMeta("FROM `user_vips`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM `vip_tiers`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM `discount_campaigns`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM `freight_templates`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "charge_type", "is_default"}).
+			AddRow(1, "默认运费", enum.FreightChargeByPiece, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM `freight_template_rules`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "template_id", "provinces", "first_unit", "first_fee", "additional_unit", "additional_fee"}).
+			AddRow(1, 1, "", 1, 1000, 1, 500))
+}
+
+// signBuyNowCheckoutToken 模拟立即购买前查看账单, 返回账单和结算凭证
+func signBuyNowCheckoutToken(t *testing.T, userId int64) (*do.CartBillInfo, string) {
+	expectBuyNowBillQueries()
+	billChecker := domainservice.NewCartBillChecker(context.TODO(), newBuyNowItems(userId), userId, newBuyNowAddress(userId))
+	billChecker.SelectedCouponId = enum.BillNoCoupon
+	billInfo, err := billChecker.GetBill()
+	assert.Nil(t, err)
+	token, err := domainservice.SignCheckoutToken(userId, newBuyNowItems(userId), billInfo)
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	return billInfo, token
+}
+
+// TestOrderDomainSvc_CreateBuyNowOrder 立即购买和购物车下单一样计算金额、扣减库存、保存收货地址快照, 不会改动购物车
+func TestOrderDomainSvc_CreateBuyNowOrder(t *testing.T) {
+	var userId int64 = 1
+	ctx, cancel := context.WithCancel(context.TODO())
+	t.Cleanup(cancel)
+	assert.Nil(t, domainservice.StartSerialNoGenerator(ctx))
+	billInfo, token := signBuyNowCheckoutToken(t, userId)
+	// 2 件商品 100 元, 运费首件 10 元加续件 5 元
+	assert.Equal(t, 10000, billInfo.OriginalTotalPrice)
+	assert.Equal(t, 1500, billInfo.FreightMoney)
+	assert.Equal(t, 11500, billInfo.TotalPrice)
+
+	var orderId int64 = 101
+	expectBuyNowBillQueries()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `orders`")).
+		WillReturnResult(sqlmock.NewResult(orderId, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `order_items`")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `order_address`")).
+		WithArgs(orderId, "张三", "13800000000", "北京", "北京市", "朝阳区", "建国路 1 号").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `commodities`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "selling_price", "stock_num"}).
+			AddRow(12, "Apple iPhone 15", 5000, 5))
+	// 库存从 5 件减到 3 件
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `commodities` SET `stock_num`=?")).
+		WithArgs(3, sqlmock.AnyArg(), 0, 12).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// 没有设置购物车的 SQL 期望, 改动购物车时 sqlmock 会返回错误
+	order, err := domainservice.NewOrderDomainSvc(context.TODO()).CreateBuyNowOrder(newBuyNowItems(userId), newBuyNowAddress(userId), token, enum.BillNoCoupon)
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, orderId, order.ID)
+	assert.Equal(t, enum.OrderStatusCreated, order.OrderStatus)
+	assert.Equal(t, 10000, order.BillMoney)
+	assert.Equal(t, 1500, order.FreightMoney)
+	assert.Equal(t, 11500, order.PayMoney)
+	assert.Len(t, order.Items, 1)
+	assert.Equal(t, 10000, order.Items[0].PayMoney)
+	assert.Equal(t, "建国路 1 号", order.Address.DetailAddress)
+}
+
+// TestOrderDomainSvc_CreateBuyNowOrderCheckout 立即购买必须传结算凭证, 账单和查看账单时不一致时不创建订单
+func TestOrderDomainSvc_CreateBuyNowOrderCheckout(t *testing.T) {
+	var userId int64 = 1
+	_, token := signBuyNowCheckoutToken(t, userId)
+	ods := domainservice.NewOrderDomainSvc(context.TODO())
+
+	t.Run("没有结算凭证", func(t *testing.T) {
+		expectBuyNowBillQueries()
+		_, err := ods.CreateBuyNowOrder(newBuyNowItems(userId), newBuyNowAddress(userId), "", enum.BillNoCoupon)
+		assert.ErrorIs(t, err, errcode.ErrOrderCheckoutInvalid)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("商品降价后账单变化", func(t *testing.T) {
+		expectBuyNowBillQueries()
+		items := newBuyNowItems(userId)
+		items[0].CommoditySellingPrice = 4500
+		_, err := ods.CreateBuyNowOrder(items, newBuyNowAddress(userId), token, enum.BillNoCoupon)
+		assert.ErrorIs(t, err, errcode.ErrOrderBillChanged)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("其他用户的结算凭证", func(t *testing.T) {
+		expectBuyNowBillQueries()
+		_, err := ods.CreateBuyNowOrder(newBuyNowItems(2), newBuyNowAddress(2), token, enum.BillNoCoupon)
+		assert.ErrorIs(t, err, errcode.ErrOrderCheckoutInvalid)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
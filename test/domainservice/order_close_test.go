@@ -0,0 +1,156 @@
+package domainservice
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/dal/cache"
+	"github.com/hd2yao/go-mall/logic/do"
+	"github.com/hd2yao/go-mall/logic/domainservice"
+)
+
+// resetCloseQueue 清空关单延迟队列, 测试结束后再清空一次
+func resetCloseQueue(t *testing.T) {
+	assert.Nil(t, cache.Redis().Del(context.TODO(), enum.REDIS_KEY_ORDER_CLOSE_QUEUE).Err())
+	t.Cleanup(func() {
+		cache.Redis().Del(context.TODO(), enum.REDIS_KEY_ORDER_CLOSE_QUEUE)
+	})
+}
+
+// closeQueueScore 返回订单在关单延迟队列中的关闭时间, 订单不在队列中时 inQueue 为 false
+func closeQueueScore(t *testing.T, orderNo string) (closeAt int64, inQueue bool) {
+	score, err := cache.Redis().ZScore(context.TODO(), enum.REDIS_KEY_ORDER_CLOSE_QUEUE, orderNo).Result()
+	if err == redis.Nil {
+		return 0, false
+	}
+	assert.Nil(t, err)
+	return int64(score), true
+}
+
+// TestPopDueCloseOrders 只取出到了关闭时间的订单, 多个实例同时取时每个订单只会被取出一次
+func TestPopDueCloseOrders(t *testing.T) {
+	resetCloseQueue(t)
+	ctx := context.TODO()
+	now := time.Now()
+	dueOrderNos := make([]string, 0, 20)
+	for i := 0; i < 20; i++ {
+		orderNo := fmt.Sprintf("168390405200%02d", i)
+		dueOrderNos = append(dueOrderNos, orderNo)
+		assert.Nil(t, cache.AddOrderToCloseQueue(ctx, orderNo, now.Add(-time.Duration(i+1)*time.Second)))
+	}
+	assert.Nil(t, cache.AddOrderToCloseQueue(ctx, "16839040529999", now.Add(time.Hour)))
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		popped []string
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				orderNos, err := cache.PopDueCloseOrders(ctx, now, 3)
+				assert.Nil(t, err)
+				if len(orderNos) == 0 {
+					return
+				}
+				mu.Lock()
+				popped = append(popped, orderNos...)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.ElementsMatch(t, dueOrderNos, popped)
+	_, inQueue := closeQueueScore(t, "16839040529999")
+	assert.True(t, inQueue)
+}
+
+// TestOrderDomainSvc_CloseDueUnpaidOrders 关闭到期的订单: 关闭支付平台的交易, 恢复商品库存并释放优惠券, 没到期的订单留在队列中
+func TestOrderDomainSvc_CloseDueUnpaidOrders(t *testing.T) {
+	resetCloseQueue(t)
+	var orderId int64 = 101
+	orderNo := "16839040520001"
+	notDueOrderNo := "16839040520002"
+	querier := setStubOrderPayQuerier(t, &do.OrderPayResult{TradeState: "NOTPAY"})
+	assert.Nil(t, cache.AddOrderToCloseQueue(context.TODO(), orderNo, time.Now().Add(-time.Minute)))
+	assert.Nil(t, cache.AddOrderToCloseQueue(context.TODO(), notDueOrderNo, time.Now().Add(time.Hour)))
+
+	expectUnpaidOrderQueries(orderId, orderNo, 2)
+	expectCloseUnpaidOrderTx(orderId, enum.OrderStatusUnpaidClose)
+
+	assert.Nil(t, domainservice.NewOrderDomainSvc(context.TODO()).CloseDueUnpaidOrders())
+	assert.Nil(t, mock.ExpectationsWereMet())
+	// 用户还没有支付的交易在支付平台上关闭, 用户不能再支付
+	assert.Equal(t, []string{orderNo}, querier.closedOrderNos)
+	_, inQueue := closeQueueScore(t, orderNo)
+	assert.False(t, inQueue)
+	_, inQueue = closeQueueScore(t, notDueOrderNo)
+	assert.True(t, inQueue)
+}
+
+// TestOrderDomainSvc_CloseDueUnpaidOrdersRetry 订单状态在查询之后被修改了, 关单失败的订单重新放回队列稍后再检查
+func TestOrderDomainSvc_CloseDueUnpaidOrdersRetry(t *testing.T) {
+	resetCloseQueue(t)
+	var orderId int64 = 101
+	orderNo := "16839040520001"
+	setStubOrderPayQuerier(t, &do.OrderPayResult{TradeState: "NOTPAY"})
+	assert.Nil(t, cache.AddOrderToCloseQueue(context.TODO(), orderNo, time.Now().Add(-time.Minute)))
+
+	expectUnpaidOrderQueries(orderId, orderNo, 2)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `orders` SET `order_status`=?")).
+		WithArgs(enum.OrderStatusUnpaidClose, sqlmock.AnyArg(), orderId, enum.OrderStatusUnPaid, 0).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	assert.Nil(t, domainservice.NewOrderDomainSvc(context.TODO()).CloseDueUnpaidOrders())
+	assert.Nil(t, mock.ExpectationsWereMet())
+	closeAt, inQueue := closeQueueScore(t, orderNo)
+	assert.True(t, inQueue)
+	assert.Greater(t, closeAt, time.Now().Unix())
+}
+
+// TestOrderDomainSvc_CloseTimeoutUnpaidOrderAlreadyPaid 支付平台上已经支付成功的订单更新为已支付, 不关闭订单也不恢复库存
+func TestOrderDomainSvc_CloseTimeoutUnpaidOrderAlreadyPaid(t *testing.T) {
+	var orderId int64 = 101
+	orderNo := "16839040520001"
+	paidAt := time.Date(2024, 9, 3, 10, 20, 30, 0, time.Local)
+	querier := setStubOrderPayQuerier(t, &do.OrderPayResult{
+		Paid:       true,
+		TradeState: "SUCCESS",
+		PayTransId: "4200000000202409030000000001",
+		PaidMoney:  10000,
+		PaidAt:     paidAt,
+	})
+
+	// 没有设置恢复库存和释放优惠券的 SQL 期望, 关闭订单时 sqlmock 会返回错误
+	expectUnpaidOrderQueries(orderId, orderNo, 3)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `orders` SET `order_status`=?,`paid_at`=?,`pay_state`=?,`pay_trans_id`=?,`pay_type`=?")).
+		WithArgs(enum.OrderStatusPaid, paidAt, enum.PayStatePaid, "4200000000202409030000000001", enum.PayTypeWxPay, sqlmock.AnyArg(), orderId, enum.OrderStatusUnPaid, 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `order_status_logs`")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `group_buy_members`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `user_coupons` SET")).
+		WithArgs(enum.UserCouponUsed, paidAt, sqlmock.AnyArg(), orderNo, enum.UserCouponLocked).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.Nil(t, domainservice.NewOrderDomainSvc(context.TODO()).CloseTimeoutUnpaidOrder(orderNo))
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Empty(t, querier.closedOrderNos)
+}
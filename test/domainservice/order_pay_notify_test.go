@@ -0,0 +1,223 @@
+package domainservice
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/api/controller"
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/config"
+	"github.com/hd2yao/go-mall/library"
+	"github.com/hd2yao/go-mall/library/wxpayfake"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+// setupWxPayFake 启动模拟微信支付服务, 把应用的微信支付配置指向它, 测试结束后恢复原来的配置
+func setupWxPayFake(t *testing.T) *wxpayfake.Server {
+	keyDir := t.TempDir()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	privateKeyBytes, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.Nil(t, err)
+	privateKeyPath := filepath.Join(keyDir, "wxpay.private.pem")
+	assert.Nil(t, os.WriteFile(privateKeyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKeyBytes}), 0600))
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.Nil(t, err)
+
+	fakeConfig := wxpayfake.Config{
+		AppId:        "appId12345",
+		MchId:        "mch12345",
+		AesKey:       "0123456789abcdef0123456789abcdef",
+		MchPublicKey: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyBytes}),
+	}
+	fakeServer, err := wxpayfake.NewServer(fakeConfig)
+	assert.Nil(t, err)
+	apiServer := httptest.NewServer(fakeServer.Handler())
+	t.Cleanup(apiServer.Close)
+	platformPublicKey, err := fakeServer.PlatformPublicKeyPEM()
+	assert.Nil(t, err)
+	platformPublicKeyPath := filepath.Join(keyDir, "wxp_pub.pem")
+	assert.Nil(t, os.WriteFile(platformPublicKeyPath, platformPublicKey, 0644))
+
+	originConfig := config.App.WechatPay
+	t.Cleanup(func() {
+		config.App.WechatPay = originConfig
+	})
+	config.App.WechatPay.AppId = fakeConfig.AppId
+	config.App.WechatPay.MchId = fakeConfig.MchId
+	config.App.WechatPay.PrivateSerialNo = "567"
+	config.App.WechatPay.AesKey = fakeConfig.AesKey
+	config.App.WechatPay.Gateway = enum.WxPayGatewayWechat
+	config.App.WechatPay.ApiBaseUrl = apiServer.URL
+	config.App.WechatPay.PrivateKeyPath = privateKeyPath
+	config.App.WechatPay.PlatformPublicKeyPath = platformPublicKeyPath
+	return fakeServer
+}
+
+// newWxPayNotifyRouter 只注册微信支付结果通知接口的路由
+func newWxPayNotifyRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/order/notify/wxpay", controller.WxPayNotify)
+	return router
+}
+
+// wxPayNotify 模拟服务发出的支付结果通知
+type wxPayNotify struct {
+	header http.Header
+	body   []byte
+}
+
+// captureWxPayNotify 在模拟服务中为订单下单并支付, 截获模拟服务发出的支付结果通知, payMoney 为用户在微信支付实际支付的金额
+func captureWxPayNotify(t *testing.T, fakeServer *wxpayfake.Server, orderNo string, payMoney int) (*wxPayNotify, wxpayfake.Transaction) {
+	notify := new(wxPayNotify)
+	captureServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		notify.header = r.Header.Clone()
+		notify.body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer captureServer.Close()
+
+	wxPayLib := library.NewWxPayLib(context.TODO(), library.WxPayConfig{
+		AppId:                 config.App.WechatPay.AppId,
+		MchId:                 config.App.WechatPay.MchId,
+		PrivateSerialNo:       config.App.WechatPay.PrivateSerialNo,
+		AesKey:                config.App.WechatPay.AesKey,
+		NotifyUrl:             captureServer.URL,
+		ApiBaseUrl:            config.App.WechatPay.ApiBaseUrl,
+		PrivateKeyPath:        config.App.WechatPay.PrivateKeyPath,
+		PlatformPublicKeyPath: config.App.WechatPay.PlatformPublicKeyPath,
+	})
+	_, err := wxPayLib.CreateNativeOrderPay(&do.Order{
+		OrderNo:  orderNo,
+		PayMoney: payMoney,
+		Items:    []*do.OrderItem{{CommodityName: "Apple iPhone 15"}},
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, fakeServer.Pay(orderNo))
+	trans, ok := fakeServer.Transaction(orderNo)
+	assert.True(t, ok)
+	return notify, trans
+}
+
+// sendWxPayNotify 把支付结果通知发送给通知接口
+func sendWxPayNotify(router *gin.Engine, notify *wxPayNotify) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/order/notify/wxpay", bytes.NewReader(notify.body))
+	req.Header = notify.header.Clone()
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// assertWxPayNotifyReply 微信支付要求通知接口处理成功时返回 200 和 {"code":"SUCCESS"}, 失败时返回 4XX/5XX 和 {"code":"FAIL"}
+func assertWxPayNotifyReply(t *testing.T, recorder *httptest.ResponseRecorder, status int, body string) {
+	assert.Equal(t, status, recorder.Code)
+	assert.JSONEq(t, body, recorder.Body.String())
+}
+
+// expectWxPayNotifyOrderQuery 处理通知时按订单号查询订单
+func expectWxPayNotifyOrderQuery(orderId int64, orderNo string, payMoney int, payTransId string, payState, orderStatus int) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `orders` WHERE order_no = ?")).
+		WithArgs(orderNo, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_no", "user_id", "pay_type", "pay_trans_id", "pay_money", "pay_state", "order_status"}).
+			AddRow(orderId, orderNo, 1, enum.PayTypeWxPay, payTransId, payMoney, payState, orderStatus))
+}
+
+// TestWxPayNotify_Success 处理成功的通知把订单更新为已支付, 返回微信支付要求的成功应答
+func TestWxPayNotify_Success(t *testing.T) {
+	var orderId int64 = 101
+	orderNo := "16839040520101"
+	fakeServer := setupWxPayFake(t)
+	notify, trans := captureWxPayNotify(t, fakeServer, orderNo, 10000)
+
+	expectWxPayNotifyOrderQuery(orderId, orderNo, 10000, "", enum.PayStateUnPaid, enum.OrderStatusUnPaid)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `orders` SET `order_status`=?,`paid_at`=?,`pay_state`=?,`pay_trans_id`=?,`pay_type`=?")).
+		WithArgs(enum.OrderStatusPaid, sqlmock.AnyArg(), enum.PayStatePaid, trans.TransactionId, enum.PayTypeWxPay, sqlmock.AnyArg(), orderId, enum.OrderStatusUnPaid, 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `order_status_logs`")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `group_buy_members`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `user_coupons` SET")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	recorder := sendWxPayNotify(newWxPayNotifyRouter(), notify)
+	assertWxPayNotifyReply(t, recorder, http.StatusOK, `{"code":"SUCCESS","message":"成功"}`)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+// TestWxPayNotify_Duplicate 同一笔支付的重复通知不再更新订单, 仍然返回成功应答, 避免微信支付一直重发
+func TestWxPayNotify_Duplicate(t *testing.T) {
+	var orderId int64 = 101
+	orderNo := "16839040520102"
+	fakeServer := setupWxPayFake(t)
+	notify, trans := captureWxPayNotify(t, fakeServer, orderNo, 10000)
+
+	// 没有设置更新订单的 SQL 期望, 更新订单时 sqlmock 会返回错误
+	expectWxPayNotifyOrderQuery(orderId, orderNo, 10000, trans.TransactionId, enum.PayStatePaid, enum.OrderStatusPaid)
+
+	recorder := sendWxPayNotify(newWxPayNotifyRouter(), notify)
+	assertWxPayNotifyReply(t, recorder, http.StatusOK, `{"code":"SUCCESS","message":"成功"}`)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+// TestWxPayNotify_AmountMismatch 实际支付的金额和订单的实付金额不一致时不更新订单, 返回失败应答
+func TestWxPayNotify_AmountMismatch(t *testing.T) {
+	var orderId int64 = 101
+	orderNo := "16839040520103"
+	fakeServer := setupWxPayFake(t)
+	notify, _ := captureWxPayNotify(t, fakeServer, orderNo, 1)
+
+	expectWxPayNotifyOrderQuery(orderId, orderNo, 10000, "", enum.PayStateUnPaid, enum.OrderStatusUnPaid)
+
+	recorder := sendWxPayNotify(newWxPayNotifyRouter(), notify)
+	assertWxPayNotifyReply(t, recorder, http.StatusBadRequest, `{"code":"FAIL","message":"通知验证失败"}`)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+// TestWxPayNotify_Invalid 签名不对或者时间戳过期的通知在查询订单前就被拒绝
+func TestWxPayNotify_Invalid(t *testing.T) {
+	fakeServer := setupWxPayFake(t)
+	notify, _ := captureWxPayNotify(t, fakeServer, "16839040520104", 10000)
+	router := newWxPayNotifyRouter()
+
+	t.Run("篡改通知数据", func(t *testing.T) {
+		tampered := &wxPayNotify{header: notify.header, body: bytes.Replace(notify.body, []byte("支付成功"), []byte("支付失败"), 1)}
+		recorder := sendWxPayNotify(router, tampered)
+		assertWxPayNotifyReply(t, recorder, http.StatusBadRequest, `{"code":"FAIL","message":"通知验证失败"}`)
+	})
+	t.Run("其他密钥的签名", func(t *testing.T) {
+		forged := &wxPayNotify{header: notify.header.Clone(), body: notify.body}
+		forged.header.Set("Wechatpay-Signature", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 256)))
+		recorder := sendWxPayNotify(router, forged)
+		assertWxPayNotifyReply(t, recorder, http.StatusBadRequest, `{"code":"FAIL","message":"通知验证失败"}`)
+	})
+	t.Run("时间戳过期", func(t *testing.T) {
+		replayed := &wxPayNotify{header: notify.header.Clone(), body: notify.body}
+		replayed.header.Set("Wechatpay-Timestamp", strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10))
+		recorder := sendWxPayNotify(router, replayed)
+		assertWxPayNotifyReply(t, recorder, http.StatusBadRequest, `{"code":"FAIL","message":"通知验证失败"}`)
+	})
+	// 没有设置 SQL 期望, 验证不通过的通知不会查询和更新订单
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
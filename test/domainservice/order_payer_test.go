@@ -0,0 +1,103 @@
+package domainservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/dal/cache"
+	"github.com/hd2yao/go-mall/library"
+	"github.com/hd2yao/go-mall/logic/do"
+	"github.com/hd2yao/go-mall/logic/domainservice"
+)
+
+// slowChannelPayHandler 使用真实的防重校验, 不查询订单, 向支付平台下单时只记录下单次数并返回固定的调起支付信息
+type slowChannelPayHandler struct {
+	domainservice.OrderPayHandlerContract
+	handler      *domainservice.WxOrderPayHandler
+	channelCalls *int32
+	calling      chan struct{} // 开始向支付平台下单时关闭
+}
+
+func (h *slowChannelPayHandler) ValidateOrder() error {
+	h.handler.Order = &do.Order{OrderNo: h.handler.OrderNo, UserId: h.handler.UserId, CreatedAt: time.Now()}
+	return nil
+}
+
+func (h *slowChannelPayHandler) HandleOrderPay() (interface{}, error) {
+	if atomic.AddInt32(h.channelCalls, 1) == 1 {
+		close(h.calling)
+	}
+	// 支付平台下单比较慢, 用户在此期间又点了一次支付
+	time.Sleep(300 * time.Millisecond)
+	return &library.WxPayInvokeInfo{
+		AppId:     "wx0000000000000001",
+		TimeStamp: "1725330030",
+		NonceStr:  "5K8264ILTKCH16CQ2502SI8ZNMTM67VS",
+		Package:   "prepay_id=wx03102030000000000000000000000000",
+		SignType:  "RSA",
+		PaySign:   "signature",
+	}, nil
+}
+
+// TestOrderPayTemplate_CheckRepetition 同一个订单的两个支付请求同时到达时只向支付平台下单一次, 后到的请求返回缓存的调起支付信息
+func TestOrderPayTemplate_CheckRepetition(t *testing.T) {
+	var userId int64 = 1
+	orderNo := fmt.Sprintf("1683904052%04d", time.Now().UnixNano()%10000)
+	t.Cleanup(func() {
+		cache.Redis().Del(context.TODO(), fmt.Sprintf(enum.REDIS_KEY_ORDER_PREPAY, userId, orderNo),
+			fmt.Sprintf(enum.REDIS_KEY_ORDER_PAY_LOCK, userId, orderNo))
+	})
+	var channelCalls int32
+	calling := make(chan struct{})
+	newPayTemplate := func() *domainservice.OrderPayTemplate {
+		payTemplate := domainservice.NewOrderPayTemplate(context.TODO(), userId, orderNo, "jsapi", enum.PayTypeWxPay)
+		payTemplate.OrderPayHandlerContract = &slowChannelPayHandler{
+			OrderPayHandlerContract: payTemplate.OrderPayHandlerContract,
+			handler:                 payTemplate.OrderPayHandlerContract.(*domainservice.WxOrderPayHandler),
+			channelCalls:            &channelCalls,
+			calling:                 calling,
+		}
+		return payTemplate
+	}
+	// 返回缓存的预支付信息前确认订单还在等待微信支付
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `orders` WHERE order_no = ?")).
+		WithArgs(orderNo, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_no", "user_id", "pay_type", "order_status"}).
+			AddRow(101, orderNo, userId, enum.PayTypeWxPay, enum.OrderStatusUnPaid))
+
+	var (
+		wg                      sync.WaitGroup
+		firstReply, secondReply interface{}
+		firstErr, secondErr     error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		firstReply, firstErr = newPayTemplate().CreateOrderPay()
+	}()
+	<-calling
+	go func() {
+		defer wg.Done()
+		secondReply, secondErr = newPayTemplate().CreateOrderPay()
+	}()
+	wg.Wait()
+
+	assert.Nil(t, firstErr)
+	assert.Nil(t, secondErr)
+	assert.Equal(t, int32(1), channelCalls)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	firstPayInfo, err := json.Marshal(firstReply)
+	assert.Nil(t, err)
+	assert.IsType(t, json.RawMessage{}, secondReply)
+	assert.JSONEq(t, string(firstPayInfo), string(secondReply.(json.RawMessage)))
+}
@@ -0,0 +1,153 @@
+package domainservice
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/logic/domainservice"
+)
+
+// enteredBeforeArg 匹配自动任务查询订单时传入的进入状态的截止时间, 截止时间应该是 days 天前
+type enteredBeforeArg struct {
+	days int
+}
+
+func (a enteredBeforeArg) Match(v driver.Value) bool {
+	enteredBefore, ok := v.(time.Time)
+	if !ok {
+		return false
+	}
+	expected := time.Now().AddDate(0, 0, -a.days)
+	return enteredBefore.After(expected.Add(-time.Minute)) && enteredBefore.Before(expected.Add(time.Minute))
+}
+
+// expectOrderQuery 按订单号查询订单
+func expectOrderQuery(orderId, userId int64, orderNo string, orderStatus int) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `orders` WHERE order_no = ?")).
+		WithArgs(orderNo, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_no", "user_id", "pay_money", "order_status"}).
+			AddRow(orderId, orderNo, userId, 10000, orderStatus))
+}
+
+// expectUserOrderQueries 查询用户的订单时还会查询订单的收货地址和购物明细
+func expectUserOrderQueries(orderId, userId int64, orderNo string, orderStatus int) {
+	expectOrderQuery(orderId, userId, orderNo, orderStatus)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `order_address`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id"}).AddRow(1, orderId))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `order_items`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "commodity_num", "pay_money"}).AddRow(1, orderId, 1, 10000))
+}
+
+// expectOrderStatusTransit 订单按原状态条件更新成 toStatus, 更新成功时记录状态变更
+func expectOrderStatusTransit(orderId int64, fromStatus, toStatus int, updated bool) {
+	var rowsAffected int64
+	if updated {
+		rowsAffected = 1
+	}
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `orders` SET `order_status`=?")).
+		WithArgs(toStatus, sqlmock.AnyArg(), orderId, fromStatus, 0).
+		WillReturnResult(sqlmock.NewResult(0, rowsAffected))
+	if updated {
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `order_status_logs`")).
+			WithArgs(orderId, sqlmock.AnyArg(), fromStatus, toStatus, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(orderId, 1))
+	}
+}
+
+// TestOrderDomainSvc_ConfirmOrderReceipt 用户确认收货, 商家还没有设置为已送达的发货记录同时变更为已送达
+func TestOrderDomainSvc_ConfirmOrderReceipt(t *testing.T) {
+	var orderId, userId, shipmentId int64 = 101, 1, 7
+	orderNo := "16839040520001"
+	ods := domainservice.NewOrderDomainSvc(context.TODO())
+
+	t.Run("已发货的订单确认收货", func(t *testing.T) {
+		expectUserOrderQueries(orderId, userId, orderNo, enum.OrderStatusShipped)
+		mock.ExpectBegin()
+		expectOrderStatusTransit(orderId, enum.OrderStatusShipped, enum.OrderStatusConfirmReceipt, true)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `order_shipments`")).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "status"}).AddRow(shipmentId, orderId, enum.ShipmentStatusShipped))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE `order_shipments` SET")).
+			WithArgs(sqlmock.AnyArg(), enum.ShipmentStatusDelivered, sqlmock.AnyArg(), shipmentId, enum.ShipmentStatusShipped).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.Nil(t, ods.ConfirmOrderReceipt(orderNo, userId))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("并发请求已经变更了订单状态", func(t *testing.T) {
+		expectUserOrderQueries(orderId, userId, orderNo, enum.OrderStatusDelivered)
+		mock.ExpectBegin()
+		expectOrderStatusTransit(orderId, enum.OrderStatusDelivered, enum.OrderStatusConfirmReceipt, false)
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, ods.ConfirmOrderReceipt(orderNo, userId), errcode.ErrOrderCanNotBeChanged)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("还没有发货的订单不能确认收货", func(t *testing.T) {
+		expectUserOrderQueries(orderId, userId, orderNo, enum.OrderStatusPaid)
+
+		assert.ErrorIs(t, ods.ConfirmOrderReceipt(orderNo, userId), errcode.ErrOrderCanNotBeChanged)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("不能确认其他用户的订单", func(t *testing.T) {
+		expectOrderQuery(orderId, 2, orderNo, enum.OrderStatusDelivered)
+
+		assert.ErrorIs(t, ods.ConfirmOrderReceipt(orderNo, userId), errcode.ErrOrderParams)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+// TestOrderDomainSvc_AutoConfirmDeliveredOrders 按进入已送达状态的时间筛选订单, 已经被其他请求变更的订单跳过
+func TestOrderDomainSvc_AutoConfirmDeliveredOrders(t *testing.T) {
+	mock.ExpectQuery(regexp.QuoteMeta("JOIN order_status_logs ON order_status_logs.order_id = orders.id AND order_status_logs.to_status = orders.order_status")).
+		WithArgs(enum.OrderStatusDelivered, 0, enteredBeforeArg{days: domainservice.AutoConfirmReceiptDays()}, 200).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_no", "user_id", "order_status"}).
+			AddRow(101, "16839040520001", 1, enum.OrderStatusDelivered).
+			AddRow(102, "16839040520002", 1, enum.OrderStatusDelivered))
+	mock.ExpectBegin()
+	expectOrderStatusTransit(101, enum.OrderStatusDelivered, enum.OrderStatusConfirmReceipt, true)
+	mock.ExpectCommit()
+	// 用户在扫描之后自己确认了收货
+	mock.ExpectBegin()
+	expectOrderStatusTransit(102, enum.OrderStatusDelivered, enum.OrderStatusConfirmReceipt, false)
+	mock.ExpectCommit()
+
+	assert.Nil(t, domainservice.NewOrderDomainSvc(context.TODO()).AutoConfirmDeliveredOrders())
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+// TestOrderDomainSvc_AutoCompleteOrders 评价期结束的订单变更为已完成, 并把扣除退款后的实付金额计入用户的会员累计消费
+func TestOrderDomainSvc_AutoCompleteOrders(t *testing.T) {
+	var orderId, userId, userVipId int64 = 101, 1, 3
+	mock.ExpectQuery(regexp.QuoteMeta("JOIN order_status_logs ON order_status_logs.order_id = orders.id AND order_status_logs.to_status = orders.order_status")).
+		WithArgs(enum.OrderStatusConfirmReceipt, 0, enteredBeforeArg{days: domainservice.ReviewWindowDays()}, 200).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_no", "user_id", "order_status"}).
+			AddRow(orderId, "16839040520001", userId, enum.OrderStatusConfirmReceipt))
+	mock.ExpectBegin()
+	expectOrderStatusTransit(orderId, enum.OrderStatusConfirmReceipt, enum.OrderStatusCompleted, true)
+	// 实付 100 元, 已退款 20 元
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `order_items`")).
+		WithArgs(orderId).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "pay_money", "refunded_money"}).AddRow(1, orderId, 10000, 2000))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `user_vips`")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `user_vips`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "tier_id", "total_spend"}).AddRow(userVipId, userId, 0, 5000))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `vip_tiers`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `user_vips` SET")).
+		WithArgs(sqlmock.AnyArg(), 0, 13000, sqlmock.AnyArg(), userVipId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.Nil(t, domainservice.NewOrderDomainSvc(context.TODO()).AutoCompleteOrders())
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
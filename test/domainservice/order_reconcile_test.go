@@ -0,0 +1,145 @@
+package domainservice
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/logic/do"
+	"github.com/hd2yao/go-mall/logic/domainservice"
+)
+
+// stubOrderPayQuerier 不请求支付平台, 返回设置好的支付结果, 并记录关闭了哪些订单的交易
+type stubOrderPayQuerier struct {
+	payResult      *do.OrderPayResult
+	closedOrderNos []string
+}
+
+func (querier *stubOrderPayQuerier) QueryOrderPay(ctx context.Context, order *do.Order) (*do.OrderPayResult, error) {
+	return querier.payResult, nil
+}
+
+func (querier *stubOrderPayQuerier) CloseOrderPay(ctx context.Context, order *do.Order) error {
+	querier.closedOrderNos = append(querier.closedOrderNos, order.OrderNo)
+	return nil
+}
+
+// setStubOrderPayQuerier 测试结束后恢复按支付方式选择支付平台
+func setStubOrderPayQuerier(t *testing.T, payResult *do.OrderPayResult) *stubOrderPayQuerier {
+	querier := &stubOrderPayQuerier{payResult: payResult}
+	domainservice.SetUTOrderPayQuerier(querier)
+	t.Cleanup(func() {
+		domainservice.SetUTOrderPayQuerier(nil)
+	})
+	return querier
+}
+
+// expectUnpaidOrderQueries 待支付的订单按订单号被查询 times 次, 订单购买了 2 件 ID 为 12 的商品, 实付 100 元
+func expectUnpaidOrderQueries(orderId int64, orderNo string, times int) {
+	for i := 0; i < times; i++ {
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `orders` WHERE order_no = ?")).
+			WithArgs(orderNo, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "order_no", "user_id", "pay_type", "pay_money", "pay_state", "order_status"}).
+				AddRow(orderId, orderNo, 1, enum.PayTypeWxPay, 10000, enum.PayStateUnPaid, enum.OrderStatusUnPaid))
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `order_address`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id"}).AddRow(1, orderId))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `order_items`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "commodity_id", "commodity_num", "pay_money"}).AddRow(1, orderId, 12, 2, 10000))
+}
+
+// expectCloseUnpaidOrderTx 关闭未支付订单的事务: 订单变更为 toStatus, 恢复 2 件商品的库存, 释放优惠券和满减活动
+// 订单不是秒杀订单, 也不是拼团订单
+func expectCloseUnpaidOrderTx(orderId int64, toStatus int) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `orders` SET `order_status`=?")).
+		WithArgs(toStatus, sqlmock.AnyArg(), orderId, enum.OrderStatusUnPaid, 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `order_status_logs`")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `flash_sale_orders`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `commodities`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock_num"}).AddRow(12, "Apple iPhone 15", 5))
+	// 库存从 5 件恢复到 7 件
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `commodities` SET `stock_num`=?")).
+		WithArgs(7, sqlmock.AnyArg(), 0, 12).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `user_coupons` SET")).
+		WithArgs("", enum.UserCouponUnused, sqlmock.AnyArg(), sqlmock.AnyArg(), enum.UserCouponLocked).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `order_discount_records` SET")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `group_buy_members`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
+}
+
+// TestOrderDomainSvc_ReconcileOrderPaySettled 支付平台已经支付成功, 订单按查询到的支付结果更新为已支付
+func TestOrderDomainSvc_ReconcileOrderPaySettled(t *testing.T) {
+	var orderId int64 = 101
+	orderNo := "16839040520001"
+	paidAt := time.Date(2024, 9, 3, 10, 20, 30, 0, time.Local)
+	setStubOrderPayQuerier(t, &do.OrderPayResult{
+		Paid:       true,
+		TradeState: "SUCCESS",
+		PayTransId: "4200000000202409030000000001",
+		PaidMoney:  10000,
+		PaidAt:     paidAt,
+	})
+
+	expectUnpaidOrderQueries(orderId, orderNo, 3)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `orders` SET `order_status`=?,`paid_at`=?,`pay_state`=?,`pay_trans_id`=?,`pay_type`=?")).
+		WithArgs(enum.OrderStatusPaid, paidAt, enum.PayStatePaid, "4200000000202409030000000001", enum.PayTypeWxPay, sqlmock.AnyArg(), orderId, enum.OrderStatusUnPaid, 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `order_status_logs`")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `group_buy_members`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `user_coupons` SET")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	decision, err := domainservice.NewOrderDomainSvc(context.TODO()).ReconcileOrderPay(orderNo)
+	assert.Nil(t, err)
+	assert.Equal(t, "settled", decision)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+// TestOrderDomainSvc_ReconcileOrderPayClosed 支付平台的交易已经关闭, 订单关闭并恢复库存, 不需要再关闭支付平台的交易
+func TestOrderDomainSvc_ReconcileOrderPayClosed(t *testing.T) {
+	var orderId int64 = 101
+	orderNo := "16839040520001"
+	querier := setStubOrderPayQuerier(t, &do.OrderPayResult{Closed: true, TradeState: "CLOSED"})
+
+	expectUnpaidOrderQueries(orderId, orderNo, 2)
+	expectCloseUnpaidOrderTx(orderId, enum.OrderStatusUnpaidClose)
+
+	decision, err := domainservice.NewOrderDomainSvc(context.TODO()).ReconcileOrderPay(orderNo)
+	assert.Nil(t, err)
+	assert.Equal(t, "closed", decision)
+	assert.Empty(t, querier.closedOrderNos)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+// TestOrderDomainSvc_ReconcileOrderPayWaiting 用户还没有完成支付, 订单保持待支付, 不更新数据库
+func TestOrderDomainSvc_ReconcileOrderPayWaiting(t *testing.T) {
+	var orderId int64 = 101
+	orderNo := "16839040520001"
+	querier := setStubOrderPayQuerier(t, &do.OrderPayResult{TradeState: "NOTPAY"})
+
+	// 没有设置更新的 SQL 期望, 更新订单时 sqlmock 会返回错误
+	expectUnpaidOrderQueries(orderId, orderNo, 2)
+
+	decision, err := domainservice.NewOrderDomainSvc(context.TODO()).ReconcileOrderPay(orderNo)
+	assert.Nil(t, err)
+	assert.Equal(t, "waiting", decision)
+	assert.Empty(t, querier.closedOrderNos)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,63 @@
+package domainservice
+
+import (
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/logic/do"
+	"github.com/hd2yao/go-mall/logic/domainservice"
+)
+
+// TestAllocateMoney 按权重分摊金额, 分摊结果之和总是等于总金额, 除不尽的零头不会丢失
+func TestAllocateMoney(t *testing.T) {
+	cases := []struct {
+		name     string
+		total    int
+		weights  []int
+		expected []int
+	}{
+		{"按比例整除", 1000, []int{300, 700}, []int{300, 700}},
+		{"除不尽时零头四舍五入到累计金额", 100, []int{1, 1, 1}, []int{33, 34, 33}},
+		{"权重为 0 的不分摊", 500, []int{0, 200, 300}, []int{0, 200, 300}},
+		{"总金额为 0", 0, []int{100, 200}, []int{0, 0}},
+		{"权重都为 0", 100, []int{0, 0}, []int{0, 0}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			shares := domainservice.AllocateMoney(c.total, c.weights)
+			assert.Equal(t, c.expected, shares)
+			if lo.Sum(c.weights) > 0 {
+				assert.Equal(t, c.total, lo.Sum(shares))
+			}
+		})
+	}
+
+	// 权重悬殊时分摊结果之和也等于总金额
+	shares := domainservice.AllocateMoney(9999, []int{549700, 9900, 9900, 1})
+	assert.Equal(t, 9999, lo.Sum(shares))
+}
+
+// TestOrderItemRefundMoney 同一订单明细分多次退款时, 累计退款金额正好等于它分摊后的实付金额
+func TestOrderItemRefundMoney(t *testing.T) {
+	orderItem := &do.OrderItem{CommodityNum: 3, PayMoney: 1000}
+
+	// 第一次退 1 件, 1000/3 向下取整
+	refundMoney := orderItem.RefundMoney(1)
+	assert.Equal(t, 333, refundMoney)
+	orderItem.RefundedNum, orderItem.RefundedMoney = 1, refundMoney
+
+	// 第二次退 1 件, 按累计 2 件应退 666 减去已退的 333
+	refundMoney = orderItem.RefundMoney(1)
+	assert.Equal(t, 333, refundMoney)
+	orderItem.RefundedNum, orderItem.RefundedMoney = 2, orderItem.RefundedMoney+refundMoney
+
+	// 最后 1 件退回剩下的全部金额
+	refundMoney = orderItem.RefundMoney(1)
+	assert.Equal(t, 334, refundMoney)
+	assert.Equal(t, orderItem.PayMoney, orderItem.RefundedMoney+refundMoney)
+
+	// 一次退完所有商品
+	assert.Equal(t, 1000, (&do.OrderItem{CommodityNum: 3, PayMoney: 1000}).RefundMoney(3))
+}
@@ -0,0 +1,50 @@
+package domainservice
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/logic/domainservice"
+)
+
+// TestCanTransitOrderStatus 状态机只允许表中记录的状态变更, 并且只能由记录的操作方触发
+func TestCanTransitOrderStatus(t *testing.T) {
+	cases := []struct {
+		name     string
+		from     int
+		to       int
+		actor    int
+		expected bool
+	}{
+		{"用户发起支付", enum.OrderStatusCreated, enum.OrderStatusUnPaid, enum.OrderActorUser, true},
+		{"系统不能替用户发起支付", enum.OrderStatusCreated, enum.OrderStatusUnPaid, enum.OrderActorSystem, false},
+		{"支付成功", enum.OrderStatusUnPaid, enum.OrderStatusPaid, enum.OrderActorPayment, true},
+		{"用户不能把订单设置为已支付", enum.OrderStatusUnPaid, enum.OrderStatusPaid, enum.OrderActorUser, false},
+		{"已关闭的订单不能再支付", enum.OrderStatusUnpaidClose, enum.OrderStatusPaid, enum.OrderActorPayment, false},
+		{"用户取消未支付的订单", enum.OrderStatusUnPaid, enum.OrderStatusUserQuit, enum.OrderActorUser, true},
+		{"超时关闭未支付的订单", enum.OrderStatusUnPaid, enum.OrderStatusUnpaidClose, enum.OrderActorSystem, true},
+		{"已支付的订单不能取消", enum.OrderStatusPaid, enum.OrderStatusUserQuit, enum.OrderActorUser, false},
+		{"商家检货", enum.OrderStatusPaid, enum.OrderStatusChecked, enum.OrderActorMerchant, true},
+		{"不能跳过检货直接发货", enum.OrderStatusPaid, enum.OrderStatusShipped, enum.OrderActorMerchant, false},
+		{"系统更新物流状态", enum.OrderStatusShipped, enum.OrderStatusOnDelivery, enum.OrderActorSystem, true},
+		{"用户确认收货", enum.OrderStatusDelivered, enum.OrderStatusConfirmReceipt, enum.OrderActorUser, true},
+		{"商家不能替用户确认收货", enum.OrderStatusDelivered, enum.OrderStatusConfirmReceipt, enum.OrderActorMerchant, false},
+		{"过了评价期自动完成", enum.OrderStatusConfirmReceipt, enum.OrderStatusCompleted, enum.OrderActorSystem, true},
+		{"用户申请退款", enum.OrderStatusShipped, enum.OrderStatusRefunding, enum.OrderActorUser, true},
+		{"已完成的订单不能申请退款", enum.OrderStatusCompleted, enum.OrderStatusRefunding, enum.OrderActorUser, false},
+		{"拼团失败系统自动退款", enum.OrderStatusPaid, enum.OrderStatusRefunding, enum.OrderActorSystem, true},
+		{"系统只能为已支付的拼团订单退款", enum.OrderStatusShipped, enum.OrderStatusRefunding, enum.OrderActorSystem, false},
+		{"拒绝退款后恢复原状态", enum.OrderStatusRefunding, enum.OrderStatusShipped, enum.OrderActorMerchant, true},
+		{"退款中的订单只能恢复成可退款的状态", enum.OrderStatusRefunding, enum.OrderStatusCompleted, enum.OrderActorMerchant, false},
+		{"退款成功", enum.OrderStatusRefunding, enum.OrderStatusRefunded, enum.OrderActorPayment, true},
+		{"商家不能直接把订单设置为已退款", enum.OrderStatusRefunding, enum.OrderStatusRefunded, enum.OrderActorMerchant, false},
+		{"已退款是终态", enum.OrderStatusRefunded, enum.OrderStatusPaid, enum.OrderActorPayment, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, domainservice.CanTransitOrderStatus(c.from, c.to, c.actor))
+		})
+	}
+}
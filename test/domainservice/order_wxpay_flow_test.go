@@ -0,0 +1,104 @@
+package domainservice
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/config"
+	"github.com/hd2yao/go-mall/dal/cache"
+	"github.com/hd2yao/go-mall/library"
+	"github.com/hd2yao/go-mall/logic/domainservice"
+)
+
+// capturedArg 匹配任意参数并记录参数值, 用于断言执行时才能确定的值
+type capturedArg struct {
+	value driver.Value
+}
+
+func (a *capturedArg) Match(v driver.Value) bool {
+	a.value = v
+	return true
+}
+
+// expectUserOrderQueriesWithStatus 查询用户的订单以及订单的收货地址和购物明细, 订单实付 100 元
+func expectUserOrderQueriesWithStatus(orderId, userId int64, orderNo string, orderStatus, payState int) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `orders` WHERE order_no = ?")).
+		WithArgs(orderNo, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_no", "user_id", "pay_type", "pay_money", "pay_state", "order_status", "created_at"}).
+			AddRow(orderId, orderNo, userId, enum.PayTypeWxPay, 10000, payState, orderStatus, time.Now()))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `order_address`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id"}).AddRow(1, orderId))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `order_items`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "commodity_id", "commodity_name", "commodity_num", "pay_money"}).
+			AddRow(1, orderId, 12, "Apple iPhone 15", 1, 10000))
+}
+
+// TestOrderWxPayFlow 用户发起微信 Native 支付, 在模拟服务上完成支付后, 模拟服务把支付结果通知发给真实的通知接口, 订单变更为已支付
+func TestOrderWxPayFlow(t *testing.T) {
+	var orderId, userId int64 = 101, 1
+	orderNo := fmt.Sprintf("1683904053%04d", time.Now().UnixNano()%10000)
+	t.Cleanup(func() {
+		cache.Redis().Del(context.TODO(), fmt.Sprintf(enum.REDIS_KEY_ORDER_PREPAY, userId, orderNo),
+			fmt.Sprintf(enum.REDIS_KEY_ORDER_PAY_LOCK, userId, orderNo))
+	})
+	fakeServer := setupWxPayFake(t)
+	notifyServer := httptest.NewServer(newWxPayNotifyRouter())
+	t.Cleanup(notifyServer.Close)
+	config.App.WechatPay.NotifyUrl = notifyServer.URL + "/order/notify/wxpay"
+
+	// 发起支付: 校验订单后把订单从已创建变更为待支付
+	expectUserOrderQueriesWithStatus(orderId, userId, orderNo, enum.OrderStatusCreated, enum.PayStateUnPaid)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `group_buy_members`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	expectUserOrderQueriesWithStatus(orderId, userId, orderNo, enum.OrderStatusCreated, enum.PayStateUnPaid)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `orders` SET `order_status`=?,`pay_state`=?,`pay_type`=?")).
+		WithArgs(enum.OrderStatusUnPaid, enum.PayStateUnPaid, enum.PayTypeWxPay, sqlmock.AnyArg(), orderId, enum.OrderStatusCreated, 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `order_status_logs`")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	payInfo, err := domainservice.NewOrderPayTemplate(context.TODO(), userId, orderNo, "native", enum.PayTypeWxPay).CreateOrderPay()
+	assert.Nil(t, err)
+	assert.IsType(t, &library.WxNativePayInfo{}, payInfo)
+	assert.NotEmpty(t, payInfo.(*library.WxNativePayInfo).CodeUrl)
+	assert.Nil(t, mock.ExpectationsWereMet())
+
+	// 处理支付结果通知: 订单从待支付变更为已支付, 记录微信支付的交易号和支付时间
+	expectWxPayNotifyOrderQuery(orderId, orderNo, 10000, "", enum.PayStateUnPaid, enum.OrderStatusUnPaid)
+	var paidAt, payTransId capturedArg
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `orders` SET `order_status`=?,`paid_at`=?,`pay_state`=?,`pay_trans_id`=?,`pay_type`=?")).
+		WithArgs(enum.OrderStatusPaid, &paidAt, enum.PayStatePaid, &payTransId, enum.PayTypeWxPay, sqlmock.AnyArg(), orderId, enum.OrderStatusUnPaid, 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `order_status_logs`")).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `group_buy_members`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `user_coupons` SET")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	// 模拟服务同步发送通知, 通知接口没有返回 2XX 时 Pay 返回错误
+	assert.Nil(t, fakeServer.Pay(orderNo))
+	assert.Nil(t, mock.ExpectationsWereMet())
+	// 订单记录的是微信支付生成的交易号和支付完成时间
+	trans, ok := fakeServer.Transaction(orderNo)
+	assert.True(t, ok)
+	assert.Equal(t, "SUCCESS", trans.TradeState)
+	assert.Equal(t, 10000, trans.Total)
+	assert.NotEmpty(t, trans.TransactionId)
+	assert.Equal(t, trans.TransactionId, payTransId.value)
+	assert.IsType(t, time.Time{}, paidAt.value)
+	assert.True(t, trans.SuccessTime.Equal(paidAt.value.(time.Time)))
+}
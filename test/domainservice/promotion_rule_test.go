@@ -0,0 +1,150 @@
+package domainservice
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/config"
+	"github.com/hd2yao/go-mall/logic/do"
+	"github.com/hd2yao/go-mall/logic/domainservice"
+)
+
+// setPromotionConfig 按 steps ("优惠:基准金额") 修改配置的优惠叠加规则, 测试结束后恢复成配置文件中的规则
+func setPromotionConfig(t *testing.T, steps []string, exclusions [][]string) {
+	origin := config.App.Promotion
+	t.Cleanup(func() {
+		config.App.Promotion = origin
+	})
+	// 配置项是匿名结构体, 复制配置文件中的一项再修改
+	stacking := slices.Clone(origin.Stacking)[:0]
+	for _, step := range steps {
+		stackStep := origin.Stacking[0]
+		stackStep.Name, stackStep.Base, _ = strings.Cut(step, ":")
+		stacking = append(stacking, stackStep)
+	}
+	config.App.Promotion.Stacking = stacking
+	config.App.Promotion.Exclusions = exclusions
+}
+
+func stackStepNames(rules *do.PromotionStackRules) []string {
+	names := make([]string, 0, len(rules.Steps))
+	for _, step := range rules.Steps {
+		names = append(names, step.Name+":"+step.Base)
+	}
+	return names
+}
+
+// TestPromotionStackRules 读取配置的优惠叠加规则, 配置错误时使用默认规则
+func TestPromotionStackRules(t *testing.T) {
+	defaultSteps := []string{"vip:original", "coupon:original", "discount:reduced"}
+
+	t.Run("按配置的顺序和基准金额", func(t *testing.T) {
+		setPromotionConfig(t, []string{"discount:original", "vip:reduced"}, [][]string{{enum.PromotionCoupon, enum.PromotionDiscount}})
+		rules := domainservice.PromotionStackRules(context.TODO())
+		assert.Equal(t, []string{"discount:original", "vip:reduced"}, stackStepNames(rules))
+		assert.Equal(t, [][]string{{enum.PromotionCoupon, enum.PromotionDiscount}}, rules.Exclusions)
+	})
+	t.Run("没有注册的优惠", func(t *testing.T) {
+		setPromotionConfig(t, []string{"vip:original", "points:reduced"}, nil)
+		assert.Equal(t, defaultSteps, stackStepNames(domainservice.PromotionStackRules(context.TODO())))
+	})
+	t.Run("重复的优惠", func(t *testing.T) {
+		setPromotionConfig(t, []string{"vip:original", "coupon:original", "vip:reduced"}, nil)
+		assert.Equal(t, defaultSteps, stackStepNames(domainservice.PromotionStackRules(context.TODO())))
+	})
+	t.Run("错误的基准金额", func(t *testing.T) {
+		setPromotionConfig(t, []string{"vip:paid", "coupon:original"}, nil)
+		assert.Equal(t, defaultSteps, stackStepNames(domainservice.PromotionStackRules(context.TODO())))
+	})
+}
+
+// TestPromotionStackRules_ExcludedBy 同一互斥组中已经使用了其他优惠时返回那项优惠
+func TestPromotionStackRules_ExcludedBy(t *testing.T) {
+	rules := &do.PromotionStackRules{
+		Exclusions: [][]string{{enum.PromotionCoupon, enum.PromotionDiscount}},
+	}
+	assert.Equal(t, enum.PromotionCoupon, rules.ExcludedBy(enum.PromotionDiscount, []string{enum.PromotionVip, enum.PromotionCoupon}))
+	assert.Equal(t, "", rules.ExcludedBy(enum.PromotionDiscount, []string{enum.PromotionVip}))
+	assert.Equal(t, "", rules.ExcludedBy(enum.PromotionVip, []string{enum.PromotionCoupon}))
+	assert.Equal(t, "", rules.ExcludedBy(enum.PromotionCoupon, []string{enum.PromotionCoupon}))
+}
+
+// expectStackingBillQueries 用户是 9 折会员, 有一个全部商品满 95 元减 10 元的满减活动
+func expectStackingBillQueries(userId int64) {
+	mock.ExpectQuery(regexp.QuoteMeta("FROM `user_vips`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "tier_id", "total_spend", "expire_at"}).
+			AddRow(1, userId, 2, 100000, time.Now().Add(24*time.Hour)))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM `vip_tiers`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "level", "discount_rate", "upgrade_spend"}).
+			AddRow(2, "黄金会员", 2, 10, 50000))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM `discount_campaigns`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "scope_type", "per_user_limit", "status"}).
+			AddRow(7, "满95减10", enum.DiscountScopeAll, 0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM `discount_campaign_tiers`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "campaign_id", "threshold", "discount_money"}).
+			AddRow(1, 7, 9500, 1000))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM `order_discount_records`")).
+		WillReturnRows(sqlmock.NewRows([]string{"campaign_id", "num"}))
+}
+
+func getStackingBill(t *testing.T) *do.CartBillInfo {
+	var userId int64 = 1
+	expectStackingBillQueries(userId)
+	items := []*do.ShoppingCartItem{
+		{CommodityId: 12, CommoditySellingPrice: 5000, CommodityNum: 2},
+	}
+	billChecker := domainservice.NewCartBillChecker(context.TODO(), items, userId, nil)
+	billChecker.SelectedCouponId = enum.BillNoCoupon
+	billInfo, err := billChecker.GetBill()
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	return billInfo
+}
+
+// TestCartBillChecker_GetBillStacking 按优惠叠加规则计算账单, 基准金额和互斥规则不同时减免的金额不同
+func TestCartBillChecker_GetBillStacking(t *testing.T) {
+	t.Run("满减按减去会员折扣后的金额判断门槛", func(t *testing.T) {
+		billInfo := getStackingBill(t)
+		assert.Equal(t, 10000, billInfo.OriginalTotalPrice)
+		assert.Equal(t, 1000, billInfo.VipDiscountMoney)
+		// 减去会员折扣后 90 元, 没有达到满减门槛
+		assert.Equal(t, 0, billInfo.Discount.DiscountMoney)
+		assert.Equal(t, 9000, billInfo.TotalPrice)
+		assert.Equal(t, 9000, billInfo.Steps[2].BaseMoney)
+		assert.Equal(t, 0, billInfo.Steps[2].DeductMoney)
+	})
+	t.Run("满减按商品原价判断门槛", func(t *testing.T) {
+		setPromotionConfig(t, []string{"vip:original", "coupon:original", "discount:original"}, nil)
+		billInfo := getStackingBill(t)
+		assert.Equal(t, 1000, billInfo.VipDiscountMoney)
+		assert.Equal(t, int64(7), billInfo.Discount.DiscountId)
+		assert.Equal(t, 1000, billInfo.Discount.DiscountMoney)
+		assert.Equal(t, 8000, billInfo.TotalPrice)
+		assert.Equal(t, 8000, billInfo.Items[0].PayMoney)
+	})
+	t.Run("会员折扣和满减互斥", func(t *testing.T) {
+		setPromotionConfig(t, []string{"vip:original", "coupon:original", "discount:original"}, [][]string{{enum.PromotionVip, enum.PromotionDiscount}})
+		billInfo := getStackingBill(t)
+		assert.Equal(t, 1000, billInfo.VipDiscountMoney)
+		assert.Equal(t, 0, billInfo.Discount.DiscountMoney)
+		assert.Equal(t, 9000, billInfo.TotalPrice)
+		assert.Equal(t, 0, billInfo.Steps[2].DeductMoney)
+		assert.Contains(t, billInfo.Steps[2].Description, "互斥")
+	})
+	t.Run("满减先于会员折扣计算", func(t *testing.T) {
+		setPromotionConfig(t, []string{"discount:original", "vip:reduced"}, nil)
+		billInfo := getStackingBill(t)
+		assert.Equal(t, 1000, billInfo.Discount.DiscountMoney)
+		// 会员折扣按减去满减后的 90 元计算
+		assert.Equal(t, 900, billInfo.VipDiscountMoney)
+		assert.Equal(t, 8100, billInfo.TotalPrice)
+	})
+}
@@ -0,0 +1,215 @@
+package library
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/library"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+func TestAliPayAmountConvert(t *testing.T) {
+	assert.Equal(t, "5497.00", library.AliPayCentToAmount(549700))
+	assert.Equal(t, "0.01", library.AliPayCentToAmount(1))
+	assert.Equal(t, "12.30", library.AliPayCentToAmount(1230))
+
+	cent, err := library.AliPayAmountToCent("5497.00")
+	assert.Nil(t, err)
+	assert.Equal(t, 549700, cent)
+	// 浮点数精度问题, 0.29 * 100 = 28.999999999999996
+	cent, err = library.AliPayAmountToCent("0.29")
+	assert.Nil(t, err)
+	assert.Equal(t, 29, cent)
+
+	_, err = library.AliPayAmountToCent("abc")
+	assert.NotNil(t, err)
+}
+
+// newAliPayTestLib 生成一对 RSA 密钥, 同时作为应用私钥和"支付宝公钥"使用, 测试里用这个私钥模拟支付宝给通知签名
+// gatewayUrl 为空时使用支付宝的网关地址
+func newAliPayTestLib(t *testing.T, gatewayUrl string) (*library.AliPayLib, *rsa.PrivateKey) {
+	keyDir := t.TempDir()
+	privateKeyPath, publicKey := genMchKeyPair(t, keyDir)
+	publicKeyPath := filepath.Join(keyDir, "alipay_pub.pem")
+	assert.Nil(t, os.WriteFile(publicKeyPath, publicKey, 0644))
+
+	privateKeyPem, err := os.ReadFile(privateKeyPath)
+	assert.Nil(t, err)
+	block, _ := pem.Decode(privateKeyPem)
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	assert.Nil(t, err)
+
+	apl := library.NewAliPayLib(context.TODO(), library.AliPayConfig{
+		AppId:               "2021000000000001",
+		GatewayUrl:          gatewayUrl,
+		NotifyUrl:           "https://mall.example.com/order/notify/alipay",
+		PrivateKeyPath:      privateKeyPath,
+		AliPayPublicKeyPath: publicKeyPath,
+	})
+	return apl, key.(*rsa.PrivateKey)
+}
+
+// aliPaySignContent 按支付宝的规则生成待签名字符串: 去掉空值和 excludeKeys 后按参数名升序用 & 拼接
+func aliPaySignContent(params url.Values, excludeKeys ...string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		if params.Get(key) != "" && !slices.Contains(excludeKeys, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+params.Get(key))
+	}
+	return strings.Join(pairs, "&")
+}
+
+// signAliPayNotify 模拟支付宝用 RSA2 给异步通知签名
+func signAliPayNotify(t *testing.T, key *rsa.PrivateKey, notifyForm url.Values) {
+	digest := sha256.Sum256([]byte(aliPaySignContent(notifyForm, "sign", "sign_type")))
+	signBytes, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	assert.Nil(t, err)
+	notifyForm.Set("sign", base64.StdEncoding.EncodeToString(signBytes))
+	notifyForm.Set("sign_type", "RSA2")
+}
+
+func newAliPayNotifyForm() url.Values {
+	notifyForm := url.Values{}
+	notifyForm.Set("app_id", "2021000000000001")
+	notifyForm.Set("notify_id", "2024090300222103156000000000000000")
+	notifyForm.Set("out_trade_no", "16839040520001")
+	notifyForm.Set("trade_no", "2024090322001400000000000000")
+	notifyForm.Set("trade_status", "TRADE_SUCCESS")
+	notifyForm.Set("total_amount", "5497.00")
+	notifyForm.Set("gmt_payment", "2024-09-03 10:20:30")
+	return notifyForm
+}
+
+// TestAliPayLib_SignRoundTrip 下单参数的 RSA2 签名能用对应的公钥验证通过, 异步通知的签名能被 VerifyNotifySignature 验证通过
+func TestAliPayLib_SignRoundTrip(t *testing.T) {
+	apl, key := newAliPayTestLib(t, "")
+
+	payInfo, err := apl.CreatePagePay(&do.Order{
+		OrderNo:  "16839040520001",
+		PayMoney: 549700,
+		Items:    []*do.OrderItem{{CommodityName: "Apple iPhone 15"}},
+	})
+	assert.Nil(t, err)
+	payUrl, err := url.Parse(payInfo.PayUrl)
+	assert.Nil(t, err)
+	params := payUrl.Query()
+	assert.Equal(t, "RSA2", params.Get("sign_type"))
+	// 请求签名时只有 sign 不参与签名
+	signBytes, err := base64.StdEncoding.DecodeString(params.Get("sign"))
+	assert.Nil(t, err)
+	digest := sha256.Sum256([]byte(aliPaySignContent(params, "sign")))
+	assert.Nil(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signBytes))
+
+	notifyForm := newAliPayNotifyForm()
+	signAliPayNotify(t, key, notifyForm)
+	verified, err := apl.VerifyNotifySignature(notifyForm)
+	assert.Nil(t, err)
+	assert.True(t, verified)
+}
+
+// TestAliPayLib_VerifyTamperedNotify 签名后被篡改的异步通知验签不通过
+func TestAliPayLib_VerifyTamperedNotify(t *testing.T) {
+	apl, key := newAliPayTestLib(t, "")
+
+	notifyForm := newAliPayNotifyForm()
+	signAliPayNotify(t, key, notifyForm)
+	// 篡改支付金额
+	notifyForm.Set("total_amount", "0.01")
+	verified, err := apl.VerifyNotifySignature(notifyForm)
+	assert.NotNil(t, err)
+	assert.False(t, verified)
+
+	// 追加签名时没有的参数
+	notifyForm = newAliPayNotifyForm()
+	signAliPayNotify(t, key, notifyForm)
+	notifyForm.Set("refund_fee", "5497.00")
+	verified, err = apl.VerifyNotifySignature(notifyForm)
+	assert.NotNil(t, err)
+	assert.False(t, verified)
+
+	// 其他密钥签名的通知
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	notifyForm = newAliPayNotifyForm()
+	signAliPayNotify(t, otherKey, notifyForm)
+	verified, err = apl.VerifyNotifySignature(notifyForm)
+	assert.NotNil(t, err)
+	assert.False(t, verified)
+
+	// 没有签名
+	notifyForm = newAliPayNotifyForm()
+	verified, err = apl.VerifyNotifySignature(notifyForm)
+	assert.NotNil(t, err)
+	assert.False(t, verified)
+}
+
+// TestAliPayLib_GatewayErrorResponse 网关返回 error_response 时返回其中的错误码和错误描述
+func TestAliPayLib_GatewayErrorResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json;charset=utf-8")
+		_, _ = w.Write([]byte(`{"error_response":{"code":"40002","msg":"Invalid Arguments","sub_code":"isv.invalid-app-id","sub_msg":"无效的AppID参数"},"sign":"ERITJKEIJKJHKKKKKKKHJEREEEEEEEEEEE"}`))
+	}))
+	defer server.Close()
+	apl, _ := newAliPayTestLib(t, server.URL)
+
+	_, err := apl.QueryTrade("16839040520001")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "40002")
+	assert.Contains(t, err.Error(), "isv.invalid-app-id")
+	assert.NotContains(t, err.Error(), "unexpected end of JSON input")
+}
+
+// TestAliPaySandboxLib_VerifyNotify 沙箱网关用 AppId 做 HMAC-SHA256 签名, 不需要密钥文件就能验证模拟的异步通知
+func TestAliPaySandboxLib_VerifyNotify(t *testing.T) {
+	appId := "2021000000000001"
+	asl := library.NewAliPaySandboxLib(context.TODO(), library.AliPayConfig{AppId: appId})
+
+	payInfo, err := asl.CreatePagePay(&do.Order{
+		OrderNo:  "16839040520001",
+		PayMoney: 549700,
+		Items:    []*do.OrderItem{{CommodityName: "Apple iPhone 15"}},
+	})
+	assert.Nil(t, err)
+	assert.Contains(t, payInfo.PayUrl, "out_trade_no")
+
+	notifyForm := newAliPayNotifyForm()
+	mac := hmac.New(sha256.New, []byte(appId))
+	mac.Write([]byte(aliPaySignContent(notifyForm, "sign", "sign_type")))
+	notifyForm.Set("sign", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	verified, err := asl.VerifyNotifySignature(notifyForm)
+	assert.Nil(t, err)
+	assert.True(t, verified)
+
+	notifyForm.Set("total_amount", "0.01")
+	verified, err = asl.VerifyNotifySignature(notifyForm)
+	assert.NotNil(t, err)
+	assert.False(t, verified)
+
+	queryReply, err := asl.QueryTrade("16839040520001")
+	assert.Nil(t, err)
+	assert.Equal(t, library.AliPayTradeStatusNotExist, queryReply.TradeStatus)
+}
@@ -59,7 +59,7 @@ func TestWxPayLib_CreateOrderPay(t *testing.T) {
 		AesKey:          "",
 		NotifyUrl:       "",
 	}
-	payDescription := fmt.Sprintf("GOMALL 商场购买%s 等商品", order.Items[0].CommodityName)
+	payDescription := fmt.Sprintf("GOMALL 商场购买 %s 等商品", order.Items[0].CommodityName)
 	request := library.PrePayParam{
 		AppId:       payConfig.AppId,
 		MchId:       payConfig.MchId,
@@ -74,7 +74,7 @@ func TestWxPayLib_CreateOrderPay(t *testing.T) {
 			Currency: "CNY",
 		},
 		Payer: struct {
-			OpenId string `json:"open_id"`
+			OpenId string `json:"openid"`
 		}{OpenId: openId},
 	}
 
@@ -116,3 +116,30 @@ func TestWxPayLib_CreateOrderPay(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestWxPaySandboxLib_CreateOrderPay(t *testing.T) {
+	order := &do.Order{
+		OrderNo:   "20240903374062590406950001",
+		PayMoney:  549700,
+		Items:     []*do.OrderItem{{CommodityName: "Apple iPhone 11 (A2223)"}},
+		CreatedAt: time.Date(2024, 9, 3, 10, 0, 0, 0, time.Local),
+	}
+	payConfig := library.WxPayConfig{AppId: "appId12345", MchId: "mch12345"}
+	sandboxLib := library.NewWxPaySandboxLib(context.TODO(), payConfig)
+
+	payInfo, err := sandboxLib.CreateOrderPay(order, "QsudfrhgrDYDEEA1344EF")
+	assert.Nil(t, err)
+	payInfoAgain, err := sandboxLib.CreateOrderPay(order, "QsudfrhgrDYDEEA1344EF")
+	assert.Nil(t, err)
+	// 同一订单多次下单得到的预支付信息相同
+	assert.Equal(t, payInfo, payInfoAgain)
+
+	appPayInfo, err := sandboxLib.CreateAppOrderPay(order)
+	assert.Nil(t, err)
+	assert.Equal(t, "prepay_id="+appPayInfo.PrepayId, payInfo.Package)
+	assert.Equal(t, payConfig.MchId, appPayInfo.PartnerId)
+
+	nativePayInfo, err := sandboxLib.CreateNativeOrderPay(order)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, nativePayInfo.CodeUrl)
+}
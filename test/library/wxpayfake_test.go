@@ -0,0 +1,183 @@
+package library
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/library"
+	"github.com/hd2yao/go-mall/library/wxpayfake"
+	"github.com/hd2yao/go-mall/logic/do"
+)
+
+// TestWxPayFakeServer_PayAndNotify 使用本地的模拟微信支付服务跑通 下单 -> 支付 -> 支付结果通知验签解密 的流程
+func TestWxPayFakeServer_PayAndNotify(t *testing.T) {
+	fakeServer, payConfig := startWxPayFakeServer(t)
+	// 模拟业务方接收支付结果通知的接口
+	notifyResult := make(chan *library.WxPayNotifyResourceData, 1)
+	notifyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawBody, _ := io.ReadAll(r.Body)
+		wpl := library.NewWxPayLib(context.TODO(), payConfig)
+		verified, err := wpl.ValidateNotifySignature(r.Header.Get("Wechatpay-Timestamp"), r.Header.Get("Wechatpay-Nonce"),
+			r.Header.Get("Wechatpay-Signature"), string(rawBody))
+		if err != nil || !verified {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resourceData, err := wpl.DecryptNotifyResourceData(string(rawBody))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		notifyResult <- resourceData
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer notifyServer.Close()
+	payConfig.NotifyUrl = notifyServer.URL
+
+	order := &do.Order{
+		OrderNo:  "20240903374062590406950001",
+		PayMoney: 549700,
+		Items:    []*do.OrderItem{{CommodityName: "Apple iPhone 11 (A2223)"}},
+	}
+	wxPayLib := library.NewWxPayLib(context.TODO(), payConfig)
+	payInfo, err := wxPayLib.CreateOrderPay(order, "QsudfrhgrDYDEEA1344EF")
+	assert.Nil(t, err)
+	trans, ok := fakeServer.Transaction(order.OrderNo)
+	assert.True(t, ok)
+	assert.Equal(t, "prepay_id="+trans.PrepayId, payInfo.Package)
+	assert.Equal(t, wxpayfake.TradeStateNotPay, trans.TradeState)
+
+	assert.Nil(t, fakeServer.Pay(order.OrderNo))
+	resourceData := <-notifyResult
+	assert.Equal(t, order.OrderNo, resourceData.OutTradeNo)
+	assert.Equal(t, "SUCCESS", resourceData.TradeState)
+	assert.Equal(t, order.PayMoney, resourceData.Amount.Total)
+	assert.Equal(t, payConfig.MchId, resourceData.Mchid)
+	trans, _ = fakeServer.Transaction(order.OrderNo)
+	assert.Equal(t, trans.TransactionId, resourceData.TransactionID)
+
+	// 已支付的订单不能再次下单
+	_, err = wxPayLib.CreateOrderPay(order, "QsudfrhgrDYDEEA1344EF")
+	assert.NotNil(t, err)
+}
+
+// TestWxPayLib_QueryAndCloseOrder 查询模拟服务中的交易并关单, 关单后用户不能再支付
+func TestWxPayLib_QueryAndCloseOrder(t *testing.T) {
+	fakeServer, payConfig := startWxPayFakeServer(t)
+	wxPayLib := library.NewWxPayLib(context.TODO(), payConfig)
+
+	transaction, err := wxPayLib.QueryOrder("20240903374062590406950002")
+	assert.Nil(t, err)
+	assert.Equal(t, library.WxTradeStateNotExist, transaction.TradeState)
+
+	order := &do.Order{
+		OrderNo:  "20240903374062590406950002",
+		PayMoney: 549700,
+		Items:    []*do.OrderItem{{CommodityName: "Apple iPhone 11 (A2223)"}},
+	}
+	_, err = wxPayLib.CreateAppOrderPay(order)
+	assert.Nil(t, err)
+	transaction, err = wxPayLib.QueryOrder(order.OrderNo)
+	assert.Nil(t, err)
+	assert.Equal(t, library.WxTradeStateNotPay, transaction.TradeState)
+	assert.Equal(t, order.PayMoney, transaction.Amount.Total)
+
+	assert.Nil(t, wxPayLib.CloseOrder(order.OrderNo))
+	transaction, err = wxPayLib.QueryOrder(order.OrderNo)
+	assert.Nil(t, err)
+	assert.Equal(t, library.WxTradeStateClosed, transaction.TradeState)
+	assert.NotNil(t, fakeServer.Pay(order.OrderNo))
+}
+
+// TestWxPayLib_DownloadTradeBill 支付成功后下载当天的交易账单, 账单中有这笔交易
+func TestWxPayLib_DownloadTradeBill(t *testing.T) {
+	fakeServer, payConfig := startWxPayFakeServer(t)
+	notifyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer notifyServer.Close()
+	payConfig.NotifyUrl = notifyServer.URL
+	wxPayLib := library.NewWxPayLib(context.TODO(), payConfig)
+
+	order := &do.Order{
+		OrderNo:  "20240903374062590406950003",
+		PayMoney: 549701,
+		Items:    []*do.OrderItem{{CommodityName: "Apple iPhone 11 (A2223)"}},
+	}
+	_, err := wxPayLib.CreateNativeOrderPay(order)
+	assert.Nil(t, err)
+	assert.Nil(t, fakeServer.Pay(order.OrderNo))
+	trans, _ := fakeServer.Transaction(order.OrderNo)
+
+	records, err := wxPayLib.DownloadTradeBill(trans.SuccessTime)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, order.OrderNo, records[0].OutTradeNo)
+	assert.Equal(t, trans.TransactionId, records[0].TransactionId)
+	assert.Equal(t, library.WxBillTradeStateSuccess, records[0].TradeState)
+	assert.Equal(t, order.PayMoney, records[0].OrderTotal)
+	assert.True(t, trans.SuccessTime.Equal(records[0].TradeTime))
+
+	records, err = wxPayLib.DownloadTradeBill(trans.SuccessTime.AddDate(0, 0, -1))
+	assert.Nil(t, err)
+	assert.Len(t, records, 0)
+}
+
+// startWxPayFakeServer 启动模拟微信支付服务, 返回模拟服务和指向它的支付配置
+func startWxPayFakeServer(t *testing.T) (*wxpayfake.Server, library.WxPayConfig) {
+	keyDir := t.TempDir()
+	mchPrivateKeyPath, mchPublicKey := genMchKeyPair(t, keyDir)
+
+	fakeConfig := wxpayfake.Config{
+		AppId:        "appId12345",
+		MchId:        "mch12345",
+		AesKey:       "0123456789abcdef0123456789abcdef",
+		MchPublicKey: mchPublicKey,
+	}
+	fakeServer, err := wxpayfake.NewServer(fakeConfig)
+	assert.Nil(t, err)
+	apiServer := httptest.NewServer(fakeServer.Handler())
+	t.Cleanup(apiServer.Close)
+
+	platformPublicKey, err := fakeServer.PlatformPublicKeyPEM()
+	assert.Nil(t, err)
+	platformPublicKeyPath := filepath.Join(keyDir, "wxp_pub.pem")
+	assert.Nil(t, os.WriteFile(platformPublicKeyPath, platformPublicKey, 0644))
+
+	payConfig := library.WxPayConfig{
+		AppId:                 fakeConfig.AppId,
+		MchId:                 fakeConfig.MchId,
+		PrivateSerialNo:       "567",
+		AesKey:                fakeConfig.AesKey,
+		ApiBaseUrl:            apiServer.URL,
+		PrivateKeyPath:        mchPrivateKeyPath,
+		PlatformPublicKeyPath: platformPublicKeyPath,
+	}
+	return fakeServer, payConfig
+}
+
+// genMchKeyPair 生成商户密钥对, 私钥写入文件供 WxPayLib 签名使用, 返回私钥文件路径和公钥
+func genMchKeyPair(t *testing.T, dir string) (string, []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	privateKeyBytes, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.Nil(t, err)
+	privateKeyPath := filepath.Join(dir, "wxpay.private.pem")
+	err = os.WriteFile(privateKeyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKeyBytes}), 0600)
+	assert.Nil(t, err)
+
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.Nil(t, err)
+	return privateKeyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyBytes})
+}
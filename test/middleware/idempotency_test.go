@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/errcode"
+	"github.com/hd2yao/go-mall/common/middleware"
+	"github.com/hd2yao/go-mall/common/util"
+)
+
+// newIdempotentRouter 创建使用 Idempotent 中间件的路由, handler 为接口的处理逻辑
+// 用固定的 user_id 代替 AuthUser 中间件
+func newIdempotentRouter(handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/order/create", func(c *gin.Context) {
+		c.Set("user_id", int64(1))
+	}, middleware.Idempotent(), handler)
+	return router
+}
+
+func doRequest(router *gin.Engine, idempotencyKey, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/order/create", strings.NewReader(body))
+	if idempotencyKey != "" {
+		req.Header.Set(enum.IdempotencyKeyHeader, idempotencyKey)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// responseCode 响应中的业务错误码
+func responseCode(t *testing.T, recorder *httptest.ResponseRecorder) int {
+	var reply struct {
+		Code int `json:"code"`
+	}
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &reply))
+	return reply.Code
+}
+
+// TestIdempotent_Replay 同一个幂等键的重复请求只处理一次, 之后重放第一次请求的响应
+func TestIdempotent_Replay(t *testing.T) {
+	handled := 0
+	router := newIdempotentRouter(func(c *gin.Context) {
+		handled++
+		c.JSON(http.StatusOK, gin.H{"code": 0, "order_no": util.RandomString(8)})
+	})
+	idempotencyKey := util.RandomString(16)
+
+	first := doRequest(router, idempotencyKey, `{"cart_item_id_list":[1,2]}`)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Empty(t, first.Header().Get(enum.IdempotencyReplayedHeader))
+
+	second := doRequest(router, idempotencyKey, `{"cart_item_id_list":[1,2]}`)
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, "true", second.Header().Get(enum.IdempotencyReplayedHeader))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, 1, handled)
+
+	// 同一个幂等键用于不同的请求内容
+	changed := doRequest(router, idempotencyKey, `{"cart_item_id_list":[3]}`)
+	assert.Equal(t, errcode.ErrIdempotencyKey.Code(), responseCode(t, changed))
+	assert.Equal(t, 1, handled)
+}
+
+// TestIdempotent_WithoutKey 没有传幂等键的请求每次都会被处理
+func TestIdempotent_WithoutKey(t *testing.T) {
+	handled := 0
+	router := newIdempotentRouter(func(c *gin.Context) {
+		handled++
+		c.JSON(http.StatusOK, gin.H{"code": 0})
+	})
+	doRequest(router, "", `{}`)
+	doRequest(router, "", `{}`)
+	assert.Equal(t, 2, handled)
+}
+
+// TestIdempotent_KeyTooLong 幂等键超过最大长度时不处理请求
+func TestIdempotent_KeyTooLong(t *testing.T) {
+	handled := 0
+	router := newIdempotentRouter(func(c *gin.Context) {
+		handled++
+	})
+	recorder := doRequest(router, strings.Repeat("k", enum.IdempotencyKeyMaxLength+1), `{}`)
+	assert.Equal(t, errcode.ErrIdempotencyKey.Code(), responseCode(t, recorder))
+	assert.Equal(t, 0, handled)
+}
+
+// TestIdempotent_InFlight 第一次请求还没处理完时, 重复的请求返回 ErrRequestInFlight
+func TestIdempotent_InFlight(t *testing.T) {
+	var router *gin.Engine
+	var inFlight *httptest.ResponseRecorder
+	idempotencyKey := util.RandomString(16)
+	router = newIdempotentRouter(func(c *gin.Context) {
+		if inFlight == nil {
+			// 第一次请求处理的过程中收到了重复的请求
+			inFlight = doRequest(router, idempotencyKey, `{}`)
+		}
+		c.JSON(http.StatusOK, gin.H{"code": 0})
+	})
+	first := doRequest(router, idempotencyKey, `{}`)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, errcode.ErrRequestInFlight.Code(), responseCode(t, inFlight))
+	assert.Equal(t, http.StatusConflict, inFlight.Code)
+}
+
+// TestIdempotent_TransientError 第一次请求出现服务端错误时不保存响应, 用同一个幂等键重试会再次处理
+func TestIdempotent_TransientError(t *testing.T) {
+	handled := 0
+	router := newIdempotentRouter(func(c *gin.Context) {
+		handled++
+		if handled == 1 {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": errcode.ErrServer.Code()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"code": 0})
+	})
+	idempotencyKey := util.RandomString(16)
+
+	assert.Equal(t, http.StatusInternalServerError, doRequest(router, idempotencyKey, `{}`).Code)
+	retried := doRequest(router, idempotencyKey, `{}`)
+	assert.Equal(t, http.StatusOK, retried.Code)
+	assert.Empty(t, retried.Header().Get(enum.IdempotencyReplayedHeader))
+	assert.Equal(t, 2, handled)
+}
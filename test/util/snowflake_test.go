@@ -0,0 +1,67 @@
+package util
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hd2yao/go-mall/common/enum"
+	"github.com/hd2yao/go-mall/common/util"
+)
+
+// TestSnowflake_NextNo 并发生成的单号不重复, 同一个生成器生成的单号按生成顺序递增
+func TestSnowflake_NextNo(t *testing.T) {
+	snowflake, err := util.NewSnowflake(3, 0)
+	assert.Nil(t, err)
+
+	const goroutines, perGoroutine = 8, 5000
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	serialNos := make(map[string]struct{}, goroutines*perGoroutine)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(userId int64) {
+			defer wg.Done()
+			generated := make([]string, 0, perGoroutine)
+			for j := 0; j < perGoroutine; j++ {
+				serialNo, err := snowflake.NextNo(userId)
+				assert.Nil(t, err)
+				generated = append(generated, serialNo)
+			}
+			assert.True(t, sort.StringsAreSorted(generated))
+			mu.Lock()
+			for _, serialNo := range generated {
+				serialNos[serialNo] = struct{}{}
+			}
+			mu.Unlock()
+		}(int64(1234567 + i))
+	}
+	wg.Wait()
+	assert.Len(t, serialNos, goroutines*perGoroutine)
+
+	serialNo, err := snowflake.NextNo(1234567)
+	assert.Nil(t, err)
+	assert.Len(t, serialNo, 26)
+	assert.Equal(t, time.Now().Format(enum.TimeFormatYMD), serialNo[:8])
+	assert.Equal(t, "4567", serialNo[22:])
+}
+
+// TestSnowflake_ClockBackwards 机器ID上一次的生成时间晚于当前时间时不生成单号
+func TestSnowflake_ClockBackwards(t *testing.T) {
+	snowflake, err := util.NewSnowflake(1, time.Now().Add(time.Minute).UnixMilli())
+	assert.Nil(t, err)
+	_, err = snowflake.NextNo(1)
+	assert.ErrorIs(t, err, util.ErrClockBackwards)
+
+	// 小幅度的时钟回拨等待时钟追上后继续生成
+	snowflake, err = util.NewSnowflake(1, time.Now().Add(5*time.Millisecond).UnixMilli())
+	assert.Nil(t, err)
+	_, err = snowflake.NextNo(1)
+	assert.Nil(t, err)
+
+	_, err = util.NewSnowflake(util.SnowflakeMaxWorkerId+1, 0)
+	assert.NotNil(t, err)
+}